@@ -69,6 +69,8 @@ It expects the genesis file as argument.`,
 			utils.GCModeFlag,
 			utils.CacheDatabaseFlag,
 			utils.CacheGCFlag,
+			utils.DposSnapshotFlag,
+			utils.DposKeepSnapshotsFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -76,7 +78,10 @@ The import command imports blocks from an RLP-encoded form. The form can be one
 with several RLP-encoded blocks, or several files can be used.
 
 If only one file is used, import error will result in failure. If several files are used,
-processing will proceed even if an individual RLP-file import failure occurs.`,
+processing will proceed even if an individual RLP-file import failure occurs.
+
+With --dpos.snapshot, an empty chain starts from the first state snapshot found in the
+files instead of executing the blocks before it.`,
 	}
 	exportCommand = cli.Command{
 		Action:    utils.MigrateFlags(exportChain),
@@ -87,6 +92,7 @@ processing will proceed even if an individual RLP-file import failure occurs.`,
 			utils.DataDirFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
+			utils.DposSnapshotFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -94,7 +100,11 @@ Requires a first argument of the file to write to.
 Optional second and third arguments control the first and
 last block to write. In this mode, the file will be appended
 if already existing. If the file ends with .gz, the output will
-be gzipped.`,
+be gzipped.
+
+With --dpos.snapshot, the blocks are preceded by a snapshot of
+the state at the first block of the last DPoS epoch they reach,
+for an import with --dpos.snapshot to start from.`,
 	}
 	importPreimagesCommand = cli.Command{
 		Action:    utils.MigrateFlags(importPreimages),
@@ -184,26 +194,16 @@ func initGenesis(ctx *cli.Context) error {
 	}
 	defer file.Close()
 	genesis := new(core.Genesis)
-	fmt.Println("+++++++++++++++++++1111+++++++++++++++++++\n")
-	fmt.Println("InitGenesis config.....")
 	if err := json.NewDecoder(file).Decode(genesis); err != nil {
 		utils.Fatalf("invalid genesis file: %v", err)
 	}
-	log.Info("initGenesis>>>>>", "genesis struct", genesis.Config.Dpos.Validators[0].Hex())
-	mxvs := genesis.Config.Dpos.MaxValidatorSize
-	vsz := len(genesis.Config.Dpos.Validators)
-	svsz := int(genesis.Config.Dpos.MaxValidatorSize)*2/3+1
-	blockinterval := genesis.Config.Dpos.BlockInterval
-	log.Info("initDpos >>>>", "maxvalidatorSize", mxvs,"ValidatorSize",vsz)
-	log.Info("initDpos >>>>", "blockInterVal",blockinterval )
-	if vsz < svsz {
-		log.Error("Init Validators Error: too few candidator!")
-		os.Exit(0)
+	if err := genesis.Validate(); err != nil {
+		utils.Fatalf("invalid genesis file: %v", err)
 	}
+	log.Info("Validated DPoS genesis", "validators", len(genesis.Config.Dpos.Validators), "maxValidatorSize", genesis.Config.Dpos.MaxValidatorSize, "blockInterval", genesis.Config.Dpos.BlockInterval)
 
 	// Open an initialise both full and light databases
 	stack := makeFullNode(ctx)
-	log.Info("initGenesis>>>>>", "genesis struct", genesis.Config.Dpos.Validators[0].Hex())
 	for _, name := range []string{"chaindata", "lightchaindata"} {
 		chaindb, err := stack.OpenDatabase(name, 0, 0)
 		if err != nil {
@@ -245,12 +245,12 @@ func importChain(ctx *cli.Context) error {
 	start := time.Now()
 
 	if len(ctx.Args()) == 1 {
-		if err := utils.ImportChain(chain, ctx.Args().First()); err != nil {
+		if err := utils.ImportChain(chain, ctx.Args().First(), ctx.Bool(utils.DposSnapshotFlag.Name)); err != nil {
 			log.Error("Import error", "err", err)
 		}
 	} else {
 		for _, arg := range ctx.Args() {
-			if err := utils.ImportChain(chain, arg); err != nil {
+			if err := utils.ImportChain(chain, arg, ctx.Bool(utils.DposSnapshotFlag.Name)); err != nil {
 				log.Error("Import error", "file", arg, "err", err)
 			}
 		}
@@ -323,7 +323,7 @@ func exportChain(ctx *cli.Context) error {
 	var err error
 	fp := ctx.Args().First()
 	if len(ctx.Args()) < 3 {
-		err = utils.ExportChain(chain, fp)
+		err = utils.ExportChain(chain, fp, ctx.Bool(utils.DposSnapshotFlag.Name))
 	} else {
 		// This can be improved to allow for numbers larger than 9223372036854775807
 		first, ferr := strconv.ParseInt(ctx.Args().Get(1), 10, 64)
@@ -334,7 +334,7 @@ func exportChain(ctx *cli.Context) error {
 		if first < 0 || last < 0 {
 			utils.Fatalf("Export error: block number must be greater than 0\n")
 		}
-		err = utils.ExportAppendChain(chain, fp, uint64(first), uint64(last))
+		err = utils.ExportAppendChain(chain, fp, uint64(first), uint64(last), ctx.Bool(utils.DposSnapshotFlag.Name))
 	}
 
 	if err != nil {
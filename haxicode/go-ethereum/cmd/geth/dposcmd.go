@@ -0,0 +1,694 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strconv"
+
+	"github.com/haxicode/go-ethereum/accounts/keystore"
+	"github.com/haxicode/go-ethereum/cmd/utils"
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/common/hexutil"
+	"github.com/haxicode/go-ethereum/common/math"
+	"github.com/haxicode/go-ethereum/consensus/dpos"
+	"github.com/haxicode/go-ethereum/core"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/eth"
+	"github.com/haxicode/go-ethereum/node"
+	"github.com/haxicode/go-ethereum/params"
+	"github.com/haxicode/go-ethereum/rlp"
+	"github.com/haxicode/go-ethereum/rpc"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dposAttachFlag = cli.StringFlag{
+		Name:  "attach",
+		Value: node.DefaultIPCEndpoint(clientIdentifier),
+		Usage: "API endpoint to attach to",
+	}
+	dposFromFlag = cli.StringFlag{
+		Name:  "from",
+		Usage: "Account (address or keystore index) to send the transaction from",
+		Value: "0",
+	}
+	dposValueFlag = cli.StringFlag{
+		Name:  "value",
+		Usage: "Stake in wei to lock (default: the minimum deposit of the chain)",
+	}
+	dposSignerFlag = cli.StringFlag{
+		Name:  "signer",
+		Usage: "Address of the key the candidate seals its blocks with (default: the account)",
+	}
+	dposCommissionFlag = cli.Uint64Flag{
+		Name:  "commission",
+		Usage: "Percentage of the block rewards kept by the validator",
+		Value: types.DefaultCommission,
+	}
+	dposNameFlag = cli.StringFlag{
+		Name:  "name",
+		Usage: "Name the candidate is known by",
+	}
+	dposWebsiteFlag = cli.StringFlag{
+		Name:  "website",
+		Usage: "Website describing the candidate",
+	}
+	dposEnodeFlag = cli.StringFlag{
+		Name:  "enode",
+		Usage: "Enode URL of the node of the candidate",
+	}
+	dposMaxValidatorSizeFlag = cli.Uint64Flag{
+		Name:  "maxvalidatorsize",
+		Usage: "Proposed number of elected validators",
+	}
+	dposBlockIntervalFlag = cli.Uint64Flag{
+		Name:  "blockinterval",
+		Usage: "Proposed number of seconds between blocks",
+	}
+	dposKickoutThresholdFlag = cli.Uint64Flag{
+		Name:  "kickoutthreshold",
+		Usage: "Proposed percentage of its slots a validator must seal not to be kicked out",
+	}
+	dposBlockRewardFlag = cli.StringFlag{
+		Name:  "blockreward",
+		Usage: "Proposed wei issued per block",
+	}
+	dposNonceFlag = cli.StringFlag{
+		Name:  "nonce",
+		Usage: "Nonce of the transaction (default: the pending nonce of the account)",
+	}
+	dposGasPriceFlag = cli.StringFlag{
+		Name:  "gasprice",
+		Usage: "Gas price in wei of the transaction (default: the suggested gas price)",
+	}
+	dposChainIDFlag = cli.StringFlag{
+		Name:  "chainid",
+		Usage: "Chain ID to sign the transaction for (default: the chain ID of the node)",
+	}
+	dposOutFlag = cli.StringFlag{
+		Name:  "out",
+		Usage: "File to write the raw signed transaction to instead of submitting it",
+	}
+
+	dposTxFlags = []cli.Flag{
+		utils.DataDirFlag,
+		utils.KeyStoreDirFlag,
+		utils.PasswordFileFlag,
+		utils.LightKDFFlag,
+		dposAttachFlag,
+		dposFromFlag,
+		dposNonceFlag,
+		dposGasPriceFlag,
+		dposChainIDFlag,
+		dposOutFlag,
+	}
+
+	dposCommand = cli.Command{
+		Name:     "dpos",
+		Usage:    "Manage DPoS candidacies, votes and parameters",
+		Category: "DPOS COMMANDS",
+		Description: `
+
+Register as a candidate, vote for candidates, change the parameters as a
+validator and inspect the elections of a delegated-proof-of-stake chain.
+
+Transactions are signed with an account of the keystore and submitted to the
+node given by --attach, which is also asked for the nonce, gas price, chain ID
+and minimum deposits not given on the command line. With --out the raw signed
+transaction is written to a file instead, to be submitted later through
+eth.sendRawTransaction; no node is needed if --nonce, --chainid and, for the
+transactions locking a stake, --value are given.`,
+		Subcommands: []cli.Command{
+			{
+				Name:   "register",
+				Usage:  "Register an account as a candidate",
+				Action: utils.MigrateFlags(dposRegister),
+				Flags:  append(dposTxFlags, dposValueFlag, dposSignerFlag, dposCommissionFlag, dposNameFlag, dposWebsiteFlag, dposEnodeFlag),
+				Description: `
+    geth dpos register --from <address> [--name <name>] [--website <url>] [--enode <url>]
+
+Registers the account as a candidate, locking the given stake as its deposit.
+The candidate seals its blocks with the --signer key if given, and shares the
+block rewards it doesn't keep as --commission with its delegators. The name,
+website and enode URL tell the voters who runs the candidate.`,
+			},
+			{
+				Name:   "update",
+				Usage:  "Change the commission and description of a candidate",
+				Action: utils.MigrateFlags(dposUpdate),
+				Flags:  append(dposTxFlags, dposCommissionFlag, dposNameFlag, dposWebsiteFlag, dposEnodeFlag),
+				Description: `
+    geth dpos update --from <address> [--commission <percent>] [--name <name>]
+                     [--website <url>] [--enode <url>]
+
+Replaces the commission and description the candidate registered with. Fields
+not given are cleared, and the commission defaults to 10 percent.`,
+			},
+			{
+				Name:   "unregister",
+				Usage:  "Withdraw the candidacy of an account",
+				Action: utils.MigrateFlags(dposUnregister),
+				Flags:  dposTxFlags,
+				Description: `
+    geth dpos unregister --from <address>
+
+Withdraws the candidacy of the account. Its deposit is paid back after the
+unbonding period.`,
+			},
+			{
+				Name:      "delegate",
+				Usage:     "Vote for a candidate",
+				ArgsUsage: "<candidate>",
+				Action:    utils.MigrateFlags(dposDelegate),
+				Flags:     append(dposTxFlags, dposValueFlag),
+				Description: `
+    geth dpos delegate --from <address> <candidate>
+
+Votes for the candidate with the account, locking the given stake.`,
+			},
+			{
+				Name:      "undelegate",
+				Usage:     "Withdraw the vote of an account",
+				ArgsUsage: "[<candidate>]",
+				Action:    utils.MigrateFlags(dposUndelegate),
+				Flags:     dposTxFlags,
+				Description: `
+    geth dpos undelegate --from <address> [<candidate>]
+
+Withdraws the vote of the account. The candidate is looked up on the node
+when not given.`,
+			},
+			{
+				Name:   "propose",
+				Usage:  "Propose a change of the DPoS parameters",
+				Action: utils.MigrateFlags(dposPropose),
+				Flags:  append(dposTxFlags, dposMaxValidatorSizeFlag, dposBlockIntervalFlag, dposKickoutThresholdFlag, dposBlockRewardFlag),
+				Description: `
+    geth dpos propose --from <validator> [--maxvalidatorsize <n>] [--blockinterval <seconds>]
+                      [--kickoutthreshold <percent>] [--blockreward <wei>]
+
+Proposes to change the given parameters, replacing the previous proposal of
+the validator. The change takes effect at the start of the epoch after 2/3+1
+of the current validators approved it.`,
+			},
+			{
+				Name:      "approve",
+				Usage:     "Approve the parameter change proposed by a validator",
+				ArgsUsage: "<proposer>",
+				Action:    utils.MigrateFlags(dposApprove),
+				Flags:     dposTxFlags,
+				Description: `
+    geth dpos approve --from <validator> <proposer>
+
+Approves the parameter change the proposer validator proposed.`,
+			},
+			{
+				Name:      "status",
+				Usage:     "Print the current election state of a running node",
+				ArgsUsage: "[<address>]",
+				Action:    utils.MigrateFlags(dposStatus),
+				Flags: []cli.Flag{
+					dposAttachFlag,
+				},
+				Description: `
+    geth dpos status [<address>]
+
+Prints the epoch, the validators and their schedule, and the candidates with
+their votes, commission and name. With an address, also prints its vote and
+rewards.`,
+			},
+			{
+				Name:      "inspect",
+				Usage:     "Print the elections stored in the chain database",
+				ArgsUsage: "[<blockHash> | <blockNum>]...",
+				Action:    utils.MigrateFlags(dposInspect),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.SyncModeFlag,
+				},
+				Description: `
+    geth dpos inspect [<blockHash> | <blockNum>]...
+
+Prints the validators, candidates and votes at the given blocks, read from the
+local chain database without starting a node. Without arguments, prints them
+at the last block of every epoch of the local chain.`,
+			},
+		},
+	}
+)
+
+// dposRegister sends a candidate registration.
+func dposRegister(ctx *cli.Context) error {
+	var signer *common.Address
+	if ctx.IsSet(dposSignerFlag.Name) {
+		addr := parseAddress(ctx.String(dposSignerFlag.Name))
+		signer = &addr
+	}
+	var data []byte
+	for _, flag := range []string{dposCommissionFlag.Name, dposNameFlag.Name, dposWebsiteFlag.Name, dposEnodeFlag.Name} {
+		if ctx.IsSet(flag) {
+			data = candidateRegistration(ctx)
+			break
+		}
+	}
+	return sendDposTx(ctx, types.RegCandidate, signer, data, func(config *params.DposConfig) *big.Int {
+		return config.CandidateDeposit
+	})
+}
+
+// dposUpdate sends a change of the commission and description of a candidate.
+func dposUpdate(ctx *cli.Context) error {
+	return sendDposTx(ctx, types.UpdateCandidate, nil, candidateRegistration(ctx), nil)
+}
+
+// candidateRegistration encodes the commission and description given by the
+// flags, or fails hard if they are invalid.
+func candidateRegistration(ctx *cli.Context) []byte {
+	reg := &types.CandidateRegistration{
+		Commission: ctx.Uint64(dposCommissionFlag.Name),
+		Name:       ctx.String(dposNameFlag.Name),
+		Website:    ctx.String(dposWebsiteFlag.Name),
+		Enode:      ctx.String(dposEnodeFlag.Name),
+	}
+	data, err := rlp.EncodeToBytes(reg)
+	if err != nil {
+		utils.Fatalf("Failed to encode registration: %v", err)
+	}
+	if _, err := types.DecodeCandidateRegistration(data); err != nil {
+		utils.Fatalf("Invalid registration: %v", err)
+	}
+	return data
+}
+
+// dposUnregister sends a candidacy withdrawal.
+func dposUnregister(ctx *cli.Context) error {
+	return sendDposTx(ctx, types.UnregCandidate, nil, nil, nil)
+}
+
+// dposDelegate sends a vote for the candidate given as argument.
+func dposDelegate(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires the candidate address as argument.")
+	}
+	candidate := parseAddress(ctx.Args().First())
+	return sendDposTx(ctx, types.Delegate, &candidate, nil, func(config *params.DposConfig) *big.Int {
+		return config.DelegateDeposit
+	})
+}
+
+// dposUndelegate sends a vote withdrawal.
+func dposUndelegate(ctx *cli.Context) error {
+	if len(ctx.Args()) > 1 {
+		utils.Fatalf("This command takes at most the candidate address as argument.")
+	}
+	var candidate *common.Address
+	if len(ctx.Args()) == 1 {
+		addr := parseAddress(ctx.Args().First())
+		candidate = &addr
+	}
+	return sendDposTx(ctx, types.UnDelegate, candidate, nil, nil)
+}
+
+// dposPropose sends a proposal to change the parameters given by the flags.
+func dposPropose(ctx *cli.Context) error {
+	proposal := &types.DposParams{
+		MaxValidatorSize: ctx.Uint64(dposMaxValidatorSizeFlag.Name),
+		BlockInterval:    ctx.Uint64(dposBlockIntervalFlag.Name),
+		KickoutThreshold: ctx.Uint64(dposKickoutThresholdFlag.Name),
+	}
+	if ctx.IsSet(dposBlockRewardFlag.Name) {
+		proposal.BlockReward = parseBig(ctx, dposBlockRewardFlag.Name)
+	}
+	if proposal.IsEmpty() {
+		utils.Fatalf("The proposal must change at least one parameter.")
+	}
+	data, err := rlp.EncodeToBytes(proposal)
+	if err != nil {
+		utils.Fatalf("Failed to encode proposal: %v", err)
+	}
+	return sendDposTx(ctx, types.ProposeParams, nil, data, nil)
+}
+
+// dposApprove sends the approval of the proposal of the validator given as
+// argument.
+func dposApprove(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires the proposer address as argument.")
+	}
+	proposer := parseAddress(ctx.Args().First())
+	return sendDposTx(ctx, types.ApproveParams, &proposer, nil, nil)
+}
+
+// sendDposTx signs a transaction of the given type with the keystore account
+// and either submits it to the node or writes it to the --out file. Candidacy
+// and proposal transactions have no recipient unless one is given. The stake is
+// only locked by transaction types passing a deposit function, which retrieves
+// the minimum stake used when none is given.
+func sendDposTx(ctx *cli.Context, txType types.TxType, to *common.Address, data []byte, deposit func(*params.DposConfig) *big.Int) error {
+	stack, _ := makeConfigNode(ctx)
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	account, _ := unlockAccount(ctx, ks, ctx.String(dposFromFlag.Name), 0, utils.MakePasswordList(ctx))
+
+	// Attach to the node unless everything needed is known offline
+	offline := ctx.String(dposOutFlag.Name) != ""
+	var client *rpc.Client
+	if !offline || !ctx.IsSet(dposNonceFlag.Name) {
+		var err error
+		if client, err = dialRPC(ctx.String(dposAttachFlag.Name)); err != nil {
+			utils.Fatalf("Unable to attach to geth node: %v", err)
+		}
+		defer client.Close()
+	}
+	// The chain ID and the minimum deposit come from the node unless given
+	var config *params.ChainConfig
+	if !ctx.IsSet(dposChainIDFlag.Name) || (deposit != nil && !ctx.IsSet(dposValueFlag.Name)) {
+		switch {
+		case client == nil && !ctx.IsSet(dposChainIDFlag.Name):
+			utils.Fatalf("The chain ID must be given with --%s when not attached to a node.", dposChainIDFlag.Name)
+		case client == nil:
+			utils.Fatalf("The stake must be given with --%s when not attached to a node.", dposValueFlag.Name)
+		}
+		var err error
+		if config, err = retrieveChainConfig(client); err != nil {
+			utils.Fatalf("Failed to retrieve chain config (give --%s and --%s instead): %v", dposChainIDFlag.Name, dposValueFlag.Name, err)
+		}
+		if config.Dpos == nil {
+			utils.Fatalf("The attached node doesn't run a DPoS chain.")
+		}
+	}
+	// Assemble the transaction from the flags and the node's state
+	switch {
+	case to != nil, txType != types.UnDelegate:
+	case client != nil:
+		if err := client.Call(&to, "dpos_getVote", account.Address, "latest"); err != nil {
+			utils.Fatalf("Failed to retrieve vote: %v", err)
+		}
+		if to == nil {
+			utils.Fatalf("Account %s has no vote to withdraw.", account.Address.Hex())
+		}
+	default:
+		utils.Fatalf("The candidate must be given when not attached to a node.")
+	}
+	value := new(big.Int)
+	if deposit != nil {
+		switch {
+		case ctx.IsSet(dposValueFlag.Name):
+			value = parseBig(ctx, dposValueFlag.Name)
+		case deposit(config.Dpos) != nil:
+			value = deposit(config.Dpos)
+		default:
+			utils.Fatalf("The chain has no minimum deposit, the stake must be given with --%s.", dposValueFlag.Name)
+		}
+	}
+	var nonce uint64
+	if ctx.IsSet(dposNonceFlag.Name) {
+		var err error
+		if nonce, err = strconv.ParseUint(ctx.String(dposNonceFlag.Name), 0, 64); err != nil {
+			utils.Fatalf("Invalid nonce: %v", err)
+		}
+	} else {
+		var pending hexutil.Uint64
+		if err := client.Call(&pending, "eth_getTransactionCount", account.Address, "pending"); err != nil {
+			utils.Fatalf("Failed to retrieve nonce: %v", err)
+		}
+		nonce = uint64(pending)
+	}
+	gasPrice := eth.DefaultConfig.MinerGasPrice
+	switch {
+	case ctx.IsSet(dposGasPriceFlag.Name):
+		gasPrice = parseBig(ctx, dposGasPriceFlag.Name)
+	case client != nil:
+		var suggested hexutil.Big
+		if err := client.Call(&suggested, "eth_gasPrice"); err != nil {
+			utils.Fatalf("Failed to retrieve gas price: %v", err)
+		}
+		gasPrice = suggested.ToInt()
+	}
+	var chainID *big.Int
+	switch {
+	case ctx.IsSet(dposChainIDFlag.Name):
+		chainID = parseBig(ctx, dposChainIDFlag.Name)
+	case config.ChainID != nil:
+		chainID = config.ChainID
+	default:
+		utils.Fatalf("The attached node has no chain ID, give one with --%s.", dposChainIDFlag.Name)
+	}
+	gas, err := core.IntrinsicGas(txType, data, false, true)
+	if err != nil {
+		utils.Fatalf("Failed to compute gas: %v", err)
+	}
+	var recipient common.Address // DPoS transactions with the zero recipient have none
+	if to != nil {
+		recipient = *to
+	}
+	tx, err := ks.SignTx(account, types.NewTransaction(txType, nonce, recipient, value, gas, gasPrice, data), chainID)
+	if err != nil {
+		utils.Fatalf("Failed to sign transaction: %v", err)
+	}
+	// Hand the signed transaction over
+	enc, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		utils.Fatalf("Failed to encode transaction: %v", err)
+	}
+	if offline {
+		if err := ioutil.WriteFile(ctx.String(dposOutFlag.Name), []byte(hexutil.Encode(enc)), 0600); err != nil {
+			utils.Fatalf("Failed to write transaction: %v", err)
+		}
+		fmt.Printf("Transaction: %s\n", tx.Hash().Hex())
+		return nil
+	}
+	var hash common.Hash
+	if err := client.Call(&hash, "eth_sendRawTransaction", hexutil.Bytes(enc)); err != nil {
+		utils.Fatalf("Failed to submit transaction: %v", err)
+	}
+	fmt.Printf("Transaction: %s\n", hash.Hex())
+	return nil
+}
+
+// retrieveChainConfig retrieves the chain config of the attached node, which
+// is only available if the admin API is exposed.
+func retrieveChainConfig(client *rpc.Client) (*params.ChainConfig, error) {
+	var info struct {
+		Protocols struct {
+			Eth *eth.NodeInfo `json:"eth"`
+		} `json:"protocols"`
+	}
+	if err := client.Call(&info, "admin_nodeInfo"); err != nil {
+		return nil, err
+	}
+	if info.Protocols.Eth == nil || info.Protocols.Eth.Config == nil {
+		return nil, fmt.Errorf("eth protocol not running")
+	}
+	return info.Protocols.Eth.Config, nil
+}
+
+// parseAddress parses a hex address or fails hard.
+func parseAddress(s string) common.Address {
+	if !common.IsHexAddress(s) {
+		utils.Fatalf("Invalid address: %s", s)
+	}
+	return common.HexToAddress(s)
+}
+
+// parseBig parses a decimal or hex integer flag or fails hard.
+func parseBig(ctx *cli.Context, name string) *big.Int {
+	n, ok := math.ParseBig256(ctx.String(name))
+	if !ok {
+		utils.Fatalf("Invalid --%s: %s", name, ctx.String(name))
+	}
+	return n
+}
+
+// dposStatus prints the election state of the attached node.
+func dposStatus(ctx *cli.Context) error {
+	if len(ctx.Args()) > 1 {
+		utils.Fatalf("This command takes at most an address as argument.")
+	}
+	client, err := dialRPC(ctx.String(dposAttachFlag.Name))
+	if err != nil {
+		utils.Fatalf("Unable to attach to geth node: %v", err)
+	}
+	defer client.Close()
+
+	var (
+		epoch      dpos.EpochInfo
+		candidates []common.Address
+		tally      map[common.Address]*hexutil.Big
+	)
+	if err := client.Call(&epoch, "dpos_getEpoch", "latest"); err != nil {
+		utils.Fatalf("Failed to retrieve epoch: %v", err)
+	}
+	if err := client.Call(&candidates, "dpos_getCandidates", "latest"); err != nil {
+		utils.Fatalf("Failed to retrieve candidates: %v", err)
+	}
+	if err := client.Call(&tally, "dpos_getVoteTally", "latest"); err != nil {
+		utils.Fatalf("Failed to retrieve votes: %v", err)
+	}
+	fmt.Printf("Epoch #%d: %d - %d\n", epoch.Number, epoch.Start, epoch.End)
+	fmt.Println("Validators:")
+	for i, validator := range epoch.Validators {
+		fmt.Printf("  #%d: %s\n", i, validator.Hex())
+	}
+	fmt.Println("Schedule:")
+	for _, slot := range epoch.Schedule {
+		fmt.Printf("  %d: %s\n", slot.Time, slot.Validator.Hex())
+	}
+	fmt.Println("Candidates:")
+	for _, candidate := range candidates {
+		votes := new(big.Int)
+		if tally[candidate] != nil {
+			votes = tally[candidate].ToInt()
+		}
+		var info dpos.CandidateInfo
+		if err := client.Call(&info, "dpos_getCandidateInfo", candidate, "latest"); err != nil {
+			utils.Fatalf("Failed to retrieve candidate info: %v", err)
+		}
+		fmt.Printf("  %s: %v votes, %d%% commission", candidate.Hex(), votes, info.Commission)
+		if info.Name != "" {
+			fmt.Printf(", %s", info.Name)
+		}
+		if info.Website != "" {
+			fmt.Printf(" (%s)", info.Website)
+		}
+		fmt.Println()
+	}
+	if len(ctx.Args()) == 0 {
+		return nil
+	}
+	address := parseAddress(ctx.Args().First())
+
+	var (
+		vote    *common.Address
+		rewards dpos.Rewards
+	)
+	if err := client.Call(&vote, "dpos_getVote", address, "latest"); err != nil {
+		utils.Fatalf("Failed to retrieve vote: %v", err)
+	}
+	if err := client.Call(&rewards, "dpos_getRewards", address, "latest"); err != nil {
+		utils.Fatalf("Failed to retrieve rewards: %v", err)
+	}
+	fmt.Printf("Account %s:\n", address.Hex())
+	if vote != nil && *vote != (common.Address{}) {
+		fmt.Printf("  Vote: %s\n", vote.Hex())
+	} else {
+		fmt.Println("  Vote: none")
+	}
+	fmt.Printf("  Rewards: %v accrued, %v withdrawn\n", (*big.Int)(rewards.Accrued), (*big.Int)(rewards.Withdrawn))
+	return nil
+}
+
+// dposElection is the election state at a block printed by dpos inspect.
+type dposElection struct {
+	Number     uint64                              `json:"number"`
+	Hash       common.Hash                         `json:"hash"`
+	Epoch      uint64                              `json:"epoch"`
+	Validators []common.Address                    `json:"validators"`
+	Candidates []common.Address                    `json:"candidates"`
+	Votes      map[common.Address]*hexutil.Big     `json:"votes"`
+	Delegators map[common.Address][]common.Address `json:"delegators"`
+}
+
+// dposInspect prints the election state at the requested blocks, or at the
+// end of every epoch, from the chain database.
+func dposInspect(ctx *cli.Context) error {
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	engine, ok := chain.Engine().(*dpos.Dpos)
+	if !ok {
+		utils.Fatalf("Chain is not running DPoS")
+	}
+	api := engine.APIs(chain)[0].Service.(*dpos.API)
+
+	var headers []*types.Header
+	for _, arg := range ctx.Args() {
+		var header *types.Header
+		if hashish(arg) {
+			header = chain.GetHeaderByHash(common.HexToHash(arg))
+		} else {
+			num, _ := strconv.ParseUint(arg, 10, 64)
+			header = chain.GetHeaderByNumber(num)
+		}
+		if header == nil {
+			utils.Fatalf("block not found: %s", arg)
+		}
+		headers = append(headers, header)
+	}
+	if len(ctx.Args()) == 0 {
+		head := chain.CurrentHeader().Number.Uint64()
+		for number := uint64(0); number <= head; number++ {
+			header := chain.GetHeaderByNumber(number)
+			if next := chain.GetHeaderByNumber(number + 1); next == nil || dposEpoch(chain, engine, next) != dposEpoch(chain, engine, header) {
+				headers = append(headers, header)
+			}
+		}
+	}
+	for _, header := range headers {
+		election, err := inspectElection(chain, api, engine, header)
+		if err != nil {
+			utils.Fatalf("Failed to inspect block %d: %v", header.Number, err)
+		}
+		out, err := json.MarshalIndent(election, "", "  ")
+		if err != nil {
+			utils.Fatalf("Failed to encode election: %v", err)
+		}
+		fmt.Printf("%s\n", out)
+	}
+	return nil
+}
+
+// dposEpoch returns the epoch the header was sealed in.
+func dposEpoch(chain *core.BlockChain, engine *dpos.Dpos, header *types.Header) uint64 {
+	dposContext, err := types.NewDposContextFromProto(chain.StateCache().TrieDB(), header.DposContext)
+	if err != nil {
+		utils.Fatalf("Failed to load DPoS state of block %d: %v", header.Number, err)
+	}
+	return uint64(dpos.EpochNumber(dposContext, header.Time.Int64(), engine.Config(header.Number)))
+}
+
+// inspectElection collects the election state committed with the header.
+func inspectElection(chain *core.BlockChain, api *dpos.API, engine *dpos.Dpos, header *types.Header) (*dposElection, error) {
+	hash := header.Hash()
+	election := &dposElection{
+		Number:     header.Number.Uint64(),
+		Hash:       hash,
+		Epoch:      dposEpoch(chain, engine, header),
+		Delegators: make(map[common.Address][]common.Address),
+	}
+	var err error
+	if election.Validators, err = api.GetValidatorsAtHash(hash); err != nil {
+		return nil, err
+	}
+	if election.Candidates, err = api.GetCandidatesAtHash(hash); err != nil {
+		return nil, err
+	}
+	if election.Votes, err = api.GetVoteTallyAtHash(hash); err != nil {
+		return nil, err
+	}
+	for _, candidate := range election.Candidates {
+		if election.Delegators[candidate], err = api.GetDelegatorsAtHash(candidate, hash); err != nil {
+			return nil, err
+		}
+	}
+	return election, nil
+}
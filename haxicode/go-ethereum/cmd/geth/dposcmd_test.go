@@ -0,0 +1,195 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/common/hexutil"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/ethclient"
+	"github.com/haxicode/go-ethereum/rlp"
+)
+
+// dposGenesis is a DPoS chain with account #2 of the test keystore as its only
+// validator, funding accounts #0 and #1 to register and vote.
+const dposGenesis = `{
+	"config": {
+		"chainId": 1337,
+		"homesteadBlock": 0,
+		"eip150Block": 0,
+		"eip155Block": 0,
+		"eip158Block": 0,
+		"byzantiumBlock": 0,
+		"dpos": {
+			"validators": ["0x289d485d9771714cce91d3393d764e1311907acc"],
+			"maxValidatorSize": 1,
+			"blockInterval": 1,
+			"candidateDeposit": 1000,
+			"delegateDeposit": 10
+		}
+	},
+	"alloc": {
+		"7ef5a6135f1fd6a02593eedc869c6d41d934aef8": {"balance": "1000000000000000000"},
+		"f466859ead1932d743d622cb74fc058882e8648a": {"balance": "1000000000000000000"}
+	},
+	"difficulty": "0x1",
+	"gasLimit":   "0x47b760",
+	"timestamp":  "0x00"
+}`
+
+// Tests that a candidate registration is signed without a node when all the
+// chain dependent fields are given, and rejected when one is missing.
+func TestDposOfflineRegister(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	defer os.RemoveAll(datadir)
+	out := filepath.Join(datadir, "tx.hex")
+
+	geth := runGeth(t, "dpos", "register", "--datadir", datadir, "--password", "testdata/passwords.txt",
+		"--nonce", "3", "--chainid", "1337", "--value", "1000", "--gasprice", "1", "--out", out)
+	geth.ExpectRegexp(`Transaction: 0x[0-9a-f]{64}\n`)
+	geth.ExpectExit()
+
+	blob, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("failed to read transaction: %v", err)
+	}
+	enc, err := hexutil.Decode(string(blob))
+	if err != nil {
+		t.Fatalf("invalid transaction encoding: %v", err)
+	}
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(enc, tx); err != nil {
+		t.Fatalf("failed to decode transaction: %v", err)
+	}
+	if tx.Type() != types.RegCandidate {
+		t.Errorf("type mismatch: have %v, want %v", tx.Type(), types.RegCandidate)
+	}
+	if tx.To() != nil {
+		t.Errorf("recipient mismatch: have %x, want none", tx.To())
+	}
+	if tx.Nonce() != 3 {
+		t.Errorf("nonce mismatch: have %d, want 3", tx.Nonce())
+	}
+	if tx.Value().Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("value mismatch: have %v, want 1000", tx.Value())
+	}
+	from, err := types.Sender(types.NewEIP155Signer(big.NewInt(1337)), tx)
+	if err != nil {
+		t.Fatalf("failed to recover sender: %v", err)
+	}
+	if want := common.HexToAddress("0x7ef5a6135f1fd6a02593eedc869c6d41d934aef8"); from != want {
+		t.Errorf("sender mismatch: have %x, want %x", from, want)
+	}
+}
+
+// Tests that signing offline fails instead of dialing a node for the chain ID
+// and the minimum stake.
+func TestDposOfflineMissingFlags(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	defer os.RemoveAll(datadir)
+	out := filepath.Join(datadir, "tx.hex")
+
+	geth := runGeth(t, "dpos", "register", "--datadir", datadir, "--password", "testdata/passwords.txt",
+		"--nonce", "0", "--value", "1000", "--out", out)
+	geth.ExpectRegexp(`Fatal: The chain ID must be given with --chainid when not attached to a node.\n`)
+	geth.ExpectExit()
+
+	geth = runGeth(t, "dpos", "register", "--datadir", datadir, "--password", "testdata/passwords.txt",
+		"--nonce", "0", "--chainid", "1337", "--out", out)
+	geth.ExpectRegexp(`Fatal: The stake must be given with --value when not attached to a node.\n`)
+	geth.ExpectExit()
+
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("transaction written despite the missing flags: %v", err)
+	}
+}
+
+// Tests that a candidate registration and a vote for it are submitted to a
+// running node, taking the chain ID, stakes and nonces from the node, and get
+// mined by the validator.
+func TestDposRegisterDelegate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("IPC endpoint path is unix only")
+	}
+	datadir := tmpDatadirWithKeystore(t)
+	defer os.RemoveAll(datadir)
+
+	// Start the validator of a fresh DPoS chain
+	nodedir := tmpdir(t)
+	defer os.RemoveAll(nodedir)
+	json := filepath.Join(nodedir, "genesis.json")
+	if err := ioutil.WriteFile(json, []byte(dposGenesis), 0600); err != nil {
+		t.Fatalf("failed to write genesis file: %v", err)
+	}
+	runGeth(t, "--datadir", nodedir, "init", json).WaitExit()
+
+	validator := "0x289d485d9771714cce91d3393d764e1311907acc"
+	ipc := filepath.Join(nodedir, "geth.ipc")
+	node := runGeth(t, "--datadir", nodedir, "--keystore", filepath.Join(datadir, "keystore"),
+		"--port", "0", "--maxpeers", "0", "--nodiscover", "--nat", "none", "--ipcpath", ipc,
+		"--unlock", validator, "--password", "testdata/passwords.txt", "--validator", validator, "--mine")
+	defer func() {
+		node.Interrupt()
+		node.ExpectExit()
+	}()
+	time.Sleep(2 * time.Second) // Simple way to wait for the RPC endpoint to open
+
+	client, err := ethclient.Dial(ipc)
+	if err != nil {
+		t.Fatalf("failed to attach to node: %v", err)
+	}
+	defer client.Close()
+
+	// Register account #0 and vote for it from account #1 once the registration
+	// is mined, both transactions have to succeed
+	geth := runGeth(t, "dpos", "register", "--attach", "ipc:"+ipc, "--datadir", datadir,
+		"--password", "testdata/passwords.txt", "--from", "0", "--name", "test")
+	_, matches := geth.ExpectRegexp(`Transaction: (0x[0-9a-f]{64})\n`)
+	geth.ExpectExit()
+	waitDposReceipt(t, client, common.HexToHash(matches[1]))
+
+	geth = runGeth(t, "dpos", "delegate", "--attach", "ipc:"+ipc, "--datadir", datadir,
+		"--password", "testdata/passwords.txt", "--from", "1", "0x7ef5a6135f1fd6a02593eedc869c6d41d934aef8")
+	_, matches = geth.ExpectRegexp(`Transaction: (0x[0-9a-f]{64})\n`)
+	geth.ExpectExit()
+	waitDposReceipt(t, client, common.HexToHash(matches[1]))
+}
+
+// waitDposReceipt waits for the transaction to be mined and checks it succeeded.
+func waitDposReceipt(t *testing.T, client *ethclient.Client, hash common.Hash) {
+	var receipt *types.Receipt
+	for deadline := time.Now().Add(20 * time.Second); receipt == nil && time.Now().Before(deadline); {
+		if receipt, _ = client.TransactionReceipt(context.Background(), hash); receipt == nil {
+			time.Sleep(250 * time.Millisecond)
+		}
+	}
+	if receipt == nil {
+		t.Fatalf("transaction %x not mined", hash)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("transaction %x failed: %s", hash, receipt.Reason)
+	}
+}
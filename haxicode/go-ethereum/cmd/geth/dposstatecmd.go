@@ -0,0 +1,252 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/haxicode/go-ethereum/cmd/utils"
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/core"
+	"github.com/haxicode/go-ethereum/core/state"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/core/vm"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dposStateFlags = []cli.Flag{
+		utils.DataDirFlag,
+		utils.CacheFlag,
+		utils.SyncModeFlag,
+	}
+	dposStateCommand = cli.Command{
+		Name:     "dpos-state",
+		Usage:    "Inspect and check the DPoS state stored in the chain database",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+
+Opens the chain database of a stopped node to look into the DPoS tries of its
+blocks, typically to find out which of them diverged when a block is rejected
+with an invalid dpos root. Blocks are given by number or hash.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "dump",
+				Usage:     "Dump the DPoS tries of blocks as JSON",
+				ArgsUsage: "[<blockHash> | <blockNum>]...",
+				Action:    utils.MigrateFlags(dposStateDump),
+				Flags:     dposStateFlags,
+				Description: `
+    geth dpos-state dump <block>...
+
+Prints the entries of every DPoS trie of the blocks, keys and values in hex.`,
+			},
+			{
+				Name:      "diff",
+				Usage:     "Show the DPoS entries that differ between two blocks",
+				ArgsUsage: "<block> <block>",
+				Action:    utils.MigrateFlags(dposStateDiff),
+				Flags:     dposStateFlags,
+				Description: `
+    geth dpos-state diff <from> <to>
+
+Prints, for every DPoS trie whose root differs between the two blocks, the
+entries that changed along with their value in both blocks.`,
+			},
+			{
+				Name:      "replay",
+				Usage:     "Re-execute a block and compare the DPoS roots it yields",
+				ArgsUsage: "<block>",
+				Action:    utils.MigrateFlags(dposStateReplay),
+				Flags:     dposStateFlags,
+				Description: `
+    geth dpos-state replay <block>
+
+Applies the transactions and the election of the block on top of the state of
+its parent, then compares the root of every DPoS trie with the one recorded in
+the header and shows the entries of those that differ. The state of the parent
+must be available, which on a full node only holds for recent blocks.`,
+			},
+			{
+				Name:      "verify",
+				Usage:     "Check that every node of the DPoS tries of blocks is on disk",
+				ArgsUsage: "[<blockHash> | <blockNum>]...",
+				Action:    utils.MigrateFlags(dposStateVerify),
+				Flags:     dposStateFlags,
+				Description: `
+    geth dpos-state verify <block>...
+
+Walks the DPoS tries referenced by the headers and reports the nodes missing
+from the database. It fails if any trie is incomplete.`,
+			},
+		},
+	}
+)
+
+// dposStateBlock retrieves the block given by number or hash on the command line.
+func dposStateBlock(chain *core.BlockChain, arg string) *types.Block {
+	var block *types.Block
+	if hashish(arg) {
+		block = chain.GetBlockByHash(common.HexToHash(arg))
+	} else {
+		num, _ := strconv.Atoi(arg)
+		block = chain.GetBlockByNumber(uint64(num))
+	}
+	if block == nil {
+		utils.Fatalf("Block %s not found", arg)
+	}
+	return block
+}
+
+// dposStateDumpAt opens and dumps the DPoS tries recorded in the header.
+func dposStateDumpAt(chain *core.BlockChain, header *types.Header) (types.DposDump, error) {
+	dposContext, err := types.NewDposContextFromProto(chain.StateCache().TrieDB(), header.DposContext)
+	if err != nil {
+		return types.DposDump{}, err
+	}
+	return dposContext.RawDump()
+}
+
+func printJSON(v interface{}) {
+	out, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		utils.Fatalf("Failed to encode JSON: %v", err)
+	}
+	fmt.Println(string(out))
+}
+
+func dposStateDump(ctx *cli.Context) error {
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	for _, arg := range ctx.Args() {
+		block := dposStateBlock(chain, arg)
+		dump, err := dposStateDumpAt(chain, block.Header())
+		if err != nil {
+			utils.Fatalf("Failed to read the DPoS state of block %d: %v", block.NumberU64(), err)
+		}
+		printJSON(dump)
+	}
+	return nil
+}
+
+func dposStateDiff(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 {
+		utils.Fatalf("This command requires two blocks.")
+	}
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	dumps := make([]types.DposDump, 2)
+	for i, arg := range ctx.Args() {
+		block := dposStateBlock(chain, arg)
+		dump, err := dposStateDumpAt(chain, block.Header())
+		if err != nil {
+			utils.Fatalf("Failed to read the DPoS state of block %d: %v", block.NumberU64(), err)
+		}
+		dumps[i] = dump
+	}
+	printJSON(types.DiffDposDumps(dumps[0], dumps[1]))
+	return nil
+}
+
+func dposStateReplay(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires a block.")
+	}
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	block := dposStateBlock(chain, ctx.Args().First())
+	if block.NumberU64() == 0 {
+		utils.Fatalf("The genesis block can't be replayed")
+	}
+	parent := chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		utils.Fatalf("Parent of block %d not found", block.NumberU64())
+	}
+	statedb, err := state.New(parent.Root(), chain.StateCache())
+	if err != nil {
+		utils.Fatalf("State of block %d not available: %v", parent.NumberU64(), err)
+	}
+	dposContext, err := types.NewDposContextFromProto(chain.StateCache().TrieDB(), parent.Header().DposContext)
+	if err != nil {
+		utils.Fatalf("DPoS state of block %d not available: %v", parent.NumberU64(), err)
+	}
+	// Process the block on top of the parent, as the chain does on import
+	block.DposContext = dposContext
+	if _, _, _, err := chain.Processor().Process(block, statedb, vm.Config{}); err != nil {
+		utils.Fatalf("Failed to process block %d: %v", block.NumberU64(), err)
+	}
+	recorded, replayed := block.Header().DposContext, dposContext.ToProto()
+	fmt.Printf("Block %d (%x)\n", block.NumberU64(), block.Hash())
+	fmt.Printf("%-14s %x (recorded) %x (replayed)\n", "root", recorded.Root(), replayed.Root())
+
+	diverged := false
+	replayedRoots := replayed.Roots()
+	for i, root := range recorded.Roots() {
+		status := "ok"
+		if root != replayedRoots[i] {
+			status, diverged = "DIFFERS", true
+		}
+		fmt.Printf("%-14s %x %x %s\n", types.DposTrieNames[i], root, replayedRoots[i], status)
+	}
+	if !diverged {
+		return nil
+	}
+	// Show the entries that differ if the recorded tries are on disk
+	dump, err := dposStateDumpAt(chain, block.Header())
+	if err != nil {
+		fmt.Printf("Recorded DPoS state not available: %v\n", err)
+		return nil
+	}
+	replayedDump, err := dposContext.RawDump()
+	if err != nil {
+		utils.Fatalf("Failed to dump the replayed DPoS state: %v", err)
+	}
+	printJSON(types.DiffDposDumps(dump, replayedDump))
+	return nil
+}
+
+func dposStateVerify(ctx *cli.Context) error {
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	incomplete := 0
+	for _, arg := range ctx.Args() {
+		block := dposStateBlock(chain, arg)
+		fmt.Printf("Block %d (%x)\n", block.NumberU64(), block.Hash())
+		for _, check := range block.Header().DposContext.Check(chain.StateCache().TrieDB()) {
+			if check.Err != nil {
+				incomplete++
+				fmt.Printf("%-14s %x %d nodes, %v\n", check.Name, check.Root, check.Nodes, check.Err)
+			} else {
+				fmt.Printf("%-14s %x %d nodes, ok\n", check.Name, check.Root, check.Nodes)
+			}
+		}
+	}
+	if incomplete > 0 {
+		utils.Fatalf("%d DPoS tries are incomplete", incomplete)
+	}
+	return nil
+}
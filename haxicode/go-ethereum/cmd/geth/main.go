@@ -85,6 +85,8 @@ var (
 		utils.TxPoolLifetimeFlag,
 		utils.SyncModeFlag,
 		utils.GCModeFlag,
+		utils.DposCheckpointFlag,
+		utils.DposKeepSnapshotsFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
 		utils.LightKDFFlag,
@@ -110,6 +112,7 @@ var (
 		utils.MinerExtraDataFlag,
 		utils.MinerLegacyExtraDataFlag,
 		utils.MinerRecommitIntervalFlag,
+		utils.MinerFailoverFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
@@ -183,6 +186,10 @@ func init() {
 		// See accountcmd.go:
 		accountCommand,
 		walletCommand,
+		// See dposcmd.go:
+		dposCommand,
+		// See dposstatecmd.go:
+		dposStateCommand,
 		// See consolecmd.go:
 		consoleCommand,
 		attachCommand,
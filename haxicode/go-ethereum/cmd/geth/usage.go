@@ -76,6 +76,8 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.RinkebyFlag,
 			utils.SyncModeFlag,
 			utils.GCModeFlag,
+			utils.DposCheckpointFlag,
+			utils.DposKeepSnapshotsFlag,
 			utils.EthStatsURLFlag,
 			utils.IdentityFlag,
 			utils.LightServFlag,
@@ -193,6 +195,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.CoinbaseFlag,
 			utils.MinerExtraDataFlag,
 			utils.MinerRecommitIntervalFlag,
+			utils.MinerFailoverFlag,
 		},
 	},
 	{
@@ -46,6 +46,15 @@ func (w *wizard) makeGenesis() {
 			ByzantiumBlock: big.NewInt(4),
 		},
 	}
+	// Figure out how the validators are elected, until the chain can start with them
+	for {
+		genesis.Config.Dpos = w.makeDposConfig()
+		if err := genesis.Validate(); err != nil {
+			log.Error("Invalid DPoS configuration, please retry", "err", err)
+			continue
+		}
+		break
+	}
 	// Consensus all set, just ask for initial funds and go
 	fmt.Println()
 	fmt.Println("Which accounts should be pre-funded? (advisable at least one)")
@@ -75,6 +84,67 @@ func (w *wizard) makeGenesis() {
 	w.conf.flush()
 }
 
+// makeDposConfig queries the user for the DPoS parameters of a new chain, along
+// with its genesis validators and their votes.
+func (w *wizard) makeDposConfig() *params.DposConfig {
+	config := &params.DposConfig{RandaoBlock: big.NewInt(0)} // New chains seed their elections with randao
+
+	// Figure out how fast the validators seal and how many are elected
+	fmt.Println()
+	fmt.Println("How many seconds should blocks take? (default = 10)")
+	config.BlockInterval = uint64(w.readDefaultInt(10))
+
+	fmt.Println()
+	fmt.Println("How many validators should be elected every epoch? (default = 21)")
+	config.MaxValidatorSize = uint64(w.readDefaultInt(21))
+
+	fmt.Println()
+	fmt.Printf("How many seconds should an epoch last? (default = %d)\n", params.DefaultDposEpoch)
+	config.Epoch = uint64(w.readDefaultInt(params.DefaultDposEpoch))
+
+	// We also need the initial list of validators
+	fmt.Println()
+	fmt.Printf("Which accounts are the genesis validators? (mandatory at least %d)\n", config.SafeSize())
+	for {
+		if address := w.readAddress(); address != nil {
+			config.Validators = append(config.Validators, *address)
+			continue
+		}
+		if len(config.Validators) >= config.SafeSize() {
+			break
+		}
+		fmt.Println()
+		fmt.Printf("Which other accounts are genesis validators? (%d given, mandatory at least %d)\n", len(config.Validators), config.SafeSize())
+	}
+	// Genesis validators vote for themselves, others may add their stake
+	fmt.Println()
+	fmt.Println("Which accounts should vote for a genesis validator? (optional)")
+	for {
+		delegator := w.readAddress()
+		if delegator == nil {
+			break
+		}
+		fmt.Println()
+		fmt.Printf("Which validator does 0x%x vote for?\n", *delegator)
+		candidate := w.readAddress()
+		if candidate == nil {
+			continue
+		}
+		fmt.Println()
+		fmt.Println("How many ethers does it stake? (default = 1)")
+		stake := w.readDefaultBigInt(big.NewInt(1))
+
+		config.Delegations = append(config.Delegations, &params.DposDelegation{
+			Delegator: *delegator,
+			Candidate: *candidate,
+			Stake:     new(big.Int).Mul(stake, big.NewInt(params.Ether)),
+		})
+		fmt.Println()
+		fmt.Println("Which other account should vote for a genesis validator? (optional)")
+	}
+	return config
+}
+
 // manageGenesis permits the modification of chain configuration parameters in
 // a genesis config and the export of the entire genesis spec.
 func (w *wizard) manageGenesis() {
@@ -41,6 +41,10 @@ import (
 
 const (
 	importBatchSize = 2500
+
+	// snapshotMarker precedes every state snapshot in an exported chain, which
+	// tells it apart from the blocks, encoded as lists.
+	snapshotMarker = "dpos-snapshot"
 )
 
 // Fatalf formats a message to standard error and exits the program.
@@ -85,7 +89,10 @@ func StartNode(stack *node.Node) {
 	}()
 }
 
-func ImportChain(chain *core.BlockChain, fn string) error {
+// ImportChain imports the blocks of the specified file into the chain. With
+// snapshots set, a chain holding nothing but its genesis block starts from the
+// first state snapshot of the file instead of executing the blocks before it.
+func ImportChain(chain *core.BlockChain, fn string, snapshots bool) error {
 	// Watch for Ctrl-C while the import is running.
 	// If a signal is received, the import will stop at the next batch.
 	interrupt := make(chan os.Signal, 1)
@@ -126,23 +133,48 @@ func ImportChain(chain *core.BlockChain, fn string) error {
 	stream := rlp.NewStream(reader, 0)
 
 	// Run actual the import.
-	blocks := make(types.Blocks, importBatchSize)
+	var (
+		blocks   = make(types.Blocks, importBatchSize)
+		snapshot *core.ChainSnapshot
+		base     uint64 // Blocks up to a snapshot started from are skipped
+	)
 	n := 0
 	for batch := 0; ; batch++ {
 		// Load a batch of RLP blocks.
 		if checkInterrupt() {
 			return fmt.Errorf("interrupted")
 		}
+		// Start from the snapshot ending the previous batch if possible
+		if snapshot != nil && snapshots {
+			if head := chain.CurrentBlock().NumberU64(); head > 0 {
+				log.Info("Skipping snapshot of non-empty chain", "number", snapshot.Block.Number(), "head", head)
+			} else if err := chain.ImportSnapshot(snapshot); err != nil {
+				return fmt.Errorf("invalid snapshot %d: %v", snapshot.Block.NumberU64(), err)
+			} else {
+				base = snapshot.Block.NumberU64()
+			}
+		}
+		snapshot = nil
 		i := 0
 		for ; i < importBatchSize; i++ {
-			var b types.Block
-			if err := stream.Decode(&b); err == io.EOF {
+			kind, _, err := stream.Kind()
+			if err == io.EOF {
 				break
 			} else if err != nil {
 				return fmt.Errorf("at block %d: %v", n, err)
 			}
-			// don't import first block
-			if b.NumberU64() == 0 {
+			if kind == rlp.String {
+				if snapshot, err = decodeSnapshot(stream); err != nil {
+					return fmt.Errorf("at block %d: %v", n, err)
+				}
+				break
+			}
+			var b types.Block
+			if err := stream.Decode(&b); err != nil {
+				return fmt.Errorf("at block %d: %v", n, err)
+			}
+			// don't import first block, nor those a snapshot covers
+			if b.NumberU64() <= base {
 				i--
 				continue
 			}
@@ -150,6 +182,9 @@ func ImportChain(chain *core.BlockChain, fn string) error {
 			n++
 		}
 		if i == 0 {
+			if snapshot != nil {
+				continue
+			}
 			break
 		}
 		// Import the batch.
@@ -168,6 +203,22 @@ func ImportChain(chain *core.BlockChain, fn string) error {
 	return nil
 }
 
+// decodeSnapshot reads a state snapshot from the stream, along with its marker.
+func decodeSnapshot(stream *rlp.Stream) (*core.ChainSnapshot, error) {
+	var marker string
+	if err := stream.Decode(&marker); err != nil {
+		return nil, err
+	}
+	if marker != snapshotMarker {
+		return nil, fmt.Errorf("unknown item %q", marker)
+	}
+	snapshot := new(core.ChainSnapshot)
+	if err := stream.Decode(snapshot); err != nil {
+		return nil, fmt.Errorf("invalid snapshot: %v", err)
+	}
+	return snapshot, nil
+}
+
 func missingBlocks(chain *core.BlockChain, blocks []*types.Block) []*types.Block {
 	head := chain.CurrentBlock()
 	for i, block := range blocks {
@@ -187,8 +238,9 @@ func missingBlocks(chain *core.BlockChain, blocks []*types.Block) []*types.Block
 }
 
 // ExportChain exports a blockchain into the specified file, truncating any data
-// already present in the file.
-func ExportChain(blockchain *core.BlockChain, fn string) error {
+// already present in the file. With snapshot set, the blocks are preceded by the
+// state snapshot of the last block starting a DPoS epoch.
+func ExportChain(blockchain *core.BlockChain, fn string, snapshot bool) error {
 	log.Info("Exporting blockchain", "file", fn)
 
 	// Open the file handle and potentially wrap with a gzip stream
@@ -203,6 +255,11 @@ func ExportChain(blockchain *core.BlockChain, fn string) error {
 		writer = gzip.NewWriter(writer)
 		defer writer.(*gzip.Writer).Close()
 	}
+	if snapshot {
+		if err := exportSnapshot(blockchain, writer, blockchain.CurrentBlock().NumberU64()); err != nil {
+			return err
+		}
+	}
 	// Iterate over the blocks and export them
 	if err := blockchain.Export(writer); err != nil {
 		return err
@@ -213,8 +270,10 @@ func ExportChain(blockchain *core.BlockChain, fn string) error {
 }
 
 // ExportAppendChain exports a blockchain into the specified file, appending to
-// the file if data already exists in it.
-func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, last uint64) error {
+// the file if data already exists in it. With snapshot set, the blocks are
+// preceded by the state snapshot of the last block starting a DPoS epoch in the
+// range.
+func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, last uint64, snapshot bool) error {
 	log.Info("Exporting blockchain", "file", fn)
 
 	// Open the file handle and potentially wrap with a gzip stream
@@ -229,6 +288,11 @@ func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, las
 		writer = gzip.NewWriter(writer)
 		defer writer.(*gzip.Writer).Close()
 	}
+	if snapshot {
+		if err := exportSnapshot(blockchain, writer, last); err != nil {
+			return err
+		}
+	}
 	// Iterate over the blocks and export them
 	if err := blockchain.ExportN(writer, first, last); err != nil {
 		return err
@@ -237,6 +301,24 @@ func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, las
 	return nil
 }
 
+// exportSnapshot writes the state snapshot of the last block at or below number
+// starting a DPoS epoch, for an import to start from it instead of the genesis.
+func exportSnapshot(blockchain *core.BlockChain, w io.Writer, number uint64) error {
+	block := blockchain.LastSnapshotBlock(number)
+	if block == nil {
+		return fmt.Errorf("no epoch start with available state at or below block %d (run with --%s or --%s archive)", number, DposKeepSnapshotsFlag.Name, GCModeFlag.Name)
+	}
+	snapshot, err := blockchain.Snapshot(block)
+	if err != nil {
+		return err
+	}
+	log.Info("Exporting snapshot", "number", block.Number(), "hash", block.Hash(), "accounts", len(snapshot.Accounts))
+	if err := rlp.Encode(w, snapshotMarker); err != nil {
+		return err
+	}
+	return rlp.Encode(w, snapshot)
+}
+
 // ImportPreimages imports a batch of exported hash preimages into the database.
 func ImportPreimages(db *ethdb.LDBDatabase, fn string) error {
 	log.Info("Importing preimages", "file", fn)
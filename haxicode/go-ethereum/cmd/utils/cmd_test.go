@@ -0,0 +1,112 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/haxicode/go-ethereum/consensus/dpos/simulation"
+	"github.com/haxicode/go-ethereum/params"
+)
+
+// newSnapshotSimulator returns a single validator DPoS network, whose epochs
+// start every 10 blocks. Simulators are deterministic, so they all share the
+// same genesis block.
+func newSnapshotSimulator(t *testing.T) *simulation.Simulator {
+	sim, err := simulation.New(&params.DposConfig{MaxValidatorSize: 1, BlockInterval: 1, Epoch: 10}, 1)
+	if err != nil {
+		t.Fatalf("failed to create simulator: %v", err)
+	}
+	return sim
+}
+
+// Tests that a chain exported along with a snapshot is imported from the
+// snapshot on, or in full without --dpos.snapshot.
+func TestExportImportSnapshot(t *testing.T) {
+	source := newSnapshotSimulator(t)
+	defer source.Stop()
+	if err := source.RunEpochs(3); err != nil {
+		t.Fatalf("failed to run simulation: %v", err)
+	}
+	if err := source.Run(5 * time.Second); err != nil {
+		t.Fatalf("failed to run simulation: %v", err)
+	}
+	chain := source.Node(0).Chain
+	head := chain.CurrentBlock()
+
+	dir, err := ioutil.TempDir("", "dpos-export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name        string
+		export      func(fn string) error
+		snapshots   bool
+		head, first uint64 // Expected head and first non-genesis block of the importer
+	}{
+		{
+			name:      "full",
+			export:    func(fn string) error { return ExportChain(chain, fn, true) },
+			snapshots: true,
+			head:      head.NumberU64(),
+			first:     30,
+		},
+		{
+			name:      "range",
+			export:    func(fn string) error { return ExportAppendChain(chain, fn, 1, 25, true) },
+			snapshots: true,
+			head:      25,
+			first:     20,
+		},
+		{
+			name:      "ignored",
+			export:    func(fn string) error { return ExportChain(chain, fn, true) },
+			snapshots: false,
+			head:      head.NumberU64(),
+			first:     1,
+		},
+	}
+	for _, tt := range tests {
+		fn := filepath.Join(dir, tt.name+".rlp")
+		if err := tt.export(fn); err != nil {
+			t.Fatalf("%s: failed to export: %v", tt.name, err)
+		}
+		importer := newSnapshotSimulator(t)
+		importer.Clock.Set(time.Unix(source.Now(), 0)) // None of the blocks are from the future
+		imported := importer.Node(0).Chain
+		if err := ImportChain(imported, fn, tt.snapshots); err != nil {
+			importer.Stop()
+			t.Fatalf("%s: failed to import: %v", tt.name, err)
+		}
+		if have, want := imported.CurrentBlock().Hash(), chain.GetHeaderByNumber(tt.head).Hash(); have != want {
+			t.Errorf("%s: head mismatch: have #%d, want #%d", tt.name, imported.CurrentBlock().NumberU64(), tt.head)
+		}
+		for number := uint64(1); number <= tt.head; number++ {
+			has := imported.GetHeaderByNumber(number) != nil
+			if want := number >= tt.first; has != want {
+				t.Errorf("%s: block #%d presence mismatch: have %v, want %v", tt.name, number, has, want)
+			}
+		}
+		importer.Stop()
+	}
+}
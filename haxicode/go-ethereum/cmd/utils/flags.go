@@ -19,6 +19,7 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/big"
@@ -32,9 +33,13 @@ import (
 	"github.com/haxicode/go-ethereum/accounts/keystore"
 	"github.com/haxicode/go-ethereum/common"
 	"github.com/haxicode/go-ethereum/common/fdlimit"
+	"github.com/haxicode/go-ethereum/consensus"
+	"github.com/haxicode/go-ethereum/consensus/clique"
 	"github.com/haxicode/go-ethereum/consensus/dpos"
+	"github.com/haxicode/go-ethereum/consensus/ethash"
 	"github.com/haxicode/go-ethereum/core"
 	"github.com/haxicode/go-ethereum/core/state"
+	"github.com/haxicode/go-ethereum/core/types"
 	"github.com/haxicode/go-ethereum/core/vm"
 	"github.com/haxicode/go-ethereum/crypto"
 	"github.com/haxicode/go-ethereum/dashboard"
@@ -140,7 +145,7 @@ var (
 	}
 	DeveloperFlag = cli.BoolFlag{
 		Name:  "dev",
-		Usage: "Ephemeral proof-of-authority network with a pre-funded developer account, mining enabled",
+		Usage: "Ephemeral DPoS network with a pre-funded developer account as its only validator, mining enabled",
 	}
 	DeveloperPeriodFlag = cli.IntFlag{
 		Name:  "dev.period",
@@ -166,6 +171,18 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	DposCheckpointFlag = cli.StringFlag{
+		Name:  "dpos.checkpoint",
+		Usage: "JSON file with the trusted DPoS block fast sync starts from (see dpos.getCheckpoint)",
+	}
+	DposKeepSnapshotsFlag = cli.BoolFlag{
+		Name:  "dpos.keepsnapshots",
+		Usage: "Keep the state of every DPoS epoch start on disk for export --dpos.snapshot (never pruned)",
+	}
+	DposSnapshotFlag = cli.BoolFlag{
+		Name:  "dpos.snapshot",
+		Usage: "Export the state at the last DPoS epoch start along with the blocks, or start an import from it",
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -372,6 +389,10 @@ var (
 		Usage: "Time interval to recreate the block being mined.",
 		Value: eth.DefaultConfig.MinerRecommit,
 	}
+	MinerFailoverFlag = cli.StringFlag{
+		Name:  "miner.failover",
+		Usage: "Lock shared by the nodes of a validator, only the holder seals (lock file path or etcd URL with the key as path)",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -395,7 +416,7 @@ var (
 	}
 	FakePoWFlag = cli.BoolFlag{
 		Name:  "fakepow",
-		Usage: "Disables proof-of-work verification",
+		Usage: "Disables proof-of-work verification and seals ethash blocks instantly",
 	}
 	NoCompactionFlag = cli.BoolFlag{
 		Name:  "nocompaction",
@@ -1066,6 +1087,9 @@ func setEthash(ctx *cli.Context, cfg *eth.Config) {
 	if ctx.GlobalIsSet(EthashDatasetsOnDiskFlag.Name) {
 		cfg.Ethash.DatasetsOnDisk = ctx.GlobalInt(EthashDatasetsOnDiskFlag.Name)
 	}
+	if ctx.GlobalBool(FakePoWFlag.Name) {
+		cfg.Ethash.PowMode = ethash.ModeFake
+	}
 }
 
 // checkExclusive verifies that only a single instance of the provided flags was
@@ -1116,6 +1140,22 @@ func SetShhConfig(ctx *cli.Context, stack *node.Node, cfg *whisper.Config) {
 	}
 }
 
+// readDposCheckpoint loads the trusted checkpoint stored as JSON in the file.
+func readDposCheckpoint(path string) *types.DposCheckpoint {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		Fatalf("Failed to read DPoS checkpoint: %v", err)
+	}
+	checkpoint := new(types.DposCheckpoint)
+	if err := json.Unmarshal(blob, checkpoint); err != nil {
+		Fatalf("Invalid DPoS checkpoint %s: %v", path, err)
+	}
+	if checkpoint.Hash == (common.Hash{}) || checkpoint.DposContext == nil {
+		Fatalf("Invalid DPoS checkpoint %s: hash and dposContext are required", path)
+	}
+	return checkpoint
+}
+
 // SetEthConfig applies eth-related command line flags to the config.
 func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	// Avoid conflicting network flags
@@ -1132,6 +1172,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(SyncModeFlag.Name) {
 		cfg.SyncMode = *GlobalTextMarshaler(ctx, SyncModeFlag.Name).(*downloader.SyncMode)
 	}
+	if ctx.GlobalIsSet(DposCheckpointFlag.Name) {
+		if cfg.SyncMode != downloader.FastSync {
+			Fatalf("--%s requires --%s fast", DposCheckpointFlag.Name, SyncModeFlag.Name)
+		}
+		cfg.DposCheckpoint = readDposCheckpoint(ctx.GlobalString(DposCheckpointFlag.Name))
+	}
 	if ctx.GlobalIsSet(LightServFlag.Name) {
 		cfg.LightServ = ctx.GlobalInt(LightServFlag.Name)
 	}
@@ -1151,6 +1197,7 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
+	cfg.DposSnapshots = ctx.GlobalBool(DposKeepSnapshotsFlag.Name)
 
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cfg.TrieCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
@@ -1182,6 +1229,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(MinerRecommitIntervalFlag.Name) {
 		cfg.MinerRecommit = ctx.Duration(MinerRecommitIntervalFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerFailoverFlag.Name) {
+		cfg.MinerFailover = ctx.GlobalString(MinerFailoverFlag.Name)
+	}
 	if ctx.GlobalIsSet(VMEnableDebugFlag.Name) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
@@ -1264,7 +1314,11 @@ func RegisterEthService(stack *node.Node, cfg *eth.Config) {
 // RegisterDashboardService adds a dashboard to the stack.
 func RegisterDashboardService(stack *node.Node, cfg *dashboard.Config, commit string) {
 	stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
-		return dashboard.New(cfg, commit, ctx.ResolvePath("logs")), nil
+		// Retrieve the eth service for the chain data, missing on light nodes
+		var ethServ *eth.Ethereum
+		ctx.Service(&ethServ)
+
+		return dashboard.New(cfg, commit, ctx.ResolvePath("logs"), ethServ), nil
 	})
 }
 
@@ -1363,7 +1417,25 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	if err != nil {
 		Fatalf("%v", err)
 	}
-	engine := dpos.New(config.Dpos, chainDb)
+	var engine consensus.Engine
+	switch {
+	case config.IsDpos():
+		engine = dpos.New(config.Dpos, chainDb)
+	case config.Clique != nil:
+		engine = clique.New(config.Clique, chainDb)
+	default:
+		engine = ethash.NewFaker()
+		if !ctx.GlobalBool(FakePoWFlag.Name) {
+			engine = ethash.New(ethash.Config{
+				CacheDir:       stack.ResolvePath(eth.DefaultConfig.Ethash.CacheDir),
+				CachesInMem:    eth.DefaultConfig.Ethash.CachesInMem,
+				CachesOnDisk:   eth.DefaultConfig.Ethash.CachesOnDisk,
+				DatasetDir:     stack.ResolvePath(eth.DefaultConfig.Ethash.DatasetDir),
+				DatasetsInMem:  eth.DefaultConfig.Ethash.DatasetsInMem,
+				DatasetsOnDisk: eth.DefaultConfig.Ethash.DatasetsOnDisk,
+			}, nil)
+		}
+	}
 	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
 	}
@@ -1371,6 +1443,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		Disabled:      ctx.GlobalString(GCModeFlag.Name) == "archive",
 		TrieNodeLimit: eth.DefaultConfig.TrieCache,
 		TrieTimeLimit: eth.DefaultConfig.TrieTimeout,
+		DposSnapshots: ctx.GlobalBool(DposKeepSnapshotsFlag.Name),
 	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cache.TrieNodeLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
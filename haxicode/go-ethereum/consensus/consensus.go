@@ -25,6 +25,7 @@ import (
 	"github.com/haxicode/go-ethereum/core/types"
 	"github.com/haxicode/go-ethereum/params"
 	"github.com/haxicode/go-ethereum/rpc"
+	"github.com/haxicode/go-ethereum/trie"
 )
 
 // ChainReader defines a small collection of methods needed to access the local
@@ -59,7 +60,7 @@ type Engine interface {
 	// VerifyHeader checks whether a header conforms to the consensus rules of a
 	// given engine. Verifying the seal may be done optionally here, or explicitly
 	// via the VerifySeal method.
-	VerifyHeader(chain ChainReader, header *types.Header, seal bool, blockInterval uint64) error
+	VerifyHeader(chain ChainReader, header *types.Header, seal bool) error
 
 	// VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers
 	// concurrently. The method returns a quit channel to abort the operations and
@@ -73,7 +74,7 @@ type Engine interface {
 
 	// VerifySeal checks whether the crypto seal on a header is valid according to
 	// the consensus rules of the given engine.
-	VerifySeal(chain ChainReader, header *types.Header, genesisheader *types.Header) error
+	VerifySeal(chain ChainReader, header *types.Header) error
 
 	// Prepare initializes the consensus fields of a block header according to the
 	// rules of a particular engine. The changes are executed inline.
@@ -108,3 +109,36 @@ type PoW interface {
 	// Hashrate returns the current mining hashrate of a PoW consensus engine.
 	Hashrate() float64
 }
+
+// Irreversible is a consensus engine under which blocks become irreversible once
+// enough validators built upon or voted for them. The chain and the downloader
+// refuse any fork below the irreversible block, so that history can't be
+// rewritten by a long-range attack.
+type Irreversible interface {
+	Engine
+
+	// IrreversibleHeader retrieves the latest header that must never be reverted,
+	// nil if there is none yet.
+	IrreversibleHeader(chain ChainReader) *types.Header
+}
+
+// Scheduled is a consensus engine that assigns every block to a time slot of a
+// single validator. Instead of sealing on top of every new head, the miner asks
+// it at each tick whether the local validator owns the next slot.
+type Scheduled interface {
+	Engine
+
+	// CheckValidator returns nil if the local validator may seal a block on top
+	// of the parent at the given unix time, or the reason it may not.
+	CheckValidator(parent *types.Block, now int64) error
+}
+
+// TrieBacked is a consensus engine that reads its own tries from the state of
+// the chain. As those are only flushed to disk once in a while, the chain hands
+// it the in-memory trie database they are written to.
+type TrieBacked interface {
+	Engine
+
+	// SetTrieDB sets the trie database the tries of the chain are held in.
+	SetTrieDB(db *trie.Database)
+}
@@ -20,15 +20,15 @@ import (
 	"encoding/binary"
 	"errors"
 	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/common/hexutil"
 	"github.com/haxicode/go-ethereum/consensus"
 	"github.com/haxicode/go-ethereum/core/types"
-	"github.com/haxicode/go-ethereum/crypto"
 	"github.com/haxicode/go-ethereum/log"
+	"github.com/haxicode/go-ethereum/params"
 	"github.com/haxicode/go-ethereum/rpc"
 	"github.com/haxicode/go-ethereum/trie"
 	"math/rand"
 	"sort"
-	"fmt"
 
 	"math/big"
 )
@@ -40,19 +40,57 @@ type API struct {
 	dpos  *Dpos
 }
 
-// GetValidators retrieves the list of the validators at specified block
-func (api *API) GetValidators(number *rpc.BlockNumber) ([]common.Address, error) {
+// header retrieves the header at specified block, the current one if no number
+// is given.
+func (api *API) header(number *rpc.BlockNumber) (*types.Header, error) {
 	var header *types.Header
 	if number == nil || *number == rpc.LatestBlockNumber {
 		header = api.chain.CurrentHeader()
+	} else if *number == rpc.FinalizedBlockNumber {
+		header = api.dpos.FinalizedHeader(api.chain)
 	} else {
 		header = api.chain.GetHeaderByNumber(uint64(number.Int64()))
 	}
 	if header == nil {
 		return nil, errUnknownBlock
 	}
+	return header, nil
+}
+
+// headerAtHash retrieves the header with specified hash.
+func (api *API) headerAtHash(hash common.Hash) (*types.Header, error) {
+	header := api.chain.GetHeaderByHash(hash)
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	return header, nil
+}
 
-	trieDB := trie.NewDatabase(api.dpos.db)
+// dposContext opens the dpos tries committed with the header.
+func (api *API) dposContext(header *types.Header) (*types.DposContext, error) {
+	return types.NewDposContextFromProto(api.dpos.trieDatabase(), header.DposContext)
+}
+
+// GetValidators retrieves the list of the validators at specified block
+func (api *API) GetValidators(number *rpc.BlockNumber) ([]common.Address, error) {
+	header, err := api.header(number)
+	if err != nil {
+		return nil, err
+	}
+	return api.validators(header)
+}
+
+// GetValidatorsAtHash retrieves the list of the validators at specified block
+func (api *API) GetValidatorsAtHash(hash common.Hash) ([]common.Address, error) {
+	header, err := api.headerAtHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return api.validators(header)
+}
+
+func (api *API) validators(header *types.Header) ([]common.Address, error) {
+	trieDB := api.dpos.trieDatabase()
 	epochTrie, err := types.NewEpochTrie(header.DposContext.EpochHash, trieDB)
 
 	if err != nil {
@@ -67,46 +105,472 @@ func (api *API) GetValidators(number *rpc.BlockNumber) ([]common.Address, error)
 	return validators, nil
 }
 
-// GetConfirmedBlockNumber retrieves the latest irreversible block
-func (api *API) GetConfirmedBlockNumber() (*big.Int, error) {
-	var err error
-	header := api.dpos.confirmedBlockHeader
-	if header == nil {
-		header, err = api.dpos.loadConfirmedBlockHeader(api.chain)
+// GetCandidates retrieves the list of the candidates at specified block
+func (api *API) GetCandidates(number *rpc.BlockNumber) ([]common.Address, error) {
+	header, err := api.header(number)
+	if err != nil {
+		return nil, err
+	}
+	return api.candidates(header)
+}
+
+// GetCandidatesAtHash retrieves the list of the candidates at specified block
+func (api *API) GetCandidatesAtHash(hash common.Hash) ([]common.Address, error) {
+	header, err := api.headerAtHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return api.candidates(header)
+}
+
+func (api *API) candidates(header *types.Header) ([]common.Address, error) {
+	dposContext, err := api.dposContext(header)
+	if err != nil {
+		return nil, err
+	}
+	return dposContext.GetCandidates()
+}
+
+// GetDelegators retrieves the delegators voting for a candidate at specified block
+func (api *API) GetDelegators(candidate common.Address, number *rpc.BlockNumber) ([]common.Address, error) {
+	header, err := api.header(number)
+	if err != nil {
+		return nil, err
+	}
+	return api.delegators(candidate, header)
+}
+
+// GetDelegatorsAtHash retrieves the delegators voting for a candidate at specified block
+func (api *API) GetDelegatorsAtHash(candidate common.Address, hash common.Hash) ([]common.Address, error) {
+	header, err := api.headerAtHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return api.delegators(candidate, header)
+}
+
+func (api *API) delegators(candidate common.Address, header *types.Header) ([]common.Address, error) {
+	dposContext, err := api.dposContext(header)
+	if err != nil {
+		return nil, err
+	}
+	return dposContext.GetDelegators(candidate)
+}
+
+// GetVote retrieves the candidate a delegator votes for at specified block, null
+// if it doesn't vote
+func (api *API) GetVote(delegator common.Address, number *rpc.BlockNumber) (*common.Address, error) {
+	header, err := api.header(number)
+	if err != nil {
+		return nil, err
+	}
+	return api.vote(delegator, header)
+}
+
+// GetVoteAtHash retrieves the candidate a delegator votes for at specified block,
+// null if it doesn't vote
+func (api *API) GetVoteAtHash(delegator common.Address, hash common.Hash) (*common.Address, error) {
+	header, err := api.headerAtHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return api.vote(delegator, header)
+}
+
+func (api *API) vote(delegator common.Address, header *types.Header) (*common.Address, error) {
+	dposContext, err := api.dposContext(header)
+	if err != nil {
+		return nil, err
+	}
+	return dposContext.GetVote(delegator)
+}
+
+// GetVoteTally retrieves the votes of every candidate at specified block, as
+// counted by the next election
+func (api *API) GetVoteTally(number *rpc.BlockNumber) (map[common.Address]*hexutil.Big, error) {
+	header, err := api.header(number)
+	if err != nil {
+		return nil, err
+	}
+	return api.voteTally(header)
+}
+
+// GetVoteTallyAtHash retrieves the votes of every candidate at specified block,
+// as counted by the next election
+func (api *API) GetVoteTallyAtHash(hash common.Hash) (map[common.Address]*hexutil.Big, error) {
+	header, err := api.headerAtHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return api.voteTally(header)
+}
+
+func (api *API) voteTally(header *types.Header) (map[common.Address]*hexutil.Big, error) {
+	dposContext, err := api.dposContext(header)
+	if err != nil {
+		return nil, err
+	}
+	epochContext := &EpochContext{DposContext: dposContext, TimeStamp: header.Time.Int64()}
+	votes, err := epochContext.countVotes()
+	if err != nil {
+		return nil, err
+	}
+	tally := make(map[common.Address]*hexutil.Big, len(votes))
+	for candidate, cnt := range votes {
+		tally[candidate] = (*hexutil.Big)(cnt)
+	}
+	return tally, nil
+}
+
+// GetMintCounts retrieves the number of blocks minted by each validator in an
+// epoch, the epoch of specified block if none is given
+func (api *API) GetMintCounts(epoch *hexutil.Uint64, number *rpc.BlockNumber) (map[common.Address]hexutil.Uint64, error) {
+	header, err := api.header(number)
+	if err != nil {
+		return nil, err
+	}
+	return api.mintCounts(epoch, header)
+}
+
+// GetMintCountsAtHash retrieves the number of blocks minted by each validator in
+// an epoch, the epoch of specified block if none is given
+func (api *API) GetMintCountsAtHash(epoch *hexutil.Uint64, hash common.Hash) (map[common.Address]hexutil.Uint64, error) {
+	header, err := api.headerAtHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return api.mintCounts(epoch, header)
+}
+
+func (api *API) mintCounts(epoch *hexutil.Uint64, header *types.Header) (map[common.Address]hexutil.Uint64, error) {
+	dposContext, err := api.dposContext(header)
+	if err != nil {
+		return nil, err
+	}
+	id := EpochNumber(dposContext, header.Time.Int64(), api.dpos.Config(header.Number))
+	if epoch != nil {
+		id = int64(*epoch)
+	}
+	counts, err := dposContext.GetMintCounts(id)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[common.Address]hexutil.Uint64, len(counts))
+	for validator, cnt := range counts {
+		result[validator] = hexutil.Uint64(cnt)
+	}
+	return result, nil
+}
+
+// GetValidatorStats retrieves the blocks produced, the slots missed and the
+// uptime of each validator in an epoch, the epoch of specified block if none is
+// given
+func (api *API) GetValidatorStats(epoch *hexutil.Uint64, number *rpc.BlockNumber) (map[common.Address]*ValidatorStats, error) {
+	header, err := api.header(number)
+	if err != nil {
+		return nil, err
+	}
+	return api.validatorStats(epoch, header)
+}
+
+// GetValidatorStatsAtHash retrieves the blocks produced, the slots missed and
+// the uptime of each validator in an epoch, the epoch of specified block if none
+// is given
+func (api *API) GetValidatorStatsAtHash(epoch *hexutil.Uint64, hash common.Hash) (map[common.Address]*ValidatorStats, error) {
+	header, err := api.headerAtHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return api.validatorStats(epoch, header)
+}
+
+func (api *API) validatorStats(epoch *hexutil.Uint64, header *types.Header) (map[common.Address]*ValidatorStats, error) {
+	dposContext, err := api.dposContext(header)
+	if err != nil {
+		return nil, err
+	}
+	id := EpochNumber(dposContext, header.Time.Int64(), api.dpos.Config(header.Number))
+	if epoch != nil {
+		id = int64(*epoch)
+	}
+	return validatorStats(dposContext, id)
+}
+
+// Slot is a block production slot and the validator scheduled to seal it.
+type Slot struct {
+	Time      hexutil.Uint64 `json:"time"`
+	Validator common.Address `json:"validator"`
+}
+
+// EpochInfo describes the epoch the block following a given block belongs to.
+type EpochInfo struct {
+	Number        hexutil.Uint64   `json:"number"`
+	Start         hexutil.Uint64   `json:"start"`
+	End           hexutil.Uint64   `json:"end"`
+	BlockInterval hexutil.Uint64   `json:"blockInterval"`
+	Validators    []common.Address `json:"validators"`
+	// Schedule lists the slots after the block up to one full rotation of the
+	// validators, the rotation repeats itself until the end of the epoch.
+	Schedule []*Slot `json:"schedule"`
+}
+
+// GetEpoch retrieves the epoch and slot schedule following specified block
+func (api *API) GetEpoch(number *rpc.BlockNumber) (*EpochInfo, error) {
+	header, err := api.header(number)
+	if err != nil {
+		return nil, err
+	}
+	return api.epoch(header)
+}
+
+// GetEpochAtHash retrieves the epoch and slot schedule following specified block
+func (api *API) GetEpochAtHash(hash common.Hash) (*EpochInfo, error) {
+	header, err := api.headerAtHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return api.epoch(header)
+}
+
+func (api *API) epoch(header *types.Header) (*EpochInfo, error) {
+	dposContext, err := api.dposContext(header)
+	if err != nil {
+		return nil, err
+	}
+	validators, err := dposContext.GetValidators()
+	if err != nil {
+		return nil, err
+	}
+	config, err := api.dpos.ConfigAt(header.Number, dposContext)
+	if err != nil {
+		return nil, err
+	}
+	start := header.Time.Uint64() / config.Epoch * config.Epoch
+	info := &EpochInfo{
+		Number:        hexutil.Uint64(EpochNumber(dposContext, header.Time.Int64(), config)),
+		Start:         hexutil.Uint64(start),
+		End:           hexutil.Uint64(start + config.Epoch),
+		BlockInterval: hexutil.Uint64(config.BlockInterval),
+		Validators:    validators,
+		Schedule:      []*Slot{},
+	}
+	epochContext := &EpochContext{DposContext: dposContext}
+	slot := NextSlot(header.Time.Int64()+1, config.BlockInterval)
+	for i := 0; i < len(validators) && slot < int64(info.End); i++ {
+		validator, err := epochContext.lookupValidator(slot, config)
 		if err != nil {
 			return nil, err
 		}
+		info.Schedule = append(info.Schedule, &Slot{Time: hexutil.Uint64(slot), Validator: validator})
+		slot += int64(config.BlockInterval)
+	}
+	return info, nil
+}
+
+// Rewards are the block rewards accrued by a delegator and withdrawn so far.
+type Rewards struct {
+	Accrued   *hexutil.Big `json:"accrued"`
+	Withdrawn *hexutil.Big `json:"withdrawn"`
+}
+
+// GetRewards retrieves the block rewards of a delegator at specified block
+func (api *API) GetRewards(address common.Address, number *rpc.BlockNumber) (*Rewards, error) {
+	header, err := api.header(number)
+	if err != nil {
+		return nil, err
+	}
+	return api.rewards(address, header)
+}
+
+// GetRewardsAtHash retrieves the block rewards of a delegator at specified block
+func (api *API) GetRewardsAtHash(address common.Address, hash common.Hash) (*Rewards, error) {
+	header, err := api.headerAtHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return api.rewards(address, header)
+}
+
+func (api *API) rewards(address common.Address, header *types.Header) (*Rewards, error) {
+	dposContext, err := api.dposContext(header)
+	if err != nil {
+		return nil, err
+	}
+	accrued, withdrawn, err := dposContext.Rewards(address)
+	if err != nil {
+		return nil, err
+	}
+	return &Rewards{Accrued: (*hexutil.Big)(accrued), Withdrawn: (*hexutil.Big)(withdrawn)}, nil
+}
+
+// CandidateInfo is the commission and description a candidate registered with.
+type CandidateInfo struct {
+	Name       string         `json:"name"`
+	Website    string         `json:"website"`
+	Enode      string         `json:"enode"`
+	Commission hexutil.Uint64 `json:"commission"`
+}
+
+// GetCandidateInfo retrieves the commission and description of a candidate at
+// specified block
+func (api *API) GetCandidateInfo(candidate common.Address, number *rpc.BlockNumber) (*CandidateInfo, error) {
+	header, err := api.header(number)
+	if err != nil {
+		return nil, err
+	}
+	return api.candidateInfo(candidate, header)
+}
+
+// GetCandidateInfoAtHash retrieves the commission and description of a
+// candidate at specified block
+func (api *API) GetCandidateInfoAtHash(candidate common.Address, hash common.Hash) (*CandidateInfo, error) {
+	header, err := api.headerAtHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return api.candidateInfo(candidate, header)
+}
+
+func (api *API) candidateInfo(candidate common.Address, header *types.Header) (*CandidateInfo, error) {
+	dposContext, err := api.dposContext(header)
+	if err != nil {
+		return nil, err
+	}
+	info, err := dposContext.CandidateInfo(candidate)
+	if err != nil {
+		return nil, err
+	}
+	commission, err := dposContext.Commission(candidate)
+	if err != nil {
+		return nil, err
+	}
+	return &CandidateInfo{
+		Name:       info.Name,
+		Website:    info.Website,
+		Enode:      info.Enode,
+		Commission: hexutil.Uint64(commission),
+	}, nil
+}
+
+// Params are the DPoS parameters in effect, possibly changed through governance.
+type Params struct {
+	MaxValidatorSize hexutil.Uint64 `json:"maxValidatorSize"`
+	BlockInterval    hexutil.Uint64 `json:"blockInterval"`
+	KickoutThreshold hexutil.Uint64 `json:"kickoutThreshold"`
+	BlockReward      *hexutil.Big   `json:"blockReward"`
+}
+
+// GetParams retrieves the DPoS parameters in effect for the block following
+// specified block
+func (api *API) GetParams(number *rpc.BlockNumber) (*Params, error) {
+	header, err := api.header(number)
+	if err != nil {
+		return nil, err
+	}
+	return api.params(header)
+}
+
+// GetParamsAtHash retrieves the DPoS parameters in effect for the block
+// following specified block
+func (api *API) GetParamsAtHash(hash common.Hash) (*Params, error) {
+	header, err := api.headerAtHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return api.params(header)
+}
+
+func (api *API) params(header *types.Header) (*Params, error) {
+	dposContext, err := api.dposContext(header)
+	if err != nil {
+		return nil, err
+	}
+	config, err := api.dpos.ConfigAt(header.Number, dposContext)
+	if err != nil {
+		return nil, err
+	}
+	return &Params{
+		MaxValidatorSize: hexutil.Uint64(config.MaxValidatorSize),
+		BlockInterval:    hexutil.Uint64(config.BlockInterval),
+		KickoutThreshold: hexutil.Uint64(config.KickoutThreshold),
+		BlockReward:      (*hexutil.Big)(config.BlockReward),
+	}, nil
+}
+
+// GetConfirmedBlockNumber retrieves the latest irreversible block
+func (api *API) GetConfirmedBlockNumber() (*big.Int, error) {
+	header, err := api.dpos.confirmedHeader(api.chain)
+	if err != nil {
+		return nil, err
 	}
 	return header.Number, nil
 }
-func (ec *EpochContext) tryElect(genesis, parent *types.Header) error {
 
-	genesisEpoch := genesis.Time.Int64() / epochInterval   //genesisEpoch is 0
-	prevEpoch := parent.Time.Int64() / epochInterval
-	currentEpoch := ec.TimeStamp / epochInterval
+// FinalizedBlock is a block with a quorum certificate and the validators that
+// pre-committed it.
+type FinalizedBlock struct {
+	Number  hexutil.Uint64   `json:"number"`
+	Hash    common.Hash      `json:"hash"`
+	Signers []common.Address `json:"signers"`
+}
+
+// GetFinalizedBlock retrieves the latest block with a quorum certificate
+func (api *API) GetFinalizedBlock() (*FinalizedBlock, error) {
+	header := api.dpos.FinalizedHeader(api.chain)
+	if header == nil {
+		return nil, errNoFinalizedBlock
+	}
+	block := &FinalizedBlock{Number: hexutil.Uint64(header.Number.Uint64()), Hash: header.Hash()}
+	if cert := api.dpos.QuorumCert(block.Hash, uint64(block.Number)); cert != nil {
+		for _, vote := range cert.PreCommits() {
+			signer, err := recoverPreCommit(vote)
+			if err != nil {
+				return nil, err
+			}
+			block.Signers = append(block.Signers, signer)
+		}
+	}
+	return block, nil
+}
+
+// GetCheckpoint retrieves the specified block as a checkpoint other nodes can be
+// configured to sync from, preferably a finalized one
+func (api *API) GetCheckpoint(number *rpc.BlockNumber) (*types.DposCheckpoint, error) {
+	header, err := api.header(number)
+	if err != nil {
+		return nil, err
+	}
+	return &types.DposCheckpoint{
+		Number:      header.Number.Uint64(),
+		Hash:        header.Hash(),
+		DposContext: header.DposContext,
+	}, nil
+}
+
+func (ec *EpochContext) tryElect(genesis, parent *types.Header, parentConfig, config *params.DposConfig) error {
+	genesisEpoch := genesis.Time.Int64() / int64(parentConfig.Epoch) //genesisEpoch is 0
+	// A fork changing the epoch length starts a new epoch, numbered after the
+	// one of the parent, so exactly one election happens at the fork
+	prevEpoch, currentEpoch, offset := EpochNumbers(ec.DposContext, parent.Time.Int64(), ec.TimeStamp, parentConfig, config)
 
 	prevEpochIsGenesis := prevEpoch == genesisEpoch  		// bool type
 	if prevEpochIsGenesis && prevEpoch < currentEpoch {
 		prevEpoch = currentEpoch - 1
 	}
+	elections := currentEpoch - prevEpoch
 
 	prevEpochBytes := make([]byte, 8)
 	binary.BigEndian.PutUint64(prevEpochBytes, uint64(prevEpoch))
 	iter := trie.NewIterator(ec.DposContext.MintCntTrie().PrefixIterator(prevEpochBytes))
-	from_genesis_maxsize :=  genesis.MaxValidatorSize
-	fmt.Print("+++++++++++++++++++6666666666666666666++++++++++++++++++++++++++++\n")
-	fmt.Print(from_genesis_maxsize)
 	// 根据当前块和上一块的时间计算当前块和上一块是否属于同一个周期，
 	// 如果是同一个周期，意味着当前块不是周期的第一块，不需要触发选举
 	// 如果不是同一周期，说明当前块是该周期的第一块，则触发选举
-	fmt.Print("+++++++++++++++++++8888888++++++++++++++++++++++++++++\n")
-	fmt.Print("Genesis init get maxvalidatorsize to kickoutValidator")
-	for i := prevEpoch; i < currentEpoch; i++ {
+	for i := currentEpoch - elections; i < currentEpoch; i++ {
 		// if prevEpoch is not genesis, kickout not active candidate
 		// 如果前一个周期不是创世周期，触发踢出候选人规则
 		// 踢出规则主要是看上一周期是否存在候选人出块少于特定阈值(50%), 如果存在则踢出
 		if !prevEpochIsGenesis && iter.Next() {
-			if err := ec.kickoutValidator(prevEpoch,genesis); err != nil {
+			if err := ec.kickoutValidator(prevEpoch, parentConfig); err != nil {
 				return err
 			}
 		}
@@ -116,15 +580,13 @@ func (ec *EpochContext) tryElect(genesis, parent *types.Header) error {
 		if err != nil {
 			return err
 		}
-		//add
-		maxValidatorSize := int(genesis.MaxValidatorSize)
-		safeSize := maxValidatorSize*2/3+1
+		maxValidatorSize := int(config.MaxValidatorSize)
+		safeSize := config.SafeSize()
 		candidates := sortableAddresses{}
 		for candidate, cnt := range votes {
 			candidates = append(candidates, &sortableAddress{candidate, cnt})
 		}
 		if len(candidates) < safeSize {
-			//fmt.Print("whteaaa!!!!!",safeSize)
 			return errors.New("too few candidates")
 		}
 		sort.Sort(candidates)
@@ -133,9 +595,9 @@ func (ec *EpochContext) tryElect(genesis, parent *types.Header) error {
 		}
 
 		// shuffle candidates
-		// 打乱验证人列表，由于使用 seed 是由父块的 hash 以及当前周期编号组成，
+		// 打乱验证人列表，由于使用 seed 是由 randao 随机数(或父块的 hash)以及当前周期编号组成，
 		// 所以每个节点计算出来的验证人列表也会一致
-		seed := int64(binary.LittleEndian.Uint32(crypto.Keccak512(parent.Hash().Bytes()))) + i
+		seed := electionSeed(ec.DposContext, parent, i)
 		r := rand.New(rand.NewSource(seed))
 		for i := len(candidates) - 1; i > 0; i-- {
 			j := int(r.Int31n(int32(i + 1)))
@@ -149,11 +611,17 @@ func (ec *EpochContext) tryElect(genesis, parent *types.Header) error {
 		epochTrie, _ := types.NewEpochTrie(common.Hash{}, ec.DposContext.DB())
 		ec.DposContext.SetEpoch(epochTrie)
 		ec.DposContext.SetValidators(sortedValidators)
+		if err := ec.DposContext.SetEpochOffset(offset); err != nil {
+			return err
+		}
 		log.Info("Come to new epoch", "prevEpoch", i, "nextEpoch", i+1)
 	}
+	// The kickout only looks at the previous epoch, older counters are kept for
+	// the horizon to serve the API and then dropped from the state
+	if horizon := int64(config.MintCntHorizon); elections > 0 && horizon > 0 && currentEpoch > horizon {
+		if err := ec.DposContext.PruneMintCounts(currentEpoch - horizon); err != nil {
+			return err
+		}
+	}
 	return nil
 }
-
-
-
-
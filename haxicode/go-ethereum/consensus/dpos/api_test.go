@@ -0,0 +1,130 @@
+package dpos
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/common/hexutil"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/ethdb"
+	"github.com/haxicode/go-ethereum/params"
+	"github.com/haxicode/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// testChain is a chain of headers implementing consensus.ChainReader.
+type testChain struct {
+	config  *params.ChainConfig
+	headers []*types.Header
+	side    []*types.Header // Headers off the canonical chain
+}
+
+func (c *testChain) Config() *params.ChainConfig  { return c.config }
+func (c *testChain) CurrentHeader() *types.Header { return c.headers[len(c.headers)-1] }
+func (c *testChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := c.GetHeaderByNumber(number); header != nil && header.Hash() == hash {
+		return header
+	}
+	for _, header := range c.side {
+		if header.Hash() == hash {
+			return header
+		}
+	}
+	return nil
+}
+func (c *testChain) GetHeaderByNumber(number uint64) *types.Header {
+	if number < uint64(len(c.headers)) {
+		return c.headers[number]
+	}
+	return nil
+}
+func (c *testChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	for _, header := range c.headers {
+		if header.Hash() == hash {
+			return header
+		}
+	}
+	return nil
+}
+func (c *testChain) GetBlock(hash common.Hash, number uint64) *types.Block { return nil }
+
+func TestAPI(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	dposContext := mockNewDposContext(db)
+	candidate := common.HexToAddress(MockEpoch[0])
+	delegator := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	assert.Nil(t, dposContext.Delegate(delegator, candidate))
+	assert.Nil(t, dposContext.LockDelegateDeposit(delegator, big.NewInt(5)))
+	setMintCntTrie(2, candidate, dposContext.MintCntTrie(), 3)
+	setMintCntTrie(3, candidate, dposContext.MintCntTrie(), 1)
+	assert.Nil(t, dposContext.AddMissedCount(2, candidate, 1))
+	proto, err := dposContext.Commit()
+	assert.Nil(t, err)
+
+	genesis := &types.Header{Number: big.NewInt(0), Time: big.NewInt(0), DposContext: proto}
+	// the second block is in epoch 2, its slot is the 8th of the epoch
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(2*epochInterval + 7*blockInterval), DposContext: proto}
+	chain := &testChain{
+		config:  &params.ChainConfig{Dpos: testDposConfig},
+		headers: []*types.Header{genesis, header},
+	}
+	engine := New(testDposConfig, db)
+	engine.SetTrieDB(dposContext.DB())
+	api := &API{chain: chain, dpos: engine}
+	latest := rpc.LatestBlockNumber
+
+	candidates, err := api.GetCandidates(&latest)
+	assert.Nil(t, err)
+	assert.Equal(t, len(MockEpoch), len(candidates))
+
+	delegators, err := api.GetDelegatorsAtHash(candidate, header.Hash())
+	assert.Nil(t, err)
+	assert.Equal(t, []common.Address{delegator, candidate}, delegators)
+
+	vote, err := api.GetVote(delegator, &latest)
+	assert.Nil(t, err)
+	assert.Equal(t, candidate, *vote)
+	vote, err = api.GetVote(common.Address{}, &latest)
+	assert.Nil(t, err)
+	assert.Nil(t, vote)
+
+	tally, err := api.GetVoteTally(&latest)
+	assert.Nil(t, err)
+	assert.Equal(t, len(MockEpoch), len(tally))
+	assert.Equal(t, int64(5), tally[candidate].ToInt().Int64())
+
+	counts, err := api.GetMintCounts(nil, &latest)
+	assert.Nil(t, err)
+	assert.Equal(t, map[common.Address]hexutil.Uint64{candidate: 3}, counts)
+	epoch := hexutil.Uint64(3)
+	counts, err = api.GetMintCountsAtHash(&epoch, header.Hash())
+	assert.Nil(t, err)
+	assert.Equal(t, map[common.Address]hexutil.Uint64{candidate: 1}, counts)
+
+	stats, err := api.GetValidatorStats(nil, &latest)
+	assert.Nil(t, err)
+	assert.Equal(t, map[common.Address]*ValidatorStats{candidate: {Produced: 3, Missed: 1, Uptime: 0.75}}, stats)
+	stats, err = api.GetValidatorStatsAtHash(&epoch, header.Hash())
+	assert.Nil(t, err)
+	assert.Equal(t, map[common.Address]*ValidatorStats{candidate: {Produced: 1, Uptime: 1}}, stats)
+
+	info, err := api.GetEpoch(&latest)
+	assert.Nil(t, err)
+	assert.Equal(t, hexutil.Uint64(2), info.Number)
+	assert.Equal(t, hexutil.Uint64(2*epochInterval), info.Start)
+	assert.Equal(t, hexutil.Uint64(3*epochInterval), info.End)
+	assert.Equal(t, maxValidatorSize, len(info.Validators))
+	assert.Equal(t, maxValidatorSize, len(info.Schedule))
+	for i, slot := range info.Schedule {
+		assert.Equal(t, hexutil.Uint64(2*epochInterval+int64(8+i)*blockInterval), slot.Time)
+		assert.Equal(t, info.Validators[(8+i)%maxValidatorSize], slot.Validator)
+	}
+
+	checkpoint, err := api.GetCheckpoint(&latest)
+	assert.Nil(t, err)
+	assert.Equal(t, &types.DposCheckpoint{Number: 1, Hash: header.Hash(), DposContext: proto}, checkpoint)
+
+	_, err = api.GetCandidatesAtHash(common.Hash{})
+	assert.Equal(t, errUnknownBlock, err)
+}
@@ -0,0 +1,190 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/consensus"
+	"github.com/haxicode/go-ethereum/core/rawdb"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/log"
+)
+
+// confirmedBlockPrefix + genesis hash -> hash of the latest block of that chain
+// confirmed by the safe number of validators.
+var confirmedBlockPrefix = []byte("dpos-confirmed-block-")
+
+// chainState is the engine state derived from the headers of a chain. The
+// engine holds one per genesis hash, so that the chains of a process don't see
+// each other's state. Nothing in it depends on the order the headers were
+// processed in: it is rebuilt from the database and the headers of the chain the
+// first time the engine sees the chain, which brings a restarted engine to the
+// state of one that ran all along.
+type chainState struct {
+	genesis   common.Hash
+	confirmed *types.Header // Latest block confirmed by the safe number of validators
+	finalized *types.Header // Latest block with a quorum certificate, nil if none
+}
+
+// chainState returns the state of the chain, rebuilding it on first use. The
+// caller must hold the engine lock.
+func (d *Dpos) chainState(chain consensus.ChainReader) (*chainState, error) {
+	genesis := chain.GetHeaderByNumber(0)
+	if genesis == nil {
+		return nil, ErrNilBlockHeader
+	}
+	if s, ok := d.chains[genesis.Hash()]; ok {
+		return s, nil
+	}
+	s := &chainState{genesis: genesis.Hash(), confirmed: genesis}
+	if header := d.loadConfirmedBlockHeader(chain, s.genesis); header != nil {
+		s.confirmed = header
+	}
+	// Catch up with the headers written while the engine wasn't running
+	if err := d.confirmBlocks(chain, s); err != nil {
+		log.Debug("Failed to confirm DPoS blocks", "err", err)
+	}
+	d.chains[s.genesis] = s
+	return s, nil
+}
+
+// updateConfirmedBlockHeader moves the confirmed block of the chain up to the
+// latest one the safe number of validators built on. The caller must hold the
+// engine lock.
+func (d *Dpos) updateConfirmedBlockHeader(chain consensus.ChainReader) error {
+	s, err := d.chainState(chain)
+	if err != nil {
+		return err
+	}
+	return d.confirmBlocks(chain, s)
+}
+
+// confirmBlocks walks the chain down from its head to the confirmed block, and
+// confirms the first block which the safe number of validators sealed blocks
+// on top of within its epoch.
+func (d *Dpos) confirmBlocks(chain consensus.ChainReader, s *chainState) error {
+	curHeader := chain.CurrentHeader()
+
+	epoch, epochLength := int64(-1), uint64(0)
+	validatorMap := make(map[common.Address]bool)
+	for s.confirmed.Hash() != curHeader.Hash() &&
+		s.confirmed.Number.Uint64() < curHeader.Number.Uint64() {
+		config, err := d.headerConfig(curHeader)
+		if err != nil {
+			return err
+		}
+		curEpoch := curHeader.Time.Int64() / int64(config.Epoch)
+		if curEpoch != epoch || config.Epoch != epochLength {
+			epoch, epochLength = curEpoch, config.Epoch
+			validatorMap = make(map[common.Address]bool)
+		}
+		// fast return
+		// if block number difference less consensusSize-witnessNum
+		// there is no need to check block is confirmed
+		consensusSize := config.SafeSize()
+		if curHeader.Number.Int64()-s.confirmed.Number.Int64() < int64(consensusSize-len(validatorMap)) {
+			log.Debug("Dpos fast return", "current", curHeader.Number.String(), "confirmed", s.confirmed.Number.String(), "witnessCount", len(validatorMap))
+			return nil
+		}
+		validatorMap[curHeader.Validator] = true
+		if len(validatorMap) >= consensusSize {
+			s.confirmed = curHeader
+			if err := d.storeConfirmedBlockHeader(s); err != nil {
+				return err
+			}
+			log.Debug("dpos set confirmed block header success", "currentHeader", curHeader.Number.String())
+			return nil
+		}
+		parent := chain.GetHeaderByHash(curHeader.ParentHash)
+		if parent == nil {
+			// A chain synced from a trusted checkpoint has nothing below it, the
+			// checkpoint counts as confirmed in place of the genesis block
+			if s.confirmed.Number.Sign() != 0 {
+				return ErrNilBlockHeader
+			}
+			s.confirmed = curHeader
+			return d.storeConfirmedBlockHeader(s)
+		}
+		curHeader = parent
+	}
+	return nil
+}
+
+// loadConfirmedBlockHeader returns the confirmed block of the chain stored in
+// the database, nil if there is none or the chain doesn't have its header.
+func (d *Dpos) loadConfirmedBlockHeader(chain consensus.ChainReader, genesis common.Hash) *types.Header {
+	hash, err := d.db.Get(append(confirmedBlockPrefix, genesis.Bytes()...))
+	if err != nil {
+		return nil
+	}
+	return chain.GetHeaderByHash(common.BytesToHash(hash))
+}
+
+// storeConfirmedBlockHeader writes the confirmed block of the chain into the
+// database.
+func (d *Dpos) storeConfirmedBlockHeader(s *chainState) error {
+	return d.db.Put(append(confirmedBlockPrefix, s.genesis.Bytes()...), s.confirmed.Hash().Bytes())
+}
+
+// finalizedHeader returns the latest finalized block of the chain, loading it
+// from the database while it isn't known. The caller must hold the engine lock.
+func (d *Dpos) finalizedHeader(chain consensus.ChainReader, s *chainState) *types.Header {
+	if s.finalized == nil {
+		if hash := rawdb.ReadFinalizedBlockHash(d.db, s.genesis); hash != (common.Hash{}) {
+			s.finalized = chain.GetHeaderByHash(hash)
+		}
+	}
+	return s.finalized
+}
+
+// confirmedHeader returns the latest block of the chain confirmed by the safe
+// number of validators, up to date with the head of the chain. Blocks sealed
+// locally are never verified, so the head may be ahead of the last update.
+func (d *Dpos) confirmedHeader(chain consensus.ChainReader) (*types.Header, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, err := d.chainState(chain)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.confirmBlocks(chain, s); err != nil {
+		return nil, err
+	}
+	return s.confirmed, nil
+}
+
+// IrreversibleHeader implements consensus.Irreversible, returning the highest
+// of the block confirmed by the safe number of validators and the block
+// finalized by a quorum of pre-commits.
+func (d *Dpos) IrreversibleHeader(chain consensus.ChainReader) *types.Header {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, err := d.chainState(chain)
+	if err != nil {
+		return nil
+	}
+	if err := d.confirmBlocks(chain, s); err != nil {
+		log.Debug("Failed to confirm DPoS blocks", "err", err)
+	}
+	confirmed, finalized := s.confirmed, d.finalizedHeader(chain, s)
+	if finalized != nil && finalized.Number.Cmp(confirmed.Number) > 0 {
+		return finalized
+	}
+	return confirmed
+}
@@ -0,0 +1,114 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/ethdb"
+	"github.com/haxicode/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+)
+
+// newConfirmChain returns a chain of n blocks sealed in turn by 16 validators,
+// whose second epoch starts at block 31.
+func newConfirmChain(genesisTime int64, n int) *testChain {
+	genesis := &types.Header{Number: big.NewInt(0), Time: big.NewInt(genesisTime)}
+	headers := []*types.Header{genesis}
+	for i := 1; i <= n; i++ {
+		time := genesisTime + int64(i)*blockInterval
+		if i > 30 {
+			time += epochInterval
+		}
+		headers = append(headers, &types.Header{
+			ParentHash:       headers[i-1].Hash(),
+			Number:           big.NewInt(int64(i)),
+			Time:             big.NewInt(time),
+			Validator:        common.BigToAddress(big.NewInt(int64(i%16 + 1))),
+			MaxValidatorSize: maxValidatorSize,
+			BlockInterval:    uint64(blockInterval),
+		})
+	}
+	return &testChain{config: &params.ChainConfig{Dpos: testDposConfig}, headers: headers}
+}
+
+func TestConfirmedBlockRestart(t *testing.T) {
+	full := newConfirmChain(0, 80)
+	running := New(testDposConfig, ethdb.NewMemDatabase())
+	defer running.Close()
+
+	// The engine is restarted in the middle of the second epoch
+	db := ethdb.NewMemDatabase()
+	engine := New(testDposConfig, db)
+	for number := 1; number <= 80; number++ {
+		chain := &testChain{config: full.config, headers: full.headers[:number+1]}
+		if number == 50 {
+			engine.Close()
+			engine = New(testDposConfig, db)
+		}
+		running.mu.Lock()
+		assert.Nil(t, running.updateConfirmedBlockHeader(chain))
+		running.mu.Unlock()
+		engine.mu.Lock()
+		assert.Nil(t, engine.updateConfirmedBlockHeader(chain))
+		engine.mu.Unlock()
+
+		want, err := running.confirmedHeader(chain)
+		assert.Nil(t, err)
+		have, err := engine.confirmedHeader(chain)
+		assert.Nil(t, err)
+		assert.Equal(t, want.Hash(), have.Hash(), "block %d", number)
+	}
+	engine.Close()
+
+	// The 15 validators needed sealed blocks 66 to 80
+	confirmed, err := running.confirmedHeader(full)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(66), confirmed.Number.Uint64())
+
+	// An engine without any state of the chain rebuilds it from the headers
+	rebuilt := New(testDposConfig, ethdb.NewMemDatabase())
+	defer rebuilt.Close()
+	assert.Equal(t, confirmed.Hash(), rebuilt.IrreversibleHeader(full).Hash())
+}
+
+func TestConfirmedBlockPerChain(t *testing.T) {
+	first, second := newConfirmChain(0, 80), newConfirmChain(1, 40)
+	db := ethdb.NewMemDatabase()
+	engine := New(testDposConfig, db)
+	defer engine.Close()
+
+	engine.mu.Lock()
+	assert.Nil(t, engine.updateConfirmedBlockHeader(first))
+	assert.Nil(t, engine.updateConfirmedBlockHeader(second))
+	engine.mu.Unlock()
+
+	// The second epoch of the shorter chain is too short to confirm any of its blocks
+	confirmed, err := engine.confirmedHeader(first)
+	assert.Nil(t, err)
+	assert.Equal(t, first.headers[66].Hash(), confirmed.Hash())
+	confirmed, err = engine.confirmedHeader(second)
+	assert.Nil(t, err)
+	assert.Equal(t, second.headers[16].Hash(), confirmed.Hash())
+
+	// Both survive a restart
+	assert.Equal(t, first.headers[66].Hash(), engine.loadConfirmedBlockHeader(first, first.headers[0].Hash()).Hash())
+	assert.Equal(t, second.headers[16].Hash(), engine.loadConfirmedBlockHeader(second, second.headers[0].Hash()).Hash())
+}
@@ -0,0 +1,53 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import "time"
+
+// Clock is the wall clock slots are measured against. It is the system clock
+// unless replaced, so that simulations can run many epochs without sleeping.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock implements Clock using the system clock.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SetClock replaces the clock the engine checks and seals blocks against.
+func (d *Dpos) SetClock(clock Clock) {
+	d.mu.Lock()
+	d.clock = clock
+	d.mu.Unlock()
+}
+
+// now returns the current time of the engine clock.
+func (d *Dpos) now() time.Time {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.clock.Now()
+}
+
+// after waits for the duration to elapse on the engine clock.
+func (d *Dpos) after(delay time.Duration) <-chan time.Time {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.clock.After(delay)
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/haxicode/go-ethereum/crypto"
 	"github.com/haxicode/go-ethereum/crypto/sha3"
 	"github.com/haxicode/go-ethereum/ethdb"
+	"github.com/haxicode/go-ethereum/event"
 	"github.com/haxicode/go-ethereum/log"
 	"github.com/haxicode/go-ethereum/params"
 	"github.com/haxicode/go-ethereum/rlp"
@@ -31,12 +32,6 @@ const (
 	extraVanity        = 32   // Fixed number of extra-data prefix bytes reserved for signer vanity
 	extraSeal          = 65   // Fixed number of extra-data suffix bytes reserved for signer seal
 	inmemorySignatures = 4096 // Number of recent block signatures to keep in memory
-
-	//blockInterval    = int64(10)  	//出块间隔
-	epochInterval    = int64(86400)  //选举周期间隔24 *60*60 s
-	//maxValidatorSize = 21
-	//safeSize         =  15	//maxValidatorSize*2/3 + 1
-	//consensusSize    =  15 	//maxValidatorSize*2/3 + 1
 )
 
 
@@ -48,10 +43,6 @@ var (
 
 	frontierBlockReward  *big.Int = big.NewInt(5e+18) // Block reward in wei for successfully mining a block
 	byzantiumBlockReward *big.Int = big.NewInt(3e+18) // Block reward in wei for successfully mining a block upward from Byzantium
-
-	timeOfFirstBlock = int64(0)
-
-	confirmedBlockHead = []byte("confirmed-block-head")
 )
 
 var (
@@ -69,6 +60,9 @@ var (
 	// errInvalidUncleHash is returned if a block contains an non-empty uncle list.
 	errInvalidUncleHash  = errors.New("non empty uncle hash")
 	errInvalidDifficulty = errors.New("invalid difficulty")
+	// errInvalidDposParams is returned if the validator size or block interval
+	// recorded in a header differ from the params in effect for it.
+	errInvalidDposParams = errors.New("invalid dpos parameters")
 
 	// ErrInvalidTimestamp is returned if the timestamp of a block is lower than
 	// the previous block's timestamp + the minimum block period.
@@ -87,11 +81,23 @@ var (
 type Dpos struct {
 	config *params.DposConfig // Consensus engine configuration parameters
 	db      ethdb.Database     // Database to store and retrieve snapshot checkpoints
+	trieDB  *trie.Database     // Trie database the chain writes the DPoS tries to, nil if not set
+
+	signer     common.Address
+	signFn     SignerFn
+	signatures *lru.ARCCache                // Signatures of recent blocks to speed up mining
+	chains     map[common.Hash]*chainState // State derived from the headers of each chain, by genesis hash
+	leadership Leadership                  // Leader election among nodes sharing the signer, nil if not shared
+	clock      Clock                       // Wall clock slots are measured against
+
+	slotHeaders  *lru.ARCCache // Recently seen headers by signer and slot, to catch double signing
+	evidenceFeed event.Feed
 
-	signer               common.Address
-	signFn               SignerFn
-	signatures           *lru.ARCCache // Signatures of recent blocks to speed up mining
-	confirmedBlockHeader *types.Header
+	preCommits    *lru.ARCCache // Pre-commits gathered for recent blocks, by block hash
+	futureVotes   *lru.ARCCache // Pre-commits for blocks not known yet, by block hash
+	finalizedFeed event.Feed
+
+	scope event.SubscriptionScope
 
 	mu   sync.RWMutex
 	stop chan bool
@@ -137,11 +143,19 @@ func sigHash(header *types.Header) (hash common.Hash) {
 
 func New(config *params.DposConfig, db ethdb.Database) *Dpos {
 	signatures, _ := lru.NewARC(inmemorySignatures)
+	slotHeaders, _ := lru.NewARC(inmemorySignatures)
+	preCommits, _ := lru.NewARC(inmemoryPreCommits)
+	futureVotes, _ := lru.NewARC(inmemoryPreCommits)
 
 	return &Dpos{
-		config:     config,
-		db:         db,
-		signatures: signatures,
+		config:      config,
+		db:          db,
+		signatures:  signatures,
+		chains:      make(map[common.Hash]*chainState),
+		slotHeaders: slotHeaders,
+		preCommits:  preCommits,
+		futureVotes: futureVotes,
+		clock:       systemClock{},
 	}
 }
 
@@ -149,18 +163,44 @@ func (d *Dpos) Author(header *types.Header) (common.Address, error) {
 	return header.Validator, nil
 }
 
+// SetTrieDB implements consensus.TrieBacked. The chain sets it once on creation,
+// before the engine verifies or seals any block.
+func (d *Dpos) SetTrieDB(db *trie.Database) {
+	d.trieDB = db
+}
+
+// trieDatabase returns the trie database the DPoS tries of the chain are held
+// in. Without a chain, the tries are read from the disk database.
+func (d *Dpos) trieDatabase() *trie.Database {
+	if d.trieDB != nil {
+		return d.trieDB
+	}
+	return trie.NewDatabase(d.db)
+}
+
+// Config returns the DPoS parameters in effect at the given block number.
+func (d *Dpos) Config(number *big.Int) *params.DposConfig {
+	return d.config.At(number)
+}
+
 //Verify that the bulk complies with the consensus algorithm rules
-func (d *Dpos) VerifyHeader(chain consensus.ChainReader, header *types.Header, seal bool, blockInterval uint64) error {
-	return d.verifyHeader(chain, header, nil, blockInterval)
+func (d *Dpos) VerifyHeader(chain consensus.ChainReader, header *types.Header, seal bool) error {
+	return d.verifyHeader(chain, header, nil)
 }
 
-func (d *Dpos) verifyHeader(chain consensus.ChainReader, header *types.Header, parents []*types.Header,blockInterval uint64 ) error {
+func (d *Dpos) verifyHeader(chain consensus.ChainReader, header *types.Header, parents []*types.Header) error {
 	if header.Number == nil {
 		return errUnknownBlock
 	}
 	number := header.Number.Uint64()
+	// The params in effect depend on the parent state, Finalize checks that the
+	// header recorded them
+	config, err := d.headerConfig(header)
+	if err != nil {
+		return err
+	}
 	// Unnecssary to verify the block from feature
-	if header.Time.Cmp(big.NewInt(time.Now().Unix())) > 0 {
+	if header.Time.Cmp(big.NewInt(d.now().Unix())) > 0 {
 		return consensus.ErrFutureBlock
 	}
 	// Check that the extra-data contains both the vanity and signature
@@ -170,6 +210,9 @@ func (d *Dpos) verifyHeader(chain consensus.ChainReader, header *types.Header, p
 	if len(header.Extra) < extraVanity+extraSeal {
 		return errMissingSignature
 	}
+	if err := verifyRandaoSection(config, header); err != nil {
+		return err
+	}
 	// Ensure that the mix digest is zero as we don't have fork protection currently
 	if header.MixDigest != (common.Hash{}) {
 		return errInvalidMixDigest
@@ -199,7 +242,7 @@ func (d *Dpos) verifyHeader(chain consensus.ChainReader, header *types.Header, p
 	if parent == nil || parent.Number.Uint64() != number-1 || parent.Hash() != header.ParentHash {
 		return consensus.ErrUnknownAncestor
 	}
-	if parent.Time.Uint64()+blockInterval> header.Time.Uint64() {
+	if parent.Time.Uint64()+config.BlockInterval > header.Time.Uint64() {
 		return ErrInvalidTimestamp
 	}
 	return nil
@@ -209,12 +252,11 @@ func (d *Dpos) verifyHeader(chain consensus.ChainReader, header *types.Header, p
 func (d *Dpos) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, seals []bool,) (chan<- struct{}, <-chan error) {
 	abort := make(chan struct{})
 	results := make(chan error, len(headers))
-	blockInterval := chain.GetHeaderByNumber(0).BlockInterval
 
 	go func() {
 		for i, header := range headers {
 			//header.Extra = make([]byte, extraVanity+extraSeal)
-			err := d.verifyHeader(chain, header, headers[:i],blockInterval)
+			err := d.verifyHeader(chain, header, headers[:i])
 			select {
 			case <-abort:
 				return
@@ -236,11 +278,11 @@ func (d *Dpos) VerifyUncles(chain consensus.ChainReader, block *types.Block) err
 
 // VerifySeal implements consensus.Engine, checking whether the signature contained
 // in the header satisfies the consensus protocol requirements.
-func (d *Dpos) VerifySeal(chain consensus.ChainReader, currentheader, genesisheader *types.Header) error {
-	return d.verifySeal(chain, currentheader, genesisheader,nil)
+func (d *Dpos) VerifySeal(chain consensus.ChainReader, currentheader *types.Header) error {
+	return d.verifySeal(chain, currentheader, nil)
 }
 
-func (d *Dpos) verifySeal(chain consensus.ChainReader, currentheader, genesisheader *types.Header, parents []*types.Header) error {
+func (d *Dpos) verifySeal(chain consensus.ChainReader, currentheader *types.Header, parents []*types.Header) error {
 	// Verifying the genesis block is not supported
 	number := currentheader.Number.Uint64()
 	if number == 0 {
@@ -253,105 +295,102 @@ func (d *Dpos) verifySeal(chain consensus.ChainReader, currentheader, genesishea
 		parent = chain.GetHeader(currentheader.ParentHash, number-1)
 	}
 
-	trieDB := trie.NewDatabase(d.db)
+	trieDB := d.trieDatabase()
 	dposContext, err := types.NewDposContextFromProto(trieDB, parent.DposContext) //todo nil
 
 	if err != nil {
 		return err
 	}
 	epochContext := &EpochContext{DposContext: dposContext}
-	blockInterVal := genesisheader.BlockInterval
-	validator, err := epochContext.lookupValidator(currentheader.Time.Int64(),blockInterVal)
+	// The header has to record the params in effect in the parent state
+	config, err := GovernedConfig(d.Config(currentheader.Number), dposContext)
+	if err != nil {
+		return err
+	}
+	if currentheader.MaxValidatorSize != config.MaxValidatorSize || currentheader.BlockInterval != config.BlockInterval {
+		return errInvalidDposParams
+	}
+	validator, err := epochContext.lookupValidator(currentheader.Time.Int64(), config)
+	if err != nil {
+		return err
+	}
+	signer, err := epochContext.lookupSigner(currentheader.Time.Int64(), config)
 	if err != nil {
 		return err
 	}
 	//出块者签名验证
-	if err := d.verifyBlockSigner(validator, currentheader); err != nil {
+	if err := d.verifyBlockSigner(validator, signer, currentheader); err != nil {
 		return err
 	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	return d.updateConfirmedBlockHeader(chain)
 }
 
-func (d *Dpos) verifyBlockSigner(validator common.Address, header *types.Header) error {
-	signer, err := ecrecover(header, d.signatures)
+// VerifySealWithValidators checks that header was signed by the validator
+// scheduled for its slot, given the validator set and their signing keys
+// recorded in the epoch trie of its parent. It is used by light clients, which
+// retrieve both through ODR instead of reading the DPoS tries from a local
+// database.
+func (d *Dpos) VerifySealWithValidators(header *types.Header, validators, signers []common.Address) error {
+	if header.Number.Uint64() == 0 {
+		return errUnknownBlock
+	}
+	config, err := d.headerConfig(header)
 	if err != nil {
 		return err
 	}
-	if bytes.Compare(signer.Bytes(), validator.Bytes()) != 0 {
-		return ErrInvalidBlockValidator
+	validator, err := validatorAt(validators, header.Time.Int64(), config)
+	if err != nil {
+		return err
 	}
-	if bytes.Compare(signer.Bytes(), header.Validator.Bytes()) != 0 {
-		return ErrMismatchSignerAndValidator
+	signer, err := validatorAt(signers, header.Time.Int64(), config)
+	if err != nil {
+		return err
 	}
-	return nil
+	return d.verifyBlockSigner(validator, signer, header)
 }
 
-func (d *Dpos) updateConfirmedBlockHeader(chain consensus.ChainReader) error {
-	if d.confirmedBlockHeader == nil {
-		header, err := d.loadConfirmedBlockHeader(chain)
-		if err != nil {
-			header = chain.GetHeaderByNumber(0)
-			if header == nil {
-				return err
-			}
-		}
-		d.confirmedBlockHeader = header
-	}
-
-	curHeader := chain.CurrentHeader()
-
-	fmt.Println("+++++++++++++++++++555555++++++++++++++++++++++\n")
-	genesisHeader := chain.GetHeaderByNumber(0)
-	fmt.Println("+++++++++++++++++++from genesisBlock to get Maxvalidatorsize++++++++++++++++++++++\n")
-	epoch := int64(-1)
-	validatorMap := make(map[common.Address]bool)
-	for d.confirmedBlockHeader.Hash() != curHeader.Hash() &&
-		d.confirmedBlockHeader.Number.Uint64() < curHeader.Number.Uint64() {
-		curEpoch := curHeader.Time.Int64() / epochInterval
-		if curEpoch != epoch {
-			epoch = curEpoch
-			validatorMap = make(map[common.Address]bool)
-		}
-		// fast return
-		// if block number difference less consensusSize-witnessNum
-		// there is no need to check block is confirmed
-		consensusSize :=int(genesisHeader.MaxValidatorSize*2/3+1)
-		if curHeader.Number.Int64()-d.confirmedBlockHeader.Number.Int64() < int64(consensusSize-len(validatorMap)) {
-			log.Debug("Dpos fast return", "current", curHeader.Number.String(), "confirmed", d.confirmedBlockHeader.Number.String(), "witnessCount", len(validatorMap))
-			return nil
-		}
-		validatorMap[curHeader.Validator] = true
-		if len(validatorMap) >= consensusSize {
-			d.confirmedBlockHeader = curHeader
-			if err := d.storeConfirmedBlockHeader(d.db); err != nil {
-				return err
-			}
-			log.Debug("dpos set confirmed block header success", "currentHeader", curHeader.Number.String())
-			return nil
-		}
-		curHeader = chain.GetHeaderByHash(curHeader.ParentHash)
-		if curHeader == nil {
-			return ErrNilBlockHeader
-		}
+// VerifyParamsChange checks the DPoS params recorded in a header against those
+// of its parent, for light clients without access to the parent state. Changes
+// approved through governance take effect with the election at the first block
+// of an epoch, so they are recorded from the block after it on, and forks
+// change the params at their block. Anywhere else the header has to keep the
+// params of its parent. The grandparent is nil if the parent is the genesis
+// block or the grandparent isn't known, which accepts any change.
+func (d *Dpos) VerifyParamsChange(grandparent, parent, header *types.Header) error {
+	if grandparent == nil {
+		return nil
+	}
+	grandparentConfig, parentConfig, config := d.Config(grandparent.Number), d.Config(parent.Number), d.Config(header.Number)
+	if grandparentConfig.Epoch != parentConfig.Epoch || grandparent.Time.Uint64()/grandparentConfig.Epoch != parent.Time.Uint64()/parentConfig.Epoch {
+		return nil
+	}
+	if header.MaxValidatorSize != parent.MaxValidatorSize &&
+		(parentConfig.MaxValidatorSize == config.MaxValidatorSize || header.MaxValidatorSize != config.MaxValidatorSize) {
+		return errInvalidDposParams
+	}
+	if header.BlockInterval != parent.BlockInterval &&
+		(parentConfig.BlockInterval == config.BlockInterval || header.BlockInterval != config.BlockInterval) {
+		return errInvalidDposParams
 	}
 	return nil
 }
 
-func (s *Dpos) loadConfirmedBlockHeader(chain consensus.ChainReader) (*types.Header, error) {
-	key, err := s.db.Get(confirmedBlockHead)
+// verifyBlockSigner checks that header was sealed with the signing key of the
+// scheduled validator and names that validator.
+func (d *Dpos) verifyBlockSigner(validator, signer common.Address, header *types.Header) error {
+	sealer, err := ecrecover(header, d.signatures)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	header := chain.GetHeaderByHash(common.BytesToHash(key))
-	if header == nil {
-		return nil, ErrNilBlockHeader
+	if bytes.Compare(sealer.Bytes(), signer.Bytes()) != 0 {
+		return ErrInvalidBlockValidator
 	}
-	return header, nil
-}
-
-// store inserts the snapshot into the database.
-func (s *Dpos) storeConfirmedBlockHeader(db ethdb.Database) error {
-	return db.Put(confirmedBlockHead, s.confirmedBlockHeader.Hash().Bytes())
+	if bytes.Compare(validator.Bytes(), header.Validator.Bytes()) != 0 {
+		return ErrMismatchSignerAndValidator
+	}
+	return nil
 }
 
 func (d *Dpos) Prepare(chain consensus.ChainReader, header *types.Header) error {
@@ -361,60 +400,181 @@ func (d *Dpos) Prepare(chain consensus.ChainReader, header *types.Header) error
 		header.Extra = append(header.Extra, bytes.Repeat([]byte{0x00}, extraVanity-len(header.Extra))...)
 	}
 	header.Extra = header.Extra[:extraVanity]
-	header.Extra = append(header.Extra, make([]byte, extraSeal)...)
 	parent := chain.GetHeader(header.ParentHash, number-1)
 	if parent == nil {
 		return consensus.ErrUnknownAncestor
 	}
+	d.mu.RLock()
+	signer := d.signer
+	d.mu.RUnlock()
+
+	// The local key may seal the blocks of a candidate with another account
+	dposContext, err := types.NewDposContextFromProto(d.trieDatabase(), parent.DposContext)
+	if err != nil {
+		return err
+	}
+	validator, err := dposContext.SigningKeyOwner(signer)
+	if err != nil {
+		return err
+	}
+	if validator == (common.Address{}) {
+		validator = signer
+	}
+	// Record the params in effect, possibly changed through governance
+	config, err := d.ConfigAt(parent.Number, dposContext)
+	if err != nil {
+		return err
+	}
+	header.MaxValidatorSize = config.MaxValidatorSize
+	header.BlockInterval = config.BlockInterval
+	// Reveal the secret committed to in the previous block and commit to a new one
+	randao, err := d.prepareRandao(number, dposContext, validator)
+	if err != nil {
+		return err
+	}
+	header.Extra = append(header.Extra, randao...)
+	header.Extra = append(header.Extra, make([]byte, extraSeal)...)
 	header.Difficulty = d.CalcDifficulty(chain, header.Time.Uint64(), parent)
-	header.Validator = d.signer
+	header.Validator = validator
 	return nil
 }
 
-func AccumulateRewards(config *params.ChainConfig, state *state.StateDB, header *types.Header, uncles []*types.Header) {
+// AccumulateRewards pays the validator of the block its commission on the block
+// reward in effect. The rest is accrued to the delegators voting for the
+// validator, who withdraw it later on.
+func AccumulateRewards(config *params.ChainConfig, dposConfig *params.DposConfig, state *state.StateDB, header *types.Header, dposContext *types.DposContext) error {
 	// Select the correct block reward based on chain progression
-	blockReward := frontierBlockReward
-	if config.IsByzantium(header.Number) {
-		blockReward = byzantiumBlockReward
+	blockReward := dposConfig.BlockReward
+	if blockReward == nil {
+		blockReward = frontierBlockReward
+		if config.IsByzantium(header.Number) {
+			blockReward = byzantiumBlockReward
+		}
+	}
+	reward, err := dposContext.DistributeReward(header.Validator, blockReward)
+	if err != nil {
+		return err
 	}
-	// Accumulate the rewards for the miner and any included uncles
-	reward := new(big.Int).Set(blockReward)
 	state.AddBalance(header.Coinbase, reward)
+	return nil
+}
+
+// releaseUnbonded pays the stake whose unbonding period is over at time now
+// back out of the DposContext escrow to its owners.
+func releaseUnbonded(state *state.StateDB, dposContext *types.DposContext, now int64) error {
+	released, err := dposContext.ReleaseUnbonded(now)
+	if err != nil {
+		return err
+	}
+	for _, entry := range released {
+		state.AddBalance(entry.Address, entry.Amount)
+		log.Debug("Released unbonded stake", "address", entry.Address, "amount", entry.Amount)
+	}
+	return nil
 }
 
 //将出块周期内的交易打包进新的区块中
 func (d *Dpos) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction,
 	uncles []*types.Header, receipts []*types.Receipt, dposContext *types.DposContext) (*types.Block, error) {
-	// Accumulate block rewards and commit the final state root
-	AccumulateRewards(chain.Config(), state, header, uncles)
+	parent := chain.GetHeaderByHash(header.ParentHash)
+	if parent == nil {
+		return nil, consensus.ErrUnknownAncestor
+	}
+	// The block is sealed with the params in effect in the parent state, the
+	// changes it activates apply from its election on
+	parentConfig, err := GovernedConfig(d.Config(parent.Number), dposContext)
+	if err != nil {
+		return nil, err
+	}
+	config, err := GovernedConfig(d.Config(header.Number), dposContext)
+	if err != nil {
+		return nil, err
+	}
+	if header.MaxValidatorSize != config.MaxValidatorSize || header.BlockInterval != config.BlockInterval {
+		return nil, errInvalidDposParams
+	}
+	// Accumulate block rewards, pay back matured stake and commit the final state root
+	if err := AccumulateRewards(chain.Config(), config, state, header, dposContext); err != nil {
+		return nil, err
+	}
+	if err := releaseUnbonded(state, dposContext, header.Time.Int64()); err != nil {
+		return nil, err
+	}
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
 
-	parent := chain.GetHeaderByHash(header.ParentHash)
 	epochContext := &EpochContext{
-		statedb:     state,
-		DposContext: dposContext,
-		TimeStamp:   header.Time.Int64(),
+		statedb:          state,
+		DposContext:      dposContext,
+		TimeStamp:        header.Time.Int64(),
 	}
-	if timeOfFirstBlock == 0 {
-		if firstBlockHeader := chain.GetHeaderByNumber(1); firstBlockHeader != nil {
-			timeOfFirstBlock = firstBlockHeader.Time.Int64()
+	genesis := chain.GetHeaderByNumber(0)
+	prevEpoch, curEpoch, _ := EpochNumbers(dposContext, parent.Time.Int64(), header.Time.Int64(), parentConfig, config)
+
+	// Charge the slots skipped since the parent to the validators of the parent
+	// epoch before they are re-elected, and those of the new epoch afterwards.
+	// Slots of whole epochs without blocks aren't charged to anyone.
+	skipped := parent.Number.Sign() > 0
+	prevEnd := (parent.Time.Int64()/int64(parentConfig.Epoch) + 1) * int64(parentConfig.Epoch)
+	if prevEnd > header.Time.Int64() {
+		prevEnd = header.Time.Int64()
+	}
+	if skipped {
+		if err := epochContext.countMissedSlots(prevEpoch, parent.Time.Int64()+1, prevEnd, parentConfig); err != nil {
+			return nil, err
 		}
 	}
-	fmt.Println("++++++++++++++77777++++++++++++++++++\n")
-	fmt.Println("**************get genesis header********\n")
-	genesis := chain.GetHeaderByNumber(0)
-
-	err := epochContext.tryElect(genesis, parent)
+	// Parameter changes approved in an earlier epoch take effect with the election
+	if err := activateParams(dposContext, d.Config(header.Number), curEpoch); err != nil {
+		return nil, err
+	}
+	if config, err = GovernedConfig(d.Config(header.Number), dposContext); err != nil {
+		return nil, err
+	}
+	// Only the election closing the first epoch depends on the time of block 1
+	if curEpoch != prevEpoch {
+		epochContext.timeOfFirstBlock = firstBlockTime(chain, header, header.Time.Int64()-int64(parentConfig.Epoch))
+	}
+	err = epochContext.tryElect(genesis, parent, parentConfig, config)
 	if err != nil {
 		return nil, fmt.Errorf("got error when elect next epoch, err: %s", err)
 	}
+	if skipped && curEpoch != prevEpoch {
+		// A fork lengthening the epoch may start it before the parent epoch ended
+		curStart := header.Time.Int64() / int64(config.Epoch) * int64(config.Epoch)
+		if curStart < prevEnd {
+			curStart = prevEnd
+		}
+		if err := epochContext.countMissedSlots(curEpoch, curStart, header.Time.Int64(), config); err != nil {
+			return nil, err
+		}
+	}
 
+	// Mix in the revealed randao secret once the election is over, so that the
+	// block can't influence the shuffling of its own epoch
+	if err := applyRandao(config, header, dposContext, curEpoch); err != nil {
+		return nil, err
+	}
 	//update mint count trie
-	updateMintCnt(parent.Time.Int64(), header.Time.Int64(), header.Validator, dposContext)
+	updateMintCnt(prevEpoch, curEpoch, header.Validator, dposContext)
+	reportValidatorStats(dposContext, curEpoch)
 	header.DposContext = dposContext.ToProto()
 	return types.NewBlock(header, txs, uncles, receipts), nil
 }
 
+// firstBlockTime returns the time of block 1 if it is the header or one of its
+// ancestors younger than the given time, zero otherwise. The ancestry of the
+// header is walked instead of looking up the canonical block 1, so that blocks
+// of side chains are processed the same whichever chain is canonical.
+func firstBlockTime(chain consensus.ChainReader, header *types.Header, until int64) int64 {
+	for header != nil && header.Number.Sign() > 0 && header.Time.Int64() > until {
+		if header.Number.Uint64() == 1 {
+			return header.Time.Int64()
+		}
+		header = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+	return 0
+}
+
 func (d *Dpos) checkDeadline(lastBlock *types.Block, now int64, blockInterval uint64) error {
 	prevSlot := PrevSlot(now, blockInterval)
 	nextSlot := NextSlot(now, blockInterval)
@@ -428,24 +588,34 @@ func (d *Dpos) checkDeadline(lastBlock *types.Block, now int64, blockInterval ui
 	return ErrWaitForPrevBlock
 }
 
+// CheckValidator implements consensus.Scheduled.
 //检查当前的验证人是否在当前的节点上
-func (d *Dpos) CheckValidator(lastBlock *types.Block, now int64,blockInterval uint64) error {
-	if err := d.checkDeadline(lastBlock, now, blockInterval); err != nil {
+func (d *Dpos) CheckValidator(lastBlock *types.Block, now int64) error {
+	//lastBlock.DposContext.DB()修改trie.NewDatabase(d.db)，解决没有创世块启动报错
+	dposContext, err := types.NewDposContextFromProto(d.trieDatabase(), lastBlock.Header().DposContext)
+	if err != nil {
 		return err
 	}
-	//lastBlock.DposContext.DB()修改trie.NewDatabase(d.db)，解决没有创世块启动报错
-	dposContext, err := types.NewDposContextFromProto(trie.NewDatabase(d.db), lastBlock.Header().DposContext)
+	config, err := d.ConfigAt(lastBlock.Number(), dposContext)
 	if err != nil {
 		return err
 	}
+	if err := d.checkDeadline(lastBlock, now, config.BlockInterval); err != nil {
+		return err
+	}
 	epochContext := &EpochContext{DposContext: dposContext}
-	validator, err := epochContext.lookupValidator(now,blockInterval)
+	signer, err := epochContext.lookupSigner(now, config)
 	if err != nil {
 		return err
 	}
-	if (validator == common.Address{}) || bytes.Compare(validator.Bytes(), d.signer.Bytes()) != 0 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if (signer == common.Address{}) || bytes.Compare(signer.Bytes(), d.signer.Bytes()) != 0 {
 		return ErrInvalidBlockValidator
 	}
+	if !d.isLeader() {
+		return ErrNotLeader
+	}
 	return nil
 }
 
@@ -459,20 +629,32 @@ func (d *Dpos) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan
 	if number == 0 {
 		return nil, errUnknownBlock
 	}
-	now := time.Now().Unix()
-	delay := NextSlot(now,chain.GetHeaderByNumber(0).BlockInterval) - now
+	now := d.now().Unix()
+	delay := NextSlot(now, header.BlockInterval) - now
 	if delay > 0 {
 		select {
 		case <-stop:
 			return nil, nil
-		case <-time.After(time.Duration(delay) * time.Second):
+		case <-d.after(time.Duration(delay) * time.Second):
 		}
 	}
-	block.Header().Time.SetInt64(time.Now().Unix())
+	block.Header().Time.SetInt64(d.now().Unix())
 
-	// time's up, sign the block
+	// time's up, sign the block if the local node leads the validator nodes and
+	// hasn't signed the slot yet
 	// 对新块进行签名
-	sighash, err := d.signFn(accounts.Account{Address: d.signer}, sigHash(header).Bytes())
+	d.mu.Lock()
+	signer, signFn := d.signer, d.signFn
+	if !d.isLeader() {
+		d.mu.Unlock()
+		return nil, ErrNotLeader
+	}
+	err := d.markSlotSigned(signer, header.Time.Uint64())
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	sighash, err := signFn(accounts.Account{Address: signer}, sigHash(header).Bytes())
 	if err != nil {
 		return nil, err
 	}
@@ -501,15 +683,19 @@ func (d *Dpos) Authorize(signer common.Address, signFn SignerFn) {
 }
 
 func (d *Dpos) Close() error {
+	d.scope.Close()
 	return nil
 }
 
-// ecrecover extracts the Ethereum account address from a signed header.
+// ecrecover extracts the Ethereum account address from a signed header. The
+// signature cache is optional.
 func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, error) {
 	// If the signature's already cached, return that
 	hash := header.Hash()
-	if address, known := sigcache.Get(hash); known {
-		return address.(common.Address), nil
+	if sigcache != nil {
+		if address, known := sigcache.Get(hash); known {
+			return address.(common.Address), nil
+		}
 	}
 	// Retrieve the signature from the header extra-data
 	if len(header.Extra) < extraSeal {
@@ -523,7 +709,9 @@ func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, er
 	}
 	var signer common.Address
 	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
-	sigcache.Add(hash, signer)
+	if sigcache != nil {
+		sigcache.Add(hash, signer)
+	}
 	return signer, nil
 }
 
@@ -535,16 +723,15 @@ func NextSlot(now int64, blockInterval uint64) int64 {
 	return int64((now+int64(blockInterval)-1)/int64(blockInterval)) * int64(blockInterval)
 }
 
-// update counts in MintCntTrie for the miner of newBlock
+// update counts in MintCntTrie for the miner of newBlock, currentEpoch being
+// the epoch of the parent block and newEpoch the one of the new block
 // 更新周期内验证人出块数目
-func updateMintCnt(parentBlockTime, currentBlockTime int64, validator common.Address, dposContext *types.DposContext) {
+func updateMintCnt(currentEpoch, newEpoch int64, validator common.Address, dposContext *types.DposContext) {
 	currentMintCntTrie := dposContext.MintCntTrie()
-	currentEpoch := parentBlockTime / epochInterval
 	currentEpochBytes := make([]byte, 8)
 	binary.BigEndian.PutUint64(currentEpochBytes, uint64(currentEpoch))
 
 	cnt := int64(1)
-	newEpoch := currentBlockTime / epochInterval
 	// still during the currentEpochID
 	if currentEpoch == newEpoch {
 		iter := trie.NewIterator(currentMintCntTrie.NodeIterator(currentEpochBytes))
@@ -4,15 +4,33 @@ import (
 	"testing"
 
 	"encoding/binary"
+	"math/big"
 
 	"github.com/haxicode/go-ethereum/common"
 	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/crypto"
 	"github.com/haxicode/go-ethereum/ethdb"
+	"github.com/haxicode/go-ethereum/params"
 	"github.com/haxicode/go-ethereum/trie"
 	"github.com/stretchr/testify/assert"
 )
 
+const (
+	epochInterval    = int64(86400)
+	blockInterval    = int64(10)
+	maxValidatorSize = 21
+	safeSize         = maxValidatorSize*2/3 + 1
+)
+
 var (
+	testDposConfig = &params.DposConfig{
+		Epoch:            uint64(epochInterval),
+		BlockInterval:    uint64(blockInterval),
+		MaxValidatorSize: maxValidatorSize,
+		KickoutThreshold: params.DefaultDposKickoutThreshold,
+		RandaoBlock:      common.Big0,
+	}
+
 	MockEpoch = []string{
 		"0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e",
 		"0xa60a3886b552ff9992cfcd208ec1152079e046c2",
@@ -91,7 +109,7 @@ func TestUpdateMintCnt(t *testing.T) {
 	blockTime := int64(epochInterval + blockInterval)
 
 	beforeUpdateCnt := getMintCnt(blockTime/epochInterval, miner, dposContext.MintCntTrie())
-	updateMintCnt(lastTime, blockTime, miner, dposContext)
+	updateMintCnt(lastTime/epochInterval, blockTime/epochInterval, miner, dposContext)
 	afterUpdateCnt := getMintCnt(blockTime/epochInterval, miner, dposContext.MintCntTrie())
 	assert.Equal(t, int64(0), beforeUpdateCnt)
 	assert.Equal(t, int64(1), afterUpdateCnt)
@@ -103,7 +121,7 @@ func TestUpdateMintCnt(t *testing.T) {
 
 	// currentBlock has recorded the count for the newMiner before UpdateMintCnt
 	beforeUpdateCnt = getMintCnt(blockTime/epochInterval, miner, dposContext.MintCntTrie())
-	updateMintCnt(lastTime, blockTime, miner, dposContext)
+	updateMintCnt(lastTime/epochInterval, blockTime/epochInterval, miner, dposContext)
 	afterUpdateCnt = getMintCnt(blockTime/epochInterval, miner, dposContext.MintCntTrie())
 	assert.Equal(t, int64(1), beforeUpdateCnt)
 	assert.Equal(t, int64(2), afterUpdateCnt)
@@ -112,8 +130,111 @@ func TestUpdateMintCnt(t *testing.T) {
 	blockTime = epochInterval * 2
 
 	beforeUpdateCnt = getMintCnt(blockTime/epochInterval, miner, dposContext.MintCntTrie())
-	updateMintCnt(lastTime, blockTime, miner, dposContext)
+	updateMintCnt(lastTime/epochInterval, blockTime/epochInterval, miner, dposContext)
 	afterUpdateCnt = getMintCnt(blockTime/epochInterval, miner, dposContext.MintCntTrie())
 	assert.Equal(t, int64(0), beforeUpdateCnt)
 	assert.Equal(t, int64(1), afterUpdateCnt)
 }
+
+func TestVerifySealWithValidators(t *testing.T) {
+	first, _ := crypto.GenerateKey()
+	second, _ := crypto.GenerateKey()
+	validators := []common.Address{crypto.PubkeyToAddress(first.PublicKey), crypto.PubkeyToAddress(second.PublicKey)}
+
+	engine := New(testDposConfig, ethdb.NewMemDatabase())
+	defer engine.Close()
+
+	assert.Nil(t, engine.VerifySealWithValidators(signedHeader(t, second, 1, blockInterval, common.Hash{}), validators, validators))
+	assert.Nil(t, engine.VerifySealWithValidators(signedHeader(t, first, 2, 2*blockInterval, common.Hash{}), validators, validators))
+
+	err := engine.VerifySealWithValidators(signedHeader(t, first, 1, blockInterval, common.Hash{}), validators, validators)
+	assert.Equal(t, ErrInvalidBlockValidator, err)
+
+	err = engine.VerifySealWithValidators(signedHeader(t, second, 1, blockInterval+1, common.Hash{}), validators, validators)
+	assert.Equal(t, ErrInvalidMintBlockTime, err)
+
+	err = engine.VerifySealWithValidators(signedHeader(t, second, 0, blockInterval, common.Hash{}), validators, validators)
+	assert.Equal(t, errUnknownBlock, err)
+
+	// a validator with a signing key seals with that key instead of its account
+	key, _ := crypto.GenerateKey()
+	signers := []common.Address{validators[0], crypto.PubkeyToAddress(key.PublicKey)}
+	assert.Nil(t, engine.VerifySealWithValidators(validatorHeader(t, key, validators[1], 1, blockInterval, common.Hash{}), validators, signers))
+
+	err = engine.VerifySealWithValidators(signedHeader(t, second, 1, blockInterval, common.Hash{}), validators, signers)
+	assert.Equal(t, ErrInvalidBlockValidator, err)
+
+	err = engine.VerifySealWithValidators(signedHeader(t, key, 1, blockInterval, common.Hash{}), validators, signers)
+	assert.Equal(t, ErrMismatchSignerAndValidator, err)
+}
+
+func TestUpdateConfirmedBlockHeaderFromCheckpoint(t *testing.T) {
+	validator := common.HexToAddress(MockEpoch[0])
+	genesis := &types.Header{Number: big.NewInt(0), Time: big.NewInt(0)}
+	headers := []*types.Header{genesis}
+	// the chain starts from a checkpoint at block 100, whose parent is unknown
+	parentHash := common.HexToHash("0x01")
+	for i := int64(100); i < 103; i++ {
+		header := &types.Header{
+			ParentHash:       parentHash,
+			Number:           big.NewInt(i),
+			Time:             big.NewInt(i * blockInterval),
+			Validator:        validator,
+			MaxValidatorSize: maxValidatorSize,
+			BlockInterval:    uint64(blockInterval),
+		}
+		headers = append(headers, header)
+		parentHash = header.Hash()
+	}
+	chain := &testChain{config: &params.ChainConfig{Dpos: testDposConfig}, headers: headers}
+	engine := New(testDposConfig, ethdb.NewMemDatabase())
+	defer engine.Close()
+
+	assert.Nil(t, engine.updateConfirmedBlockHeader(chain))
+	confirmed, err := engine.confirmedHeader(chain)
+	assert.Nil(t, err)
+	assert.Equal(t, headers[1].Hash(), confirmed.Hash())
+	assert.Equal(t, headers[1].Hash(), engine.loadConfirmedBlockHeader(chain, genesis.Hash()).Hash())
+}
+
+func TestFirstBlockTimeFromAncestry(t *testing.T) {
+	chain := newConfirmChain(0, 3)
+	genesis := chain.headers[0]
+
+	// A side chain has its own first block, whichever block 1 is canonical
+	side1 := &types.Header{ParentHash: genesis.Hash(), Number: big.NewInt(1), Time: big.NewInt(15)}
+	side2 := &types.Header{ParentHash: side1.Hash(), Number: big.NewInt(2), Time: big.NewInt(25)}
+	chain.side = []*types.Header{side1, side2}
+	assert.Equal(t, int64(15), firstBlockTime(chain, side2, side2.Time.Int64()-epochInterval))
+	assert.Equal(t, int64(10), firstBlockTime(chain, chain.headers[3], chain.headers[3].Time.Int64()-epochInterval))
+	assert.Equal(t, int64(10), firstBlockTime(chain, chain.headers[1], chain.headers[1].Time.Int64()-epochInterval))
+
+	// Block 1 doesn't matter once it is an epoch old
+	header := &types.Header{ParentHash: chain.headers[3].Hash(), Number: big.NewInt(4), Time: big.NewInt(10 + epochInterval)}
+	assert.Equal(t, int64(0), firstBlockTime(chain, header, header.Time.Int64()-epochInterval))
+}
+
+func TestVerifyParamsChange(t *testing.T) {
+	engine := New(testDposConfig, ethdb.NewMemDatabase())
+	defer engine.Close()
+
+	header := func(parent *types.Header, time int64, size uint64) *types.Header {
+		h := &types.Header{Number: big.NewInt(1), Time: big.NewInt(time), MaxValidatorSize: size, BlockInterval: uint64(blockInterval)}
+		if parent != nil {
+			h.ParentHash = parent.Hash()
+			h.Number = new(big.Int).Add(parent.Number, common.Big1)
+		}
+		return h
+	}
+	first := header(nil, blockInterval, maxValidatorSize)
+	second := header(first, 2*blockInterval, maxValidatorSize)
+
+	// Within an epoch the params are kept
+	assert.Nil(t, engine.VerifyParamsChange(first, second, header(second, 3*blockInterval, maxValidatorSize)))
+	assert.Equal(t, errInvalidDposParams, engine.VerifyParamsChange(first, second, header(second, 3*blockInterval, maxValidatorSize+1)))
+
+	// Governance changes show up after the first block of an epoch
+	start := header(second, epochInterval, maxValidatorSize)
+	assert.Nil(t, engine.VerifyParamsChange(second, start, header(start, epochInterval+blockInterval, maxValidatorSize+1)))
+	assert.Nil(t, engine.VerifyParamsChange(nil, start, header(start, epochInterval+blockInterval, maxValidatorSize+1)))
+}
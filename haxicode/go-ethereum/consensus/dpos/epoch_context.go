@@ -1,7 +1,6 @@
 package dpos
 
 import (
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"math/big"
@@ -11,6 +10,7 @@ import (
 	"github.com/haxicode/go-ethereum/core/state"
 	"github.com/haxicode/go-ethereum/core/types"
 	"github.com/haxicode/go-ethereum/log"
+	"github.com/haxicode/go-ethereum/params"
 	"github.com/haxicode/go-ethereum/trie"
 )
 
@@ -18,6 +18,30 @@ type EpochContext struct {
 	TimeStamp   int64
 	DposContext *types.DposContext
 	statedb     *state.StateDB
+
+	timeOfFirstBlock int64 // Time of block 1, zero if it is more than an epoch old
+}
+
+// EpochNumber returns the number of the epoch of a block sealed at time, given
+// the DPoS state committed with the block and the params in effect for it.
+func EpochNumber(dposContext *types.DposContext, time int64, config *params.DposConfig) int64 {
+	return time/int64(config.Epoch) + dposContext.EpochOffset()
+}
+
+// EpochNumbers returns the numbers of the epochs of a parent sealed at parentTime
+// and of its child sealed at time, and the offset numbering the epoch of the
+// child, given the DPoS state of the parent. Epochs are numbered by the time
+// divided by the epoch length. A fork changing the length would renumber them,
+// so the first epoch after it follows the epoch of the parent instead, keeping
+// the numbers the counters and pending params are keyed by increasing.
+func EpochNumbers(dposContext *types.DposContext, parentTime, time int64, parentConfig, config *params.DposConfig) (prevEpoch, curEpoch, offset int64) {
+	offset = dposContext.EpochOffset()
+	prevEpoch = parentTime/int64(parentConfig.Epoch) + offset
+	if parentConfig.Epoch == config.Epoch {
+		return prevEpoch, time/int64(config.Epoch) + offset, offset
+	}
+	curEpoch = prevEpoch + 1
+	return prevEpoch, curEpoch, curEpoch - time/int64(config.Epoch)
 }
 
 /*投票算法
@@ -30,8 +54,6 @@ func (ec *EpochContext) countVotes() (votes map[common.Address]*big.Int, err err
 	//获取投票人列表，候选人列表，及用户基本信息列表
 	delegateTrie := ec.DposContext.DelegateTrie()
 	candidateTrie := ec.DposContext.CandidateTrie()
-	statedb := ec.statedb
-
 	//迭代器获取候选人列表迭代
 	iterCandidate := trie.NewIterator(candidateTrie.NodeIterator(nil))
 	existCandidate := iterCandidate.Next()
@@ -56,8 +78,11 @@ func (ec *EpochContext) countVotes() (votes map[common.Address]*big.Int, err err
 				score = new(big.Int)                                                 //当没有查询到投票人信息时将定义一个局部遍历score
 			}
 			delegatorAddr := common.BytesToAddress(delegator)                        //将投票人bytes类型转换为address
-			// 获取投票人的余额作为票数累积到候选人的票数中
-			weight := statedb.GetBalance(delegatorAddr)
+			// 获取投票人锁定的押金作为票数累积到候选人的票数中
+			weight, err := ec.DposContext.DelegateDeposit(delegatorAddr)
+			if err != nil {
+				return nil, err
+			}
 			score.Add(score, weight)
 			votes[candidateAddr] = score
 			existDelegator = delegateIterator.Next()
@@ -68,16 +93,10 @@ func (ec *EpochContext) countVotes() (votes map[common.Address]*big.Int, err err
 }
 
 //剔除验证人算法
-func (ec *EpochContext) kickoutValidator(epoch int64,genesis *types.Header) error {
+func (ec *EpochContext) kickoutValidator(epoch int64, config *params.DposConfig) error {
 	validators, err := ec.DposContext.GetValidators()
-
-
-	//var maxValidatorSize int64
-	//var safeSize int64
-	fmt.Println("++++++++++++++++++++++++++9999++++++++++++++++++++++\n")
-	fmt.Println("kickoutValidator test")
-	maxValidatorSize := genesis.MaxValidatorSize
-	safeSize := int(maxValidatorSize*2/3+1)
+	maxValidatorSize := config.MaxValidatorSize
+	safeSize := config.SafeSize()
 
 	if err != nil {
 		return fmt.Errorf("failed to get validator: %s", err)
@@ -86,28 +105,33 @@ func (ec *EpochContext) kickoutValidator(epoch int64,genesis *types.Header) erro
 		return errors.New("no validator could be kickout")
 	}
 
+	epochInterval := int64(config.Epoch)
 	epochDuration := epochInterval
-	fmt.Println("0000000000000000000",epochDuration,"00000000000000\n")
-	blockInterval := genesis.BlockInterval
+	blockInterval := config.BlockInterval
 	// First epoch duration may lt epoch interval,
 	// while the first block time wouldn't always align with epoch interval,
 	// so caculate the first epoch duartion with first block time instead of epoch interval,
 	// prevent the validators were kickout incorrectly.
-	if ec.TimeStamp-timeOfFirstBlock < epochInterval {
-		epochDuration = ec.TimeStamp - timeOfFirstBlock
+	if ec.TimeStamp-ec.timeOfFirstBlock < epochInterval {
+		epochDuration = ec.TimeStamp - ec.timeOfFirstBlock
 	}
 
 	needKickoutValidators := sortableAddresses{}
 	for _, validator := range validators {
-		key := make([]byte, 8)
-		binary.BigEndian.PutUint64(key, uint64(epoch))
-		key = append(key, validator.Bytes()...)
-		cnt := int64(0)
-		if cntBytes := ec.DposContext.MintCntTrie().Get(key); cntBytes != nil {
-			cnt = int64(binary.BigEndian.Uint64(cntBytes))
+		minted := int64(ec.DposContext.MintCount(epoch, validator))
+		missed := int64(ec.DposContext.MissedCount(epoch, validator))
+		unrevealed := int64(ec.DposContext.UnrevealedCount(epoch, validator))
+
+		// Validators that sealed less than the kickout threshold of their slots
+		// are inactive. The slots are known from the missed ones, estimated for
+		// epochs without any. Blocks withholding the randao secret don't count as
+		// sealed.
+		cnt := minted - unrevealed
+		slots := minted + missed
+		if missed == 0 && unrevealed == 0 {
+			slots = epochDuration / int64(blockInterval) / int64(maxValidatorSize)
 		}
-
-		if cnt < epochDuration/int64(blockInterval)/ int64(maxValidatorSize) /2 {
+		if cnt < slots*int64(config.KickoutThreshold)/100 {
 			// not active validators need kickout
 			needKickoutValidators = append(needKickoutValidators, &sortableAddress{validator, big.NewInt(cnt)})
 		}
@@ -135,7 +159,7 @@ func (ec *EpochContext) kickoutValidator(epoch int64,genesis *types.Header) erro
 			return nil
 		}
 
-		if err := ec.DposContext.KickoutCandidate(validator.address); err != nil {
+		if err := ec.DposContext.KickoutCandidate(validator.address, ec.TimeStamp+int64(config.UnbondingPeriod)); err != nil {
 			return err
 		}
 		// if kickout success, candidateCount minus 1
@@ -145,19 +169,60 @@ func (ec *EpochContext) kickoutValidator(epoch int64,genesis *types.Header) erro
 	return nil
 }
 
-//实时检查出块者是否是本节点
-func (ec *EpochContext) lookupValidator(now int64, blockInterval uint64) (validator common.Address, err error) {
-	validator = common.Address{}
-	offset := now % epochInterval
-	if offset%int64(blockInterval) != 0 {    //判断当前时间是否在出块周期内
-		return common.Address{}, ErrInvalidMintBlockTime
+// countMissedSlots charges the slots of epoch in [from, to) to the validators
+// scheduled for them, for blocks that were skipped between two blocks.
+func (ec *EpochContext) countMissedSlots(epoch, from, to int64, config *params.DposConfig) error {
+	if from >= to {
+		return nil
+	}
+	validators, err := ec.DposContext.GetValidators()
+	if err != nil {
+		return err
+	}
+	missed := make(map[common.Address]uint64)
+	for slot := NextSlot(from, config.BlockInterval); slot < to; slot += int64(config.BlockInterval) {
+		validator, err := validatorAt(validators, slot, config)
+		if err != nil {
+			return err
+		}
+		missed[validator]++
+	}
+	for validator, cnt := range missed {
+		if err := ec.DposContext.AddMissedCount(epoch, validator, cnt); err != nil {
+			return err
+		}
 	}
-	offset /= int64(blockInterval)
+	return nil
+}
 
+//实时检查出块者是否是本节点
+func (ec *EpochContext) lookupValidator(now int64, config *params.DposConfig) (validator common.Address, err error) {
 	validators, err := ec.DposContext.GetValidators()
 	if err != nil {
 		return common.Address{}, err
 	}
+	return validatorAt(validators, now, config)
+}
+
+// lookupSigner returns the key the validator scheduled to mint the slot at time
+// now seals its block with.
+func (ec *EpochContext) lookupSigner(now int64, config *params.DposConfig) (common.Address, error) {
+	signers, err := ec.DposContext.GetValidatorSigners()
+	if err != nil {
+		return common.Address{}, err
+	}
+	return validatorAt(signers, now, config)
+}
+
+// validatorAt returns the member of validators scheduled to mint the slot at
+// time now.
+func validatorAt(validators []common.Address, now int64, config *params.DposConfig) (common.Address, error) {
+	offset := now % int64(config.Epoch)
+	if offset%int64(config.BlockInterval) != 0 {    //判断当前时间是否在出块周期内
+		return common.Address{}, ErrInvalidMintBlockTime
+	}
+	offset /= int64(config.BlockInterval)
+
 	validatorSize := len(validators)
 	if validatorSize == 0 {
 		return common.Address{}, errors.New("failed to lookup validator")
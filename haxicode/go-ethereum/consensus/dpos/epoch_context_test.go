@@ -10,6 +10,7 @@ import (
 	"github.com/haxicode/go-ethereum/core/state"
 	"github.com/haxicode/go-ethereum/core/types"
 	"github.com/haxicode/go-ethereum/ethdb"
+	"github.com/haxicode/go-ethereum/params"
 	"github.com/haxicode/go-ethereum/trie"
 
 	"github.com/stretchr/testify/assert"
@@ -48,7 +49,7 @@ func TestEpochContextCountVotes(t *testing.T) {
 	for candidate, electors := range voteMap {
 		assert.Nil(t, dposContext.BecomeCandidate(candidate))
 		for _, elector := range electors {
-			stateDB.SetBalance(elector, big.NewInt(balance))
+			assert.Nil(t, dposContext.LockDelegateDeposit(elector, big.NewInt(balance)))
 			assert.Nil(t, dposContext.Delegate(elector, candidate))
 		}
 	}
@@ -76,13 +77,12 @@ func TestLookupValidator(t *testing.T) {
 	}
 	mockEpochContext.DposContext.SetValidators(validators)
 	for i, expected := range validators {
-		got, _ := mockEpochContext.lookupValidator(int64(i) * blockInterval)
+		got, _ := mockEpochContext.lookupValidator(int64(i)*blockInterval, testDposConfig)
 		if got != expected {
 			t.Errorf("Failed to test lookup validator, %s was expected but got %s", expected.Str(), got.Str())
 		}
 	}
-	blockInterval := 10
-	_, err := mockEpochContext.lookupValidator(blockInterval - 1)
+	_, err := mockEpochContext.lookupValidator(blockInterval-1, testDposConfig)
 	if err != ErrInvalidMintBlockTime {
 		t.Errorf("Failed to test lookup validator. err '%v' was expected but got '%v'", ErrInvalidMintBlockTime, err)
 	}
@@ -112,7 +112,7 @@ func TestEpochContextKickoutValidator(t *testing.T) {
 	}
 	assert.Nil(t, dposContext.SetValidators(validators))
 	assert.Nil(t, dposContext.BecomeCandidate(common.StringToAddress("addr")))
-	assert.Nil(t, epochContext.kickoutValidator(testEpoch))
+	assert.Nil(t, epochContext.kickoutValidator(testEpoch, testDposConfig))
 	candidateMap := getCandidates(dposContext.CandidateTrie())
 	assert.Equal(t, maxValidatorSize +1, len(candidateMap))
 
@@ -133,7 +133,7 @@ func TestEpochContextKickoutValidator(t *testing.T) {
 		setTestMintCnt(dposContext, testEpoch, validator, atLeastMintCnt-int64(i)-1)
 	}
 	assert.Nil(t, dposContext.SetValidators(validators))
-	assert.Nil(t, epochContext.kickoutValidator(testEpoch))
+	assert.Nil(t, epochContext.kickoutValidator(testEpoch, testDposConfig))
 	candidateMap = getCandidates(dposContext.CandidateTrie())
 	assert.Equal(t, safeSize, len(candidateMap))
 	for i := maxValidatorSize - 1; i >= safeSize; i-- {
@@ -161,7 +161,7 @@ func TestEpochContextKickoutValidator(t *testing.T) {
 		assert.Nil(t, dposContext.BecomeCandidate(candidate))
 	}
 	assert.Nil(t, dposContext.SetValidators(validators))
-	assert.Nil(t, epochContext.kickoutValidator(testEpoch))
+	assert.Nil(t, epochContext.kickoutValidator(testEpoch, testDposConfig))
 	candidateMap = getCandidates(dposContext.CandidateTrie())
 	assert.Equal(t, maxValidatorSize, len(candidateMap))
 
@@ -187,7 +187,7 @@ func TestEpochContextKickoutValidator(t *testing.T) {
 	}
 	assert.Nil(t, dposContext.BecomeCandidate(common.StringToAddress("addr")))
 	assert.Nil(t, dposContext.SetValidators(validators))
-	assert.Nil(t, epochContext.kickoutValidator(testEpoch))
+	assert.Nil(t, epochContext.kickoutValidator(testEpoch, testDposConfig))
 	candidateMap = getCandidates(dposContext.CandidateTrie())
 	assert.Equal(t, maxValidatorSize, len(candidateMap))
 	assert.False(t, candidateMap[common.StringToAddress("addr"+strconv.Itoa(0))])
@@ -213,7 +213,7 @@ func TestEpochContextKickoutValidator(t *testing.T) {
 		assert.Nil(t, dposContext.BecomeCandidate(candidate))
 	}
 	assert.Nil(t, dposContext.SetValidators(validators))
-	assert.Nil(t, epochContext.kickoutValidator(testEpoch))
+	assert.Nil(t, epochContext.kickoutValidator(testEpoch, testDposConfig))
 	candidateMap = getCandidates(dposContext.CandidateTrie())
 	assert.Equal(t, maxValidatorSize *2, len(candidateMap))
 
@@ -238,7 +238,7 @@ func TestEpochContextKickoutValidator(t *testing.T) {
 		assert.Nil(t, dposContext.BecomeCandidate(candidate))
 	}
 	assert.Nil(t, dposContext.SetValidators(validators))
-	assert.Nil(t, epochContext.kickoutValidator(testEpoch))
+	assert.Nil(t, epochContext.kickoutValidator(testEpoch, testDposConfig))
 	candidateMap = getCandidates(dposContext.CandidateTrie())
 	assert.Equal(t, maxValidatorSize, len(candidateMap))
 
@@ -250,14 +250,71 @@ func TestEpochContextKickoutValidator(t *testing.T) {
 		DposContext: dposContext,
 		statedb:     stateDB,
 	}
-	assert.NotNil(t, epochContext.kickoutValidator(testEpoch))
+	assert.NotNil(t, epochContext.kickoutValidator(testEpoch, testDposConfig))
 	dposContext.SetValidators([]common.Address{})
-	assert.NotNil(t, epochContext.kickoutValidator(testEpoch))
+	assert.NotNil(t, epochContext.kickoutValidator(testEpoch, testDposConfig))
+}
+
+func TestEpochContextKickoutMissedSlots(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	stateDB, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	dposContext, err := types.NewDposContext(trie.NewDatabase(db))
+	assert.Nil(t, err)
+	epochContext := &EpochContext{
+		TimeStamp:   epochInterval,
+		DposContext: dposContext,
+		statedb:     stateDB,
+	}
+	atLeastMintCnt := epochInterval / blockInterval / maxValidatorSize / 2
+	testEpoch := int64(1)
+
+	// validators minting enough blocks are still kicked out if they missed more
+	// slots than they sealed
+	validators := []common.Address{}
+	for i := 0; i < maxValidatorSize; i++ {
+		validator := common.StringToAddress("addr" + strconv.Itoa(i))
+		validators = append(validators, validator)
+		assert.Nil(t, dposContext.BecomeCandidate(validator))
+		setTestMintCnt(dposContext, testEpoch, validator, atLeastMintCnt)
+	}
+	assert.Nil(t, dposContext.AddMissedCount(testEpoch, validators[0], 2*uint64(atLeastMintCnt)))
+	assert.Nil(t, dposContext.AddMissedCount(testEpoch, validators[1], uint64(atLeastMintCnt)))
+	assert.Nil(t, dposContext.BecomeCandidate(common.StringToAddress("addr")))
+	assert.Nil(t, dposContext.SetValidators(validators))
+	assert.Nil(t, epochContext.kickoutValidator(testEpoch, testDposConfig))
+	candidateMap := getCandidates(dposContext.CandidateTrie())
+	assert.Equal(t, maxValidatorSize, len(candidateMap))
+	assert.False(t, candidateMap[validators[0]])
+	assert.True(t, candidateMap[validators[1]])
+}
+
+func TestEpochContextCountMissedSlots(t *testing.T) {
+	dposContext, err := types.NewDposContext(trie.NewDatabase(ethdb.NewMemDatabase()))
+	assert.Nil(t, err)
+	epochContext := &EpochContext{DposContext: dposContext}
+	validators := []common.Address{
+		common.StringToAddress("addr1"),
+		common.StringToAddress("addr2"),
+		common.StringToAddress("addr3"),
+	}
+	assert.Nil(t, dposContext.SetValidators(validators))
+
+	// the slots strictly between a block at slot 1 and one at slot 6 are missed
+	assert.Nil(t, epochContext.countMissedSlots(0, blockInterval+1, 6*blockInterval, testDposConfig))
+	assert.Equal(t, uint64(1), dposContext.MissedCount(0, validators[0]))
+	assert.Equal(t, uint64(1), dposContext.MissedCount(0, validators[1]))
+	assert.Equal(t, uint64(2), dposContext.MissedCount(0, validators[2]))
+
+	// consecutive blocks miss nothing
+	assert.Nil(t, epochContext.countMissedSlots(0, 6*blockInterval+1, 7*blockInterval, testDposConfig))
+	missed, err := dposContext.GetMissedCounts(0)
+	assert.Nil(t, err)
+	assert.Equal(t, map[common.Address]uint64{validators[0]: 1, validators[1]: 1, validators[2]: 2}, missed)
 }
 
 func setTestMintCnt(dposContext *types.DposContext, epoch int64, validator common.Address, count int64) {
 	for i := int64(0); i < count; i++ {
-		updateMintCnt(epoch*epochInterval, epoch*epochInterval+blockInterval, validator, dposContext)
+		updateMintCnt(epoch, epoch, validator, dposContext)
 	}
 }
 
@@ -289,7 +346,7 @@ func TestEpochContextTryElect(t *testing.T) {
 		validators = append(validators, validator)
 		assert.Nil(t, dposContext.BecomeCandidate(validator))
 		assert.Nil(t, dposContext.Delegate(validator, validator))
-		stateDB.SetBalance(validator, big.NewInt(1))
+		assert.Nil(t, dposContext.LockDelegateDeposit(validator, big.NewInt(1)))
 		setTestMintCnt(dposContext, testEpoch, validator, atLeastMintCnt-1)
 	}
 	dposContext.BecomeCandidate(common.StringToAddress("more"))
@@ -303,7 +360,7 @@ func TestEpochContextTryElect(t *testing.T) {
 		Time: big.NewInt(epochInterval - blockInterval),
 	}
 	oldHash := dposContext.EpochTrie().Hash()
-	assert.Nil(t, epochContext.tryElect(genesis, parent))
+	assert.Nil(t, epochContext.tryElect(genesis, parent, testDposConfig, testDposConfig))
 	result, err := dposContext.GetValidators()
 	assert.Nil(t, err)
 	assert.Equal(t, maxValidatorSize, len(result))
@@ -322,7 +379,7 @@ func TestEpochContextTryElect(t *testing.T) {
 	}
 	epochContext.TimeStamp = epochInterval
 	oldHash = dposContext.EpochTrie().Hash()
-	assert.Nil(t, epochContext.tryElect(genesis, parent))
+	assert.Nil(t, epochContext.tryElect(genesis, parent, testDposConfig, testDposConfig))
 	result, err = dposContext.GetValidators()
 	assert.Nil(t, err)
 	assert.Equal(t, maxValidatorSize, len(result))
@@ -340,7 +397,7 @@ func TestEpochContextTryElect(t *testing.T) {
 	}
 	epochContext.TimeStamp = epochInterval * 2
 	oldHash = dposContext.EpochTrie().Hash()
-	assert.Nil(t, epochContext.tryElect(genesis, parent))
+	assert.Nil(t, epochContext.tryElect(genesis, parent, testDposConfig, testDposConfig))
 	result, err = dposContext.GetValidators()
 	assert.Nil(t, err)
 	assert.Equal(t, safeSize, len(result))
@@ -362,9 +419,166 @@ func TestEpochContextTryElect(t *testing.T) {
 	}
 	epochContext.TimeStamp = epochInterval + blockInterval
 	oldHash = dposContext.EpochTrie().Hash()
-	assert.Nil(t, epochContext.tryElect(genesis, parent))
+	assert.Nil(t, epochContext.tryElect(genesis, parent, testDposConfig, testDposConfig))
 	result, err = dposContext.GetValidators()
 	assert.Nil(t, err)
 	assert.Equal(t, safeSize, len(result))
 	assert.Equal(t, oldHash, dposContext.EpochTrie().Hash())
 }
+
+func TestEpochContextTryElectEpochFork(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	stateDB, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	dposContext, err := types.NewDposContext(trie.NewDatabase(db))
+	assert.Nil(t, err)
+
+	validators := []common.Address{}
+	for i := 0; i < maxValidatorSize; i++ {
+		validator := common.StringToAddress("addr" + strconv.Itoa(i))
+		validators = append(validators, validator)
+		assert.Nil(t, dposContext.BecomeCandidate(validator))
+		assert.Nil(t, dposContext.Delegate(validator, validator))
+		assert.Nil(t, dposContext.LockDelegateDeposit(validator, big.NewInt(1)))
+	}
+	assert.Nil(t, dposContext.SetValidators(validators))
+
+	// The fork shortens the epoch in the middle of the first day, which would
+	// renumber it from 0 to 72. The new epoch is numbered 1 instead and exactly
+	// one election has to happen.
+	forkConfig := &params.DposConfig{
+		Epoch:            600,
+		BlockInterval:    uint64(blockInterval),
+		MaxValidatorSize: maxValidatorSize,
+	}
+	genesis := &types.Header{Time: big.NewInt(0)}
+	parent := &types.Header{Time: big.NewInt(epochInterval/2 - blockInterval)}
+	epochContext := &EpochContext{
+		TimeStamp:   epochInterval / 2,
+		DposContext: dposContext,
+		statedb:     stateDB,
+	}
+	oldHash := dposContext.EpochTrie().Hash()
+	assert.Nil(t, epochContext.tryElect(genesis, parent, testDposConfig, forkConfig))
+	assert.NotEqual(t, oldHash, dposContext.EpochTrie().Hash())
+	assert.Equal(t, int64(1), EpochNumber(dposContext, epochContext.TimeStamp, forkConfig))
+
+	// After the fork the short epochs are used on both sides
+	parent = &types.Header{Time: big.NewInt(epochInterval / 2)}
+	epochContext.TimeStamp = epochInterval/2 + blockInterval
+	oldHash = dposContext.EpochTrie().Hash()
+	assert.Nil(t, epochContext.tryElect(genesis, parent, forkConfig, forkConfig))
+	assert.Equal(t, oldHash, dposContext.EpochTrie().Hash())
+
+	parent = &types.Header{Time: big.NewInt(epochInterval/2 + 600 - blockInterval)}
+	epochContext.TimeStamp = epochInterval/2 + 600
+	assert.Nil(t, epochContext.tryElect(genesis, parent, forkConfig, forkConfig))
+	assert.NotEqual(t, oldHash, dposContext.EpochTrie().Hash())
+}
+
+func TestEpochContextTryElectEpochLengthened(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	stateDB, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	dposContext, err := types.NewDposContext(trie.NewDatabase(db))
+	assert.Nil(t, err)
+
+	shortConfig := &params.DposConfig{
+		Epoch:            600,
+		BlockInterval:    uint64(blockInterval),
+		MaxValidatorSize: maxValidatorSize,
+		MintCntHorizon:   2,
+	}
+	longConfig := *shortConfig
+	longConfig.Epoch = uint64(epochInterval)
+
+	slots := 600 / blockInterval / maxValidatorSize
+	validators := []common.Address{}
+	for i := 0; i < maxValidatorSize; i++ {
+		validator := common.StringToAddress("addr" + strconv.Itoa(i))
+		validators = append(validators, validator)
+		assert.Nil(t, dposContext.BecomeCandidate(validator))
+		assert.Nil(t, dposContext.Delegate(validator, validator))
+		assert.Nil(t, dposContext.LockDelegateDeposit(validator, big.NewInt(1)))
+		for epoch := int64(97); epoch <= 99; epoch++ {
+			setTestMintCnt(dposContext, epoch, validator, slots)
+		}
+	}
+	assert.Nil(t, dposContext.SetValidators(validators))
+
+	// The fork lengthens the epoch at the end of short epoch 99, which would be
+	// renumbered 0. The new epoch is numbered 100 instead, and the counters of
+	// the short epochs are pruned as usual.
+	genesis := &types.Header{Time: big.NewInt(0)}
+	parent := &types.Header{Time: big.NewInt(100*600 - blockInterval)}
+	epochContext := &EpochContext{
+		TimeStamp:   100 * 600,
+		DposContext: dposContext,
+		statedb:     stateDB,
+	}
+	prevEpoch, curEpoch, _ := EpochNumbers(dposContext, parent.Time.Int64(), epochContext.TimeStamp, shortConfig, &longConfig)
+	assert.Equal(t, int64(99), prevEpoch)
+	assert.Equal(t, int64(100), curEpoch)
+
+	oldHash := dposContext.EpochTrie().Hash()
+	assert.Nil(t, epochContext.tryElect(genesis, parent, shortConfig, &longConfig))
+	assert.NotEqual(t, oldHash, dposContext.EpochTrie().Hash())
+	assert.Equal(t, int64(100), dposContext.EpochOffset())
+	assert.Equal(t, int64(100), EpochNumber(dposContext, epochContext.TimeStamp, &longConfig))
+	assert.Equal(t, uint64(0), dposContext.MintCount(97, validators[0]))
+	assert.Equal(t, uint64(slots), dposContext.MintCount(98, validators[0]))
+	assert.Equal(t, uint64(slots), dposContext.MintCount(99, validators[0]))
+
+	// Later blocks of the long epoch don't elect
+	parent = &types.Header{Time: big.NewInt(100 * 600)}
+	epochContext.TimeStamp = 100*600 + blockInterval
+	oldHash = dposContext.EpochTrie().Hash()
+	assert.Nil(t, epochContext.tryElect(genesis, parent, &longConfig, &longConfig))
+	assert.Equal(t, oldHash, dposContext.EpochTrie().Hash())
+
+	// The next long epoch is numbered 101, keeping the offset
+	parent = &types.Header{Time: big.NewInt(epochInterval - blockInterval)}
+	epochContext.TimeStamp = epochInterval
+	prevEpoch, curEpoch, _ = EpochNumbers(dposContext, parent.Time.Int64(), epochContext.TimeStamp, &longConfig, &longConfig)
+	assert.Equal(t, int64(100), prevEpoch)
+	assert.Equal(t, int64(101), curEpoch)
+	assert.Nil(t, epochContext.tryElect(genesis, parent, &longConfig, &longConfig))
+	assert.NotEqual(t, oldHash, dposContext.EpochTrie().Hash())
+	assert.Equal(t, int64(100), dposContext.EpochOffset())
+	assert.Equal(t, uint64(0), dposContext.MintCount(98, validators[0]))
+	assert.Equal(t, uint64(slots), dposContext.MintCount(99, validators[0]))
+}
+
+func TestEpochContextTryElectMintCntHorizon(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	stateDB, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	dposContext, err := types.NewDposContext(trie.NewDatabase(db))
+	assert.Nil(t, err)
+
+	slots := epochInterval / blockInterval / maxValidatorSize
+	validators := []common.Address{}
+	for i := 0; i < maxValidatorSize; i++ {
+		validator := common.StringToAddress("addr" + strconv.Itoa(i))
+		validators = append(validators, validator)
+		assert.Nil(t, dposContext.BecomeCandidate(validator))
+		assert.Nil(t, dposContext.Delegate(validator, validator))
+		assert.Nil(t, dposContext.LockDelegateDeposit(validator, big.NewInt(1)))
+		for epoch := int64(1); epoch <= 3; epoch++ {
+			setTestMintCnt(dposContext, epoch, validator, slots)
+		}
+	}
+	assert.Nil(t, dposContext.SetValidators(validators))
+
+	// Electing the validators of epoch 4 drops the counters older than 2 epochs
+	config := *testDposConfig
+	config.MintCntHorizon = 2
+	genesis := &types.Header{Time: big.NewInt(0)}
+	parent := &types.Header{Time: big.NewInt(4*epochInterval - blockInterval)}
+	epochContext := &EpochContext{
+		TimeStamp:   4 * epochInterval,
+		DposContext: dposContext,
+		statedb:     stateDB,
+	}
+	assert.Nil(t, epochContext.tryElect(genesis, parent, &config, &config))
+	assert.Equal(t, uint64(0), dposContext.MintCount(1, validators[0]))
+	assert.Equal(t, uint64(slots), dposContext.MintCount(2, validators[0]))
+	assert.Equal(t, uint64(slots), dposContext.MintCount(3, validators[0]))
+}
@@ -0,0 +1,141 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"errors"
+
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/event"
+	"github.com/haxicode/go-ethereum/log"
+)
+
+var (
+	// errEvidenceSameBlock is returned if both headers of a double sign evidence
+	// are the same block.
+	errEvidenceSameBlock = errors.New("evidence headers are the same block")
+	// errEvidenceSlotMismatch is returned if the headers of a double sign evidence
+	// were sealed for different slots.
+	errEvidenceSlotMismatch = errors.New("evidence headers are for different slots")
+	// errEvidenceSignerMismatch is returned if the headers of a double sign
+	// evidence were sealed by different validators.
+	errEvidenceSignerMismatch = errors.New("evidence headers have different signers")
+)
+
+// DoubleSignEvent is posted when two different headers sealed by the same
+// validator for the same slot have been seen.
+type DoubleSignEvent struct {
+	Offender common.Address
+	Evidence *types.DoubleSignEvidence
+}
+
+// VerifyDoubleSign checks that the headers of the evidence are two different
+// blocks sealed for the same slot by the same validator, with the signing key
+// the validator had registered in the DposContext for that slot, and returns
+// that validator.
+func VerifyDoubleSign(evidence *types.DoubleSignEvidence, dposContext *types.DposContext) (common.Address, error) {
+	first, second := evidence.First, evidence.Second
+	if first.Time == nil || second.Time == nil || first.Time.Cmp(second.Time) != 0 {
+		return common.Address{}, errEvidenceSlotMismatch
+	}
+	if first.Hash() == second.Hash() {
+		return common.Address{}, errEvidenceSameBlock
+	}
+	signer, err := recoverSigner(first)
+	if err != nil {
+		return common.Address{}, err
+	}
+	other, err := recoverSigner(second)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if signer != other || first.Validator != second.Validator {
+		return common.Address{}, errEvidenceSignerMismatch
+	}
+	key, err := dposContext.SigningKeyAt(first.Validator, first.Time.Int64())
+	if err != nil {
+		return common.Address{}, err
+	}
+	if signer != key {
+		return common.Address{}, ErrMismatchSignerAndValidator
+	}
+	return first.Validator, nil
+}
+
+// recoverSigner returns the key a header was sealed with.
+func recoverSigner(header *types.Header) (common.Address, error) {
+	if len(header.Extra) < extraVanity+extraSeal {
+		return common.Address{}, errMissingSignature
+	}
+	return ecrecover(header, nil)
+}
+
+// slotHeader is the first header seen for a validator, signer and slot.
+type slotHeader struct {
+	header   *types.Header
+	reported bool
+}
+
+// ObserveHeader records a header seen on the network and posts a DoubleSignEvent
+// the first time another header sealed by the same validator and key for the
+// same slot shows up. Headers without a valid seal are ignored, whether the key
+// belongs to the validator is left to VerifyDoubleSign.
+func (d *Dpos) ObserveHeader(header *types.Header) {
+	if header.Number == nil || header.Number.Sign() == 0 || header.Time == nil {
+		return
+	}
+	if len(header.Extra) < extraVanity+extraSeal {
+		return
+	}
+	signer, err := ecrecover(header, d.signatures)
+	if err != nil {
+		return
+	}
+	key := struct {
+		validator common.Address
+		signer    common.Address
+		slot      int64
+	}{header.Validator, signer, header.Time.Int64()}
+
+	d.mu.Lock()
+	cached, ok := d.slotHeaders.Get(key)
+	if !ok {
+		d.slotHeaders.Add(key, &slotHeader{header: header})
+		d.mu.Unlock()
+		return
+	}
+	seen := cached.(*slotHeader)
+	if seen.reported || seen.header.Hash() == header.Hash() {
+		d.mu.Unlock()
+		return
+	}
+	seen.reported = true
+	d.mu.Unlock()
+
+	log.Warn("Validator sealed conflicting blocks", "validator", header.Validator, "signer", signer, "slot", key.slot,
+		"number", header.Number, "hash", header.Hash(), "other", seen.header.Hash())
+	d.evidenceFeed.Send(DoubleSignEvent{
+		Offender: header.Validator,
+		Evidence: &types.DoubleSignEvidence{First: seen.header, Second: header},
+	})
+}
+
+// SubscribeDoubleSignEvent registers a subscription of DoubleSignEvent.
+func (d *Dpos) SubscribeDoubleSignEvent(ch chan<- DoubleSignEvent) event.Subscription {
+	return d.scope.Track(d.evidenceFeed.Subscribe(ch))
+}
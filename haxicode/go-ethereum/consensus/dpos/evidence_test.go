@@ -0,0 +1,151 @@
+package dpos
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/crypto"
+	"github.com/haxicode/go-ethereum/ethdb"
+	"github.com/haxicode/go-ethereum/trie"
+	"github.com/stretchr/testify/assert"
+)
+
+// signedHeader returns a header for the slot sealed by key.
+func signedHeader(t *testing.T, key *ecdsa.PrivateKey, number, slot int64, root common.Hash) *types.Header {
+	return validatorHeader(t, key, crypto.PubkeyToAddress(key.PublicKey), number, slot, root)
+}
+
+// validatorHeader returns a header of the validator sealed with the given key.
+func validatorHeader(t *testing.T, key *ecdsa.PrivateKey, validator common.Address, number, slot int64, root common.Hash) *types.Header {
+	header := &types.Header{
+		Number:      big.NewInt(number),
+		Time:        big.NewInt(slot),
+		Difficulty:  big.NewInt(1),
+		Root:        root,
+		Validator:   validator,
+		Extra:       make([]byte, extraVanity+extraSeal),
+		DposContext: &types.DposContextProto{},
+
+		MaxValidatorSize: maxValidatorSize,
+		BlockInterval:    uint64(blockInterval),
+	}
+	sig, err := crypto.Sign(sigHash(header).Bytes(), key)
+	assert.Nil(t, err)
+	copy(header.Extra[len(header.Extra)-extraSeal:], sig)
+	return header
+}
+
+func TestVerifyDoubleSign(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	validator := crypto.PubkeyToAddress(key.PublicKey)
+	dposContext, err := types.NewDposContext(trie.NewDatabase(ethdb.NewMemDatabase()))
+	assert.Nil(t, err)
+
+	first := signedHeader(t, key, 1, 10, common.Hash{1})
+	second := signedHeader(t, key, 1, 10, common.Hash{2})
+	offender, err := VerifyDoubleSign(&types.DoubleSignEvidence{First: first, Second: second}, dposContext)
+	assert.Nil(t, err)
+	assert.Equal(t, validator, offender)
+
+	// blocks on different heights still conflict if they share the slot
+	offender, err = VerifyDoubleSign(&types.DoubleSignEvidence{First: first, Second: signedHeader(t, key, 2, 10, common.Hash{1})}, dposContext)
+	assert.Nil(t, err)
+	assert.Equal(t, validator, offender)
+
+	_, err = VerifyDoubleSign(&types.DoubleSignEvidence{First: first, Second: first}, dposContext)
+	assert.Equal(t, errEvidenceSameBlock, err)
+
+	_, err = VerifyDoubleSign(&types.DoubleSignEvidence{First: first, Second: signedHeader(t, key, 2, 20, common.Hash{1})}, dposContext)
+	assert.Equal(t, errEvidenceSlotMismatch, err)
+
+	_, err = VerifyDoubleSign(&types.DoubleSignEvidence{First: first, Second: signedHeader(t, other, 1, 10, common.Hash{2})}, dposContext)
+	assert.Equal(t, errEvidenceSignerMismatch, err)
+
+	// a header claiming another validator than its signer is no evidence
+	forged := validatorHeader(t, other, validator, 1, 10, common.Hash{2})
+	_, err = VerifyDoubleSign(&types.DoubleSignEvidence{First: forged, Second: validatorHeader(t, other, validator, 1, 10, common.Hash{3})}, dposContext)
+	assert.Equal(t, ErrMismatchSignerAndValidator, err)
+
+	// unless the validator registered that signer as its signing key
+	assert.Nil(t, dposContext.SetSigningKey(validator, crypto.PubkeyToAddress(other.PublicKey), 5))
+	offender, err = VerifyDoubleSign(&types.DoubleSignEvidence{First: forged, Second: validatorHeader(t, other, validator, 1, 10, common.Hash{3})}, dposContext)
+	assert.Nil(t, err)
+	assert.Equal(t, validator, offender)
+}
+
+func TestVerifyDoubleSignAfterRotation(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	rotated, _ := crypto.GenerateKey()
+	validator := crypto.PubkeyToAddress(key.PublicKey)
+	dposContext, err := types.NewDposContext(trie.NewDatabase(ethdb.NewMemDatabase()))
+	assert.Nil(t, err)
+
+	// The validator double signs a slot and rotates its key before the evidence
+	// gets included
+	evidence := &types.DoubleSignEvidence{First: signedHeader(t, key, 1, 10, common.Hash{1}), Second: signedHeader(t, key, 1, 10, common.Hash{2})}
+	assert.Nil(t, dposContext.SetSigningKey(validator, crypto.PubkeyToAddress(rotated.PublicKey), 20))
+
+	offender, err := VerifyDoubleSign(evidence, dposContext)
+	assert.Nil(t, err)
+	assert.Equal(t, validator, offender)
+
+	// The new key doesn't seal the slots before the rotation
+	forged := &types.DoubleSignEvidence{First: validatorHeader(t, rotated, validator, 1, 10, common.Hash{1}), Second: validatorHeader(t, rotated, validator, 1, 10, common.Hash{2})}
+	_, err = VerifyDoubleSign(forged, dposContext)
+	assert.Equal(t, ErrMismatchSignerAndValidator, err)
+
+	// but those after it
+	evidence = &types.DoubleSignEvidence{First: validatorHeader(t, rotated, validator, 3, 30, common.Hash{1}), Second: validatorHeader(t, rotated, validator, 3, 30, common.Hash{2})}
+	offender, err = VerifyDoubleSign(evidence, dposContext)
+	assert.Nil(t, err)
+	assert.Equal(t, validator, offender)
+}
+
+func TestObserveHeader(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	validator := crypto.PubkeyToAddress(key.PublicKey)
+
+	engine := New(testDposConfig, ethdb.NewMemDatabase())
+	defer engine.Close()
+	events := make(chan DoubleSignEvent, 2)
+	sub := engine.SubscribeDoubleSignEvent(events)
+	defer sub.Unsubscribe()
+
+	first := signedHeader(t, key, 1, 10, common.Hash{1})
+	engine.ObserveHeader(first)
+	engine.ObserveHeader(first)
+	engine.ObserveHeader(signedHeader(t, key, 2, 20, common.Hash{1}))
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected double sign event for %x", ev.Offender)
+	default:
+	}
+
+	second := signedHeader(t, key, 1, 10, common.Hash{2})
+	engine.ObserveHeader(second)
+	select {
+	case ev := <-events:
+		assert.Equal(t, validator, ev.Offender)
+		assert.Equal(t, first.Hash(), ev.Evidence.First.Hash())
+		assert.Equal(t, second.Hash(), ev.Evidence.Second.Hash())
+		dposContext, err := types.NewDposContext(trie.NewDatabase(ethdb.NewMemDatabase()))
+		assert.Nil(t, err)
+		_, err = VerifyDoubleSign(ev.Evidence, dposContext)
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("double sign not reported")
+	}
+
+	// the offence is only reported once
+	engine.ObserveHeader(signedHeader(t, key, 1, 10, common.Hash{3}))
+	select {
+	case <-events:
+		t.Fatal("double sign reported twice")
+	default:
+	}
+}
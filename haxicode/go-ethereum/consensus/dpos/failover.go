@@ -0,0 +1,81 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/haxicode/go-ethereum/common"
+)
+
+// lastSignedSlotPrefix + signer address -> last slot the local node sealed a
+// block for with that signer.
+var lastSignedSlotPrefix = []byte("dpos-last-signed-slot-")
+
+var (
+	// ErrNotLeader is returned if the local node shares its validator identity
+	// with other nodes and is not the one currently allowed to sign.
+	ErrNotLeader = errors.New("not the leader of the validator nodes")
+	// ErrSlotAlreadySigned is returned if the local node is asked to seal a block
+	// for a slot at or below one it already sealed a block for.
+	ErrSlotAlreadySigned = errors.New("slot already signed")
+)
+
+// Leadership tells whether the local node is the active leader among the nodes
+// sharing a validator identity. Only the leader seals blocks and pre-commits.
+type Leadership interface {
+	IsLeader() bool
+}
+
+// SetLeadership makes the engine sign only while the local node is the leader
+// of the nodes sharing its validator identity. A nil leadership lets the node
+// sign on its own.
+func (d *Dpos) SetLeadership(leadership Leadership) {
+	d.mu.Lock()
+	d.leadership = leadership
+	d.mu.Unlock()
+}
+
+// isLeader reports whether the local node may sign, the caller must hold the
+// engine lock.
+func (d *Dpos) isLeader() bool {
+	return d.leadership == nil || d.leadership.IsLeader()
+}
+
+// lastSignedSlot returns the last slot the local node sealed a block for with
+// the signer, zero if it never did.
+func (d *Dpos) lastSignedSlot(signer common.Address) uint64 {
+	enc, err := d.db.Get(append(lastSignedSlotPrefix, signer.Bytes()...))
+	if err != nil || len(enc) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(enc)
+}
+
+// markSlotSigned records that the local node is about to seal a block for the
+// slot with the signer. The record is written before signing and refuses slots
+// at or below the last one signed, so that the node never signs two blocks for
+// a slot, even across restarts or losing and regaining leadership.
+func (d *Dpos) markSlotSigned(signer common.Address, slot uint64) error {
+	if slot <= d.lastSignedSlot(signer) {
+		return ErrSlotAlreadySigned
+	}
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, slot)
+	return d.db.Put(append(lastSignedSlotPrefix, signer.Bytes()...), enc)
+}
@@ -0,0 +1,59 @@
+package dpos
+
+import (
+	"crypto/ecdsa"
+	"testing"
+
+	"github.com/haxicode/go-ethereum/accounts"
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/crypto"
+	"github.com/haxicode/go-ethereum/ethdb"
+	"github.com/stretchr/testify/assert"
+)
+
+type testLeadership bool
+
+func (l *testLeadership) IsLeader() bool { return bool(*l) }
+
+func TestMarkSlotSigned(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	signer := common.HexToAddress("0x01")
+	engine := New(testDposConfig, db)
+	assert.Equal(t, uint64(0), engine.lastSignedSlot(signer))
+
+	assert.Nil(t, engine.markSlotSigned(signer, 20))
+	assert.Equal(t, ErrSlotAlreadySigned, engine.markSlotSigned(signer, 20))
+	assert.Equal(t, ErrSlotAlreadySigned, engine.markSlotSigned(signer, 10))
+	assert.Nil(t, engine.markSlotSigned(common.HexToAddress("0x02"), 10))
+
+	// The record survives restarts
+	engine = New(testDposConfig, db)
+	assert.Equal(t, uint64(20), engine.lastSignedSlot(signer))
+	assert.Equal(t, ErrSlotAlreadySigned, engine.markSlotSigned(signer, 20))
+	assert.Nil(t, engine.markSlotSigned(signer, 30))
+}
+
+func TestLeadershipPreCommit(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	db := ethdb.NewMemDatabase()
+	chain := newFinalityChain(t, db, []*ecdsa.PrivateKey{key})
+	header := chain.headers[1]
+
+	engine := New(testDposConfig, db)
+	defer engine.Close()
+	engine.Authorize(crypto.PubkeyToAddress(key.PublicKey), func(account accounts.Account, hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, key)
+	})
+
+	// Standby nodes don't pre-commit
+	leader := testLeadership(false)
+	engine.SetLeadership(&leader)
+	vote, err := engine.PreCommit(chain, header)
+	assert.Nil(t, err)
+	assert.Nil(t, vote)
+
+	leader = true
+	vote, err = engine.PreCommit(chain, header)
+	assert.Nil(t, err)
+	assert.NotNil(t, vote)
+}
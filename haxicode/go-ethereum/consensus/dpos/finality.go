@@ -0,0 +1,349 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"github.com/haxicode/go-ethereum/accounts"
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/consensus"
+	"github.com/haxicode/go-ethereum/core/rawdb"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/crypto"
+	"github.com/haxicode/go-ethereum/event"
+	"github.com/haxicode/go-ethereum/log"
+)
+
+const (
+	inmemoryPreCommits = 256 // Number of recent blocks to gather pre-commits for
+	maxFutureVotes     = 64  // Maximum number of pre-commits buffered for an unknown block
+)
+
+// lastPreCommitPrefix + genesis hash + signer address -> highest block number of
+// that chain the local node pre-committed with that signer.
+var lastPreCommitPrefix = []byte("dpos-last-pre-commit-")
+
+var (
+	// errUnknownPreCommitBlock is returned if a pre-commit is for a block that is
+	// not known locally.
+	errUnknownPreCommitBlock = errors.New("pre-commit for unknown block")
+	// errFinalizedPreCommit is returned if a pre-commit is for a height that is
+	// already final.
+	errFinalizedPreCommit = errors.New("pre-commit at or below the finalized height")
+	// errKnownPreCommit is returned if the signer already pre-committed the block.
+	errKnownPreCommit = errors.New("known pre-commit")
+	// errPreCommitNotValidator is returned if a pre-commit is signed by an address
+	// that is not a validator of the block.
+	errPreCommitNotValidator = errors.New("pre-commit signer is not a validator of the block")
+	// errQuorumNotReached is returned if a quorum certificate carries the votes of
+	// too few validators.
+	errQuorumNotReached = errors.New("quorum certificate below quorum")
+	// errNoFinalizedBlock is returned if no block has been finalized yet.
+	errNoFinalizedBlock = errors.New("no finalized block")
+)
+
+// FinalizedEvent is posted when a block gathered the pre-commits of a quorum of
+// its validators.
+type FinalizedEvent struct {
+	Header *types.Header
+	Cert   *types.QuorumCert
+}
+
+// quorumSize returns the number of pre-commits needed to finalize a block with
+// the given number of validators.
+func quorumSize(validators int) int {
+	return validators*2/3 + 1
+}
+
+// PreCommit signs a pre-commit for the header of the chain if the local signer
+// is the signing key of one of its validators and leads the nodes sharing that
+// key. Validators pre-commit at increasing heights only, so a nil vote without
+// error is returned if there is nothing to vote for.
+func (d *Dpos) PreCommit(chain consensus.ChainReader, header *types.Header) (*types.PreCommit, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.signFn == nil || !d.isLeader() {
+		return nil, nil
+	}
+	s, err := d.chainState(chain)
+	if err != nil {
+		return nil, err
+	}
+	number := header.Number.Uint64()
+	if number <= d.lastPreCommit(s.genesis, d.signer) {
+		return nil, nil
+	}
+	validators, err := d.blockSigners(header)
+	if err != nil {
+		return nil, err
+	}
+	if !containsAddress(validators, d.signer) {
+		return nil, nil
+	}
+	if err := d.markPreCommitted(s.genesis, d.signer, number); err != nil {
+		return nil, err
+	}
+	vote := &types.PreCommit{Number: number, Hash: header.Hash()}
+	if vote.Signature, err = d.signFn(accounts.Account{Address: d.signer}, vote.SigHash().Bytes()); err != nil {
+		return nil, err
+	}
+	return vote, nil
+}
+
+// lastPreCommit returns the highest block number of the chain with the given
+// genesis block the local node pre-committed with the signer, zero if it never
+// did.
+func (d *Dpos) lastPreCommit(genesis common.Hash, signer common.Address) uint64 {
+	enc, err := d.db.Get(lastPreCommitKey(genesis, signer))
+	if err != nil || len(enc) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(enc)
+}
+
+// markPreCommitted records that the local node is about to pre-commit a block
+// of the given height with the signer. Like markSlotSigned, the record is
+// written before signing, so that the node never pre-commits two blocks of a
+// height, even across restarts.
+func (d *Dpos) markPreCommitted(genesis common.Hash, signer common.Address, number uint64) error {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, number)
+	return d.db.Put(lastPreCommitKey(genesis, signer), enc)
+}
+
+// lastPreCommitKey = lastPreCommitPrefix + genesis hash + signer address
+func lastPreCommitKey(genesis common.Hash, signer common.Address) []byte {
+	key := append(common.CopyBytes(lastPreCommitPrefix), genesis.Bytes()...)
+	return append(key, signer.Bytes()...)
+}
+
+// AddPreCommit verifies a pre-commit and adds it to the votes gathered for its
+// block. Once a quorum of the validators of the block pre-committed it, the
+// block is final: its quorum certificate is stored next to the header and a
+// FinalizedEvent is posted. Pre-commits that are invalid or not needed any
+// more return an error and shouldn't be relayed. Pre-commits for blocks not
+// known yet are kept until RetryPreCommits finds the block.
+func (d *Dpos) AddPreCommit(chain consensus.ChainReader, vote *types.PreCommit) error {
+	header := chain.GetHeader(vote.Hash, vote.Number)
+	if header == nil {
+		d.bufferPreCommit(vote)
+		return errUnknownPreCommitBlock
+	}
+	if finalized := d.FinalizedHeader(chain); finalized != nil && vote.Number <= finalized.Number.Uint64() {
+		return errFinalizedPreCommit
+	}
+	signer, err := recoverPreCommit(vote)
+	if err != nil {
+		return err
+	}
+	validators, err := d.blockSigners(header)
+	if err != nil {
+		return err
+	}
+	if !containsAddress(validators, signer) {
+		return errPreCommitNotValidator
+	}
+
+	d.mu.Lock()
+	var votes map[common.Address][]byte
+	if cached, ok := d.preCommits.Get(vote.Hash); ok {
+		votes = cached.(map[common.Address][]byte)
+	} else {
+		votes = make(map[common.Address][]byte)
+		d.preCommits.Add(vote.Hash, votes)
+	}
+	if _, ok := votes[signer]; ok {
+		d.mu.Unlock()
+		return errKnownPreCommit
+	}
+	votes[signer] = vote.Signature
+	count := len(votes)
+
+	// Finalize the block when the quorum is reached, later votes are only relayed
+	if count != quorumSize(len(validators)) {
+		d.mu.Unlock()
+		return nil
+	}
+	cert := newQuorumCert(vote.Number, vote.Hash, votes)
+	rawdb.WriteQuorumCert(d.db, cert)
+	if s, err := d.chainState(chain); err == nil {
+		if finalized := d.finalizedHeader(chain, s); finalized == nil || finalized.Number.Uint64() < vote.Number {
+			rawdb.WriteFinalizedBlockHash(d.db, s.genesis, vote.Hash)
+			s.finalized = header
+		}
+	}
+	d.mu.Unlock()
+
+	log.Info("Finalized block", "number", vote.Number, "hash", vote.Hash, "votes", count)
+	d.finalizedFeed.Send(FinalizedEvent{Header: header, Cert: cert})
+	return nil
+}
+
+// bufferPreCommit keeps a pre-commit for a block which isn't known yet, as votes
+// overtaking their block on the network would be lost otherwise. Votes can't be
+// verified without their block, so only a bounded number is kept per block.
+func (d *Dpos) bufferPreCommit(vote *types.PreCommit) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var votes map[common.Hash]*types.PreCommit
+	if cached, ok := d.futureVotes.Get(vote.Hash); ok {
+		votes = cached.(map[common.Hash]*types.PreCommit)
+	} else {
+		votes = make(map[common.Hash]*types.PreCommit)
+		d.futureVotes.Add(vote.Hash, votes)
+	}
+	if len(votes) < maxFutureVotes {
+		votes[vote.ID()] = vote
+	}
+}
+
+// RetryPreCommits adds the buffered pre-commits whose block became known since
+// they arrived, returning the ones accepted so that they can be relayed.
+func (d *Dpos) RetryPreCommits(chain consensus.ChainReader) []*types.PreCommit {
+	var accepted []*types.PreCommit
+	for _, key := range d.futureVotes.Keys() {
+		hash := key.(common.Hash)
+		header := chain.GetHeaderByHash(hash)
+		if header == nil {
+			continue
+		}
+		d.mu.Lock()
+		cached, ok := d.futureVotes.Peek(hash)
+		d.futureVotes.Remove(hash)
+		d.mu.Unlock()
+		if !ok {
+			continue
+		}
+		for _, vote := range cached.(map[common.Hash]*types.PreCommit) {
+			if vote.Number != header.Number.Uint64() {
+				continue
+			}
+			if err := d.AddPreCommit(chain, vote); err != nil {
+				log.Trace("Discarded buffered pre-commit", "number", vote.Number, "hash", vote.Hash, "err", err)
+				continue
+			}
+			accepted = append(accepted, vote)
+		}
+	}
+	return accepted
+}
+
+// VerifyQuorumCert checks that the certificate carries the pre-commits of a
+// quorum of the validators of its block.
+func (d *Dpos) VerifyQuorumCert(chain consensus.ChainReader, cert *types.QuorumCert) error {
+	header := chain.GetHeader(cert.Hash, cert.Number)
+	if header == nil {
+		return errUnknownPreCommitBlock
+	}
+	validators, err := d.blockSigners(header)
+	if err != nil {
+		return err
+	}
+	signers := make(map[common.Address]bool)
+	for _, vote := range cert.PreCommits() {
+		signer, err := recoverPreCommit(vote)
+		if err != nil {
+			return err
+		}
+		if !containsAddress(validators, signer) {
+			return errPreCommitNotValidator
+		}
+		signers[signer] = true
+	}
+	if len(signers) < quorumSize(len(validators)) {
+		return errQuorumNotReached
+	}
+	return nil
+}
+
+// FinalizedHeader returns the latest block with a quorum certificate, nil if
+// no block has been finalized yet.
+func (d *Dpos) FinalizedHeader(chain consensus.ChainReader) *types.Header {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, err := d.chainState(chain)
+	if err != nil {
+		return nil
+	}
+	return d.finalizedHeader(chain, s)
+}
+
+// QuorumCert returns the finality certificate of a block, nil if the block is
+// not final.
+func (d *Dpos) QuorumCert(hash common.Hash, number uint64) *types.QuorumCert {
+	return rawdb.ReadQuorumCert(d.db, hash, number)
+}
+
+// SubscribeFinalizedEvent registers a subscription of FinalizedEvent.
+func (d *Dpos) SubscribeFinalizedEvent(ch chan<- FinalizedEvent) event.Subscription {
+	return d.scope.Track(d.finalizedFeed.Subscribe(ch))
+}
+
+// blockSigners returns the signing keys of the validators recorded in the epoch
+// trie of a block, the ones allowed to pre-commit it.
+func (d *Dpos) blockSigners(header *types.Header) ([]common.Address, error) {
+	dposContext, err := types.NewDposContextFromProto(d.trieDatabase(), header.DposContext)
+	if err != nil {
+		return nil, err
+	}
+	return dposContext.GetValidatorSigners()
+}
+
+// newQuorumCert builds the certificate of a block from the signatures of its
+// pre-commits, ordered by signer.
+func newQuorumCert(number uint64, hash common.Hash, votes map[common.Address][]byte) *types.QuorumCert {
+	signers := make([]common.Address, 0, len(votes))
+	for signer := range votes {
+		signers = append(signers, signer)
+	}
+	sort.Slice(signers, func(i, j int) bool {
+		return bytes.Compare(signers[i][:], signers[j][:]) < 0
+	})
+	cert := &types.QuorumCert{Number: number, Hash: hash}
+	for _, signer := range signers {
+		cert.Signatures = append(cert.Signatures, votes[signer])
+	}
+	return cert
+}
+
+// recoverPreCommit returns the validator that signed a pre-commit.
+func recoverPreCommit(vote *types.PreCommit) (common.Address, error) {
+	if len(vote.Signature) != extraSeal {
+		return common.Address{}, errMissingSignature
+	}
+	pubkey, err := crypto.SigToPub(vote.SigHash().Bytes(), vote.Signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pubkey), nil
+}
+
+func containsAddress(addrs []common.Address, addr common.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
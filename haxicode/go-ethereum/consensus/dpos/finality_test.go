@@ -0,0 +1,160 @@
+package dpos
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/haxicode/go-ethereum/accounts"
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/core/rawdb"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/crypto"
+	"github.com/haxicode/go-ethereum/ethdb"
+	"github.com/haxicode/go-ethereum/params"
+	"github.com/haxicode/go-ethereum/trie"
+	"github.com/stretchr/testify/assert"
+)
+
+// newFinalityChain returns a two block chain whose validators are the given keys,
+// with the epoch trie persisted into db.
+func newFinalityChain(t *testing.T, db ethdb.Database, keys []*ecdsa.PrivateKey) *testChain {
+	trieDB := trie.NewDatabase(db)
+	dposContext, err := types.NewDposContextFromProto(trieDB, &types.DposContextProto{})
+	assert.Nil(t, err)
+	validators := make([]common.Address, len(keys))
+	for i, key := range keys {
+		validators[i] = crypto.PubkeyToAddress(key.PublicKey)
+	}
+	assert.Nil(t, dposContext.SetValidators(validators))
+	proto, err := dposContext.Commit()
+	assert.Nil(t, err)
+	assert.Nil(t, trieDB.Commit(proto.EpochHash, false))
+
+	genesis := &types.Header{Number: big.NewInt(0), Time: big.NewInt(0), DposContext: proto}
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(blockInterval), ParentHash: genesis.Hash(), DposContext: proto}
+	return &testChain{
+		config:  &params.ChainConfig{Dpos: testDposConfig},
+		headers: []*types.Header{genesis, header},
+	}
+}
+
+// preCommitFrom returns the pre-commit of the header of chain signed by key.
+func preCommitFrom(t *testing.T, engine *Dpos, chain *testChain, key *ecdsa.PrivateKey, header *types.Header) *types.PreCommit {
+	engine.Authorize(crypto.PubkeyToAddress(key.PublicKey), func(account accounts.Account, hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, key)
+	})
+	vote, err := engine.PreCommit(chain, header)
+	assert.Nil(t, err)
+	assert.NotNil(t, vote)
+	return vote
+}
+
+func TestPreCommitFinality(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 4)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	db := ethdb.NewMemDatabase()
+	chain := newFinalityChain(t, db, keys)
+	header := chain.headers[1]
+
+	engine := New(testDposConfig, db)
+	defer engine.Close()
+	events := make(chan FinalizedEvent, 1)
+	sub := engine.SubscribeFinalizedEvent(events)
+	defer sub.Unsubscribe()
+
+	// Outsiders neither sign nor get their votes counted
+	outsider, _ := crypto.GenerateKey()
+	engine.Authorize(crypto.PubkeyToAddress(outsider.PublicKey), func(account accounts.Account, hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, outsider)
+	})
+	vote, err := engine.PreCommit(chain, header)
+	assert.Nil(t, err)
+	assert.Nil(t, vote)
+	vote = &types.PreCommit{Number: 1, Hash: header.Hash()}
+	vote.Signature, _ = crypto.Sign(vote.SigHash().Bytes(), outsider)
+	assert.Equal(t, errPreCommitNotValidator, engine.AddPreCommit(chain, vote))
+
+	// Validators pre-commit each height once, even across restarts
+	vote = preCommitFrom(t, engine, chain, keys[0], header)
+	again, err := engine.PreCommit(chain, header)
+	assert.Nil(t, err)
+	assert.Nil(t, again)
+	restarted := New(testDposConfig, db)
+	restarted.Authorize(crypto.PubkeyToAddress(keys[0].PublicKey), func(account accounts.Account, hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, keys[0])
+	})
+	again, err = restarted.PreCommit(chain, header)
+	assert.Nil(t, err)
+	assert.Nil(t, again)
+	restarted.Close()
+
+	assert.Nil(t, engine.AddPreCommit(chain, vote))
+	assert.Equal(t, errKnownPreCommit, engine.AddPreCommit(chain, vote))
+	assert.Nil(t, engine.AddPreCommit(chain, preCommitFrom(t, engine, chain, keys[1], header)))
+	assert.Nil(t, engine.FinalizedHeader(chain))
+
+	unknown := &types.PreCommit{Number: 1, Hash: common.Hash{0x01}}
+	assert.Equal(t, errUnknownPreCommitBlock, engine.AddPreCommit(chain, unknown))
+
+	// The third of four validators reaches the quorum
+	assert.Nil(t, engine.AddPreCommit(chain, preCommitFrom(t, engine, chain, keys[2], header)))
+	ev := <-events
+	assert.Equal(t, header.Hash(), ev.Header.Hash())
+	assert.Equal(t, 3, len(ev.Cert.Signatures))
+	assert.Equal(t, header.Hash(), engine.FinalizedHeader(chain).Hash())
+	assert.Equal(t, errFinalizedPreCommit, engine.AddPreCommit(chain, preCommitFrom(t, engine, chain, keys[3], header)))
+
+	// The certificate and the finalized block survive a restart
+	cert := rawdb.ReadQuorumCert(db, header.Hash(), 1)
+	assert.Equal(t, ev.Cert, cert)
+	restarted = New(testDposConfig, db)
+	defer restarted.Close()
+	assert.Equal(t, header.Hash(), restarted.FinalizedHeader(chain).Hash())
+	assert.Nil(t, restarted.VerifyQuorumCert(chain, cert))
+
+	cert.Signatures = cert.Signatures[:2]
+	assert.Equal(t, errQuorumNotReached, restarted.VerifyQuorumCert(chain, cert))
+
+	// Another chain in the same database has neither the finalized block nor the
+	// pre-commits of the first one
+	other := newFinalityChain(t, db, keys[:3])
+	assert.NotEqual(t, chain.headers[0].Hash(), other.headers[0].Hash())
+	assert.Nil(t, restarted.FinalizedHeader(other))
+	preCommitFrom(t, restarted, other, keys[0], other.headers[1])
+}
+
+func TestPreCommitBeforeBlock(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 4)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	db := ethdb.NewMemDatabase()
+	chain := newFinalityChain(t, db, keys)
+	header := chain.headers[1]
+	known := &testChain{config: chain.config, headers: chain.headers[:1]}
+
+	engine := New(testDposConfig, db)
+	defer engine.Close()
+
+	// Votes overtaking their block are kept rather than dropped
+	var votes []*types.PreCommit
+	for _, key := range keys[:3] {
+		vote := preCommitFrom(t, engine, chain, key, header)
+		assert.Equal(t, errUnknownPreCommitBlock, engine.AddPreCommit(known, vote))
+		votes = append(votes, vote)
+	}
+	assert.Empty(t, engine.RetryPreCommits(known))
+	assert.Nil(t, engine.FinalizedHeader(known))
+
+	// Once the block is known they are counted, and only once
+	accepted := engine.RetryPreCommits(chain)
+	assert.Equal(t, len(votes), len(accepted))
+	for _, vote := range votes {
+		assert.Contains(t, accepted, vote)
+	}
+	assert.Equal(t, header.Hash(), engine.FinalizedHeader(chain).Hash())
+	assert.Empty(t, engine.RetryPreCommits(chain))
+}
@@ -0,0 +1,189 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/log"
+	"github.com/haxicode/go-ethereum/params"
+)
+
+var (
+	// errIntervalNotInEpoch is returned if a proposal sets a block interval that
+	// doesn't divide the epoch, which would shift the slots of the next epochs.
+	errIntervalNotInEpoch = errors.New("block interval doesn't divide the epoch")
+	// errTooFewCandidates is returned if a proposal sets a validator size the
+	// registered candidates can't fill up to its safe size.
+	errTooFewCandidates = errors.New("too few candidates for the validator size")
+)
+
+// GovernedConfig returns the config with the params changed through governance,
+// as recorded in the DposContext, applied. Governance takes precedence over the
+// forks of the chain config.
+func GovernedConfig(config *params.DposConfig, dposContext *types.DposContext) (*params.DposConfig, error) {
+	active, err := dposContext.ActiveParams()
+	if err != nil {
+		return nil, err
+	}
+	return applyParams(config, active), nil
+}
+
+// applyParams returns a copy of config with the non-zero params overriding it.
+func applyParams(config *params.DposConfig, p *types.DposParams) *params.DposConfig {
+	cfg := *config
+	if p.MaxValidatorSize != 0 {
+		cfg.MaxValidatorSize = p.MaxValidatorSize
+	}
+	if p.BlockInterval != 0 {
+		cfg.BlockInterval = p.BlockInterval
+	}
+	if p.KickoutThreshold != 0 {
+		cfg.KickoutThreshold = p.KickoutThreshold
+	}
+	if p.BlockReward != nil && p.BlockReward.Sign() != 0 {
+		cfg.BlockReward = new(big.Int).Set(p.BlockReward)
+	}
+	return &cfg
+}
+
+// VerifyParamsProposal checks that the chain can keep running with the proposed
+// params applied on top of config: the slots still line up with the epochs and
+// enough candidates are registered to elect the safe number of validators.
+func VerifyParamsProposal(p *types.DposParams, config *params.DposConfig, dposContext *types.DposContext) error {
+	cfg := applyParams(config, p)
+	if cfg.Epoch%cfg.BlockInterval != 0 {
+		return errIntervalNotInEpoch
+	}
+	candidates, err := dposContext.GetCandidates()
+	if err != nil {
+		return err
+	}
+	if len(candidates) < cfg.SafeSize() {
+		return errTooFewCandidates
+	}
+	return nil
+}
+
+// TallyParamsProposal counts the approvals the proposal of proposer gathered
+// from the validators of the current epoch. Once 2/3+1 of them approved it, the
+// proposal is closed and its params are scheduled to take effect in the epoch
+// following epoch. It reports whether the proposal was approved.
+func TallyParamsProposal(dposContext *types.DposContext, proposer common.Address, epoch int64) (bool, error) {
+	validators, err := dposContext.GetValidators()
+	if err != nil {
+		return false, err
+	}
+	approvers, err := dposContext.ParamsApprovals(proposer)
+	if err != nil {
+		return false, err
+	}
+	approved := make(map[common.Address]bool, len(approvers))
+	for _, approver := range approvers {
+		approved[approver] = true
+	}
+	count := 0
+	for _, validator := range validators {
+		if approved[validator] {
+			count++
+		}
+	}
+	if count < len(validators)*2/3+1 {
+		return false, nil
+	}
+	proposal, err := dposContext.ParamsProposal(proposer)
+	if err != nil {
+		return false, err
+	}
+	pending, err := dposContext.PendingParams(epoch + 1)
+	if err != nil {
+		return false, err
+	}
+	if pending != nil {
+		proposal = pending.Merge(proposal)
+	}
+	if err := dposContext.SetPendingParams(epoch+1, proposal); err != nil {
+		return false, err
+	}
+	return true, dposContext.DeleteParamsProposal(proposer)
+}
+
+// activateParams puts the params pending for epoch, or for an earlier epoch
+// without blocks, into effect. Changes the candidates registered by then can't
+// elect validators for are dropped, so that the election doesn't halt the chain.
+func activateParams(dposContext *types.DposContext, config *params.DposConfig, epoch int64) error {
+	epochs, err := dposContext.PendingEpochs()
+	if err != nil {
+		return err
+	}
+	for _, pendingEpoch := range epochs {
+		if pendingEpoch > epoch {
+			break
+		}
+		pending, err := dposContext.PendingParams(pendingEpoch)
+		if err != nil {
+			return err
+		}
+		if err := dposContext.SetPendingParams(pendingEpoch, nil); err != nil {
+			return err
+		}
+		governed, err := GovernedConfig(config, dposContext)
+		if err != nil {
+			return err
+		}
+		if err := VerifyParamsProposal(pending, governed, dposContext); err != nil {
+			log.Warn("Dropped DPoS parameter change", "epoch", pendingEpoch, "err", err)
+			continue
+		}
+		active, err := dposContext.ActiveParams()
+		if err != nil {
+			return err
+		}
+		if err := dposContext.SetActiveParams(active.Merge(pending)); err != nil {
+			return err
+		}
+		log.Info("Activated DPoS parameter change", "epoch", epoch, "maxValidatorSize", pending.MaxValidatorSize,
+			"blockInterval", pending.BlockInterval, "kickoutThreshold", pending.KickoutThreshold, "blockReward", pending.BlockReward)
+	}
+	return nil
+}
+
+// ConfigAt returns the DPoS parameters in effect for the block following the
+// block with the given number and DposContext, that is the chain config at the
+// next block number with the params changed through governance applied.
+func (d *Dpos) ConfigAt(number *big.Int, dposContext *types.DposContext) (*params.DposConfig, error) {
+	return GovernedConfig(d.Config(new(big.Int).Add(number, common.Big1)), dposContext)
+}
+
+// headerConfig returns the DPoS parameters the header was sealed with: the
+// chain config at its number, with the validator size and block interval
+// recorded in the header. Finalize and VerifySeal check that the header recorded
+// those in effect, light clients check that they only change where they may
+// with VerifyParamsChange, the other checks rely on them without access to the
+// parent state.
+func (d *Dpos) headerConfig(header *types.Header) (*params.DposConfig, error) {
+	if header.MaxValidatorSize == 0 || header.BlockInterval == 0 {
+		return nil, errInvalidDposParams
+	}
+	cfg := *d.Config(header.Number)
+	cfg.MaxValidatorSize = header.MaxValidatorSize
+	cfg.BlockInterval = header.BlockInterval
+	return &cfg, nil
+}
@@ -0,0 +1,143 @@
+package dpos
+
+import (
+	"math/big"
+	"strconv"
+	"testing"
+
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/core/state"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/ethdb"
+	"github.com/haxicode/go-ethereum/trie"
+	"github.com/stretchr/testify/assert"
+)
+
+// newGovernanceContext returns a DposContext with the given number of
+// candidates, the first validators of them elected.
+func newGovernanceContext(t *testing.T, candidates, validators int) (*types.DposContext, []common.Address) {
+	dposContext, err := types.NewDposContext(trie.NewDatabase(ethdb.NewMemDatabase()))
+	assert.Nil(t, err)
+	addrs := make([]common.Address, candidates)
+	for i := range addrs {
+		addrs[i] = common.StringToAddress("addr" + strconv.Itoa(i))
+		assert.Nil(t, dposContext.BecomeCandidate(addrs[i]))
+	}
+	assert.Nil(t, dposContext.SetValidators(addrs[:validators]))
+	return dposContext, addrs
+}
+
+func TestTallyParamsProposal(t *testing.T) {
+	dposContext, addrs := newGovernanceContext(t, 5, 4)
+
+	// 3 of the 4 validators have to approve, the candidate doesn't count
+	assert.Nil(t, dposContext.ProposeParams(addrs[0], &types.DposParams{MaxValidatorSize: 5}))
+	assert.Nil(t, dposContext.ApproveParams(addrs[0], addrs[4]))
+	assert.Nil(t, dposContext.ApproveParams(addrs[0], addrs[1]))
+	approved, err := TallyParamsProposal(dposContext, addrs[0], 5)
+	assert.Nil(t, err)
+	assert.False(t, approved)
+
+	assert.Nil(t, dposContext.ApproveParams(addrs[0], addrs[2]))
+	approved, err = TallyParamsProposal(dposContext, addrs[0], 5)
+	assert.Nil(t, err)
+	assert.True(t, approved)
+
+	// The approved proposal is closed and waits for the next epoch
+	proposal, err := dposContext.ParamsProposal(addrs[0])
+	assert.Nil(t, err)
+	assert.Nil(t, proposal)
+	pending, err := dposContext.PendingParams(6)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(5), pending.MaxValidatorSize)
+
+	// Changes approved in the same epoch are merged, the latest one wins
+	assert.Nil(t, dposContext.ProposeParams(addrs[1], &types.DposParams{MaxValidatorSize: 4, BlockInterval: 5}))
+	for _, approver := range addrs[2:4] {
+		assert.Nil(t, dposContext.ApproveParams(addrs[1], approver))
+	}
+	approved, err = TallyParamsProposal(dposContext, addrs[1], 5)
+	assert.Nil(t, err)
+	assert.True(t, approved)
+	pending, err = dposContext.PendingParams(6)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(4), pending.MaxValidatorSize)
+	assert.Equal(t, uint64(5), pending.BlockInterval)
+}
+
+func TestActivateParams(t *testing.T) {
+	dposContext, _ := newGovernanceContext(t, 4, 4)
+	assert.Nil(t, dposContext.SetPendingParams(6, &types.DposParams{MaxValidatorSize: 5, BlockReward: big.NewInt(7)}))
+	assert.Nil(t, dposContext.SetPendingParams(7, &types.DposParams{MaxValidatorSize: 21}))
+
+	// Nothing changes before the epoch of the change
+	assert.Nil(t, activateParams(dposContext, testDposConfig, 5))
+	config, err := GovernedConfig(testDposConfig, dposContext)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(maxValidatorSize), config.MaxValidatorSize)
+
+	assert.Nil(t, activateParams(dposContext, testDposConfig, 6))
+	config, err = GovernedConfig(testDposConfig, dposContext)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(5), config.MaxValidatorSize)
+	assert.Equal(t, uint64(blockInterval), config.BlockInterval)
+	assert.Equal(t, int64(7), config.BlockReward.Int64())
+	assert.Nil(t, testDposConfig.BlockReward)
+
+	// 4 candidates can't elect the safe size of 21 validators, the change is
+	// dropped rather than halting the chain
+	assert.Nil(t, activateParams(dposContext, testDposConfig, 8))
+	config, err = GovernedConfig(testDposConfig, dposContext)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(5), config.MaxValidatorSize)
+	epochs, err := dposContext.PendingEpochs()
+	assert.Nil(t, err)
+	assert.Empty(t, epochs)
+}
+
+func TestVerifyParamsProposal(t *testing.T) {
+	dposContext, _ := newGovernanceContext(t, 4, 4)
+
+	assert.Nil(t, VerifyParamsProposal(&types.DposParams{MaxValidatorSize: 4, BlockInterval: 20}, testDposConfig, dposContext))
+	assert.Equal(t, errIntervalNotInEpoch, VerifyParamsProposal(&types.DposParams{MaxValidatorSize: 4, BlockInterval: 7}, testDposConfig, dposContext))
+	assert.Equal(t, errTooFewCandidates, VerifyParamsProposal(&types.DposParams{MaxValidatorSize: 6, BlockInterval: 20}, testDposConfig, dposContext))
+	assert.Equal(t, errTooFewCandidates, VerifyParamsProposal(&types.DposParams{BlockInterval: 20}, testDposConfig, dposContext))
+}
+
+func TestEpochContextKickoutThreshold(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	stateDB, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	dposContext, err := types.NewDposContext(trie.NewDatabase(db))
+	assert.Nil(t, err)
+	epochContext := &EpochContext{
+		TimeStamp:   epochInterval,
+		DposContext: dposContext,
+		statedb:     stateDB,
+	}
+	slots := epochInterval / blockInterval / maxValidatorSize
+	testEpoch := int64(1)
+
+	// validators sealing 60% of their slots are only kicked out with a higher
+	// threshold than the default
+	validators := []common.Address{}
+	for i := 0; i < maxValidatorSize; i++ {
+		validator := common.StringToAddress("addr" + strconv.Itoa(i))
+		validators = append(validators, validator)
+		assert.Nil(t, dposContext.BecomeCandidate(validator))
+		setTestMintCnt(dposContext, testEpoch, validator, slots)
+	}
+	lazy := common.StringToAddress("lazy")
+	validators[0] = lazy
+	assert.Nil(t, dposContext.BecomeCandidate(lazy))
+	setTestMintCnt(dposContext, testEpoch, lazy, slots*6/10)
+	assert.Nil(t, dposContext.BecomeCandidate(common.StringToAddress("addr")))
+	assert.Nil(t, dposContext.SetValidators(validators))
+
+	assert.Nil(t, epochContext.kickoutValidator(testEpoch, testDposConfig))
+	assert.True(t, getCandidates(dposContext.CandidateTrie())[lazy])
+
+	config := *testDposConfig
+	config.KickoutThreshold = 80
+	assert.Nil(t, epochContext.kickoutValidator(testEpoch, &config))
+	assert.False(t, getCandidates(dposContext.CandidateTrie())[lazy])
+}
@@ -0,0 +1,171 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/crypto"
+	"github.com/haxicode/go-ethereum/log"
+	"github.com/haxicode/go-ethereum/params"
+)
+
+// randaoSize is the number of extra-data bytes between the vanity and the seal
+// holding the secret revealed by the block and the commitment to the next one.
+const randaoSize = 2 * common.HashLength
+
+// randaoSeedPrefix + signer address -> secret the signer derives its randao
+// secrets from.
+var randaoSeedPrefix = []byte("dpos-randao-seed-")
+
+var (
+	// errInvalidRandao is returned if a block's extra-data has something else
+	// than a randao section between the vanity and the seal.
+	errInvalidRandao = errors.New("invalid extra-data randao section")
+	// errMissingCommitment is returned if a block's randao section doesn't commit
+	// to the secret its validator reveals next.
+	errMissingCommitment = errors.New("randao section without commitment")
+	// errInvalidReveal is returned if a block reveals a secret that doesn't match
+	// the commitment of its validator.
+	errInvalidReveal = errors.New("randao reveal doesn't match commitment")
+)
+
+// randaoSection returns the secret revealed by the header and the commitment to
+// the secret the validator reveals next. Headers without a randao section
+// reveal and commit nothing.
+func randaoSection(header *types.Header) (reveal, commitment common.Hash) {
+	if len(header.Extra) != extraVanity+randaoSize+extraSeal {
+		return common.Hash{}, common.Hash{}
+	}
+	section := header.Extra[extraVanity : extraVanity+randaoSize]
+	return common.BytesToHash(section[:common.HashLength]), common.BytesToHash(section[common.HashLength:])
+}
+
+// verifyRandaoSection checks that the extra-data of the header has a complete
+// randao section committing to the next secret of the validator once the randao
+// beacon is active. Validators can't leave the section out to withhold their
+// reveal, or drop their commitment to stop being charged for missing reveals.
+func verifyRandaoSection(config *params.DposConfig, header *types.Header) error {
+	if !config.IsRandao(header.Number) {
+		return nil
+	}
+	if len(header.Extra) != extraVanity+randaoSize+extraSeal {
+		return errInvalidRandao
+	}
+	if _, commitment := randaoSection(header); commitment == (common.Hash{}) {
+		return errMissingCommitment
+	}
+	return nil
+}
+
+// randaoSecret returns the secret the local signer commits to in the block with
+// the given number. Secrets are derived from a seed kept in the database, so
+// they survive restarts and can't be guessed by other nodes.
+func (d *Dpos) randaoSecret(signer common.Address, number uint64) (common.Hash, error) {
+	key := append(randaoSeedPrefix, signer.Bytes()...)
+	seed, err := d.db.Get(key)
+	if err != nil || len(seed) != common.HashLength {
+		seed = make([]byte, common.HashLength)
+		if _, err := rand.Read(seed); err != nil {
+			return common.Hash{}, err
+		}
+		if err := d.db.Put(key, seed); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, number)
+	return crypto.Keccak256Hash(seed, enc), nil
+}
+
+// SetRandaoSeed replaces the random seed the randao secrets of the signer are
+// derived from, so that simulations get reproducible elections. It must be set
+// before the signer commits to its first secret.
+func (d *Dpos) SetRandaoSeed(signer common.Address, seed common.Hash) error {
+	return d.db.Put(append(randaoSeedPrefix, signer.Bytes()...), seed.Bytes())
+}
+
+// prepareRandao returns the randao section of the block with the given number
+// sealed by the local signer, revealing the secret committed to in its previous
+// block and committing to a fresh one. Blocks before the randao block have none.
+func (d *Dpos) prepareRandao(number uint64, dposContext *types.DposContext, signer common.Address) ([]byte, error) {
+	if !d.config.IsRandao(new(big.Int).SetUint64(number)) {
+		return nil, nil
+	}
+	section := make([]byte, randaoSize)
+
+	if commitment, committed := dposContext.RandaoCommitment(signer); commitment != (common.Hash{}) {
+		secret, err := d.randaoSecret(signer, committed)
+		if err != nil {
+			return nil, err
+		}
+		if crypto.Keccak256Hash(secret.Bytes()) == commitment {
+			copy(section, secret.Bytes())
+		} else {
+			log.Warn("Lost randao secret, skipping reveal", "committed", committed)
+		}
+	}
+	secret, err := d.randaoSecret(signer, number)
+	if err != nil {
+		return nil, err
+	}
+	copy(section[common.HashLength:], crypto.Keccak256(secret.Bytes()))
+	return section, nil
+}
+
+// applyRandao mixes the secret revealed by the header into the randao mix of the
+// DposContext and records the commitment of its validator. Validators that
+// don't reveal a committed secret get charged in the epoch. Blocks before the
+// randao block leave the randao trie untouched.
+func applyRandao(config *params.DposConfig, header *types.Header, dposContext *types.DposContext, epoch int64) error {
+	if !config.IsRandao(header.Number) {
+		return nil
+	}
+	reveal, next := randaoSection(header)
+
+	commitment, _ := dposContext.RandaoCommitment(header.Validator)
+	switch {
+	case reveal != (common.Hash{}):
+		if crypto.Keccak256Hash(reveal.Bytes()) != commitment {
+			return errInvalidReveal
+		}
+		mix := dposContext.RandaoMix()
+		if err := dposContext.SetRandaoMix(crypto.Keccak256Hash(mix.Bytes(), reveal.Bytes())); err != nil {
+			return err
+		}
+	case commitment != (common.Hash{}):
+		if err := dposContext.AddUnrevealedCount(epoch, header.Validator, 1); err != nil {
+			return err
+		}
+	}
+	return dposContext.SetRandaoCommitment(header.Validator, next, header.Number.Uint64())
+}
+
+// electionSeed returns the seed the validators of the given epoch are shuffled
+// with: the randao mix, or the parent hash on chains nothing was revealed on.
+func electionSeed(dposContext *types.DposContext, parent *types.Header, epoch int64) int64 {
+	src := dposContext.RandaoMix()
+	if src == (common.Hash{}) {
+		src = parent.Hash()
+	}
+	return int64(binary.LittleEndian.Uint32(crypto.Keccak512(src.Bytes()))) + epoch
+}
@@ -0,0 +1,154 @@
+package dpos
+
+import (
+	"math/big"
+	"strconv"
+	"testing"
+
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/core/state"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/crypto"
+	"github.com/haxicode/go-ethereum/ethdb"
+	"github.com/haxicode/go-ethereum/trie"
+	"github.com/stretchr/testify/assert"
+)
+
+// newRandaoHeader returns a header of the validator carrying the given randao
+// section in its extra-data.
+func newRandaoHeader(number int64, validator common.Address, section []byte) *types.Header {
+	extra := make([]byte, extraVanity, extraVanity+randaoSize+extraSeal)
+	extra = append(extra, section...)
+	return &types.Header{
+		Number:    big.NewInt(number),
+		Validator: validator,
+		Extra:     append(extra, make([]byte, extraSeal)...),
+	}
+}
+
+func TestRandaoSection(t *testing.T) {
+	reveal, commitment := common.HexToHash("0x01"), common.HexToHash("0x02")
+	header := newRandaoHeader(1, common.Address{}, append(reveal.Bytes(), commitment.Bytes()...))
+	assert.Nil(t, verifyRandaoSection(testDposConfig, header))
+	haveReveal, haveCommitment := randaoSection(header)
+	assert.Equal(t, reveal, haveReveal)
+	assert.Equal(t, commitment, haveCommitment)
+
+	// Headers without a section or a commitment are rejected after the randao block
+	missing := newRandaoHeader(1, common.Address{}, nil)
+	assert.Equal(t, errInvalidRandao, verifyRandaoSection(testDposConfig, missing))
+	haveReveal, haveCommitment = randaoSection(missing)
+	assert.Equal(t, common.Hash{}, haveReveal)
+	assert.Equal(t, common.Hash{}, haveCommitment)
+	assert.Equal(t, errInvalidRandao, verifyRandaoSection(testDposConfig, newRandaoHeader(1, common.Address{}, reveal.Bytes())))
+	uncommitted := newRandaoHeader(1, common.Address{}, append(reveal.Bytes(), make([]byte, common.HashLength)...))
+	assert.Equal(t, errMissingCommitment, verifyRandaoSection(testDposConfig, uncommitted))
+
+	// Before it, headers don't need one
+	config := *testDposConfig
+	config.RandaoBlock = big.NewInt(2)
+	assert.Nil(t, verifyRandaoSection(&config, missing))
+	assert.Equal(t, errInvalidRandao, verifyRandaoSection(&config, newRandaoHeader(2, common.Address{}, nil)))
+}
+
+func TestRandaoCommitReveal(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	dposContext, err := types.NewDposContext(trie.NewDatabase(db))
+	assert.Nil(t, err)
+	engine := New(testDposConfig, db)
+	validator := common.StringToAddress("addr")
+
+	// The first block of a validator only commits
+	section, err := engine.prepareRandao(1, dposContext, validator)
+	assert.Nil(t, err)
+	first := newRandaoHeader(1, validator, section)
+	reveal, commitment := randaoSection(first)
+	assert.Equal(t, common.Hash{}, reveal)
+	assert.Nil(t, applyRandao(testDposConfig, first, dposContext, 0))
+	assert.Equal(t, common.Hash{}, dposContext.RandaoMix())
+	have, number := dposContext.RandaoCommitment(validator)
+	assert.Equal(t, commitment, have)
+	assert.Equal(t, uint64(1), number)
+
+	// Its next block reveals the secret, also after a restart
+	engine = New(testDposConfig, db)
+	section, err = engine.prepareRandao(4, dposContext, validator)
+	assert.Nil(t, err)
+	second := newRandaoHeader(4, validator, section)
+	reveal, _ = randaoSection(second)
+	assert.Equal(t, commitment, crypto.Keccak256Hash(reveal.Bytes()))
+	assert.Nil(t, applyRandao(testDposConfig, second, dposContext, 0))
+	assert.Equal(t, crypto.Keccak256Hash(common.Hash{}.Bytes(), reveal.Bytes()), dposContext.RandaoMix())
+	assert.Equal(t, uint64(0), dposContext.UnrevealedCount(0, validator))
+
+	// Wrong secrets are rejected, withheld ones are charged to the validator
+	forged := newRandaoHeader(5, validator, append(reveal.Bytes(), commitment.Bytes()...))
+	assert.Equal(t, errInvalidReveal, applyRandao(testDposConfig, forged, dposContext, 0))
+	withheld := newRandaoHeader(5, validator, append(make([]byte, common.HashLength), commitment.Bytes()...))
+	assert.Nil(t, applyRandao(testDposConfig, withheld, dposContext, 1))
+	assert.Equal(t, uint64(1), dposContext.UnrevealedCount(1, validator))
+	have, _ = dposContext.RandaoCommitment(validator)
+	assert.Equal(t, commitment, have)
+
+	// Blocks before the randao block leave the randao trie alone
+	config := *testDposConfig
+	config.RandaoBlock = big.NewInt(10)
+	assert.Nil(t, applyRandao(&config, newRandaoHeader(6, validator, nil), dposContext, 1))
+	assert.Equal(t, uint64(1), dposContext.UnrevealedCount(1, validator))
+	have, _ = dposContext.RandaoCommitment(validator)
+	assert.Equal(t, commitment, have)
+	section, err = New(&config, db).prepareRandao(6, dposContext, validator)
+	assert.Nil(t, err)
+	assert.Nil(t, section)
+}
+
+func TestRandaoElectionSeed(t *testing.T) {
+	dposContext, err := types.NewDposContext(trie.NewDatabase(ethdb.NewMemDatabase()))
+	assert.Nil(t, err)
+	parent := &types.Header{Number: big.NewInt(1), Time: big.NewInt(blockInterval)}
+
+	// Without a mix the parent hash seeds the shuffle
+	legacy := electionSeed(dposContext, parent, 2)
+	other := &types.Header{Number: big.NewInt(1), Time: big.NewInt(2 * blockInterval)}
+	assert.NotEqual(t, legacy, electionSeed(dposContext, other, 2))
+
+	// With one the parent doesn't matter anymore
+	assert.Nil(t, dposContext.SetRandaoMix(common.HexToHash("0x01")))
+	seed := electionSeed(dposContext, parent, 2)
+	assert.NotEqual(t, legacy, seed)
+	assert.Equal(t, seed, electionSeed(dposContext, other, 2))
+	assert.Equal(t, seed+1, electionSeed(dposContext, parent, 3))
+}
+
+func TestEpochContextKickoutUnrevealed(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	stateDB, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	dposContext, err := types.NewDposContext(trie.NewDatabase(db))
+	assert.Nil(t, err)
+	epochContext := &EpochContext{
+		TimeStamp:   epochInterval,
+		DposContext: dposContext,
+		statedb:     stateDB,
+	}
+	atLeastMintCnt := epochInterval / blockInterval / maxValidatorSize / 2
+	testEpoch := int64(1)
+
+	// validators withholding the randao secret in most of their blocks are
+	// kicked out, even though they sealed all of them
+	validators := []common.Address{}
+	for i := 0; i < maxValidatorSize; i++ {
+		validator := common.StringToAddress("addr" + strconv.Itoa(i))
+		validators = append(validators, validator)
+		assert.Nil(t, dposContext.BecomeCandidate(validator))
+		setTestMintCnt(dposContext, testEpoch, validator, 2*atLeastMintCnt)
+	}
+	assert.Nil(t, dposContext.AddUnrevealedCount(testEpoch, validators[0], uint64(atLeastMintCnt)+1))
+	assert.Nil(t, dposContext.AddUnrevealedCount(testEpoch, validators[1], uint64(atLeastMintCnt)))
+	assert.Nil(t, dposContext.BecomeCandidate(common.StringToAddress("addr")))
+	assert.Nil(t, dposContext.SetValidators(validators))
+	assert.Nil(t, epochContext.kickoutValidator(testEpoch, testDposConfig))
+	candidateMap := getCandidates(dposContext.CandidateTrie())
+	assert.Equal(t, maxValidatorSize, len(candidateMap))
+	assert.False(t, candidateMap[validators[0]])
+	assert.True(t, candidateMap[validators[1]])
+}
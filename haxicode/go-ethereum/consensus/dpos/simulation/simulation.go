@@ -0,0 +1,477 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package simulation runs deterministic DPoS chains in process, for testing
+// elections, kickouts and forks over many epochs without waiting for the wall
+// clock.
+//
+// Every account of a simulation runs a node with its own database, engine and
+// core.BlockChain, sealing the slots it is scheduled for and relaying its blocks
+// to the nodes of its partition. Accounts, randao seeds and the clock are all
+// derived from the account index, so a script yields the same chain every run.
+package simulation
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/haxicode/go-ethereum/accounts"
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/consensus/dpos"
+	"github.com/haxicode/go-ethereum/core"
+	"github.com/haxicode/go-ethereum/core/state"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/core/vm"
+	"github.com/haxicode/go-ethereum/crypto"
+	"github.com/haxicode/go-ethereum/ethdb"
+	"github.com/haxicode/go-ethereum/params"
+	"github.com/haxicode/go-ethereum/rlp"
+)
+
+// genesisTime is the time the simulated chains start at, rounded down to the
+// start of an epoch. It lies in the past so that no block is ever considered
+// to come from the future by the real clock.
+const genesisTime = 1500000000
+
+// balance is the genesis balance of every account.
+var balance = new(big.Int).Mul(big.NewInt(1000000), big.NewInt(params.Ether))
+
+var errUnknownAccount = errors.New("unknown account")
+
+// Node is the node run by one account of a simulation.
+type Node struct {
+	Key     *ecdsa.PrivateKey
+	Address common.Address
+	Engine  *dpos.Dpos
+	Chain   *core.BlockChain
+
+	online    bool
+	partition int
+}
+
+// API returns the dpos RPC API of the node, to inspect its chain.
+func (n *Node) API() *dpos.API {
+	return n.Engine.APIs(n.Chain)[0].Service.(*dpos.API)
+}
+
+// Head returns the head block of the node.
+func (n *Node) Head() *types.Block {
+	return n.Chain.CurrentBlock()
+}
+
+// Confirmed returns the latest block the node considers irreversible.
+func (n *Node) Confirmed() *types.Header {
+	return n.Engine.IrreversibleHeader(n.Chain)
+}
+
+// Simulator drives the nodes of a simulated DPoS network slot by slot.
+type Simulator struct {
+	Clock *Clock
+
+	config  *params.ChainConfig
+	signer  types.Signer
+	nodes   []*Node
+	nonces  map[common.Address]uint64
+	pending []*types.Transaction
+}
+
+// New creates a simulated network of the given number of accounts. The first
+// MaxValidatorSize of them are the genesis validators, the others can register
+// as candidates. The validator list of the config is ignored.
+func New(config *params.DposConfig, accounts int) (*Simulator, error) {
+	dposConfig := *config
+	epoch := int64(dposConfig.At(common.Big0).Epoch)
+	start := genesisTime / epoch * epoch
+
+	validators := int(dposConfig.MaxValidatorSize)
+	if validators > accounts {
+		validators = accounts
+	}
+	keys := make([]*ecdsa.PrivateKey, accounts)
+	alloc := make(core.GenesisAlloc)
+	dposConfig.Validators = nil
+	for i := range keys {
+		key, err := crypto.ToECDSA(crypto.Keccak256([]byte(fmt.Sprintf("dpos simulation account %d", i))))
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		alloc[addr] = core.GenesisAccount{Balance: balance}
+		if i < validators {
+			dposConfig.Validators = append(dposConfig.Validators, addr)
+		}
+	}
+	chainConfig := *params.DposChainConfig
+	chainConfig.Dpos = &dposConfig
+	genesis := &core.Genesis{
+		Config:     &chainConfig,
+		Timestamp:  uint64(start),
+		Difficulty: big.NewInt(1),
+		Alloc:      alloc,
+	}
+	sim := &Simulator{
+		Clock:  NewClock(time.Unix(start, 0)),
+		config: &chainConfig,
+		signer: types.NewEIP155Signer(chainConfig.ChainID),
+		nonces: make(map[common.Address]uint64),
+	}
+	for i, key := range keys {
+		node, err := sim.newNode(genesis, key, i)
+		if err != nil {
+			sim.Stop()
+			return nil, err
+		}
+		sim.nodes = append(sim.nodes, node)
+	}
+	return sim, nil
+}
+
+// newNode starts the node of an account on a fresh database.
+func (s *Simulator) newNode(genesis *core.Genesis, key *ecdsa.PrivateKey, index int) (*Node, error) {
+	db := ethdb.NewMemDatabase()
+	if _, err := genesis.Commit(db); err != nil {
+		return nil, err
+	}
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	engine := dpos.New(s.config.Dpos, db)
+	engine.SetClock(s.Clock)
+	engine.Authorize(addr, func(account accounts.Account, hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, key)
+	})
+	seed := crypto.Keccak256Hash([]byte(fmt.Sprintf("dpos simulation randao %d", index)))
+	if err := engine.SetRandaoSeed(addr, seed); err != nil {
+		return nil, err
+	}
+	chain, err := core.NewBlockChain(db, &core.CacheConfig{Disabled: true}, s.config, engine, vm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	return &Node{Key: key, Address: addr, Engine: engine, Chain: chain, online: true}, nil
+}
+
+// Stop shuts all nodes down.
+func (s *Simulator) Stop() {
+	for _, node := range s.nodes {
+		node.Chain.Stop()
+		node.Engine.Close()
+	}
+}
+
+// Len returns the number of accounts, and so nodes, of the simulation.
+func (s *Simulator) Len() int {
+	return len(s.nodes)
+}
+
+// Node returns the node of the account with the given index.
+func (s *Simulator) Node(i int) *Node {
+	return s.nodes[i]
+}
+
+// Address returns the address of the account with the given index.
+func (s *Simulator) Address(i int) common.Address {
+	return s.nodes[i].Address
+}
+
+// Now returns the current simulated time in seconds.
+func (s *Simulator) Now() int64 {
+	return s.Clock.Now().Unix()
+}
+
+// Run simulates the network for the given duration, one second at a time.
+func (s *Simulator) Run(d time.Duration) error {
+	end := s.Now() + int64(d/time.Second)
+	for now := s.Now() + 1; now <= end; now++ {
+		s.Clock.Set(time.Unix(now, 0))
+		if err := s.step(now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunEpochs simulates the network until the given number of epoch boundaries
+// are crossed, the simulation ending on the first second of the last epoch.
+func (s *Simulator) RunEpochs(epochs int) error {
+	for i := 0; i < epochs; i++ {
+		epoch := int64(s.dposConfig().Epoch)
+		next := (s.Now()/epoch + 1) * epoch
+		if err := s.Run(time.Duration(next-s.Now()) * time.Second); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// step lets the online nodes scheduled for the slot at time now seal a block on
+// their head, and relays each block to the online nodes of its partition.
+// Pending transactions go into the first block sealed in the first partition.
+func (s *Simulator) step(now int64) error {
+	for _, node := range s.nodes {
+		if !node.online {
+			continue
+		}
+		switch err := node.Engine.CheckValidator(node.Head(), now); err {
+		case nil:
+		case dpos.ErrWaitForPrevBlock, dpos.ErrMintFutureBlock, dpos.ErrInvalidBlockValidator, dpos.ErrInvalidMintBlockTime:
+			continue
+		default:
+			return fmt.Errorf("node %x: %v", node.Address, err)
+		}
+		var txs []*types.Transaction
+		if node.partition == 0 {
+			txs, s.pending = s.pending, nil
+		}
+		block, err := s.seal(node, now, txs)
+		if err != nil {
+			return fmt.Errorf("node %x: failed to seal block: %v", node.Address, err)
+		}
+		if _, err := node.Chain.InsertChain(types.Blocks{block}); err != nil {
+			return fmt.Errorf("node %x: failed to import own block: %v", node.Address, err)
+		}
+		for _, peer := range s.nodes {
+			if peer != node && peer.online && peer.partition == node.partition {
+				if err := s.sync(peer, node); err != nil {
+					return fmt.Errorf("node %x: failed to import block of node %x: %v", peer.Address, node.Address, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// seal builds and seals the block of the node for the slot at time now on top
+// of its head, the way the miner does. Transactions that can't be applied are
+// dropped.
+func (s *Simulator) seal(node *Node, now int64, txs []*types.Transaction) (*types.Block, error) {
+	parent := node.Head()
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   core.CalcGasLimit(parent),
+		Time:       big.NewInt(now),
+		Coinbase:   node.Address,
+	}
+	if err := node.Engine.Prepare(node.Chain, header); err != nil {
+		return nil, err
+	}
+	statedb, err := node.Chain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	dposContext, err := types.NewDposContextFromProto(statedb.Database().TrieDB(), parent.Header().DposContext)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		gasPool  = new(core.GasPool).AddGas(header.GasLimit)
+		included []*types.Transaction
+		receipts []*types.Receipt
+	)
+	for _, tx := range txs {
+		receipt, err := s.apply(node, dposContext, gasPool, statedb, header, tx)
+		if err != nil {
+			continue
+		}
+		included = append(included, tx)
+		receipts = append(receipts, receipt)
+	}
+	block, err := node.Engine.Finalize(node.Chain, header, statedb, included, nil, receipts, dposContext)
+	if err != nil {
+		return nil, err
+	}
+	return node.Engine.Seal(node.Chain, block, nil)
+}
+
+// apply applies a transaction to the block being sealed, reverting it if it
+// can't be included.
+func (s *Simulator) apply(node *Node, dposContext *types.DposContext, gasPool *core.GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction) (*types.Receipt, error) {
+	snap, dposSnap := statedb.Snapshot(), dposContext.Snapshot()
+	receipt, _, err := core.ApplyTransaction(s.config, dposContext, node.Chain, &header.Coinbase, gasPool, statedb, header, tx, &header.GasUsed, vm.Config{})
+	if err != nil {
+		statedb.RevertToSnapshot(snap)
+		dposContext.RevertToSnapShot(dposSnap)
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// sync imports into dst the blocks of the chain of src it doesn't have yet.
+func (s *Simulator) sync(dst, src *Node) error {
+	var blocks types.Blocks
+	for block := src.Head(); !dst.Chain.HasBlock(block.Hash(), block.NumberU64()); {
+		blocks = append(blocks, block)
+		block = src.Chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+		blocks[i], blocks[j] = blocks[j], blocks[i]
+	}
+	_, err := dst.Chain.InsertChain(blocks)
+	return err
+}
+
+// SetOnline takes the node of an account offline, or brings it back online.
+// Offline nodes neither seal nor receive blocks, and catch up with the nodes
+// of their partition when back online.
+func (s *Simulator) SetOnline(i int, online bool) error {
+	node := s.nodes[i]
+	node.online = online
+	if !online {
+		return nil
+	}
+	for _, peer := range s.nodes {
+		if peer != node && peer.online && peer.partition == node.partition {
+			if err := s.sync(node, peer); err != nil {
+				return fmt.Errorf("node %x: failed to catch up with node %x: %v", node.Address, peer.Address, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Partition splits the network into groups of accounts that only relay
+// blocks among themselves. Accounts not listed join the first group.
+func (s *Simulator) Partition(groups ...[]int) {
+	for _, node := range s.nodes {
+		node.partition = 0
+	}
+	for partition, group := range groups {
+		for _, i := range group {
+			s.nodes[i].partition = partition
+		}
+	}
+}
+
+// Heal reconnects all partitions. Every online node imports the chains of the
+// others and keeps the longest one it may switch to; forks below its
+// irreversible block are rejected. The forks are imported in account order,
+// forks of equal length are decided at random by core.BlockChain.
+func (s *Simulator) Heal() error {
+	s.Partition()
+	for _, dst := range s.nodes {
+		for _, src := range s.nodes {
+			if dst == src || !dst.online || !src.online {
+				continue
+			}
+			if err := s.sync(dst, src); err != nil && err != core.ErrReorgBelowIrreversible {
+				return fmt.Errorf("node %x: failed to import chain of node %x: %v", dst.Address, src.Address, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Transact queues a transaction of the account with the given index, to be
+// included in the next block. The nonce is tracked by the simulation.
+func (s *Simulator) Transact(from int, txType types.TxType, to common.Address, value *big.Int, data []byte) error {
+	if from < 0 || from >= len(s.nodes) {
+		return errUnknownAccount
+	}
+	node := s.nodes[from]
+	gas, err := core.IntrinsicGas(txType, data, false, true)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		value = new(big.Int)
+	}
+	nonce := s.nonces[node.Address]
+	tx, err := types.SignTx(types.NewTransaction(txType, nonce, to, value, gas, big.NewInt(1), data), s.signer, node.Key)
+	if err != nil {
+		return err
+	}
+	s.nonces[node.Address] = nonce + 1
+	s.pending = append(s.pending, tx)
+	return nil
+}
+
+// RegisterCandidate queues the candidate registration of an account, locking
+// the minimum candidate deposit. The account seals with its own key.
+func (s *Simulator) RegisterCandidate(i int) error {
+	if i < 0 || i >= len(s.nodes) {
+		return errUnknownAccount
+	}
+	return s.Transact(i, types.RegCandidate, s.nodes[i].Address, s.dposConfig().CandidateDeposit, nil)
+}
+
+// UpdateCandidate queues the change of the commission and description of a
+// candidate.
+func (s *Simulator) UpdateCandidate(i int, reg *types.CandidateRegistration) error {
+	if i < 0 || i >= len(s.nodes) {
+		return errUnknownAccount
+	}
+	data, err := rlp.EncodeToBytes(reg)
+	if err != nil {
+		return err
+	}
+	return s.Transact(i, types.UpdateCandidate, s.nodes[i].Address, nil, data)
+}
+
+// UnregisterCandidate queues the withdrawal of the candidacy of an account.
+func (s *Simulator) UnregisterCandidate(i int) error {
+	if i < 0 || i >= len(s.nodes) {
+		return errUnknownAccount
+	}
+	return s.Transact(i, types.UnregCandidate, s.nodes[i].Address, nil, nil)
+}
+
+// Delegate queues a vote of an account for a candidate, locking stake.
+func (s *Simulator) Delegate(from, candidate int, stake *big.Int) error {
+	if candidate < 0 || candidate >= len(s.nodes) {
+		return errUnknownAccount
+	}
+	return s.Transact(from, types.Delegate, s.nodes[candidate].Address, stake, nil)
+}
+
+// UnDelegate queues the withdrawal of the vote of an account for a candidate.
+func (s *Simulator) UnDelegate(from, candidate int) error {
+	if candidate < 0 || candidate >= len(s.nodes) {
+		return errUnknownAccount
+	}
+	return s.Transact(from, types.UnDelegate, s.nodes[candidate].Address, nil, nil)
+}
+
+// ProposeParams queues the proposal of a validator to change the parameters.
+func (s *Simulator) ProposeParams(i int, proposal *types.DposParams) error {
+	if i < 0 || i >= len(s.nodes) {
+		return errUnknownAccount
+	}
+	data, err := rlp.EncodeToBytes(proposal)
+	if err != nil {
+		return err
+	}
+	return s.Transact(i, types.ProposeParams, s.nodes[i].Address, nil, data)
+}
+
+// ApproveParams queues the approval by a validator of the proposal of another.
+func (s *Simulator) ApproveParams(from, proposer int) error {
+	if proposer < 0 || proposer >= len(s.nodes) {
+		return errUnknownAccount
+	}
+	return s.Transact(from, types.ApproveParams, s.nodes[proposer].Address, nil, nil)
+}
+
+// dposConfig returns the DPoS parameters of the next block of the first node.
+func (s *Simulator) dposConfig() *params.DposConfig {
+	head := s.nodes[0].Head()
+	return s.config.Dpos.At(new(big.Int).Add(head.Number(), common.Big1))
+}
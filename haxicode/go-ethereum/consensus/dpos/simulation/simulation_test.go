@@ -0,0 +1,250 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulation
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/common/hexutil"
+	"github.com/haxicode/go-ethereum/consensus/dpos"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testEpoch         = 40
+	testBlockInterval = 2
+)
+
+func newTestSimulator(t *testing.T, accounts int) *Simulator {
+	sim, err := New(&params.DposConfig{MaxValidatorSize: 4, BlockInterval: testBlockInterval, Epoch: testEpoch}, accounts)
+	assert.Nil(t, err)
+	return sim
+}
+
+// assertSchedule checks that every block of the node's chain was sealed by the
+// validator its parent scheduled for the slot.
+func assertSchedule(t *testing.T, node *Node) {
+	for number := node.Head().NumberU64(); number > 0; number-- {
+		header := node.Chain.GetHeaderByNumber(number)
+		validators, err := node.API().GetValidatorsAtHash(header.ParentHash)
+		assert.Nil(t, err)
+		slot := header.Time.Int64() % testEpoch / testBlockInterval
+		assert.Equal(t, validators[slot%int64(len(validators))], header.Validator, "block %d", number)
+	}
+}
+
+func contains(addrs []common.Address, addr common.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSimulationDeterministic(t *testing.T) {
+	var heads []common.Hash
+	for run := 0; run < 2; run++ {
+		sim := newTestSimulator(t, 6)
+		assert.Nil(t, sim.RunEpochs(3))
+
+		// Every slot got a block and every node follows the same chain
+		head := sim.Node(0).Head()
+		assert.Equal(t, uint64(3*testEpoch/testBlockInterval), head.NumberU64())
+		for i := 1; i < sim.Len(); i++ {
+			assert.Equal(t, head.Hash(), sim.Node(i).Head().Hash())
+		}
+		assertSchedule(t, sim.Node(0))
+
+		// Blocks are confirmed once the safe number of validators sealed on top
+		confirmed := sim.Node(0).Confirmed()
+		assert.NotNil(t, confirmed)
+		assert.True(t, head.NumberU64()-confirmed.Number.Uint64() < 4)
+
+		heads = append(heads, head.Hash())
+		sim.Stop()
+	}
+	assert.Equal(t, heads[0], heads[1])
+}
+
+func TestSimulationKickout(t *testing.T) {
+	sim := newTestSimulator(t, 6)
+	defer sim.Stop()
+
+	// Account 4 runs for validator with the stake of account 5, validator 1 goes
+	// down for good
+	assert.Nil(t, sim.RegisterCandidate(4))
+	assert.Nil(t, sim.Delegate(5, 4, big.NewInt(params.Ether)))
+	assert.Nil(t, sim.SetOnline(1, false))
+	assert.Nil(t, sim.RunEpochs(2))
+
+	node := sim.Node(0)
+	epoch := hexutil.Uint64(node.Chain.Genesis().Time().Uint64() / testEpoch)
+	stats, err := node.API().GetValidatorStats(&epoch, nil)
+	assert.Nil(t, err)
+	assert.NotNil(t, stats[sim.Address(1)])
+	assert.Equal(t, hexutil.Uint64(0), stats[sim.Address(1)].Produced)
+	assert.True(t, stats[sim.Address(1)].Missed > 0)
+
+	candidates, err := node.API().GetCandidates(nil)
+	assert.Nil(t, err)
+	assert.False(t, contains(candidates, sim.Address(1)))
+	assert.True(t, contains(candidates, sim.Address(4)))
+
+	validators, err := node.API().GetValidators(nil)
+	assert.Nil(t, err)
+	assert.Len(t, validators, 4)
+	assert.True(t, contains(validators, sim.Address(4)))
+	assert.False(t, contains(validators, sim.Address(1)))
+
+	// The chain keeps following the schedule across elections, and the node
+	// coming back catches up
+	assert.Nil(t, sim.RunEpochs(1))
+	assertSchedule(t, node)
+	assert.Nil(t, sim.SetOnline(1, true))
+	assert.Equal(t, node.Head().Hash(), sim.Node(1).Head().Hash())
+}
+
+func TestSimulationPartition(t *testing.T) {
+	sim := newTestSimulator(t, 4)
+	defer sim.Stop()
+
+	assert.Nil(t, sim.Run(10*time.Second))
+	forked := sim.Node(0).Head()
+
+	// Validator 3 is cut off, the other three keep confirming blocks
+	sim.Partition([]int{0, 1, 2}, []int{3})
+	assert.Nil(t, sim.Run(30*time.Second))
+
+	majority, minority := sim.Node(0), sim.Node(3)
+	assert.NotEqual(t, majority.Head().Hash(), minority.Head().Hash())
+	assert.True(t, majority.Head().NumberU64() > minority.Head().NumberU64())
+	assert.True(t, majority.Confirmed().Number.Cmp(forked.Number()) > 0)
+	assert.True(t, minority.Confirmed().Number.Cmp(forked.Number()) <= 0)
+
+	// The lone validator drops its fork once the network is healed
+	assert.Nil(t, sim.Heal())
+	assert.Equal(t, majority.Head().Hash(), minority.Head().Hash())
+	for number := forked.NumberU64() + 1; number <= minority.Head().NumberU64(); number++ {
+		header := minority.Chain.GetHeaderByNumber(number)
+		assert.NotEqual(t, sim.Address(3), header.Validator)
+	}
+	assert.Nil(t, sim.Run(10*time.Second))
+	assert.Equal(t, majority.Head().Hash(), minority.Head().Hash())
+}
+
+func TestSimulationTransact(t *testing.T) {
+	sim := newTestSimulator(t, 5)
+	defer sim.Stop()
+
+	assert.Nil(t, sim.RegisterCandidate(4))
+	assert.Nil(t, sim.Delegate(4, 4, big.NewInt(params.Ether)))
+	assert.Nil(t, sim.Run(4*time.Second))
+
+	block := sim.Node(2).Chain.GetBlockByNumber(1)
+	assert.Len(t, block.Transactions(), 2)
+	assert.Equal(t, types.RegCandidate, block.Transactions()[0].Type())
+
+	vote, err := sim.Node(2).API().GetVote(sim.Address(4), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, sim.Address(4), *vote)
+	assert.Equal(t, errUnknownAccount, sim.Delegate(0, 5, nil))
+}
+
+func TestSimulationGovernance(t *testing.T) {
+	sim := newTestSimulator(t, 6)
+	defer sim.Stop()
+
+	// Two more candidates register, then three of the four validators agree to
+	// elect six validators with a slower block interval
+	assert.Nil(t, sim.RegisterCandidate(4))
+	assert.Nil(t, sim.RegisterCandidate(5))
+	assert.Nil(t, sim.ProposeParams(0, &types.DposParams{MaxValidatorSize: 6, BlockInterval: 2 * testBlockInterval}))
+	assert.Nil(t, sim.ApproveParams(1, 0))
+	assert.Nil(t, sim.Run(4*time.Second))
+
+	node := sim.Node(0)
+	current, err := node.API().GetParams(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, hexutil.Uint64(4), current.MaxValidatorSize)
+
+	// Approvals of accounts that aren't validators don't count
+	assert.Nil(t, sim.ApproveParams(4, 0))
+	assert.Nil(t, sim.Run(4*time.Second))
+	assert.Nil(t, sim.ApproveParams(2, 0))
+	assert.Nil(t, sim.Run(4*time.Second))
+
+	// The change waits for the next epoch
+	current, err = node.API().GetParams(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, hexutil.Uint64(4), current.MaxValidatorSize)
+	assert.Equal(t, hexutil.Uint64(testBlockInterval), current.BlockInterval)
+	assert.Equal(t, uint64(4), node.Head().Header().MaxValidatorSize)
+
+	assert.Nil(t, sim.RunEpochs(2))
+	current, err = node.API().GetParams(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, hexutil.Uint64(6), current.MaxValidatorSize)
+	assert.Equal(t, hexutil.Uint64(2*testBlockInterval), current.BlockInterval)
+	assert.Equal(t, hexutil.Uint64(params.DefaultDposKickoutThreshold), current.KickoutThreshold)
+
+	validators, err := node.API().GetValidators(nil)
+	assert.Nil(t, err)
+	assert.Len(t, validators, 6)
+
+	// The blocks of the last epoch were sealed with the new parameters, and
+	// every node accepted them
+	head := node.Head()
+	assert.Equal(t, uint64(6), head.Header().MaxValidatorSize)
+	assert.Equal(t, uint64(2*testBlockInterval), head.Header().BlockInterval)
+	parent := node.Chain.GetHeaderByHash(head.ParentHash())
+	assert.True(t, head.Time().Uint64()-parent.Time.Uint64() >= 2*testBlockInterval)
+	for i := 1; i < sim.Len(); i++ {
+		assert.Equal(t, head.Hash(), sim.Node(i).Head().Hash())
+	}
+}
+
+func TestSimulationCandidateInfo(t *testing.T) {
+	sim := newTestSimulator(t, 6)
+	defer sim.Stop()
+
+	// Only registered candidates can describe themselves
+	assert.Nil(t, sim.RegisterCandidate(4))
+	assert.Nil(t, sim.UpdateCandidate(4, &types.CandidateRegistration{Commission: 20, Name: "four", Website: "https://example.org"}))
+	assert.Nil(t, sim.UpdateCandidate(5, &types.CandidateRegistration{Name: "five"}))
+	assert.Nil(t, sim.Run(4*time.Second))
+
+	api := sim.Node(1).API()
+	info, err := api.GetCandidateInfo(sim.Address(4), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, &dpos.CandidateInfo{Name: "four", Website: "https://example.org", Commission: 20}, info)
+	info, err = api.GetCandidateInfo(sim.Address(5), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "", info.Name)
+
+	// An update replaces the whole description
+	assert.Nil(t, sim.UpdateCandidate(4, &types.CandidateRegistration{Commission: 30, Name: "4"}))
+	assert.Nil(t, sim.Run(4*time.Second))
+	info, err = api.GetCandidateInfo(sim.Address(4), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, &dpos.CandidateInfo{Name: "4", Commission: 30}, info)
+}
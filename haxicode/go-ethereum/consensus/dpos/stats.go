@@ -0,0 +1,103 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"fmt"
+
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/common/hexutil"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/log"
+	"github.com/haxicode/go-ethereum/metrics"
+)
+
+// ValidatorStats is the activity of a validator during an epoch.
+type ValidatorStats struct {
+	Produced   hexutil.Uint64 `json:"produced"`   // Blocks sealed by the validator
+	Missed     hexutil.Uint64 `json:"missed"`     // Slots of the validator without a block
+	Unrevealed hexutil.Uint64 `json:"unrevealed"` // Blocks sealed without revealing the randao secret
+	Uptime     float64        `json:"uptime"`     // Share of its slots the validator sealed
+}
+
+// uptime returns the share of its slots a validator sealed, all of them if it
+// had none yet.
+func uptime(produced, missed uint64) float64 {
+	if produced+missed == 0 {
+		return 1
+	}
+	return float64(produced) / float64(produced+missed)
+}
+
+// validatorStats collects the activity of the validators that were scheduled
+// during the epoch.
+func validatorStats(dposContext *types.DposContext, epoch int64) (map[common.Address]*ValidatorStats, error) {
+	produced, err := dposContext.GetMintCounts(epoch)
+	if err != nil {
+		return nil, err
+	}
+	missed, err := dposContext.GetMissedCounts(epoch)
+	if err != nil {
+		return nil, err
+	}
+	unrevealed, err := dposContext.GetUnrevealedCounts(epoch)
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[common.Address]*ValidatorStats)
+	for validator, cnt := range produced {
+		stats[validator] = &ValidatorStats{Produced: hexutil.Uint64(cnt)}
+	}
+	for validator, cnt := range missed {
+		if _, ok := stats[validator]; !ok {
+			stats[validator] = new(ValidatorStats)
+		}
+		stats[validator].Missed = hexutil.Uint64(cnt)
+	}
+	for validator, cnt := range unrevealed {
+		if _, ok := stats[validator]; !ok {
+			stats[validator] = new(ValidatorStats)
+		}
+		stats[validator].Unrevealed = hexutil.Uint64(cnt)
+	}
+	for _, s := range stats {
+		s.Uptime = uptime(uint64(s.Produced), uint64(s.Missed))
+	}
+	return stats, nil
+}
+
+// reportValidatorStats publishes the activity of the validators of the epoch
+// through the metrics system, so that a validator going dark can be alerted on.
+func reportValidatorStats(dposContext *types.DposContext, epoch int64) {
+	if !metrics.Enabled {
+		return
+	}
+	validators, err := dposContext.GetValidators()
+	if err != nil {
+		log.Debug("Failed to report validator stats", "epoch", epoch, "err", err)
+		return
+	}
+	for _, validator := range validators {
+		produced, missed := dposContext.MintCount(epoch, validator), dposContext.MissedCount(epoch, validator)
+
+		prefix := fmt.Sprintf("dpos/validator/%s/", validator.Hex())
+		metrics.GetOrRegisterGauge(prefix+"produced", nil).Update(int64(produced))
+		metrics.GetOrRegisterGauge(prefix+"missed", nil).Update(int64(missed))
+		metrics.GetOrRegisterGauge(prefix+"unrevealed", nil).Update(int64(dposContext.UnrevealedCount(epoch, validator)))
+		metrics.GetOrRegisterGauge(prefix+"uptime", nil).Update(int64(uptime(produced, missed) * 100))
+	}
+}
@@ -548,6 +548,11 @@ func (ethash *Ethash) Finalize(chain consensus.ChainReader, header *types.Header
 	accumulateRewards(chain.Config(), state, header, uncles)
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
 
+	// DPoS transactions still update the DPoS state, commit to it
+	if ctx != nil {
+		header.DposContext = ctx.ToProto()
+	}
+
 	// Header seems complete, assemble into a block and return
 	return types.NewBlock(header, txs, uncles, receipts), nil
 }
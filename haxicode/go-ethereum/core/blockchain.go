@@ -71,6 +71,8 @@ type CacheConfig struct {
 	Disabled      bool          // Whether to disable trie write caching (archive node)
 	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
 	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+
+	DposSnapshots bool // Whether to keep the state of the blocks starting a DPoS epoch on disk
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -166,6 +168,9 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	}
 	bc.SetValidator(NewBlockValidator(chainConfig, bc, engine))
 	bc.SetProcessor(NewStateProcessor(chainConfig, bc, engine))
+	if engine, ok := engine.(consensus.TrieBacked); ok {
+		engine.SetTrieDB(bc.stateCache.TrieDB())
+	}
 
 	var err error
 	bc.hc, err = NewHeaderChain(db, chainConfig, engine, bc.getProcInterrupt)
@@ -192,10 +197,12 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			}
 		}
 	}
+	// Retry future blocks twice per DPoS slot, every 5s on other engines
+	ft := 5 * time.Second
+	if blockInterval := bc.GetBlockByNumber(0).Header().BlockInterval; blockInterval > 0 {
+		ft = time.Duration(blockInterval) * time.Second / 2
+	}
 	// Take ownership of this particular state
-	blockinterval := bc.GetBlockByNumber(0).Header().BlockInterval
-	fmt.Println(blockinterval)
-	ft := time.Duration(int64(blockinterval) / 2 )
 	go bc.update(ft)
 	return bc, nil
 }
@@ -222,7 +229,7 @@ func (bc *BlockChain) loadLastState() error {
 		return bc.Reset()
 	}
 	// Make sure the state associated with the block is available
-	if _, err := state.New(currentBlock.Root(), bc.stateCache); err != nil {
+	if err := bc.hasState(currentBlock); err != nil {
 		// Dangling block without a state associated, init from scratch
 		log.Warn("Head state missing, repairing chain", "number", currentBlock.Number(), "hash", currentBlock.Hash())
 		if err := bc.repair(&currentBlock); err != nil {
@@ -291,7 +298,7 @@ func (bc *BlockChain) SetHead(head uint64) error {
 		bc.currentBlock.Store(bc.GetBlock(currentHeader.Hash(), currentHeader.Number.Uint64()))
 	}
 	if currentBlock := bc.CurrentBlock(); currentBlock != nil {
-		if _, err := state.New(currentBlock.Root(), bc.stateCache); err != nil {
+		if err := bc.hasState(currentBlock); err != nil {
 			// Rewound state missing, rolled back to before pivot, reset to genesis
 			bc.currentBlock.Store(bc.genesisBlock)
 		}
@@ -336,6 +343,48 @@ func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	return nil
 }
 
+// InsertCheckpoint writes a trusted block whose state was downloaded without any
+// of its ancestors and makes it the head of the chain, which then continues from
+// there rather than from the genesis block. As under DPoS, every block after the
+// genesis is assumed to have the difficulty of the checkpoint.
+func (bc *BlockChain) InsertCheckpoint(block *types.Block, receipts types.Receipts) error {
+	// Make sure that both the state and the DPoS tries of the block exist
+	if _, err := trie.NewSecure(block.Root(), bc.stateCache.TrieDB(), 0); err != nil {
+		return err
+	}
+	if _, err := types.NewDposContextFromProto(bc.stateCache.TrieDB(), block.Header().DposContext); err != nil {
+		return err
+	}
+	if err := SetReceiptsData(bc.chainConfig, block, receipts); err != nil {
+		return fmt.Errorf("failed to set receipts data: %v", err)
+	}
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	td := new(big.Int).Mul(block.Difficulty(), new(big.Int).SetUint64(block.NumberU64()))
+	td.Add(td, bc.genesisBlock.Difficulty())
+
+	batch := bc.db.NewBatch()
+	rawdb.WriteTd(batch, block.Hash(), block.NumberU64(), td)
+	rawdb.WriteBlock(batch, block)
+	rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), receipts)
+	rawdb.WriteTxLookupEntries(batch, block)
+	rawdb.WriteCanonicalHash(batch, block.Hash(), block.NumberU64())
+	rawdb.WriteHeadBlockHash(batch, block.Hash())
+	rawdb.WriteHeadFastBlockHash(batch, block.Hash())
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	bc.mu.Lock()
+	bc.hc.SetCurrentHeader(block.Header())
+	bc.currentBlock.Store(block)
+	bc.currentFastBlock.Store(block)
+	bc.mu.Unlock()
+
+	log.Info("Imported checkpoint block", "number", block.Number(), "hash", block.Hash(), "td", td)
+	return nil
+}
+
 // GasLimit returns the gas limit of the current HEAD block.
 func (bc *BlockChain) GasLimit() uint64 {
 	return bc.CurrentBlock().GasLimit()
@@ -437,7 +486,7 @@ func (bc *BlockChain) ResetWithGenesisBlock(genesis *types.Block) error {
 func (bc *BlockChain) repair(head **types.Block) error {
 	for {
 		// Abort if we've rewound to a head block that does have associated state
-		if _, err := state.New((*head).Root(), bc.stateCache); err == nil {
+		if err := bc.hasState(*head); err == nil {
 			log.Info("Rewound blockchain to past state", "number", (*head).Number(), "hash", (*head).Hash())
 			return nil
 		}
@@ -446,6 +495,16 @@ func (bc *BlockChain) repair(head **types.Block) error {
 	}
 }
 
+// hasState checks whether both the state and the DPoS tries of a block are
+// available in the database, returning the error of the first one missing.
+func (bc *BlockChain) hasState(block *types.Block) error {
+	if _, err := state.New(block.Root(), bc.stateCache); err != nil {
+		return err
+	}
+	_, err := types.NewDposContextFromProto(bc.stateCache.TrieDB(), block.Header().DposContext)
+	return err
+}
+
 // Export writes the active chain to the given writer.
 func (bc *BlockChain) Export(w io.Writer) error {
 	return bc.ExportN(w, uint64(0), bc.CurrentBlock().NumberU64())
@@ -679,11 +738,20 @@ func (bc *BlockChain) Stop() {
 		for _, offset := range []uint64{0, 1, triesInMemory - 1} {
 			if number := bc.CurrentBlock().NumberU64(); number > offset {
 				recent := bc.GetBlockByNumber(number - offset)
+				if recent == nil {
+					// Below the checkpoint the chain was synced from
+					continue
+				}
 
 				log.Info("Writing cached state to disk", "block", recent.Number(), "hash", recent.Hash(), "root", recent.Root())
 				if err := triedb.Commit(recent.Root(), true); err != nil {
 					log.Error("Failed to commit recent state trie", "err", err)
 				}
+				for _, root := range recent.Header().DposContext.Roots() {
+					if err := triedb.Commit(root, false); err != nil {
+						log.Error("Failed to commit recent DPoS trie", "err", err)
+					}
+				}
 			}
 		}
 		for !bc.triegc.Empty() {
@@ -762,7 +830,7 @@ func SetReceiptsData(config *params.ChainConfig, block *types.Block, receipts ty
 		receipts[j].TxHash = transactions[j].Hash()
 
 		// The contract address can be derived from the transaction itself
-		if transactions[j].To() == nil {
+		if transactions[j].To() == nil && transactions[j].Type() == types.Binary {
 			// Deriving the signer is expensive, only do if it's actually needed
 			from, _ := types.Sender(signer, transactions[j])
 			receipts[j].ContractAddress = crypto.CreateAddress(from, transactions[j].Nonce())
@@ -916,7 +984,11 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	if err != nil {
 		return NonStatTy, err
 	}
-
+	// The DPoS tries live in the same trie database as the state
+	dposContext, err := block.DposContext.Commit()
+	if err != nil {
+		return NonStatTy, err
+	}
 	triedb := bc.stateCache.TrieDB()
 
 	// If we're running an archive node, always flush
@@ -924,12 +996,34 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 		if err := triedb.Commit(root, false); err != nil {
 			return NonStatTy, err
 		}
+		for _, dposRoot := range dposContext.Roots() {
+			if err := triedb.Commit(dposRoot, false); err != nil {
+				return NonStatTy, err
+			}
+		}
 	} else {
 		// Full but not archive node, do proper garbage collection
 		triedb.Reference(root, common.Hash{}) // metadata reference to keep trie alive
 		bc.triegc.Push(root, -float32(block.NumberU64()))
+		for _, dposRoot := range dposContext.Roots() {
+			triedb.Reference(dposRoot, common.Hash{})
+			bc.triegc.Push(dposRoot, -float32(block.NumberU64()))
+		}
+		// Keep the state of the blocks starting an epoch if asked to, which can be exported
+		// as snapshots. These states are never garbage collected.
+		if bc.cacheConfig.DposSnapshots && bc.isEpochStart(block.Header(), bc.GetHeader(block.ParentHash(), block.NumberU64()-1)) {
+			if err := triedb.Commit(root, false); err != nil {
+				return NonStatTy, err
+			}
+			for _, dposRoot := range dposContext.Roots() {
+				if err := triedb.Commit(dposRoot, false); err != nil {
+					return NonStatTy, err
+				}
+			}
+		}
 
-		if current := block.NumberU64(); current > triesInMemory {
+		// Tries of the blocks below a checkpoint the chain was synced from don't exist
+		if current := block.NumberU64(); current > triesInMemory && bc.GetHeaderByNumber(current-triesInMemory) != nil {
 			// If we exceeded our memory allowance, flush matured singleton nodes to disk
 			var (
 				nodes, imgs = triedb.Size()
@@ -951,6 +1045,9 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 				}
 				// Flush an entire trie and restart the counters
 				triedb.Commit(header.Root, true)
+				for _, dposRoot := range header.DposContext.Roots() {
+					triedb.Commit(dposRoot, false)
+				}
 				lastWrite = chosen
 				bc.gcproc = 0
 			}
@@ -970,10 +1067,6 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	batch := bc.db.NewBatch()
 	rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), receipts)
 
-	if _, err := block.DposContext.Commit(); err != nil {
-		return NonStatTy, err
-	}
-
 	// If the total difficulty is higher than our known, add it to the canonical chain
 	// Second clause in the if statement reduces the vulnerability to selfish mining.
 	// Please refer to http://www.cs.cornell.edu/~ie53/publications/btcProcFC.pdf
@@ -983,13 +1076,18 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 		// Split same-difficulty blocks by number, then at random
 		reorg = block.NumberU64() < currentBlock.NumberU64() || (block.NumberU64() == currentBlock.NumberU64() && mrand.Float64() < 0.5)
 	}
-	if reorg {
-		// Reorganise the chain if the parent is not the head block
-		if block.ParentHash() != currentBlock.Hash() {
-			if err := bc.reorg(currentBlock, block); err != nil {
+	// Reorganise the chain if the parent is not the head block, unless that
+	// would revert an irreversible block
+	if reorg && block.ParentHash() != currentBlock.Hash() {
+		if err := bc.reorg(currentBlock, block); err != nil {
+			if err != ErrReorgBelowIrreversible {
 				return NonStatTy, err
 			}
+			log.Warn("Refused reorg below irreversible block", "number", block.Number(), "hash", block.Hash())
+			reorg = false
 		}
+	}
+	if reorg {
 		// Write the positional metadata for transaction/receipt lookups and preimages
 		rawdb.WriteTxLookupEntries(batch, block)
 		rawdb.WritePreimages(batch, block.NumberU64(), state.Preimages())
@@ -1030,8 +1128,6 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 	if len(chain) == 0 {
 		return 0, nil, nil, nil
 	}
-	// get genesis header from genesis block
-	genesisblock := bc.genesisBlock
 	// Do a sanity check that the provided chain is actually ordered and linked
 	for i := 1; i < len(chain); i++ {
 		if chain[i].NumberU64() != chain[i-1].NumberU64()+1 || chain[i].ParentHash() != chain[i-1].Hash() {
@@ -1050,6 +1146,12 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 	bc.chainmu.Lock()
 	defer bc.chainmu.Unlock()
 
+	// Refuse forks that would rewrite irreversible history
+	if err := bc.hc.CheckIrreversible(chain[0].Header()); err != nil {
+		log.Warn("Rejected fork below irreversible block", "number", chain[0].Number(), "hash", chain[0].Hash())
+		return 0, nil, nil, err
+	}
+
 	// A queued approach to delivering events. This is generally
 	// faster than direct delivery and requires much less mutex
 	// acquiring.
@@ -1194,7 +1296,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		// Validate validator
 		dposEngine, isDpos := bc.engine.(*dpos.Dpos)
 		if isDpos {
-			err = dposEngine.VerifySeal(bc, block.Header(),genesisblock.Header())
+			err = dposEngine.VerifySeal(bc, block.Header())
 			if err != nil {
 				bc.reportBlock(block, receipts, err)
 				return i, events, coalescedLogs, err
@@ -1361,6 +1463,10 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 			return fmt.Errorf("Invalid new chain")
 		}
 	}
+	// Irreversible blocks are never reverted
+	if irreversible := bc.IrreversibleHeader(); irreversible != nil && commonBlock.NumberU64() < irreversible.Number.Uint64() {
+		return ErrReorgBelowIrreversible
+	}
 	// Ensure the user sees large reorgs
 	if len(oldChain) > 0 && len(newChain) > 0 {
 		logFn := log.Debug
@@ -1419,9 +1525,7 @@ func (bc *BlockChain) PostChainEvents(events []interface{}, logs []*types.Log) {
 }
 
 func (bc *BlockChain) update(ft time.Duration) {
-	// default value is 5s
-	ft = ft
-	futureTimer := time.NewTicker(ft *time.Second)
+	futureTimer := time.NewTicker(ft)
 	defer futureTimer.Stop()
 	for {
 		select {
@@ -1529,6 +1633,18 @@ func (bc *BlockChain) CurrentHeader() *types.Header {
 	return bc.hc.CurrentHeader()
 }
 
+// FinalizedHeader retrieves the latest header with a quorum certificate, nil if
+// no block has been finalized yet.
+func (bc *BlockChain) FinalizedHeader() *types.Header {
+	return bc.hc.FinalizedHeader()
+}
+
+// IrreversibleHeader retrieves the latest header the consensus engine won't
+// revert any more, nil if there is none yet.
+func (bc *BlockChain) IrreversibleHeader() *types.Header {
+	return bc.hc.IrreversibleHeader()
+}
+
 // GetTd retrieves a block's total difficulty in the canonical chain from the
 // database by hash and number, caching it if found.
 func (bc *BlockChain) GetTd(hash common.Hash, number uint64) *big.Int {
@@ -1592,6 +1708,10 @@ func (bc *BlockChain) Engine() consensus.Engine { return bc.engine }
 // export db
 func (bc *BlockChain) ChainDB() ethdb.Database  {return bc.db}
 
+// StateCache returns the caching database the state and DPoS tries of the chain
+// are read from and written to.
+func (bc *BlockChain) StateCache() state.Database { return bc.stateCache }
+
 // SubscribeRemovedLogsEvent registers a subscription of RemovedLogsEvent.
 func (bc *BlockChain) SubscribeRemovedLogsEvent(ch chan<- RemovedLogsEvent) event.Subscription {
 	return bc.scope.Track(bc.rmLogsFeed.Subscribe(ch))
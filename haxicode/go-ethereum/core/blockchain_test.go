@@ -17,6 +17,7 @@
 package core
 
 import (
+	"crypto/ecdsa"
 	"fmt"
 	"math/big"
 	"math/rand"
@@ -24,8 +25,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/haxicode/go-ethereum/accounts"
 	"github.com/haxicode/go-ethereum/common"
 	"github.com/haxicode/go-ethereum/consensus"
+	"github.com/haxicode/go-ethereum/consensus/dpos"
 	"github.com/haxicode/go-ethereum/consensus/ethash"
 	"github.com/haxicode/go-ethereum/core/rawdb"
 	"github.com/haxicode/go-ethereum/core/state"
@@ -34,6 +37,7 @@ import (
 	"github.com/haxicode/go-ethereum/crypto"
 	"github.com/haxicode/go-ethereum/ethdb"
 	"github.com/haxicode/go-ethereum/params"
+	"github.com/haxicode/go-ethereum/rlp"
 )
 
 // So we can deterministically seed different blockchains
@@ -357,7 +361,6 @@ func testBrokenChain(t *testing.T, full bool) {
 	}
 }
 
-func (bproc) ValidateDposState(block *types.Block) error { return nil }
 // Tests that reorganising a long difficult chain after a short easy one
 // overwrites the canonical numbers and links in the database.
 func TestReorgLongHeaders(t *testing.T) { testReorgLong(t, false) }
@@ -1401,3 +1404,278 @@ func BenchmarkBlockChain_1x1000Executions(b *testing.B) {
 
 	benchmarkLargeNumberOfValueToNonexisting(b, numTxs, numBlocks, recipientFn, dataFn)
 }
+
+// dposTestClock is a dpos.Clock which only moves when set, or when waited on.
+type dposTestClock struct {
+	now time.Time
+}
+
+func (c *dposTestClock) Now() time.Time { return c.now }
+
+func (c *dposTestClock) After(d time.Duration) <-chan time.Time {
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// newDposTestGenesis returns the genesis of a DPoS chain validated by a single
+// account, which issues no block reward so that empty blocks keep the state of
+// their parent.
+func newDposTestGenesis() (*Genesis, *ecdsa.PrivateKey) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+
+	config := *params.DposChainConfig
+	config.Dpos = &params.DposConfig{
+		Validators:       []common.Address{addr},
+		MaxValidatorSize: 1,
+		BlockInterval:    1,
+		Epoch:            10,
+		BlockReward:      new(big.Int),
+	}
+	genesis := &Genesis{
+		Config:     &config,
+		Timestamp:  1000,
+		Difficulty: big.NewInt(1),
+		Alloc:      GenesisAlloc{addr: {Balance: big.NewInt(1000000000000000000)}},
+	}
+	return genesis, key
+}
+
+// newDposTestEngine creates a DPoS engine sealing with the key, whose clock
+// starts at the time of the genesis block.
+func newDposTestEngine(genesis *Genesis, db ethdb.Database, key *ecdsa.PrivateKey) (*dpos.Dpos, *dposTestClock) {
+	clock := &dposTestClock{now: time.Unix(int64(genesis.Timestamp), 0)}
+	engine := dpos.New(genesis.Config.Dpos, db)
+	engine.SetClock(clock)
+	engine.Authorize(crypto.PubkeyToAddress(key.PublicKey), func(account accounts.Account, hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, key)
+	})
+	return engine, clock
+}
+
+// insertDposBlocks seals n empty blocks on top of the head of the chain, one in
+// every slot, and inserts them into the chain.
+func insertDposBlocks(t *testing.T, chain *BlockChain, engine *dpos.Dpos, clock *dposTestClock, n int) {
+	for i := 0; i < n; i++ {
+		insertDposBlock(t, chain, engine, clock, nil)
+	}
+}
+
+// insertDposBlock seals a block with the transactions in the slot after the head
+// of the chain the way the miner does, and inserts it into the chain.
+func insertDposBlock(t *testing.T, chain *BlockChain, engine *dpos.Dpos, clock *dposTestClock, txs types.Transactions) *types.Block {
+	parent := chain.CurrentBlock()
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   CalcGasLimit(parent),
+		Time:       new(big.Int).Add(parent.Time(), new(big.Int).SetUint64(parent.Header().BlockInterval)),
+		Coinbase:   parent.Coinbase(),
+	}
+	clock.now = time.Unix(header.Time.Int64(), 0)
+	if err := engine.Prepare(chain, header); err != nil {
+		t.Fatalf("block %d: failed to prepare header: %v", header.Number, err)
+	}
+	statedb, err := chain.StateAt(parent.Root())
+	if err != nil {
+		t.Fatalf("block %d: failed to retrieve parent state: %v", header.Number, err)
+	}
+	dposContext, err := types.NewDposContextFromProto(statedb.Database().TrieDB(), parent.Header().DposContext)
+	if err != nil {
+		t.Fatalf("block %d: failed to retrieve parent DPoS state: %v", header.Number, err)
+	}
+	var (
+		gasPool  = new(GasPool).AddGas(header.GasLimit)
+		receipts types.Receipts
+	)
+	for i, tx := range txs {
+		statedb.Prepare(tx.Hash(), common.Hash{}, i)
+		receipt, _, err := ApplyTransaction(chain.Config(), dposContext, chain, nil, gasPool, statedb, header, tx, &header.GasUsed, vm.Config{})
+		if err != nil {
+			t.Fatalf("block %d: failed to apply transaction %d: %v", header.Number, i, err)
+		}
+		receipts = append(receipts, receipt)
+	}
+	block, err := engine.Finalize(chain, header, statedb, txs, nil, receipts, dposContext)
+	if err != nil {
+		t.Fatalf("block %d: failed to finalize: %v", header.Number, err)
+	}
+	if block, err = engine.Seal(chain, block, nil); err != nil {
+		t.Fatalf("block %d: failed to seal: %v", header.Number, err)
+	}
+	if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+		t.Fatalf("block %d: failed to insert: %v", header.Number, err)
+	}
+	return block
+}
+
+// Tests that the DPoS transactions acting on their sender are mined without a
+// recipient, and don't get a contract address.
+func TestDposTransactionsWithoutRecipient(t *testing.T) {
+	genesis, key := newDposTestGenesis()
+	candidateKey, _ := crypto.GenerateKey()
+	candidate := crypto.PubkeyToAddress(candidateKey.PublicKey)
+	genesis.Alloc[candidate] = GenesisAccount{Balance: big.NewInt(1000000000000000000)}
+	db := ethdb.NewMemDatabase()
+	genesis.MustCommit(db)
+
+	engine, clock := newDposTestEngine(genesis, db, key)
+	defer engine.Close()
+	chain, err := NewBlockChain(db, nil, genesis.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	signer := types.NewEIP155Signer(genesis.Config.ChainID)
+	newTx := func(txType types.TxType, nonce uint64, value *big.Int, data []byte) *types.Transaction {
+		gas, err := IntrinsicGas(txType, data, false, true)
+		if err != nil {
+			t.Fatalf("failed to compute intrinsic gas: %v", err)
+		}
+		tx, err := types.SignTx(types.NewTransaction(txType, nonce, common.Address{}, value, gas, big.NewInt(1), data), signer, candidateKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		if tx.To() != nil {
+			t.Fatalf("%v transaction has a recipient", txType)
+		}
+		return tx
+	}
+	info, err := rlp.EncodeToBytes(&types.CandidateRegistration{Commission: 20, Name: "test"})
+	if err != nil {
+		t.Fatalf("failed to encode registration: %v", err)
+	}
+	tests := []struct {
+		txs       types.Transactions
+		candidate bool // Whether the sender is a candidate after the block
+	}{
+		{
+			txs: types.Transactions{
+				newTx(types.RegCandidate, 0, big.NewInt(1000), nil),
+				newTx(types.UpdateCandidate, 1, nil, info),
+			},
+			candidate: true,
+		},
+		{
+			txs:       types.Transactions{newTx(types.UnregCandidate, 2, nil, nil)},
+			candidate: false,
+		},
+	}
+	for i, tt := range tests {
+		block := insertDposBlock(t, chain, engine, clock, tt.txs)
+		receipts := rawdb.ReadReceipts(db, block.Hash(), block.NumberU64())
+		if len(receipts) != len(tt.txs) {
+			t.Fatalf("block %d: receipt count mismatch: have %d, want %d", i, len(receipts), len(tt.txs))
+		}
+		for j, receipt := range receipts {
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				t.Errorf("block %d, tx %d: failed: %s", i, j, receipt.Reason)
+			}
+			if receipt.ContractAddress != (common.Address{}) {
+				t.Errorf("block %d, tx %d: contract address %x derived", i, j, receipt.ContractAddress)
+			}
+		}
+		dposContext, err := types.NewDposContextFromProto(chain.stateCache.TrieDB(), block.Header().DposContext)
+		if err != nil {
+			t.Fatalf("block %d: failed to load DPoS state: %v", i, err)
+		}
+		if registered, _ := dposContext.IsCandidate(candidate); registered != tt.candidate {
+			t.Errorf("block %d: candidacy mismatch: have %v, want %v", i, registered, tt.candidate)
+		}
+	}
+}
+
+// Tests that processing a block fails if the consensus engine can't finalize it,
+// here because the header doesn't carry the DPoS params in effect.
+func TestDposProcessFinalizeError(t *testing.T) {
+	genesis, key := newDposTestGenesis()
+	db := ethdb.NewMemDatabase()
+	genesis.MustCommit(db)
+
+	engine, clock := newDposTestEngine(genesis, db, key)
+	defer engine.Close()
+	chain, err := NewBlockChain(db, nil, genesis.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	insertDposBlocks(t, chain, engine, clock, 1)
+
+	parent := chain.CurrentBlock()
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   CalcGasLimit(parent),
+		Time:       new(big.Int).Add(parent.Time(), new(big.Int).SetUint64(parent.Header().BlockInterval)),
+		Coinbase:   parent.Coinbase(),
+	}
+	clock.now = time.Unix(header.Time.Int64(), 0)
+	if err := engine.Prepare(chain, header); err != nil {
+		t.Fatalf("failed to prepare header: %v", err)
+	}
+	header.MaxValidatorSize++
+
+	block := types.NewBlockWithHeader(header)
+	if block.DposContext, err = types.NewDposContextFromProto(chain.stateCache.TrieDB(), parent.Header().DposContext); err != nil {
+		t.Fatalf("failed to retrieve parent DPoS state: %v", err)
+	}
+	statedb, err := chain.StateAt(parent.Root())
+	if err != nil {
+		t.Fatalf("failed to retrieve parent state: %v", err)
+	}
+	if _, _, _, err := chain.Processor().Process(block, statedb, vm.Config{}); err == nil {
+		t.Fatalf("block with mismatching DPoS params processed")
+	}
+}
+
+// Tests that a chain killed without being stopped is rewound on restart to a
+// block whose DPoS tries were written to disk, even if the state of the head
+// block is available.
+func TestDposStateRepairAfterCrash(t *testing.T) {
+	genesis, key := newDposTestGenesis()
+	db := ethdb.NewMemDatabase()
+	genesis.MustCommit(db)
+
+	engine, clock := newDposTestEngine(genesis, db, key)
+	defer engine.Close()
+	chain, err := NewBlockChain(db, nil, genesis.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	// Seal past the start of the second epoch, the mint counts of the blocks after
+	// it only being kept in memory
+	insertDposBlocks(t, chain, engine, clock, 15)
+	head := chain.CurrentBlock()
+	if head.Root() != chain.Genesis().Root() {
+		t.Fatalf("head state root mismatch: have %x, want the genesis one %x", head.Root(), chain.Genesis().Root())
+	}
+	if _, err := types.NewDposContextFromProto(state.NewDatabase(db).TrieDB(), head.Header().DposContext); err == nil {
+		t.Fatalf("DPoS tries of the head written to disk before stopping")
+	}
+	// Reopen the database without stopping the chain
+	restarted, err := NewBlockChain(db, nil, genesis.Config, dpos.New(genesis.Config.Dpos, db), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to reopen chain: %v", err)
+	}
+	defer restarted.Stop()
+
+	current := restarted.CurrentBlock()
+	if current.NumberU64() >= head.NumberU64() {
+		t.Fatalf("head not rewound: have #%d, head was #%d", current.NumberU64(), head.NumberU64())
+	}
+	if _, err := types.NewDposContextFromProto(restarted.stateCache.TrieDB(), current.Header().DposContext); err != nil {
+		t.Fatalf("DPoS tries of the rewound head #%d missing: %v", current.NumberU64(), err)
+	}
+	// Rewinding onto a block without DPoS tries doesn't leave it as the head either
+	restarted.currentBlock.Store(head)
+	if err := restarted.SetHead(head.NumberU64() - 1); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	current = restarted.CurrentBlock()
+	if _, err := types.NewDposContextFromProto(restarted.stateCache.TrieDB(), current.Header().DposContext); err != nil {
+		t.Fatalf("DPoS tries of the rewound head #%d missing: %v", current.NumberU64(), err)
+	}
+}
@@ -257,6 +257,8 @@ func makeHeader(chain consensus.ChainReader, parent *types.Block, state *state.S
 		Number:   new(big.Int).Add(parent.Number(), common.Big1),
 		Time:     time,
 		Extra: make([]byte,extraVanity+extraSeal),
+		MaxValidatorSize: parent.Header().MaxValidatorSize,
+		BlockInterval:    parent.Header().BlockInterval,
 	}
 }
 
@@ -0,0 +1,125 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/haxicode/go-ethereum/core/state"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/log"
+)
+
+// ChainSnapshot is a block along with its receipts and everything needed to
+// rebuild its state and its DPoS tries, from which a chain can be continued
+// without any of the ancestors of the block.
+type ChainSnapshot struct {
+	Block    *types.Block
+	Receipts types.Receipts
+	Accounts []state.FlatAccount
+	Dpos     [][]types.DposEntry
+}
+
+// isEpochStart reports whether the header is the first of a DPoS epoch, other
+// than the one of the first block. Nothing after such a block depends on its
+// ancestors, not even on the time of the first block, which only shortens the
+// first epoch.
+func (bc *BlockChain) isEpochStart(header, parent *types.Header) bool {
+	if bc.chainConfig.Dpos == nil || parent == nil || parent.Number.Sign() == 0 {
+		return false
+	}
+	length, parentLength := bc.chainConfig.Dpos.At(header.Number).Epoch, bc.chainConfig.Dpos.At(parent.Number).Epoch
+	return length != parentLength || header.Time.Uint64()/length != parent.Time.Uint64()/parentLength
+}
+
+// LastSnapshotBlock returns the last canonical block at or below number which
+// starts a DPoS epoch and whose state is available, nil if there is none.
+func (bc *BlockChain) LastSnapshotBlock(number uint64) *types.Block {
+	header := bc.GetHeaderByNumber(number)
+	for header != nil && header.Number.Sign() > 0 {
+		parent := bc.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+		if bc.isEpochStart(header, parent) && bc.HasState(header.Root) {
+			if _, err := types.NewDposContextFromProto(bc.stateCache.TrieDB(), header.DposContext); err == nil {
+				return bc.GetBlock(header.Hash(), header.Number.Uint64())
+			}
+		}
+		header = parent
+	}
+	return nil
+}
+
+// Snapshot returns the snapshot of a block whose state is available.
+func (bc *BlockChain) Snapshot(block *types.Block) (*ChainSnapshot, error) {
+	accounts, err := state.FlatDump(bc.stateCache, block.Root())
+	if err != nil {
+		return nil, err
+	}
+	dposContext, err := types.NewDposContextFromProto(bc.stateCache.TrieDB(), block.Header().DposContext)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := dposContext.Entries()
+	if err != nil {
+		return nil, err
+	}
+	return &ChainSnapshot{
+		Block:    block,
+		Receipts: bc.GetReceiptsByHash(block.Hash()),
+		Accounts: accounts,
+		Dpos:     entries,
+	}, nil
+}
+
+// ImportSnapshot rebuilds the state and the DPoS tries of a snapshot, checks
+// them against its block, and makes the block the head of the chain like
+// InsertCheckpoint does.
+func (bc *BlockChain) ImportSnapshot(snapshot *ChainSnapshot) error {
+	var (
+		block  = snapshot.Block
+		triedb = bc.stateCache.TrieDB()
+	)
+	if block.Header().DposContext == nil {
+		return fmt.Errorf("block %d has no DPoS context", block.NumberU64())
+	}
+	if hash := types.DeriveSha(snapshot.Receipts); hash != block.ReceiptHash() {
+		return fmt.Errorf("receipt root hash mismatch: have %x, want %x", hash, block.ReceiptHash())
+	}
+	root, err := state.RestoreFlatDump(triedb, snapshot.Accounts)
+	if err != nil {
+		return err
+	}
+	if root != block.Root() {
+		return fmt.Errorf("state root mismatch: have %x, want %x", root, block.Root())
+	}
+	dposContext, err := types.RestoreDposContext(triedb, snapshot.Dpos)
+	if err != nil {
+		return err
+	}
+	if dposContext.Root() != block.Header().DposContext.Root() {
+		return fmt.Errorf("DPoS context root mismatch: have %x, want %x", dposContext.Root(), block.Header().DposContext.Root())
+	}
+	if err := triedb.Commit(root, true); err != nil {
+		return err
+	}
+	for _, dposRoot := range dposContext.Roots() {
+		if err := triedb.Commit(dposRoot, false); err != nil {
+			return err
+		}
+	}
+	log.Info("Restored snapshot state", "number", block.Number(), "hash", block.Hash(), "accounts", len(snapshot.Accounts))
+	return bc.InsertCheckpoint(block, snapshot.Receipts)
+}
@@ -0,0 +1,122 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/haxicode/go-ethereum/consensus/dpos"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/core/vm"
+	"github.com/haxicode/go-ethereum/ethdb"
+)
+
+// newDposSnapshotChain seals n blocks of a DPoS chain and restarts it, so that
+// only the states written to disk are left.
+func newDposSnapshotChain(t *testing.T, n int, snapshots bool) (*BlockChain, *Genesis) {
+	genesis, key := newDposTestGenesis()
+	db := ethdb.NewMemDatabase()
+	genesis.MustCommit(db)
+
+	cacheConfig := &CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute, DposSnapshots: snapshots}
+	engine, clock := newDposTestEngine(genesis, db, key)
+	chain, err := NewBlockChain(db, cacheConfig, genesis.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	insertDposBlocks(t, chain, engine, clock, n)
+	chain.Stop()
+	engine.Close()
+
+	chain, err = NewBlockChain(db, cacheConfig, genesis.Config, dpos.New(genesis.Config.Dpos, db), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to reopen chain: %v", err)
+	}
+	return chain, genesis
+}
+
+// Tests that the state of the blocks starting an epoch is only kept when asked
+// for.
+func TestDposSnapshotRetention(t *testing.T) {
+	chain, _ := newDposSnapshotChain(t, 25, false)
+	defer chain.Stop()
+	if block := chain.LastSnapshotBlock(22); block != nil {
+		t.Fatalf("state of epoch start #%d kept without snapshots", block.NumberU64())
+	}
+	chain, _ = newDposSnapshotChain(t, 25, true)
+	defer chain.Stop()
+	if block := chain.LastSnapshotBlock(22); block == nil || block.NumberU64() != 20 {
+		t.Fatalf("snapshot block mismatch: have %v, want #20", block)
+	}
+}
+
+// Tests that a snapshot exported from one chain starts another one, which then
+// continues with the blocks after it.
+func TestDposImportSnapshot(t *testing.T) {
+	source, genesis := newDposSnapshotChain(t, 25, true)
+	defer source.Stop()
+
+	block := source.LastSnapshotBlock(source.CurrentBlock().NumberU64())
+	if block == nil {
+		t.Fatalf("no snapshot block")
+	}
+	snapshot, err := source.Snapshot(block)
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	db := ethdb.NewMemDatabase()
+	genesis.MustCommit(db)
+	chain, err := NewBlockChain(db, nil, genesis.Config, dpos.New(genesis.Config.Dpos, db), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// Snapshots whose state doesn't match their block are rejected
+	forged := *snapshot
+	forged.Accounts = append(forged.Accounts[:0:0], snapshot.Accounts...)
+	forged.Accounts[0].Balance = new(big.Int).Add(forged.Accounts[0].Balance, big.NewInt(1))
+	if err := chain.ImportSnapshot(&forged); err == nil {
+		t.Fatalf("forged snapshot imported")
+	}
+	if head := chain.CurrentBlock().NumberU64(); head != 0 {
+		t.Fatalf("head moved by forged snapshot: have #%d", head)
+	}
+	// The genuine one becomes the head, without any of its ancestors
+	if err := chain.ImportSnapshot(snapshot); err != nil {
+		t.Fatalf("failed to import snapshot: %v", err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != block.Hash() {
+		t.Fatalf("head mismatch: have #%d, want #%d", head.NumberU64(), block.NumberU64())
+	}
+	if chain.HasBlock(block.ParentHash(), block.NumberU64()-1) {
+		t.Fatalf("parent of the snapshot block imported")
+	}
+	// The blocks after the snapshot are executed on top of it
+	var blocks []*types.Block
+	for number := block.NumberU64() + 1; number <= source.CurrentBlock().NumberU64(); number++ {
+		blocks = append(blocks, source.GetBlockByNumber(number))
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert blocks after snapshot: %v", err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != source.CurrentBlock().Hash() {
+		t.Fatalf("head mismatch: have #%d, want #%d", head.NumberU64(), source.CurrentBlock().NumberU64())
+	}
+}
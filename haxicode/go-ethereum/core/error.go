@@ -29,7 +29,49 @@ var (
 	// ErrBlacklistedHash is returned if a block to import is on the blacklist.
 	ErrBlacklistedHash = errors.New("blacklisted hash")
 
+	// ErrReorgBelowIrreversible is returned if a chain forks off below the block
+	// the consensus engine made irreversible (confirmed or finalized).
+	ErrReorgBelowIrreversible = errors.New("fork below irreversible block")
+
 	// ErrNonceTooHigh is returned if the nonce of a transaction is higher than the
 	// next one expected based on the local chain.
 	ErrNonceTooHigh = errors.New("nonce too high")
+
+	// ErrDepositTooLow is returned if a candidate registration or a vote would
+	// leave less stake locked than the chain config requires.
+	ErrDepositTooLow = errors.New("stake deposit too low")
+
+	// ErrInsufficientFundsForDeposit is returned if the sender of a candidate
+	// registration or a vote can't afford the deposit it carries.
+	ErrInsufficientFundsForDeposit = errors.New("insufficient funds for deposit")
+
+	// ErrAlreadyCandidate is returned if a registered candidate registers again.
+	ErrAlreadyCandidate = errors.New("already registered as candidate")
+
+	// ErrNotCandidate is returned if a DPoS transaction refers to an address that
+	// is not a registered candidate.
+	ErrNotCandidate = errors.New("not a registered candidate")
+
+	// ErrNoVote is returned if a delegator undelegates from a candidate it doesn't
+	// vote for.
+	ErrNoVote = errors.New("no vote for candidate")
+
+	// ErrNoReward is returned if a delegator withdraws without accrued rewards.
+	ErrNoReward = errors.New("no reward to withdraw")
+
+	// ErrSigningKeyInUse is returned if a candidate registers a signing key that
+	// belongs to another candidate.
+	ErrSigningKeyInUse = errors.New("signing key in use by another candidate")
+
+	// ErrNotValidator is returned if an account that is not a validator of the
+	// current epoch proposes or approves a parameter change.
+	ErrNotValidator = errors.New("not a validator of the current epoch")
+
+	// ErrNoProposal is returned if a validator approves a parameter change that
+	// was never proposed or already closed.
+	ErrNoProposal = errors.New("no open parameter proposal")
+
+	// ErrAlreadyApproved is returned if a validator approves the same parameter
+	// change twice.
+	ErrAlreadyApproved = errors.New("parameter proposal already approved")
 )
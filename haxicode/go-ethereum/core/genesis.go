@@ -41,7 +41,12 @@ import (
 //go:generate gencodec -type Genesis -field-override genesisSpecMarshaling -out gen_genesis.go
 //go:generate gencodec -type GenesisAccount -field-override genesisAccountMarshaling -out gen_genesis_account.go
 
-var errGenesisNoConfig = errors.New("genesis has no chain configuration")
+var (
+	errGenesisNoConfig        = errors.New("genesis has no chain configuration")
+	errGenesisNoDposConfig    = errors.New("genesis has no dpos configuration")
+	errGenesisNoValidators    = errors.New("genesis has no dpos validators")
+	errGenesisNoBlockInterval = errors.New("genesis dpos block interval is zero")
+)
 
 // Genesis specifies the header fields, state of a genesis block. It also defines hard
 // fork switch-over blocks through the chain configuration.
@@ -219,6 +224,59 @@ func (g *Genesis) configOrDefault(ghash common.Hash) *params.ChainConfig {
 	}
 }
 
+// Validate checks that the DPoS configuration of the genesis can start a chain:
+// enough distinct validators to confirm blocks, a block interval and votes for
+// genesis validators only. Genesis blocks of other engines have nothing to check.
+func (g *Genesis) Validate() error {
+	if g.Config == nil {
+		return errGenesisNoConfig
+	}
+	if !g.Config.IsDpos() {
+		return nil
+	}
+	config := g.Config.Dpos
+	if config == nil {
+		return errGenesisNoDposConfig
+	}
+	if len(config.Validators) == 0 {
+		return errGenesisNoValidators
+	}
+	if len(config.Validators) < config.SafeSize() {
+		return fmt.Errorf("too few genesis validators: have %d, want at least %d (2/3+1 of maxValidatorSize %d)", len(config.Validators), config.SafeSize(), config.MaxValidatorSize)
+	}
+	if uint64(len(config.Validators)) > config.MaxValidatorSize {
+		return fmt.Errorf("too many genesis validators: have %d, maxValidatorSize %d", len(config.Validators), config.MaxValidatorSize)
+	}
+	if config.BlockInterval == 0 {
+		return errGenesisNoBlockInterval
+	}
+	validators := make(map[common.Address]bool)
+	for _, validator := range config.Validators {
+		if validators[validator] {
+			return fmt.Errorf("duplicate genesis validator %x", validator)
+		}
+		validators[validator] = true
+	}
+	delegators := make(map[common.Address]bool)
+	for _, delegation := range config.Delegations {
+		if !validators[delegation.Candidate] {
+			return fmt.Errorf("genesis vote of %x for %x, which is not a genesis validator", delegation.Delegator, delegation.Candidate)
+		}
+		if delegators[delegation.Delegator] {
+			return fmt.Errorf("duplicate genesis vote of %x", delegation.Delegator)
+		}
+		delegators[delegation.Delegator] = true
+
+		if delegation.Stake == nil || delegation.Stake.Sign() <= 0 {
+			return fmt.Errorf("genesis vote of %x has no stake", delegation.Delegator)
+		}
+		if config.DelegateDeposit != nil && delegation.Stake.Cmp(config.DelegateDeposit) < 0 {
+			return fmt.Errorf("genesis vote of %x stakes %v, below the delegate deposit %v", delegation.Delegator, delegation.Stake, config.DelegateDeposit)
+		}
+	}
+	return nil
+}
+
 // ToBlock creates the genesis block and writes state of a genesis specification
 // to the given database (or discards it if nil).
 func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
@@ -239,6 +297,11 @@ func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
 	// add dposcontext
 	dposContext := initGenesisDposContext(g, statedb.Database().TrieDB())
 	dposContextProto := dposContext.ToProto()
+	var dposConfig *params.DposConfig
+	if g.Config != nil {
+		dposConfig = g.Config.Dpos
+	}
+	dposConfig = dposConfig.At(new(big.Int).SetUint64(g.Number))
 
 	head := &types.Header{
 		Number:     new(big.Int).SetUint64(g.Number),
@@ -253,8 +316,8 @@ func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
 		Coinbase:   g.Coinbase,
 		Root:       root,
 		DposContext: dposContextProto,
-		MaxValidatorSize: g.Config.Dpos.MaxValidatorSize,
-		BlockInterval: g.Config.Dpos.BlockInterval,
+		MaxValidatorSize: dposConfig.MaxValidatorSize,
+		BlockInterval: dposConfig.BlockInterval,
 	}
 	if g.GasLimit == 0 {
 		head.GasLimit = params.GenesisGasLimit
@@ -279,9 +342,15 @@ func (g *Genesis) Commit(db ethdb.Database) (*types.Block, error) {
 		return nil, fmt.Errorf("can't commit genesis block with number > 0")
 	}
 	// add dposcontext
-	if _, err := block.DposContext.Commit(); err != nil {
+	dposContext, err := block.DposContext.Commit()
+	if err != nil {
 		return nil, err
 	}
+	for _, root := range dposContext.Roots() {
+		if err := block.DposContext.DB().Commit(root, true); err != nil {
+			return nil, err
+		}
+	}
 	rawdb.WriteTd(db, block.Hash(), block.NumberU64(), g.Difficulty)
 	rawdb.WriteBlock(db, block)
 	rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), nil)
@@ -350,17 +419,24 @@ func DefaultRinkebyGenesisBlock() *Genesis {
 	}
 }
 
-// DeveloperGenesisBlock returns the 'geth --dev' genesis block. Note, this must
-// be seeded with the
+// DeveloperGenesisBlock returns the 'geth --dev' genesis block: a DPoS chain
+// with the faucet as its single validator, sealing a block every period seconds
+// or, if period is 0, whenever transactions are pending.
 func DeveloperGenesisBlock(period uint64, faucet common.Address) *Genesis {
 	// Override the default period to the user requested one
-	config := *params.AllCliqueProtocolChanges
-	config.Clique.Period = period
+	config := *params.DposChainConfig
+	config.ChainID = big.NewInt(1337)
+	config.Dpos = &params.DposConfig{
+		Validators:       []common.Address{faucet},
+		MaxValidatorSize: 1,
+		BlockInterval:    1,
+		Dev:              &params.DposDevConfig{Period: period},
+	}
 
 	// Assemble and return the genesis with the precompiles and faucet pre-funded
 	return &Genesis{
 		Config:     &config,
-		ExtraData:  append(append(make([]byte, 32), faucet[:]...), make([]byte, 65)...),
+		ExtraData:  make([]byte, 32),
 		GasLimit:   6283185,
 		Difficulty: big.NewInt(1),
 		Alloc: map[common.Address]GenesisAccount{
@@ -372,7 +448,7 @@ func DeveloperGenesisBlock(period uint64, faucet common.Address) *Genesis {
 			common.BytesToAddress([]byte{6}): {Balance: big.NewInt(1)}, // ECAdd
 			common.BytesToAddress([]byte{7}): {Balance: big.NewInt(1)}, // ECScalarMul
 			common.BytesToAddress([]byte{8}): {Balance: big.NewInt(1)}, // ECPairing
-			faucet: {Balance: new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(9))},
+			faucet: {Balance: new(big.Int).Lsh(big.NewInt(1), 255)}, // Leave room for the block rewards
 		},
 	}
 }
@@ -401,6 +477,18 @@ func initGenesisDposContext(g *Genesis, db *trie.Database) *types.DposContext {
 			dc.DelegateTrie().TryUpdate(append(validator.Bytes(), validator.Bytes()...), validator.Bytes())
 			dc.CandidateTrie().TryUpdate(validator.Bytes(), validator.Bytes())
 		}
+		for _, delegation := range g.Config.Dpos.Delegations {
+			if err := dc.Delegate(delegation.Delegator, delegation.Candidate); err != nil {
+				log.Error("Failed to cast genesis vote", "delegator", delegation.Delegator, "candidate", delegation.Candidate, "err", err)
+				continue
+			}
+			if delegation.Stake == nil {
+				continue
+			}
+			if err := dc.LockDelegateDeposit(delegation.Delegator, delegation.Stake); err != nil {
+				log.Error("Failed to lock genesis stake", "delegator", delegation.Delegator, "err", err)
+			}
+		}
 	}
 	return dc
 }
@@ -162,3 +162,99 @@ func TestSetupGenesis(t *testing.T) {
 		}
 	}
 }
+
+func TestGenesisValidate(t *testing.T) {
+	validators := []common.Address{{1}, {2}, {3}}
+	dposGenesis := func(modify func(*params.DposConfig)) *Genesis {
+		config := &params.DposConfig{Validators: validators, MaxValidatorSize: 4, BlockInterval: 10}
+		if modify != nil {
+			modify(config)
+		}
+		return &Genesis{Config: &params.ChainConfig{Dpos: config}}
+	}
+	tests := []struct {
+		name    string
+		genesis *Genesis
+		wantErr string
+	}{
+		{"valid", dposGenesis(nil), ""},
+		{"no config", &Genesis{}, errGenesisNoConfig.Error()},
+		{"no dpos config", &Genesis{Config: &params.ChainConfig{}}, errGenesisNoDposConfig.Error()},
+		{"clique", &Genesis{Config: params.AllCliqueProtocolChanges}, ""},
+		{"ethash", &Genesis{Config: params.AllEthashProtocolChanges}, ""},
+		{"developer", DeveloperGenesisBlock(0, common.Address{1}), ""},
+		{"no validators", dposGenesis(func(c *params.DposConfig) { c.Validators = nil }), errGenesisNoValidators.Error()},
+		{
+			"too few validators",
+			dposGenesis(func(c *params.DposConfig) { c.Validators = validators[:2] }),
+			"too few genesis validators: have 2, want at least 3 (2/3+1 of maxValidatorSize 4)",
+		},
+		{
+			"too many validators",
+			dposGenesis(func(c *params.DposConfig) { c.MaxValidatorSize = 2 }),
+			"too many genesis validators: have 3, maxValidatorSize 2",
+		},
+		{"no block interval", dposGenesis(func(c *params.DposConfig) { c.BlockInterval = 0 }), errGenesisNoBlockInterval.Error()},
+		{
+			"duplicate validator",
+			dposGenesis(func(c *params.DposConfig) { c.Validators = []common.Address{{1}, {2}, {1}} }),
+			"duplicate genesis validator 0100000000000000000000000000000000000000",
+		},
+		{
+			"vote for non-validator",
+			dposGenesis(func(c *params.DposConfig) {
+				c.Delegations = []*params.DposDelegation{{Delegator: common.Address{5}, Candidate: common.Address{4}, Stake: big.NewInt(1)}}
+			}),
+			"genesis vote of 0500000000000000000000000000000000000000 for 0400000000000000000000000000000000000000, which is not a genesis validator",
+		},
+		{
+			"vote without stake",
+			dposGenesis(func(c *params.DposConfig) {
+				c.Delegations = []*params.DposDelegation{{Delegator: common.Address{5}, Candidate: common.Address{1}}}
+			}),
+			"genesis vote of 0500000000000000000000000000000000000000 has no stake",
+		},
+		{
+			"duplicate vote",
+			dposGenesis(func(c *params.DposConfig) {
+				c.Delegations = []*params.DposDelegation{
+					{Delegator: common.Address{5}, Candidate: common.Address{1}, Stake: big.NewInt(1)},
+					{Delegator: common.Address{5}, Candidate: common.Address{2}, Stake: big.NewInt(1)},
+				}
+			}),
+			"duplicate genesis vote of 0500000000000000000000000000000000000000",
+		},
+	}
+	for _, test := range tests {
+		err := test.genesis.Validate()
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.wantErr {
+			t.Errorf("%s: returned error %v, want %q", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestGenesisDelegations(t *testing.T) {
+	delegator, candidate := common.Address{5}, common.Address{2}
+	genesis := &Genesis{Config: &params.ChainConfig{Dpos: &params.DposConfig{
+		Validators:       []common.Address{{1}, {2}, {3}},
+		MaxValidatorSize: 3,
+		BlockInterval:    10,
+		Delegations:      []*params.DposDelegation{{Delegator: delegator, Candidate: candidate, Stake: big.NewInt(100)}},
+	}}}
+	dposContext := genesis.ToBlock(nil).DposContext
+
+	vote, err := dposContext.GetVote(delegator)
+	if err != nil || vote == nil || *vote != candidate {
+		t.Fatalf("genesis vote mismatch: have %v (%v), want %x", vote, err, candidate)
+	}
+	stake, err := dposContext.DelegateDeposit(delegator)
+	if err != nil || stake.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("genesis stake mismatch: have %v (%v), want 100", stake, err)
+	}
+}
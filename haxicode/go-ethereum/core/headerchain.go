@@ -216,6 +216,11 @@ func (hc *HeaderChain) ValidateHeaderChain(chain []*types.Header, checkFreq int)
 				chain[i-1].Hash().Bytes()[:4], i, chain[i].Number, chain[i].Hash().Bytes()[:4], chain[i].ParentHash[:4])
 		}
 	}
+	// Refuse forks that would rewrite irreversible history
+	if err := hc.CheckIrreversible(chain[0]); err != nil {
+		log.Warn("Rejected fork below irreversible block", "number", chain[0].Number, "hash", chain[0].Hash())
+		return 0, err
+	}
 
 	// Generate the list of seal verification requests, and start the parallel verifier
 	seals := make([]bool, len(chain))
@@ -431,6 +436,47 @@ func (hc *HeaderChain) CurrentHeader() *types.Header {
 	return hc.currentHeader.Load().(*types.Header)
 }
 
+// FinalizedHeader retrieves the latest header with a quorum certificate, nil if
+// no block has been finalized yet.
+func (hc *HeaderChain) FinalizedHeader() *types.Header {
+	hash := rawdb.ReadFinalizedBlockHash(hc.chainDb, hc.genesisHeader.Hash())
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	return hc.GetHeaderByHash(hash)
+}
+
+// IrreversibleHeader retrieves the latest header the consensus engine won't
+// revert any more, nil if there is none yet. Engines without a notion of
+// irreversibility fall back to the finalized header.
+func (hc *HeaderChain) IrreversibleHeader() *types.Header {
+	if engine, ok := hc.engine.(consensus.Irreversible); ok {
+		return engine.IrreversibleHeader(hc)
+	}
+	return hc.FinalizedHeader()
+}
+
+// CheckIrreversible returns ErrReorgBelowIrreversible if the chain ending with
+// header forks off the canonical chain below the irreversible header. Headers
+// with unknown ancestry are left to the regular validation.
+func (hc *HeaderChain) CheckIrreversible(header *types.Header) error {
+	irreversible := hc.IrreversibleHeader()
+	if irreversible == nil {
+		return nil
+	}
+	limit := irreversible.Number.Uint64()
+
+	// Walk back the new chain until it joins the canonical one
+	for header != nil && rawdb.ReadCanonicalHash(hc.chainDb, header.Number.Uint64()) != header.Hash() {
+		number := header.Number.Uint64()
+		if number <= limit {
+			return ErrReorgBelowIrreversible
+		}
+		header = hc.GetHeader(header.ParentHash, number-1)
+	}
+	return nil
+}
+
 // SetCurrentHeader sets the current head header of the canonical chain.
 func (hc *HeaderChain) SetCurrentHeader(head *types.Header) {
 	rawdb.WriteHeadHeaderHash(hc.chainDb, head.Hash())
@@ -0,0 +1,99 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/haxicode/go-ethereum/consensus"
+	"github.com/haxicode/go-ethereum/consensus/ethash"
+	"github.com/haxicode/go-ethereum/core/rawdb"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/ethdb"
+	"github.com/haxicode/go-ethereum/params"
+)
+
+// irreversibleEngine is a fake consensus engine with a fixed irreversible block.
+type irreversibleEngine struct {
+	consensus.Engine
+	irreversible *types.Header
+}
+
+func (e *irreversibleEngine) IrreversibleHeader(chain consensus.ChainReader) *types.Header {
+	return e.irreversible
+}
+
+// makeTestHeaders returns n headers extending parent, tagged with extra so that
+// forks of the same parent get distinct hashes.
+func makeTestHeaders(parent *types.Header, n int, extra byte) []*types.Header {
+	headers := make([]*types.Header, n)
+	for i := range headers {
+		headers[i] = &types.Header{
+			ParentHash:  parent.Hash(),
+			Number:      new(big.Int).Add(parent.Number, big.NewInt(1)),
+			Difficulty:  big.NewInt(1),
+			Extra:       []byte{extra},
+			DposContext: &types.DposContextProto{},
+		}
+		parent = headers[i]
+	}
+	return headers
+}
+
+// Tests that forks off the canonical chain below the irreversible block are
+// rejected, while forks above it and extensions of the chain are not.
+func TestCheckIrreversible(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	genesis := &types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(1), DposContext: &types.DposContextProto{}}
+	canonical := append([]*types.Header{genesis}, makeTestHeaders(genesis, 6, 0)...)
+	for _, header := range canonical {
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteCanonicalHash(db, header.Hash(), header.Number.Uint64())
+	}
+	engine := &irreversibleEngine{Engine: ethash.NewFaker(), irreversible: canonical[3]}
+	hc, err := NewHeaderChain(db, params.TestChainConfig, engine, func() bool { return false })
+	if err != nil {
+		t.Fatalf("failed to create header chain: %v", err)
+	}
+	// Extensions of the canonical chain and canonical headers are fine
+	if err := hc.CheckIrreversible(makeTestHeaders(canonical[6], 1, 0)[0]); err != nil {
+		t.Errorf("chain extension rejected: %v", err)
+	}
+	if err := hc.CheckIrreversible(canonical[2]); err != nil {
+		t.Errorf("canonical header rejected: %v", err)
+	}
+	// Forks at or above the irreversible block are fine
+	fork := makeTestHeaders(canonical[3], 3, 1)
+	for _, header := range fork {
+		rawdb.WriteHeader(db, header)
+	}
+	if err := hc.CheckIrreversible(fork[2]); err != nil {
+		t.Errorf("fork above irreversible block rejected: %v", err)
+	}
+	// Forks below it are not, even when they grow past it
+	fork = makeTestHeaders(canonical[2], 3, 2)
+	for _, header := range fork[:2] {
+		rawdb.WriteHeader(db, header)
+	}
+	if err := hc.CheckIrreversible(fork[2]); err != ErrReorgBelowIrreversible {
+		t.Errorf("fork below irreversible block: have %v, want %v", err, ErrReorgBelowIrreversible)
+	}
+	if _, err := hc.ValidateHeaderChain(makeTestHeaders(canonical[1], 4, 3), 1); err != ErrReorgBelowIrreversible {
+		t.Errorf("header chain below irreversible block: have %v, want %v", err, ErrReorgBelowIrreversible)
+	}
+}
@@ -126,6 +126,24 @@ func WriteFastTrieProgress(db DatabaseWriter, count uint64) {
 	}
 }
 
+// ReadFinalizedBlockHash retrieves the hash of the latest block with a quorum
+// certificate of the chain with the given genesis block.
+func ReadFinalizedBlockHash(db DatabaseReader, genesis common.Hash) common.Hash {
+	data, _ := db.Get(finalizedBlockKey(genesis))
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteFinalizedBlockHash stores the hash of the latest block with a quorum
+// certificate of the chain with the given genesis block.
+func WriteFinalizedBlockHash(db DatabaseWriter, genesis, hash common.Hash) {
+	if err := db.Put(finalizedBlockKey(genesis), hash.Bytes()); err != nil {
+		log.Crit("Failed to store last finalized block's hash", "err", err)
+	}
+}
+
 // ReadHeaderRLP retrieves a block header in its raw RLP database encoding.
 func ReadHeaderRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(headerKey(number, hash))
@@ -188,6 +206,31 @@ func DeleteHeader(db DatabaseDeleter, hash common.Hash, number uint64) {
 	}
 }
 
+// ReadQuorumCert retrieves the finality certificate of a block.
+func ReadQuorumCert(db DatabaseReader, hash common.Hash, number uint64) *types.QuorumCert {
+	data, _ := db.Get(headerCertKey(number, hash))
+	if len(data) == 0 {
+		return nil
+	}
+	cert := new(types.QuorumCert)
+	if err := rlp.Decode(bytes.NewReader(data), cert); err != nil {
+		log.Error("Invalid quorum certificate RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return cert
+}
+
+// WriteQuorumCert stores the finality certificate of a block.
+func WriteQuorumCert(db DatabaseWriter, cert *types.QuorumCert) {
+	data, err := rlp.EncodeToBytes(cert)
+	if err != nil {
+		log.Crit("Failed to RLP encode quorum certificate", "err", err)
+	}
+	if err := db.Put(headerCertKey(cert.Number, cert.Hash), data); err != nil {
+		log.Crit("Failed to store quorum certificate", "err", err)
+	}
+}
+
 // ReadBodyRLP retrieves the block body (transactions and uncles) in RLP encoding.
 func ReadBodyRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(blockBodyKey(number, hash))
@@ -41,11 +41,16 @@ var (
 	// fastTrieProgressKey tracks the number of trie entries imported during fast sync.
 	fastTrieProgressKey = []byte("TrieSync")
 
+	// finalizedBlockPrefix + genesis hash tracks the hash of the latest block of
+	// that chain with a quorum certificate.
+	finalizedBlockPrefix = []byte("LastFinalized")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
 	headerHashSuffix   = []byte("n") // headerPrefix + num (uint64 big endian) + headerHashSuffix -> hash
 	headerNumberPrefix = []byte("H") // headerNumberPrefix + hash -> num (uint64 big endian)
+	headerCertSuffix   = []byte("q") // headerPrefix + num (uint64 big endian) + hash + headerCertSuffix -> quorum certificate
 
 	blockBodyPrefix     = []byte("b") // blockBodyPrefix + num (uint64 big endian) + hash -> block body
 	blockReceiptsPrefix = []byte("r") // blockReceiptsPrefix + num (uint64 big endian) + hash -> block receipts
@@ -88,6 +93,11 @@ func headerTDKey(number uint64, hash common.Hash) []byte {
 	return append(headerKey(number, hash), headerTDSuffix...)
 }
 
+// headerCertKey = headerPrefix + num (uint64 big endian) + hash + headerCertSuffix
+func headerCertKey(number uint64, hash common.Hash) []byte {
+	return append(headerKey(number, hash), headerCertSuffix...)
+}
+
 // headerHashKey = headerPrefix + num (uint64 big endian) + headerHashSuffix
 func headerHashKey(number uint64) []byte {
 	return append(append(headerPrefix, encodeBlockNumber(number)...), headerHashSuffix...)
@@ -132,3 +142,8 @@ func preimageKey(hash common.Hash) []byte {
 func configKey(hash common.Hash) []byte {
 	return append(configPrefix, hash.Bytes()...)
 }
+
+// finalizedBlockKey = finalizedBlockPrefix + genesis hash
+func finalizedBlockKey(genesis common.Hash) []byte {
+	return append(finalizedBlockPrefix, genesis.Bytes()...)
+}
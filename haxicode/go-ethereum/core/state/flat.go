@@ -0,0 +1,147 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/crypto"
+	"github.com/haxicode/go-ethereum/rlp"
+	"github.com/haxicode/go-ethereum/trie"
+)
+
+// FlatEntry is a storage slot of a flat account, keyed by the hash of the slot
+// as in the storage trie. The value is RLP encoded.
+type FlatEntry struct {
+	Key   common.Hash
+	Value []byte
+}
+
+// FlatAccount is an account of a flat state dump, keyed by the hash of its
+// address as in the account trie, with its code and its storage slots.
+type FlatAccount struct {
+	Hash    common.Hash
+	Nonce   uint64
+	Balance *big.Int
+	Code    []byte
+	Storage []FlatEntry
+}
+
+// FlatDump returns every account of the state with the given root, in the
+// order of the account trie. Unlike RawDump, it doesn't need the preimages of
+// the hashed keys.
+func FlatDump(db Database, root common.Hash) ([]FlatAccount, error) {
+	tr, err := db.OpenTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	var accounts []FlatAccount
+
+	it := trie.NewIterator(tr.NodeIterator(nil))
+	for it.Next() {
+		var data Account
+		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
+			return nil, err
+		}
+		account := FlatAccount{
+			Hash:    common.BytesToHash(it.Key),
+			Nonce:   data.Nonce,
+			Balance: data.Balance,
+		}
+		if code := common.BytesToHash(data.CodeHash); code != emptyCode {
+			if account.Code, err = db.ContractCode(account.Hash, code); err != nil {
+				return nil, fmt.Errorf("account %x: %v", account.Hash, err)
+			}
+		}
+		storage, err := db.OpenStorageTrie(account.Hash, data.Root)
+		if err != nil {
+			return nil, fmt.Errorf("account %x: %v", account.Hash, err)
+		}
+		storageIt := trie.NewIterator(storage.NodeIterator(nil))
+		for storageIt.Next() {
+			account.Storage = append(account.Storage, FlatEntry{Key: common.BytesToHash(storageIt.Key), Value: storageIt.Value})
+		}
+		if storageIt.Err != nil {
+			return nil, fmt.Errorf("account %x: %v", account.Hash, storageIt.Err)
+		}
+		accounts = append(accounts, account)
+	}
+	if it.Err != nil {
+		return nil, it.Err
+	}
+	return accounts, nil
+}
+
+// RestoreFlatDump rebuilds the state of a flat dump into the trie database and
+// returns its root. Like StateDB.Commit, the tries are only held in memory
+// until the database is flushed to disk, which is left to the caller.
+func RestoreFlatDump(db *trie.Database, accounts []FlatAccount) (common.Hash, error) {
+	tr, err := trie.New(common.Hash{}, db)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	for _, account := range accounts {
+		storage, err := trie.New(common.Hash{}, db)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		for _, entry := range account.Storage {
+			if err := storage.TryUpdate(entry.Key[:], entry.Value); err != nil {
+				return common.Hash{}, err
+			}
+		}
+		data := Account{
+			Nonce:    account.Nonce,
+			Balance:  account.Balance,
+			CodeHash: emptyCodeHash,
+		}
+		if data.Root, err = storage.Commit(nil); err != nil {
+			return common.Hash{}, err
+		}
+		if len(account.Code) > 0 {
+			data.CodeHash = crypto.Keccak256(account.Code)
+			db.InsertBlob(common.BytesToHash(data.CodeHash), account.Code)
+		}
+		if data.Balance == nil {
+			data.Balance = new(big.Int)
+		}
+		enc, err := rlp.EncodeToBytes(&data)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		if err := tr.TryUpdate(account.Hash[:], enc); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	// Reference the storage tries and the code from the accounts, as Commit does
+	return tr.Commit(func(leaf []byte, parent common.Hash) error {
+		var account Account
+		if err := rlp.DecodeBytes(leaf, &account); err != nil {
+			return nil
+		}
+		if account.Root != emptyState {
+			db.Reference(account.Root, parent)
+		}
+		code := common.BytesToHash(account.CodeHash)
+		if code != emptyCode {
+			db.Reference(code, parent)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/ethdb"
+)
+
+// Tests that a state restored from its flat dump into an empty database has the
+// same root and content as the original one.
+func TestFlatDumpRestore(t *testing.T) {
+	state, _ := New(common.Hash{}, NewDatabase(ethdb.NewMemDatabase()))
+	for i := byte(0); i < 16; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		state.AddBalance(addr, big.NewInt(int64(i)))
+		state.SetNonce(addr, uint64(i))
+		if i%3 == 0 {
+			state.SetCode(addr, []byte{i, i, i})
+			state.SetState(addr, common.Hash{i}, common.Hash{i, i})
+		}
+	}
+	root, err := state.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	accounts, err := FlatDump(state.Database(), root)
+	if err != nil {
+		t.Fatalf("failed to dump state: %v", err)
+	}
+	if len(accounts) != 16 {
+		t.Fatalf("account count mismatch: have %d, want %d", len(accounts), 16)
+	}
+	diskdb := ethdb.NewMemDatabase()
+	db := NewDatabase(diskdb)
+	restored, err := RestoreFlatDump(db.TrieDB(), accounts)
+	if err != nil {
+		t.Fatalf("failed to restore state: %v", err)
+	}
+	if restored != root {
+		t.Fatalf("state root mismatch: have %x, want %x", restored, root)
+	}
+	if err := db.TrieDB().Commit(restored, false); err != nil {
+		t.Fatalf("failed to flush state: %v", err)
+	}
+	// Read the state back from disk only
+	state, err = New(restored, NewDatabase(diskdb))
+	if err != nil {
+		t.Fatalf("failed to open restored state: %v", err)
+	}
+	for i := byte(0); i < 16; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		if balance := state.GetBalance(addr); balance.Cmp(big.NewInt(int64(i))) != 0 {
+			t.Errorf("account %d: balance mismatch: have %v, want %v", i, balance, i)
+		}
+		if nonce := state.GetNonce(addr); nonce != uint64(i) {
+			t.Errorf("account %d: nonce mismatch: have %v, want %v", i, nonce, i)
+		}
+		if i%3 == 0 {
+			if code := state.GetCode(addr); !bytes.Equal(code, []byte{i, i, i}) {
+				t.Errorf("account %d: code mismatch: have %x, want %x", i, code, []byte{i, i, i})
+			}
+			if value := state.GetState(addr, common.Hash{i}); value != (common.Hash{i, i}) {
+				t.Errorf("account %d: storage mismatch: have %x, want %x", i, value, common.Hash{i, i})
+			}
+		}
+	}
+}
@@ -17,13 +17,17 @@
 package core
 
 import (
+	"math/big"
+
 	"github.com/haxicode/go-ethereum/common"
 	"github.com/haxicode/go-ethereum/consensus"
+	"github.com/haxicode/go-ethereum/consensus/dpos"
 	"github.com/haxicode/go-ethereum/consensus/misc"
 	"github.com/haxicode/go-ethereum/core/state"
 	"github.com/haxicode/go-ethereum/core/types"
 	"github.com/haxicode/go-ethereum/core/vm"
 	"github.com/haxicode/go-ethereum/crypto"
+	"github.com/haxicode/go-ethereum/log"
 	"github.com/haxicode/go-ethereum/params"
 )
 
@@ -77,7 +81,9 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		allLogs = append(allLogs, receipt.Logs...)
 	}
 	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
-	p.engine.Finalize(p.bc, header, statedb, block.Transactions(), block.Uncles(), receipts, block.DposCtx())
+	if _, err := p.engine.Finalize(p.bc, header, statedb, block.Transactions(), block.Uncles(), receipts, block.DposCtx()); err != nil {
+		return nil, nil, 0, err
+	}
 
 	return receipts, allLogs, *usedGas, nil
 }
@@ -92,7 +98,7 @@ func ApplyTransaction(config *params.ChainConfig, dposContext *types.DposContext
 		return nil, 0, err
 	}
 
-	if msg.To() == nil && msg.Type() != types.Binary {
+	if msg.To() == nil && msg.Type().NeedsRecipient() {
 		return nil, 0, types.ErrInvalidType
 	}
 
@@ -106,9 +112,21 @@ func ApplyTransaction(config *params.ChainConfig, dposContext *types.DposContext
 	if err != nil {
 		return nil, 0, err
 	}
+	// A DPoS message that can't be applied leaves the DPoS and account state
+	// untouched, but still pays for its gas and records why it failed.
+	var reason string
 	if msg.Type() != types.Binary {
-		if err = applyDposMessage(dposContext, msg); err != nil {
-			return nil, 0, err
+		dposSnapshot, stateSnapshot := dposContext.Snapshot(), statedb.Snapshot()
+		parent := bc.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+		if parent == nil {
+			return nil, 0, consensus.ErrUnknownAncestor
+		}
+		epoch := dposEpoch(config.Dpos, dposContext, parent, header)
+		if dposErr := applyDposMessage(config.Dpos.At(header.Number), dposContext, statedb, header, epoch, msg); dposErr != nil {
+			dposContext.RevertToSnapShot(dposSnapshot)
+			statedb.RevertToSnapshot(stateSnapshot)
+			failed, reason = true, dposErr.Error()
+			log.Debug("DPoS transaction failed", "hash", tx.Hash(), "type", msg.Type(), "err", dposErr)
 		}
 	}
 
@@ -126,8 +144,9 @@ func ApplyTransaction(config *params.ChainConfig, dposContext *types.DposContext
 	receipt := types.NewReceipt(root, failed, *usedGas)
 	receipt.TxHash = tx.Hash()
 	receipt.GasUsed = gas
+	receipt.Reason = reason
 	// if the transaction created a contract, store the creation address in the receipt.
-	if msg.To() == nil {
+	if msg.To() == nil && msg.Type() == types.Binary {
 		receipt.ContractAddress = crypto.CreateAddress(vmenv.Context.Origin, tx.Nonce())
 	}
 	// Set the receipt logs and create a bloom for filtering
@@ -138,18 +157,299 @@ func ApplyTransaction(config *params.ChainConfig, dposContext *types.DposContext
 }
 
 // 更新打包時会執行所有的块内交易，如果发现交易类型不是转账或者合约调用类型，将会将新的用户信息写入到候选人数据库中（候选人树）
-func applyDposMessage(dposContext *types.DposContext, msg types.Message) error {
+// The value of RegCandidate and Delegate messages is locked in the DposContext
+// as stake, and unregistering or undelegating starts unbonding it. Evidence
+// messages slash and kick out a candidate that double signed a slot,
+// WithdrawReward messages pay out the rewards accrued by a delegator and
+// RotateSigner messages switch the key a candidate seals its blocks with.
+// ProposeParams and ApproveParams messages let the validators change the DPoS
+// parameters, from the epoch after 2/3+1 of them approved the change.
+// UpdateCandidate messages replace the commission and description a candidate
+// registered with.
+func applyDposMessage(config *params.DposConfig, dposContext *types.DposContext, statedb *state.StateDB, header *types.Header, epoch int64, msg types.Message) error {
+	if err := validateDposMessage(config, dposContext, statedb, msg); err != nil {
+		return err
+	}
+	releaseTime := header.Time.Int64() + int64(config.UnbondingPeriod)
 	switch msg.Type() {
 	case types.RegCandidate:
-		dposContext.BecomeCandidate(msg.From())
+		if err := dposContext.BecomeCandidate(msg.From()); err != nil {
+			return err
+		}
+		if len(msg.Data()) > 0 {
+			if err := updateCandidate(dposContext, msg); err != nil {
+				return err
+			}
+		}
+		if msg.To() != nil {
+			if err := dposContext.SetSigningKey(msg.From(), *(msg.To()), header.Time.Int64()); err != nil {
+				return err
+			}
+		}
+		statedb.SubBalance(msg.From(), msg.Value())
+		return dposContext.LockCandidateDeposit(msg.From(), msg.Value())
 	case types.UnregCandidate:
-		dposContext.KickoutCandidate(msg.From())
+		return dposContext.KickoutCandidate(msg.From(), releaseTime)
 	case types.Delegate:
-		dposContext.Delegate(msg.From(), *(msg.To()))
+		if err := dposContext.Delegate(msg.From(), *(msg.To())); err != nil {
+			return err
+		}
+		statedb.SubBalance(msg.From(), msg.Value())
+		return dposContext.LockDelegateDeposit(msg.From(), msg.Value())
+	case types.UnDelegate:
+		if err := dposContext.UnDelegate(msg.From(), *(msg.To())); err != nil {
+			return err
+		}
+		return dposContext.UnbondDelegateDeposit(msg.From(), releaseTime)
+	case types.Evidence:
+		evidence, err := types.DecodeDoubleSignEvidence(msg.Data())
+		if err != nil {
+			return err
+		}
+		offender, err := dpos.VerifyDoubleSign(evidence, dposContext)
+		if err != nil {
+			return err
+		}
+		slot := evidence.First.Time.Int64()
+		penalty, err := dposContext.SlashCandidate(offender, slot, config.SlashPercent, releaseTime)
+		if err != nil {
+			return err
+		}
+		log.Info("Slashed double signing validator", "validator", offender, "slot", slot, "penalty", penalty, "reporter", msg.From())
+	case types.WithdrawReward:
+		reward, err := dposContext.WithdrawReward(msg.From())
+		if err != nil {
+			return err
+		}
+		statedb.AddBalance(msg.From(), reward)
+	case types.RotateSigner:
+		return dposContext.SetSigningKey(msg.From(), *(msg.To()), header.Time.Int64())
+	case types.ProposeParams:
+		proposal, err := types.DecodeDposParams(msg.Data())
+		if err != nil {
+			return err
+		}
+		if err := dposContext.ProposeParams(msg.From(), proposal); err != nil {
+			return err
+		}
+		return tallyParamsProposal(dposContext, epoch, msg.From())
+	case types.ApproveParams:
+		if err := dposContext.ApproveParams(*(msg.To()), msg.From()); err != nil {
+			return err
+		}
+		return tallyParamsProposal(dposContext, epoch, *(msg.To()))
+	case types.UpdateCandidate:
+		return updateCandidate(dposContext, msg)
+	}
+	return nil
+}
+
+// updateCandidate sets the commission and description in the payload of msg
+// for its sender.
+func updateCandidate(dposContext *types.DposContext, msg types.Message) error {
+	reg, err := types.DecodeCandidateRegistration(msg.Data())
+	if err != nil {
+		return err
+	}
+	if err := dposContext.SetCommission(msg.From(), reg.Commission); err != nil {
+		return err
+	}
+	return dposContext.UpdateCandidateInfo(msg.From(), reg.Info())
+}
+
+// dposEpoch returns the number of the epoch of header, given its parent and the
+// DPoS state of the parent.
+func dposEpoch(config *params.DposConfig, dposContext *types.DposContext, parent, header *types.Header) int64 {
+	_, epoch, _ := dpos.EpochNumbers(dposContext, parent.Time.Int64(), header.Time.Int64(), config.At(parent.Number), config.At(header.Number))
+	return epoch
+}
+
+// tallyParamsProposal schedules the proposal of proposer for the epoch after
+// epoch if enough validators approved it.
+func tallyParamsProposal(dposContext *types.DposContext, epoch int64, proposer common.Address) error {
+	approved, err := dpos.TallyParamsProposal(dposContext, proposer, epoch)
+	if approved {
+		log.Info("Approved DPoS parameter change", "proposer", proposer, "epoch", epoch+1)
+	}
+	return err
+}
+
+// validateDposMessage checks that a DPoS message can be applied to the given
+// DPoS and account state, without modifying either. Besides guarding
+// applyDposMessage, it lets the transaction pool reject DPoS transactions that
+// are bound to fail.
+func validateDposMessage(config *params.DposConfig, dposContext *types.DposContext, statedb *state.StateDB, msg types.Message) error {
+	switch msg.Type() {
+	case types.RegCandidate:
+		registered, err := dposContext.IsCandidate(msg.From())
+		if err != nil {
+			return err
+		}
+		if registered {
+			return ErrAlreadyCandidate
+		}
+		if len(msg.Data()) > 0 {
+			if _, err := types.DecodeCandidateRegistration(msg.Data()); err != nil {
+				return err
+			}
+		}
+		signer := msg.From()
+		if msg.To() != nil {
+			signer = *(msg.To())
+		}
+		if err := checkSigningKey(dposContext, msg.From(), signer); err != nil {
+			return err
+		}
+		deposit, err := dposContext.CandidateDeposit(msg.From())
+		if err != nil {
+			return err
+		}
+		return checkDeposit(statedb, msg, deposit, config.CandidateDeposit)
+	case types.UnregCandidate:
+		return checkCandidate(dposContext, msg.From())
+	case types.Delegate:
+		if err := checkCandidate(dposContext, *(msg.To())); err != nil {
+			return err
+		}
+		deposit, err := dposContext.DelegateDeposit(msg.From())
+		if err != nil {
+			return err
+		}
+		return checkDeposit(statedb, msg, deposit, config.DelegateDeposit)
 	case types.UnDelegate:
-		dposContext.UnDelegate(msg.From(), *(msg.To()))
+		vote, err := dposContext.GetVote(msg.From())
+		if err != nil {
+			return err
+		}
+		if vote == nil || *vote != *(msg.To()) {
+			return ErrNoVote
+		}
+		return checkCandidate(dposContext, *vote)
+	case types.Evidence:
+		evidence, err := types.DecodeDoubleSignEvidence(msg.Data())
+		if err != nil {
+			return err
+		}
+		offender, err := dpos.VerifyDoubleSign(evidence, dposContext)
+		if err != nil {
+			return err
+		}
+		// Slashing kicks the offender out, so evidence is only accepted once
+		return checkCandidate(dposContext, offender)
+	case types.WithdrawReward:
+		accrued, _, err := dposContext.Rewards(msg.From())
+		if err != nil {
+			return err
+		}
+		if accrued.Sign() == 0 {
+			return ErrNoReward
+		}
+		return nil
+	case types.RotateSigner:
+		if err := checkCandidate(dposContext, msg.From()); err != nil {
+			return err
+		}
+		return checkSigningKey(dposContext, msg.From(), *(msg.To()))
+	case types.ProposeParams:
+		if err := checkValidator(dposContext, msg.From()); err != nil {
+			return err
+		}
+		proposal, err := types.DecodeDposParams(msg.Data())
+		if err != nil {
+			return err
+		}
+		governed, err := dpos.GovernedConfig(config, dposContext)
+		if err != nil {
+			return err
+		}
+		return dpos.VerifyParamsProposal(proposal, governed, dposContext)
+	case types.ApproveParams:
+		if err := checkValidator(dposContext, msg.From()); err != nil {
+			return err
+		}
+		proposal, err := dposContext.ParamsProposal(*(msg.To()))
+		if err != nil {
+			return err
+		}
+		if proposal == nil {
+			return ErrNoProposal
+		}
+		approvers, err := dposContext.ParamsApprovals(*(msg.To()))
+		if err != nil {
+			return err
+		}
+		for _, approver := range approvers {
+			if approver == msg.From() {
+				return ErrAlreadyApproved
+			}
+		}
+		return nil
+	case types.UpdateCandidate:
+		if err := checkCandidate(dposContext, msg.From()); err != nil {
+			return err
+		}
+		_, err := types.DecodeCandidateRegistration(msg.Data())
+		return err
 	default:
 		return types.ErrInvalidType
 	}
+}
+
+func checkCandidate(dposContext *types.DposContext, addr common.Address) error {
+	registered, err := dposContext.IsCandidate(addr)
+	if err != nil {
+		return err
+	}
+	if !registered {
+		return ErrNotCandidate
+	}
+	return nil
+}
+
+// checkValidator checks that addr is a validator of the current epoch.
+func checkValidator(dposContext *types.DposContext, addr common.Address) error {
+	validators, err := dposContext.GetValidators()
+	if err != nil {
+		return err
+	}
+	for _, validator := range validators {
+		if validator == addr {
+			return nil
+		}
+	}
+	return ErrNotValidator
+}
+
+// checkSigningKey checks that the candidate can seal its blocks with signer: no
+// other candidate registered it and it isn't the account of another candidate.
+func checkSigningKey(dposContext *types.DposContext, candidate, signer common.Address) error {
+	owner, err := dposContext.SigningKeyOwner(signer)
+	if err != nil {
+		return err
+	}
+	if owner != (common.Address{}) && owner != candidate {
+		return ErrSigningKeyInUse
+	}
+	if signer != candidate {
+		registered, err := dposContext.IsCandidate(signer)
+		if err != nil {
+			return err
+		}
+		if registered {
+			return ErrSigningKeyInUse
+		}
+	}
+	return nil
+}
+
+// checkDeposit checks that the value of msg topping up the already locked stake
+// reaches the configured minimum and that the sender can afford it.
+func checkDeposit(statedb *state.StateDB, msg types.Message, locked, minimum *big.Int) error {
+	if minimum != nil && new(big.Int).Add(locked, msg.Value()).Cmp(minimum) < 0 {
+		return ErrDepositTooLow
+	}
+	if statedb.GetBalance(msg.From()).Cmp(msg.Value()) < 0 {
+		return ErrInsufficientFundsForDeposit
+	}
 	return nil
 }
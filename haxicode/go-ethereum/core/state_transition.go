@@ -22,6 +22,7 @@ import (
 	"math/big"
 
 	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/core/types"
 	"github.com/haxicode/go-ethereum/core/vm"
 	"github.com/haxicode/go-ethereum/log"
 	"github.com/haxicode/go-ethereum/params"
@@ -73,15 +74,38 @@ type Message interface {
 	Nonce() uint64
 	CheckNonce() bool
 	Data() []byte
+	Type() types.TxType
 }
 
-// IntrinsicGas computes the 'intrinsic gas' for a message with the given data.
-func IntrinsicGas(data []byte, contractCreation, homestead bool) (uint64, error) {
+// IntrinsicGas computes the 'intrinsic gas' for a message of the given type
+// with the given data.
+func IntrinsicGas(txType types.TxType, data []byte, contractCreation, homestead bool) (uint64, error) {
 	// Set the starting gas for the raw transaction
 	var gas uint64
-	if contractCreation && homestead {
+	switch {
+	case txType == types.RegCandidate:
+		gas = params.TxRegCandidateGas
+	case txType == types.UnregCandidate:
+		gas = params.TxUnregCandidateGas
+	case txType == types.Delegate:
+		gas = params.TxDelegateGas
+	case txType == types.UnDelegate:
+		gas = params.TxUnDelegateGas
+	case txType == types.Evidence:
+		gas = params.TxEvidenceGas
+	case txType == types.WithdrawReward:
+		gas = params.TxWithdrawRewardGas
+	case txType == types.RotateSigner:
+		gas = params.TxRotateSignerGas
+	case txType == types.ProposeParams:
+		gas = params.TxProposeParamsGas
+	case txType == types.ApproveParams:
+		gas = params.TxApproveParamsGas
+	case txType == types.UpdateCandidate:
+		gas = params.TxUpdateCandidateGas
+	case contractCreation && homestead:
 		gas = params.TxGasContractCreation
-	} else {
+	default:
 		gas = params.TxGas
 	}
 	// Bump the required gas by the amount of transactional data
@@ -190,7 +214,7 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 	contractCreation := msg.To() == nil
 
 	// Pay intrinsic gas
-	gas, err := IntrinsicGas(st.data, contractCreation, homestead)
+	gas, err := IntrinsicGas(msg.Type(), st.data, contractCreation, homestead)
 	if err != nil {
 		return nil, 0, false, err
 	}
@@ -205,7 +229,11 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 		// error.
 		vmerr error
 	)
-	if contractCreation {
+	if msg.Type() != types.Binary {
+		// DPoS transactions don't run any code, their value is the stake
+		// deposit which is moved into escrow by the consensus rules.
+		st.state.SetNonce(msg.From(), st.state.GetNonce(sender.Address())+1)
+	} else if contractCreation {
 		ret, _, st.gas, vmerr = evm.Create(sender, st.data, st.gas, st.value)
 	} else {
 		// Increment the nonce for the next transaction
@@ -198,6 +198,12 @@ type TxPool struct {
 	pendingState  *state.ManagedState // Pending state tracking virtual nonces
 	currentMaxGas uint64              // Current gas limit for transaction caps
 
+	pendingDposContext *types.DposContext // DPoS state with the pending DPoS transactions applied
+	pendingDposState   *state.StateDB     // Account state the pending DPoS transactions apply to
+	pendingDposHeader  *types.Header      // Header of the pending block, for the DPoS rules
+	pendingDposConfig  *params.DposConfig // DPoS parameters of the pending block
+	pendingDposEpoch   int64              // Number of the epoch of the pending block
+
 	locals  *accountSet // Set of local transaction to exempt from eviction rules
 	journal *txJournal  // Journal of local transaction to back up to disk
 
@@ -414,6 +420,25 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	pool.pendingState = state.ManageState(statedb)
 	pool.currentMaxGas = newHead.GasLimit
 
+	pool.pendingDposContext = nil
+	if newHead.DposContext != nil {
+		if pool.pendingDposContext, err = types.NewDposContextFromProto(statedb.Database().TrieDB(), newHead.DposContext); err != nil {
+			log.Error("Failed to reset txpool dpos state", "err", err)
+		}
+	}
+	pool.pendingDposState = statedb.Copy()
+	pool.pendingDposConfig = pool.chainconfig.Dpos.At(new(big.Int).Add(newHead.Number, big.NewInt(1)))
+	pool.pendingDposHeader = &types.Header{
+		Number: new(big.Int).Add(newHead.Number, big.NewInt(1)),
+		Time:   new(big.Int).Set(newHead.Time),
+	}
+	if pool.pendingDposConfig != nil {
+		pool.pendingDposHeader.Time.Add(pool.pendingDposHeader.Time, new(big.Int).SetUint64(pool.pendingDposConfig.BlockInterval))
+		if pool.pendingDposContext != nil {
+			pool.pendingDposEpoch = dposEpoch(pool.chainconfig.Dpos, pool.pendingDposContext, newHead, pool.pendingDposHeader)
+		}
+	}
+
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
 	senderCacher.recover(pool.signer, reinject)
@@ -425,10 +450,14 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	// higher gas price)
 	pool.demoteUnexecutables()
 
-	// Update all accounts to the latest known pending nonce
+	// Update all accounts to the latest known pending nonce and apply the
+	// pending DPoS transactions on top of the new head
 	for addr, list := range pool.pending {
 		txs := list.Flatten() // Heavy but will be cached and is needed by the miner anyway
 		pool.pendingState.SetNonce(addr, txs[len(txs)-1].Nonce()+1)
+		for _, tx := range txs {
+			pool.applyPendingDpos(tx)
+		}
 	}
 	// Check the queue and move transactions over to the pending if possible
 	// or remove those that have become invalid
@@ -598,16 +627,51 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if pool.currentState.GetBalance(from).Cmp(tx.Cost()) < 0 {
 		return ErrInsufficientFunds
 	}
-	intrGas, err := IntrinsicGas(tx.Data(), tx.To() == nil, pool.homestead)
+	intrGas, err := IntrinsicGas(tx.Type(), tx.Data(), tx.To() == nil, pool.homestead)
 	if err != nil {
 		return err
 	}
 	if tx.Gas() < intrGas {
 		return ErrIntrinsicGas
 	}
+	// DPoS transactions must apply cleanly on top of the pending ones
+	if tx.Type() != types.Binary {
+		if err := tx.Validate(); err != nil {
+			return err
+		}
+		if pool.pendingDposContext == nil {
+			return types.ErrInvalidType
+		}
+		msg, err := tx.AsMessage(pool.signer)
+		if err != nil {
+			return ErrInvalidSender
+		}
+		if err := validateDposMessage(pool.pendingDposConfig, pool.pendingDposContext, pool.pendingDposState, msg); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// applyPendingDpos applies a pending DPoS transaction to the pending DPoS state,
+// so that the transactions depending on it validate before it is mined. A
+// transaction which doesn't apply leaves the pending DPoS state untouched.
+func (pool *TxPool) applyPendingDpos(tx *types.Transaction) {
+	if tx.Type() == types.Binary || pool.pendingDposContext == nil || pool.pendingDposConfig == nil {
+		return
+	}
+	msg, err := tx.AsMessage(pool.signer)
+	if err != nil {
+		return
+	}
+	dposSnap, stateSnap := pool.pendingDposContext.Snapshot(), pool.pendingDposState.Snapshot()
+	if err := applyDposMessage(pool.pendingDposConfig, pool.pendingDposContext, pool.pendingDposState, pool.pendingDposHeader, pool.pendingDposEpoch, msg); err != nil {
+		log.Trace("Pending DPoS transaction doesn't apply", "hash", tx.Hash(), "err", err)
+		pool.pendingDposContext.RevertToSnapShot(dposSnap)
+		pool.pendingDposState.RevertToSnapshot(stateSnap)
+	}
+}
+
 // add validates a transaction and inserts it into the non-executable queue for
 // later pending promotion and execution. If the transaction is a replacement for
 // an already pending or queued one, it overwrites the previous and returns this
@@ -764,6 +828,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	// Set the potentially new pending nonce and notify any subsystems of the new tx
 	pool.beats[addr] = time.Now()
 	pool.pendingState.SetNonce(addr, tx.Nonce()+1)
+	pool.applyPendingDpos(tx)
 
 	return true
 }
@@ -126,7 +126,7 @@ func validateEvents(events chan NewTxsEvent, count int) error {
 		case ev := <-events:
 			received = append(received, ev.Txs...)
 		case <-time.After(time.Second):
-			return fmt.Errorf("event #%d not fired", received)
+			return fmt.Errorf("event #%d not fired", len(received))
 		}
 	}
 	if len(received) > count {
@@ -259,6 +259,133 @@ func TestInvalidTransactions(t *testing.T) {
 	}
 }
 
+// dposTestBlockChain is a testBlockChain whose head carries a DPoS state.
+type dposTestBlockChain struct {
+	*testBlockChain
+	dposContext *types.DposContextProto
+}
+
+func (bc *dposTestBlockChain) CurrentBlock() *types.Block {
+	return types.NewBlock(&types.Header{
+		Number:      big.NewInt(0),
+		GasLimit:    bc.gasLimit,
+		DposContext: bc.dposContext,
+	}, nil, nil, nil)
+}
+
+func (bc *dposTestBlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return bc.CurrentBlock()
+}
+
+func dposTransaction(txType types.TxType, nonce uint64, to common.Address, value *big.Int, gaslimit uint64, key *ecdsa.PrivateKey) *types.Transaction {
+	tx, _ := types.SignTx(types.NewTransaction(txType, nonce, to, value, gaslimit, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+	return tx
+}
+
+// Tests that DPoS transactions which can't be applied to the DPoS state of the
+// head are rejected by the pool.
+func TestInvalidDposTransactions(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	candidate := common.HexToAddress("0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e")
+	dposContext, _ := types.NewDposContext(statedb.Database().TrieDB())
+	dposContext.BecomeCandidate(candidate)
+	proto, _ := dposContext.Commit()
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	statedb.AddBalance(from, big.NewInt(0xffffffffffffff))
+
+	blockchain := &dposTestBlockChain{&testBlockChain{statedb, 1000000, new(event.Feed)}, proto}
+	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	tests := []struct {
+		tx  *types.Transaction
+		err error
+	}{
+		{dposTransaction(types.Delegate, 0, candidate, big.NewInt(1), params.TxDelegateGas-1, key), ErrIntrinsicGas},
+		{dposTransaction(types.Delegate, 0, common.Address{1}, big.NewInt(1), params.TxDelegateGas, key), ErrNotCandidate},
+		{dposTransaction(types.UnDelegate, 0, candidate, big.NewInt(0), params.TxUnDelegateGas, key), ErrNoVote},
+		{dposTransaction(types.UnregCandidate, 0, from, big.NewInt(0), params.TxUnregCandidateGas, key), ErrNotCandidate},
+		{dposTransaction(types.WithdrawReward, 0, from, big.NewInt(0), params.TxWithdrawRewardGas, key), ErrNoReward},
+		{dposTransaction(types.RotateSigner, 0, common.Address{1}, big.NewInt(0), params.TxRotateSignerGas, key), ErrNotCandidate},
+		{dposTransaction(types.Delegate, 0, candidate, big.NewInt(1), params.TxDelegateGas, key), nil},
+	}
+	for i, tt := range tests {
+		if err := pool.AddRemote(tt.tx); err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	// A registered candidate can't register again
+	dposContext.BecomeCandidate(from)
+	proto, _ = dposContext.Commit()
+	blockchain.dposContext = proto
+	pool.lockedReset(nil, nil)
+
+	tx := dposTransaction(types.RegCandidate, 1, from, big.NewInt(0), params.TxRegCandidateGas, key)
+	if err := pool.AddRemote(tx); err != ErrAlreadyCandidate {
+		t.Error("expected", ErrAlreadyCandidate, "got", err)
+	}
+	// Nor sign its blocks with the account of another candidate
+	tx = dposTransaction(types.RotateSigner, 1, candidate, big.NewInt(0), params.TxRotateSignerGas, key)
+	if err := pool.AddRemote(tx); err != ErrSigningKeyInUse {
+		t.Error("expected", ErrSigningKeyInUse, "got", err)
+	}
+}
+
+// Tests that DPoS transactions are validated on top of the pending ones, and
+// that candidacy transactions need no recipient.
+func TestPendingDposTransactions(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	dposContext, _ := types.NewDposContext(statedb.Database().TrieDB())
+	proto, _ := dposContext.Commit()
+
+	candidateKey, _ := crypto.GenerateKey()
+	candidate := crypto.PubkeyToAddress(candidateKey.PublicKey)
+	voterKey, _ := crypto.GenerateKey()
+	voter := crypto.PubkeyToAddress(voterKey.PublicKey)
+	statedb.AddBalance(candidate, big.NewInt(0xffffffffffffff))
+	statedb.AddBalance(voter, big.NewInt(0xffffffffffffff))
+
+	blockchain := &dposTestBlockChain{&testBlockChain{statedb, 1000000, new(event.Feed)}, proto}
+	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	// A vote for a candidate which isn't registered yet is rejected
+	vote := dposTransaction(types.Delegate, 0, candidate, big.NewInt(1), params.TxDelegateGas, voterKey)
+	if err := pool.AddRemote(vote); err != ErrNotCandidate {
+		t.Fatalf("vote before registration: error mismatch: have %v, want %v", err, ErrNotCandidate)
+	}
+	// The registration is accepted without a recipient
+	reg := dposTransaction(types.RegCandidate, 0, common.Address{}, big.NewInt(0), params.TxRegCandidateGas, candidateKey)
+	if reg.To() != nil {
+		t.Fatalf("registration recipient mismatch: have %x, want nil", reg.To())
+	}
+	if err := pool.AddRemote(reg); err != nil {
+		t.Fatalf("failed to add registration: %v", err)
+	}
+	// Once pending, the candidate can be voted for and can't register again
+	if err := pool.AddRemote(vote); err != nil {
+		t.Fatalf("failed to add vote for pending candidate: %v", err)
+	}
+	again := dposTransaction(types.RegCandidate, 1, common.Address{}, big.NewInt(0), params.TxRegCandidateGas, candidateKey)
+	if err := pool.AddRemote(again); err != ErrAlreadyCandidate {
+		t.Fatalf("second registration: error mismatch: have %v, want %v", err, ErrAlreadyCandidate)
+	}
+	// Both survive a reset to the same head
+	pool.lockedReset(nil, nil)
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 2)
+	}
+	if err := pool.AddRemote(again); err != ErrAlreadyCandidate {
+		t.Fatalf("second registration after reset: error mismatch: have %v, want %v", err, ErrAlreadyCandidate)
+	}
+}
+
 func TestTransactionQueue(t *testing.T) {
 	t.Parallel()
 
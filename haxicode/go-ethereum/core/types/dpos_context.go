@@ -2,8 +2,11 @@ package types
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
+	"math/big"
 
 	"github.com/haxicode/go-ethereum/common"
 	"github.com/haxicode/go-ethereum/crypto/sha3"
@@ -17,6 +20,10 @@ type DposContext struct {
 	voteTrie      *trie.Trie   //记录投票人对应验证人
 	candidateTrie *trie.Trie   //记录候选人列表
 	mintCntTrie   *trie.Trie   //记录验证人在周期内的出块数目
+	stakeTrie     *trie.Trie   //记录候选人及投票人锁定的押金
+	randaoTrie    *trie.Trie   //记录验证人的随机数承诺及混合随机数
+	governanceTrie *trie.Trie  // Parameter change proposals, approvals and the parameters in effect
+	candidateInfoTrie *trie.Trie // Name, website and node of the candidates
 
 	db *trie.Database
 }
@@ -27,6 +34,13 @@ var (
 	votePrefix      = []byte("vote-")
 	candidatePrefix = []byte("candidate-")
 	mintCntPrefix   = []byte("mintCnt-")
+	stakePrefix     = []byte("stake-")
+	randaoPrefix    = []byte("randao-")
+	governancePrefix = []byte("governance-")
+	candidateInfoPrefix = []byte("candidateInfo-")
+
+	validatorsKey = []byte("validator")
+	signersKey    = []byte("signers")
 )
 
 func NewEpochTrie(root common.Hash, db *trie.Database) (*trie.Trie, error) {
@@ -49,6 +63,22 @@ func NewMintCntTrie(root common.Hash, db *trie.Database) (*trie.Trie, error) {
 	return trie.NewTrieWithPrefix(root, mintCntPrefix, db)
 }
 
+func NewStakeTrie(root common.Hash, db *trie.Database) (*trie.Trie, error) {
+	return trie.NewTrieWithPrefix(root, stakePrefix, db)
+}
+
+func NewRandaoTrie(root common.Hash, db *trie.Database) (*trie.Trie, error) {
+	return trie.NewTrieWithPrefix(root, randaoPrefix, db)
+}
+
+func NewGovernanceTrie(root common.Hash, db *trie.Database) (*trie.Trie, error) {
+	return trie.NewTrieWithPrefix(root, governancePrefix, db)
+}
+
+func NewCandidateInfoTrie(root common.Hash, db *trie.Database) (*trie.Trie, error) {
+	return trie.NewTrieWithPrefix(root, candidateInfoPrefix, db)
+}
+
 func NewDposContext(db *trie.Database) (*DposContext, error) {
 	epochTrie, err := NewEpochTrie(common.Hash{}, db)
 	if err != nil {
@@ -70,12 +100,32 @@ func NewDposContext(db *trie.Database) (*DposContext, error) {
 	if err != nil {
 		return nil, err
 	}
+	stakeTrie, err := NewStakeTrie(common.Hash{}, db)
+	if err != nil {
+		return nil, err
+	}
+	randaoTrie, err := NewRandaoTrie(common.Hash{}, db)
+	if err != nil {
+		return nil, err
+	}
+	governanceTrie, err := NewGovernanceTrie(common.Hash{}, db)
+	if err != nil {
+		return nil, err
+	}
+	candidateInfoTrie, err := NewCandidateInfoTrie(common.Hash{}, db)
+	if err != nil {
+		return nil, err
+	}
 	return &DposContext{
 		epochTrie:     epochTrie,
 		delegateTrie:  delegateTrie,
 		voteTrie:      voteTrie,
 		candidateTrie: candidateTrie,
 		mintCntTrie:   mintCntTrie,
+		stakeTrie:     stakeTrie,
+		randaoTrie:    randaoTrie,
+		governanceTrie: governanceTrie,
+		candidateInfoTrie: candidateInfoTrie,
 		db:            db,
 	}, nil
 }
@@ -101,12 +151,32 @@ func NewDposContextFromProto(db *trie.Database, ctxProto *DposContextProto) (*Dp
 	if err != nil {
 		return nil, err
 	}
+	stakeTrie, err := NewStakeTrie(ctxProto.StakeHash, db)
+	if err != nil {
+		return nil, err
+	}
+	randaoTrie, err := NewRandaoTrie(ctxProto.RandaoHash, db)
+	if err != nil {
+		return nil, err
+	}
+	governanceTrie, err := NewGovernanceTrie(ctxProto.GovernanceHash, db)
+	if err != nil {
+		return nil, err
+	}
+	candidateInfoTrie, err := NewCandidateInfoTrie(ctxProto.CandidateInfoHash, db)
+	if err != nil {
+		return nil, err
+	}
 	return &DposContext{
 		epochTrie:     epochTrie,
 		delegateTrie:  delegateTrie,
 		voteTrie:      voteTrie,
 		candidateTrie: candidateTrie,
 		mintCntTrie:   mintCntTrie,
+		stakeTrie:     stakeTrie,
+		randaoTrie:    randaoTrie,
+		governanceTrie: governanceTrie,
+		candidateInfoTrie: candidateInfoTrie,
 		db:            db,
 	}, nil
 }
@@ -117,24 +187,25 @@ func (d *DposContext) Copy() *DposContext {
 	voteTrie := *d.voteTrie
 	candidateTrie := *d.candidateTrie
 	mintCntTrie := *d.mintCntTrie
+	stakeTrie := *d.stakeTrie
+	randaoTrie := *d.randaoTrie
+	governanceTrie := *d.governanceTrie
+	candidateInfoTrie := *d.candidateInfoTrie
 	return &DposContext{
 		epochTrie:     &epochTrie,
 		delegateTrie:  &delegateTrie,
 		voteTrie:      &voteTrie,
 		candidateTrie: &candidateTrie,
 		mintCntTrie:   &mintCntTrie,
+		stakeTrie:     &stakeTrie,
+		randaoTrie:    &randaoTrie,
+		governanceTrie: &governanceTrie,
+		candidateInfoTrie: &candidateInfoTrie,
 	}
 }
 
 func (d *DposContext) Root() (h common.Hash) {
-	hw := sha3.NewKeccak256()
-	rlp.Encode(hw, d.epochTrie.Hash())
-	rlp.Encode(hw, d.delegateTrie.Hash())
-	rlp.Encode(hw, d.candidateTrie.Hash())
-	rlp.Encode(hw, d.voteTrie.Hash())
-	rlp.Encode(hw, d.mintCntTrie.Hash())
-	hw.Sum(h[:0])
-	return h
+	return d.ToProto().Root()
 }
 
 func (d *DposContext) Snapshot() *DposContext {
@@ -147,6 +218,10 @@ func (d *DposContext) RevertToSnapShot(snapshot *DposContext) {
 	d.candidateTrie = snapshot.candidateTrie
 	d.voteTrie = snapshot.voteTrie
 	d.mintCntTrie = snapshot.mintCntTrie
+	d.stakeTrie = snapshot.stakeTrie
+	d.randaoTrie = snapshot.randaoTrie
+	d.governanceTrie = snapshot.governanceTrie
+	d.candidateInfoTrie = snapshot.candidateInfoTrie
 }
 
 func (d *DposContext) FromProto(dcp *DposContextProto) error {
@@ -169,6 +244,22 @@ func (d *DposContext) FromProto(dcp *DposContextProto) error {
 		return err
 	}
 	d.mintCntTrie, err = NewMintCntTrie(dcp.MintCntHash, d.db)
+	if err != nil {
+		return err
+	}
+	d.stakeTrie, err = NewStakeTrie(dcp.StakeHash, d.db)
+	if err != nil {
+		return err
+	}
+	d.randaoTrie, err = NewRandaoTrie(dcp.RandaoHash, d.db)
+	if err != nil {
+		return err
+	}
+	d.governanceTrie, err = NewGovernanceTrie(dcp.GovernanceHash, d.db)
+	if err != nil {
+		return err
+	}
+	d.candidateInfoTrie, err = NewCandidateInfoTrie(dcp.CandidateInfoHash, d.db)
 	return err
 }
 
@@ -178,6 +269,10 @@ type DposContextProto struct {
 	CandidateHash common.Hash `json:"candidateRoot"    gencodec:"required"`
 	VoteHash      common.Hash `json:"voteRoot"         gencodec:"required"`
 	MintCntHash   common.Hash `json:"mintCntRoot"      gencodec:"required"`
+	StakeHash     common.Hash `json:"stakeRoot"        gencodec:"required"`
+	RandaoHash    common.Hash `json:"randaoRoot"       gencodec:"required"`
+	GovernanceHash common.Hash `json:"governanceRoot"  gencodec:"required"`
+	CandidateInfoHash common.Hash `json:"candidateInfoRoot" gencodec:"required"`
 }
 
 func (d *DposContext) ToProto() *DposContextProto {
@@ -187,21 +282,108 @@ func (d *DposContext) ToProto() *DposContextProto {
 		CandidateHash: d.candidateTrie.Hash(),
 		VoteHash:      d.voteTrie.Hash(),
 		MintCntHash:   d.mintCntTrie.Hash(),
+		StakeHash:     d.stakeTrie.Hash(),
+		RandaoHash:    d.randaoTrie.Hash(),
+		GovernanceHash: d.governanceTrie.Hash(),
+		CandidateInfoHash: d.candidateInfoTrie.Hash(),
+	}
+}
+
+// Roots returns the roots of every trie, for the trie database to reference,
+// flush or garbage collect them.
+func (p *DposContextProto) Roots() []common.Hash {
+	return []common.Hash{
+		p.EpochHash,
+		p.DelegateHash,
+		p.CandidateHash,
+		p.VoteHash,
+		p.MintCntHash,
+		p.StakeHash,
+		p.RandaoHash,
+		p.GovernanceHash,
+		p.CandidateInfoHash,
 	}
 }
 
+// DposCheckpoint is a block of a DPoS chain trusted by configuration. Nodes
+// syncing from it download its state and execute the blocks after it, without
+// retrieving any of its ancestors.
+type DposCheckpoint struct {
+	Number      uint64            `json:"number"`
+	Hash        common.Hash       `json:"hash"`
+	DposContext *DposContextProto `json:"dposContext"`
+}
+
+// encodedRoots returns the roots a DposContextProto is encoded and hashed with.
+// The stake, randao, governance and candidate info tries were added to the DPoS
+// state later, their roots are left out while they are empty, as they are in
+// every block of chains created before. This keeps the headers of these blocks
+// encoded and signed like before. The randao trie in particular stays empty up
+// to the randao block, its root is left out on its own until then.
+func (p *DposContextProto) encodedRoots() []common.Hash {
+	roots := []common.Hash{p.EpochHash, p.DelegateHash, p.CandidateHash, p.VoteHash, p.MintCntHash}
+	switch {
+	case p.RandaoHash != EmptyRootHash:
+		return append(roots, p.StakeHash, p.RandaoHash, p.GovernanceHash, p.CandidateInfoHash)
+	case p.StakeHash != EmptyRootHash || p.GovernanceHash != EmptyRootHash || p.CandidateInfoHash != EmptyRootHash:
+		return append(roots, p.StakeHash, p.GovernanceHash, p.CandidateInfoHash)
+	}
+	return roots
+}
+
+// EncodeRLP implements rlp.Encoder, leaving the roots of empty tries added to
+// the DPoS state later out.
+func (p *DposContextProto) EncodeRLP(w io.Writer) error {
+	if p == nil {
+		_, err := w.Write(rlp.EmptyList)
+		return err
+	}
+	return rlp.Encode(w, p.encodedRoots())
+}
+
+// DecodeRLP implements rlp.Decoder, accepting the encodings with and without
+// the roots of the tries added to the DPoS state later.
+func (p *DposContextProto) DecodeRLP(s *rlp.Stream) error {
+	var roots []common.Hash
+	if err := s.Decode(&roots); err != nil {
+		return err
+	}
+	dec := DposContextProto{
+		StakeHash:         EmptyRootHash,
+		RandaoHash:        EmptyRootHash,
+		GovernanceHash:    EmptyRootHash,
+		CandidateInfoHash: EmptyRootHash,
+	}
+	switch len(roots) {
+	case 5:
+	case 8:
+		dec.StakeHash, dec.GovernanceHash, dec.CandidateInfoHash = roots[5], roots[6], roots[7]
+	case 9:
+		dec.StakeHash, dec.RandaoHash, dec.GovernanceHash, dec.CandidateInfoHash = roots[5], roots[6], roots[7], roots[8]
+	default:
+		return errors.New("invalid DPoS context encoding")
+	}
+	dec.EpochHash, dec.DelegateHash, dec.CandidateHash, dec.VoteHash, dec.MintCntHash = roots[0], roots[1], roots[2], roots[3], roots[4]
+	if len(dec.encodedRoots()) != len(roots) {
+		return errors.New("non-canonical DPoS context encoding")
+	}
+	*p = dec
+	return nil
+}
+
 func (p *DposContextProto) Root() (h common.Hash) {
 	hw := sha3.NewKeccak256()
-	rlp.Encode(hw, p.EpochHash)
-	rlp.Encode(hw, p.DelegateHash)
-	rlp.Encode(hw, p.CandidateHash)
-	rlp.Encode(hw, p.VoteHash)
-	rlp.Encode(hw, p.MintCntHash)
+	for _, root := range p.encodedRoots() {
+		rlp.Encode(hw, root)
+	}
 	hw.Sum(h[:0])
 	return h
 }
 
-func (d *DposContext) KickoutCandidate(candidateAddr common.Address) error {
+// KickoutCandidate removes the candidate, its description and every vote cast
+// for it. The deposit of the candidate and the stake of its delegators start
+// unbonding and will be paid back at releaseTime.
+func (d *DposContext) KickoutCandidate(candidateAddr common.Address, releaseTime int64) error {
 	candidate := candidateAddr.Bytes()
 	err := d.candidateTrie.TryDelete(candidate)
 	if err != nil {
@@ -209,9 +391,21 @@ func (d *DposContext) KickoutCandidate(candidateAddr common.Address) error {
 			return err
 		}
 	}
+	err = d.candidateInfoTrie.TryDelete(candidate)
+	if err != nil {
+		if _, ok := err.(*trie.MissingNodeError); !ok {
+			return err
+		}
+	}
+	if err := d.unbond(candidateStakeKey(candidateAddr), candidateAddr, releaseTime); err != nil {
+		return err
+	}
 	iter := trie.NewIterator(d.delegateTrie.PrefixIterator(candidate))
 	for iter.Next() {
 		delegator := iter.Value
+		if err := d.UnbondDelegateDeposit(common.BytesToAddress(delegator), releaseTime); err != nil {
+			return err
+		}
 		key := append(candidate, delegator...)
 		err = d.delegateTrie.TryDelete(key)
 		if err != nil {
@@ -243,6 +437,15 @@ func (d *DposContext) BecomeCandidate(candidateAddr common.Address) error {
 	return d.candidateTrie.TryUpdate(candidate, candidate)
 }
 
+// IsCandidate reports whether the address is a registered candidate.
+func (d *DposContext) IsCandidate(addr common.Address) (bool, error) {
+	candidate, err := d.candidateTrie.TryGet(addr.Bytes())
+	if err != nil {
+		return false, err
+	}
+	return candidate != nil, nil
+}
+
 //用户投票
 func (d *DposContext) Delegate(delegatorAddr, candidateAddr common.Address) error {
 	delegator, candidate := delegatorAddr.Bytes(), candidateAddr.Bytes()
@@ -257,6 +460,10 @@ func (d *DposContext) Delegate(delegatorAddr, candidateAddr common.Address) erro
 		return errors.New("invalid candidate to delegate")
 	}
 
+	// settle the rewards earned so far, the stake moves along with the vote
+	if _, err := d.undelegateStake(delegatorAddr); err != nil {
+		return err
+	}
 	// delete old candidate if exists
 	// 如果投票人之前已经给其他人投过票则先取消之前的投票
 	oldCandidate, err := d.voteTrie.TryGet(delegator)
@@ -273,7 +480,14 @@ func (d *DposContext) Delegate(delegatorAddr, candidateAddr common.Address) erro
 		return err
 	}
 	//更新投票人对应的候选人列表
-	return d.voteTrie.TryUpdate(delegator, candidate)
+	if err = d.voteTrie.TryUpdate(delegator, candidate); err != nil {
+		return err
+	}
+	stake, err := d.DelegateDeposit(delegatorAddr)
+	if err != nil {
+		return err
+	}
+	return d.delegateStake(delegatorAddr, candidate, stake)
 }
 
 //取消投票--删除投票人对应的候选人列表及候选人对应的投票人列表信息
@@ -301,6 +515,9 @@ func (d *DposContext) UnDelegate(delegatorAddr, candidateAddr common.Address) er
 	if !bytes.Equal(candidate, oldCandidate) {
 		return errors.New("mismatch candidate to undelegate")
 	}
+	if _, err := d.undelegateStake(delegatorAddr); err != nil {
+		return err
+	}
 
 	// 删除候选人对应投票人的列表中
 	if err = d.delegateTrie.TryDelete(append(candidate, delegator...)); err != nil {
@@ -311,6 +528,9 @@ func (d *DposContext) UnDelegate(delegatorAddr, candidateAddr common.Address) er
 }
 
 
+// Commit writes the tries into the trie database they were opened from. Like
+// the state trie, they are only held in memory until the database is flushed
+// to disk, which is left to the caller.
 func (d *DposContext) Commit() (*DposContextProto, error) {
 
 	epochRoot, err := d.epochTrie.Commit(nil)
@@ -344,11 +564,29 @@ func (d *DposContext) Commit() (*DposContextProto, error) {
 	}
 	d.mintCntTrie.TryUpdate(mintCntRoot[:], d.mintCntTrie.Get(mintCntRoot[:]))
 
-	d.db.Commit(epochRoot,true)
-	d.db.Commit(delegateRoot,true)
-	d.db.Commit(candidateRoot,true)
-	d.db.Commit(voteRoot,true)
-	d.db.Commit(mintCntRoot,true)
+	stakeRoot, err := d.stakeTrie.Commit(nil)
+	if err != nil {
+		return nil, err
+	}
+	d.stakeTrie.TryUpdate(stakeRoot[:], d.stakeTrie.Get(stakeRoot[:]))
+
+	randaoRoot, err := d.randaoTrie.Commit(nil)
+	if err != nil {
+		return nil, err
+	}
+	d.randaoTrie.TryUpdate(randaoRoot[:], d.randaoTrie.Get(randaoRoot[:]))
+
+	governanceRoot, err := d.governanceTrie.Commit(nil)
+	if err != nil {
+		return nil, err
+	}
+	d.governanceTrie.TryUpdate(governanceRoot[:], d.governanceTrie.Get(governanceRoot[:]))
+
+	candidateInfoRoot, err := d.candidateInfoTrie.Commit(nil)
+	if err != nil {
+		return nil, err
+	}
+	d.candidateInfoTrie.TryUpdate(candidateInfoRoot[:], d.candidateInfoTrie.Get(candidateInfoRoot[:]))
 
 	return &DposContextProto{
 		EpochHash:     epochRoot,
@@ -356,6 +594,10 @@ func (d *DposContext) Commit() (*DposContextProto, error) {
 		VoteHash:      voteRoot,
 		CandidateHash: candidateRoot,
 		MintCntHash:   mintCntRoot,
+		StakeHash:     stakeRoot,
+		RandaoHash:    randaoRoot,
+		GovernanceHash: governanceRoot,
+		CandidateInfoHash: candidateInfoRoot,
 	}, nil
 }
 
@@ -364,29 +606,974 @@ func (d *DposContext) DelegateTrie() *trie.Trie           { return d.delegateTri
 func (d *DposContext) VoteTrie() *trie.Trie               { return d.voteTrie }
 func (d *DposContext) EpochTrie() *trie.Trie              { return d.epochTrie }
 func (d *DposContext) MintCntTrie() *trie.Trie            { return d.mintCntTrie }
+func (d *DposContext) StakeTrie() *trie.Trie              { return d.stakeTrie }
+func (d *DposContext) RandaoTrie() *trie.Trie             { return d.randaoTrie }
+func (d *DposContext) GovernanceTrie() *trie.Trie         { return d.governanceTrie }
+func (d *DposContext) CandidateInfoTrie() *trie.Trie      { return d.candidateInfoTrie }
 func (d *DposContext) DB() *trie.Database                 { return d.db }
 func (dc *DposContext) SetEpoch(epoch *trie.Trie)         { dc.epochTrie = epoch }
 func (dc *DposContext) SetDelegate(delegate *trie.Trie)   { dc.delegateTrie = delegate }
 func (dc *DposContext) SetVote(vote *trie.Trie)           { dc.voteTrie = vote }
 func (dc *DposContext) SetCandidate(candidate *trie.Trie) { dc.candidateTrie = candidate }
 func (dc *DposContext) SetMintCnt(mintCnt *trie.Trie)     { dc.mintCntTrie = mintCnt }
+func (dc *DposContext) SetStake(stake *trie.Trie)         { dc.stakeTrie = stake }
+func (dc *DposContext) SetRandao(randao *trie.Trie)       { dc.randaoTrie = randao }
+func (dc *DposContext) SetGovernance(governance *trie.Trie) { dc.governanceTrie = governance }
+func (dc *DposContext) SetCandidateInfo(candidateInfo *trie.Trie) { dc.candidateInfoTrie = candidateInfo }
 
 func (dc *DposContext) GetValidators() ([]common.Address, error) {
+	return DecodeValidators(dc.epochTrie.Get(validatorsKey))
+}
+
+// ValidatorsProofKey returns the full path of the validator set in the epoch
+// trie. Merkle proofs against DposContextProto.EpochHash are built and
+// verified with this key, as proofs do not apply the trie prefix.
+func ValidatorsProofKey() []byte {
+	return append(common.CopyBytes(epochPrefix), validatorsKey...)
+}
+
+// GetValidatorSigners returns the keys the validators seal their blocks with,
+// in the order of GetValidators. The keys are only stored once a validator
+// registered a signing key other than its own account.
+func (dc *DposContext) GetValidatorSigners() ([]common.Address, error) {
+	_, signers, err := DecodeValidatorSigners(dc.epochTrie.Get(validatorsKey), dc.epochTrie.Get(signersKey))
+	return signers, err
+}
+
+// SignersProofKey returns the full path of the validator signing keys in the
+// epoch trie, see ValidatorsProofKey.
+func SignersProofKey() []byte {
+	return append(common.CopyBytes(epochPrefix), signersKey...)
+}
+
+// epochOffsetKey is the key of the epoch trie holding the offset the current
+// epoch is numbered with.
+var epochOffsetKey = []byte("offset")
+
+// EpochOffset returns the offset added to the time divided by the epoch length
+// to number the current epoch. It only differs from zero once a fork changed the
+// epoch length, keeping the epoch numbers increasing across the fork.
+func (dc *DposContext) EpochOffset() int64 {
+	value := dc.epochTrie.Get(epochOffsetKey)
+	if len(value) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(value))
+}
+
+// SetEpochOffset records the offset the current epoch is numbered with. A zero
+// offset isn't stored, so the epoch trie of chains that never changed the epoch
+// length stays the same.
+func (dc *DposContext) SetEpochOffset(offset int64) error {
+	if offset == 0 {
+		return dc.epochTrie.TryDelete(epochOffsetKey)
+	}
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(offset))
+	return dc.epochTrie.TryUpdate(epochOffsetKey, value)
+}
+
+// DecodeValidatorSigners decodes a validator set and their signing keys as
+// stored in the epoch trie. Without signing keys the validators sign with their
+// own accounts.
+func DecodeValidatorSigners(validatorsRLP, signersRLP []byte) ([]common.Address, []common.Address, error) {
+	validators, err := DecodeValidators(validatorsRLP)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(signersRLP) == 0 {
+		return validators, validators, nil
+	}
+	signers, err := DecodeValidators(signersRLP)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(signers) != len(validators) {
+		return nil, nil, errors.New("signing keys don't match validators")
+	}
+	return validators, signers, nil
+}
+
+// DecodeValidators decodes a validator set as stored in the epoch trie.
+func DecodeValidators(validatorsRLP []byte) ([]common.Address, error) {
 	var validators []common.Address
-	key := []byte("validator")
-	validatorsRLP := dc.epochTrie.Get(key)
 	if err := rlp.DecodeBytes(validatorsRLP, &validators); err != nil {
 		return nil, fmt.Errorf("failed to decode validators: %s", err)
 	}
 	return validators, nil
 }
 
+// GetCandidates returns the registered candidates, ordered by address.
+func (dc *DposContext) GetCandidates() ([]common.Address, error) {
+	var candidates []common.Address
+	iter := trie.NewIterator(dc.candidateTrie.NodeIterator(nil))
+	for iter.Next() {
+		candidates = append(candidates, common.BytesToAddress(iter.Value))
+	}
+	return candidates, iter.Err
+}
+
+// GetDelegators returns the delegators voting for the candidate, ordered by
+// address.
+func (dc *DposContext) GetDelegators(candidate common.Address) ([]common.Address, error) {
+	var delegators []common.Address
+	iter := trie.NewIterator(dc.delegateTrie.PrefixIterator(candidate.Bytes()))
+	for iter.Next() {
+		delegators = append(delegators, common.BytesToAddress(iter.Value))
+	}
+	return delegators, iter.Err
+}
+
+// GetVote returns the candidate the delegator votes for, nil if it doesn't vote.
+func (dc *DposContext) GetVote(delegator common.Address) (*common.Address, error) {
+	candidate, err := dc.voteTrie.TryGet(delegator.Bytes())
+	if err != nil || candidate == nil {
+		return nil, err
+	}
+	addr := common.BytesToAddress(candidate)
+	return &addr, nil
+}
+
+// GetMintCounts returns the number of blocks each validator minted in the epoch.
+// Validators that didn't mint are left out.
+func (dc *DposContext) GetMintCounts(epoch int64) (map[common.Address]uint64, error) {
+	counts := make(map[common.Address]uint64)
+	epochBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(epochBytes, uint64(epoch))
+	iter := trie.NewIterator(dc.mintCntTrie.PrefixIterator(epochBytes))
+	for iter.Next() {
+		// Iterator keys carry the trie prefix in front of the epoch and address
+		key := iter.Key[len(mintCntPrefix):]
+		if len(key) != 8+common.AddressLength {
+			continue
+		}
+		counts[common.BytesToAddress(key[8:])] = binary.BigEndian.Uint64(iter.Value)
+	}
+	return counts, iter.Err
+}
+
+// PruneMintCounts deletes the counters of the epochs before the given one.
+func (dc *DposContext) PruneMintCounts(before int64) error {
+	// Keys start with the big endian epoch, so the iteration is ordered by epoch
+	var keys [][]byte
+	iter := trie.NewIterator(dc.mintCntTrie.NodeIterator(nil))
+	for iter.Next() {
+		key := iter.Key[len(mintCntPrefix):]
+		if len(key) < 8 {
+			continue
+		}
+		if int64(binary.BigEndian.Uint64(key[:8])) >= before {
+			break
+		}
+		keys = append(keys, common.CopyBytes(key))
+	}
+	if iter.Err != nil {
+		return iter.Err
+	}
+	for _, key := range keys {
+		if err := dc.mintCntTrie.TryDelete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Suffixes telling the counters of the mint count trie apart. All of them are
+// keyed by epoch and validator, the minted block counters have no suffix.
+var (
+	missedCntSuffix     = []byte("missed")     // Slots the validator didn't seal
+	unrevealedCntSuffix = []byte("unrevealed") // Blocks sealed without revealing the randao commitment
+)
+
+func mintCntKey(epoch int64, validator common.Address) []byte {
+	key := make([]byte, 8, 8+common.AddressLength+len(unrevealedCntSuffix))
+	binary.BigEndian.PutUint64(key, uint64(epoch))
+	return append(key, validator.Bytes()...)
+}
+
+func (dc *DposContext) getCount(key []byte) uint64 {
+	if cnt := dc.mintCntTrie.Get(key); cnt != nil {
+		return binary.BigEndian.Uint64(cnt)
+	}
+	return 0
+}
+
+func (dc *DposContext) addCount(key []byte, n uint64) error {
+	cnt := make([]byte, 8)
+	binary.BigEndian.PutUint64(cnt, dc.getCount(key)+n)
+	return dc.mintCntTrie.TryUpdate(key, cnt)
+}
+
+// getCounts returns the counters with the given suffix of every validator in
+// the epoch.
+func (dc *DposContext) getCounts(epoch int64, suffix []byte) (map[common.Address]uint64, error) {
+	counts := make(map[common.Address]uint64)
+	epochBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(epochBytes, uint64(epoch))
+	iter := trie.NewIterator(dc.mintCntTrie.PrefixIterator(epochBytes))
+	for iter.Next() {
+		// Iterator keys carry the trie prefix in front of the epoch and address
+		key := iter.Key[len(mintCntPrefix):]
+		if len(key) != 8+common.AddressLength+len(suffix) || !bytes.HasSuffix(key, suffix) {
+			continue
+		}
+		counts[common.BytesToAddress(key[8:8+common.AddressLength])] = binary.BigEndian.Uint64(iter.Value)
+	}
+	return counts, iter.Err
+}
+
+// MintCount returns the number of blocks the validator minted in the epoch.
+func (dc *DposContext) MintCount(epoch int64, validator common.Address) uint64 {
+	return dc.getCount(mintCntKey(epoch, validator))
+}
+
+// MissedCount returns the number of slots the validator missed in the epoch.
+func (dc *DposContext) MissedCount(epoch int64, validator common.Address) uint64 {
+	return dc.getCount(append(mintCntKey(epoch, validator), missedCntSuffix...))
+}
+
+// AddMissedCount charges the validator with n more slots missed in the epoch.
+func (dc *DposContext) AddMissedCount(epoch int64, validator common.Address, n uint64) error {
+	return dc.addCount(append(mintCntKey(epoch, validator), missedCntSuffix...), n)
+}
+
+// GetMissedCounts returns the number of slots each validator missed in the
+// epoch. Validators that didn't miss any are left out.
+func (dc *DposContext) GetMissedCounts(epoch int64) (map[common.Address]uint64, error) {
+	return dc.getCounts(epoch, missedCntSuffix)
+}
+
+// UnrevealedCount returns the number of blocks the validator sealed in the epoch
+// without revealing its randao commitment.
+func (dc *DposContext) UnrevealedCount(epoch int64, validator common.Address) uint64 {
+	return dc.getCount(append(mintCntKey(epoch, validator), unrevealedCntSuffix...))
+}
+
+// AddUnrevealedCount charges the validator with n more blocks sealed in the
+// epoch without revealing its randao commitment.
+func (dc *DposContext) AddUnrevealedCount(epoch int64, validator common.Address, n uint64) error {
+	return dc.addCount(append(mintCntKey(epoch, validator), unrevealedCntSuffix...), n)
+}
+
+// GetUnrevealedCounts returns the number of blocks each validator sealed in the
+// epoch without revealing its randao commitment. Validators that always
+// revealed are left out.
+func (dc *DposContext) GetUnrevealedCounts(epoch int64) (map[common.Address]uint64, error) {
+	return dc.getCounts(epoch, unrevealedCntSuffix)
+}
+
+// randaoMixKey is the key of the randao trie holding the mix of all the secrets
+// revealed so far. Commitments are keyed by validator address.
+var randaoMixKey = []byte("mix")
+
+// RandaoMix returns the mix of all the randao secrets revealed so far.
+func (dc *DposContext) RandaoMix() common.Hash {
+	return common.BytesToHash(dc.randaoTrie.Get(randaoMixKey))
+}
+
+// SetRandaoMix stores the mix of all the randao secrets revealed so far.
+func (dc *DposContext) SetRandaoMix(mix common.Hash) error {
+	return dc.randaoTrie.TryUpdate(randaoMixKey, mix.Bytes())
+}
+
+// RandaoCommitment returns the hash of the secret the validator committed to
+// and the number of the block committing it, a zero hash if the validator has
+// nothing to reveal.
+func (dc *DposContext) RandaoCommitment(validator common.Address) (common.Hash, uint64) {
+	value := dc.randaoTrie.Get(validator.Bytes())
+	if len(value) != common.HashLength+8 {
+		return common.Hash{}, 0
+	}
+	return common.BytesToHash(value[:common.HashLength]), binary.BigEndian.Uint64(value[common.HashLength:])
+}
+
+// SetRandaoCommitment records the hash of the secret the validator committed to
+// in block number, a zero hash clears the commitment.
+func (dc *DposContext) SetRandaoCommitment(validator common.Address, commitment common.Hash, number uint64) error {
+	if commitment == (common.Hash{}) {
+		return dc.randaoTrie.TryDelete(validator.Bytes())
+	}
+	value := make([]byte, common.HashLength+8)
+	copy(value, commitment.Bytes())
+	binary.BigEndian.PutUint64(value[common.HashLength:], number)
+	return dc.randaoTrie.TryUpdate(validator.Bytes(), value)
+}
+
 func (dc *DposContext) SetValidators(validators []common.Address) error {
-	key := []byte("validator")
 	validatorsRLP, err := rlp.EncodeToBytes(validators)
 	if err != nil {
 		return fmt.Errorf("failed to encode validators to rlp bytes: %s", err)
 	}
-	dc.epochTrie.Update(key, validatorsRLP)
+	dc.epochTrie.Update(validatorsKey, validatorsRLP)
+	return dc.setValidatorSigners(validators)
+}
+
+// setValidatorSigners records the signing keys of the validators in the epoch
+// trie. Nothing is stored while every validator signs with its own account, so
+// the epoch trie of chains without signing keys stays the same.
+func (dc *DposContext) setValidatorSigners(validators []common.Address) error {
+	signers := make([]common.Address, len(validators))
+	separate := false
+	for i, validator := range validators {
+		signer, err := dc.SigningKey(validator)
+		if err != nil {
+			return err
+		}
+		signers[i] = signer
+		separate = separate || signer != validator
+	}
+	if !separate {
+		return dc.epochTrie.TryDelete(signersKey)
+	}
+	signersRLP, err := rlp.EncodeToBytes(signers)
+	if err != nil {
+		return fmt.Errorf("failed to encode signers to rlp bytes: %s", err)
+	}
+	return dc.epochTrie.TryUpdate(signersKey, signersRLP)
+}
+
+// Key spaces of the stake trie. Locked stake is keyed by kind and owner, while
+// unbonding stake is keyed by release time first so that matured entries can
+// be found by iterating from the start of the unbonding key space.
+const (
+	candidateStakeKind byte = iota + 1 // Deposit locked by RegCandidate
+	delegateStakeKind                  // Stake locked by Delegate, counted as votes
+	unbondingStakeKind                 // Stake waiting to be paid back
+	slashedStakeKind                   // Marks a double signed slot that was punished
+	commissionKind                     // Share of the block rewards kept by a validator
+	delegatedStakeKind                 // Total stake of the delegators voting for a candidate
+	rewardPerStakeKind                 // Delegator rewards per unit of stake accumulated by a candidate
+	rewardDebtKind                     // Rewards per unit of stake a delegator was last settled at
+	accruedRewardKind                  // Settled rewards of a delegator not withdrawn yet
+	withdrawnRewardKind                // Rewards withdrawn by a delegator so far
+	signingKeyKind                     // Key a candidate seals its blocks with
+	signerOwnerKind                    // Candidate a signing key belongs to
+	signingKeyHistoryKind              // Key a candidate sealed its blocks with until a change of its key
+)
+
+// rewardPrecision scales the rewards per unit of stake to keep the rounding
+// errors of the distribution negligible.
+var rewardPrecision = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+
+// DefaultCommission is the percentage of the block rewards kept by validators
+// that never set a commission.
+const DefaultCommission = 10
+
+func addrStakeKey(kind byte, addr []byte) []byte {
+	return append([]byte{kind}, addr...)
+}
+
+// signingKeyHistoryKey = signingKeyHistoryKind + candidate + time (uint64 big endian)
+func signingKeyHistoryKey(candidate common.Address, time int64) []byte {
+	key := make([]byte, 1+common.AddressLength+8)
+	key[0] = signingKeyHistoryKind
+	copy(key[1:], candidate.Bytes())
+	binary.BigEndian.PutUint64(key[1+common.AddressLength:], uint64(time))
+	return key
+}
+
+func candidateStakeKey(addr common.Address) []byte {
+	return append([]byte{candidateStakeKind}, addr.Bytes()...)
+}
+
+func delegateStakeKey(addr common.Address) []byte {
+	return append([]byte{delegateStakeKind}, addr.Bytes()...)
+}
+
+func unbondingStakeKey(releaseTime int64, addr common.Address) []byte {
+	key := make([]byte, 9, 9+common.AddressLength)
+	key[0] = unbondingStakeKind
+	binary.BigEndian.PutUint64(key[1:], uint64(releaseTime))
+	return append(key, addr.Bytes()...)
+}
+
+func slashedStakeKey(slot int64, addr common.Address) []byte {
+	key := make([]byte, 9, 9+common.AddressLength)
+	key[0] = slashedStakeKind
+	binary.BigEndian.PutUint64(key[1:], uint64(slot))
+	return append(key, addr.Bytes()...)
+}
+
+func (d *DposContext) getStake(key []byte) (*big.Int, error) {
+	enc, err := d.stakeTrie.TryGet(key)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(enc), nil
+}
+
+func (d *DposContext) setStake(key []byte, amount *big.Int) error {
+	if amount.Sign() == 0 {
+		return d.stakeTrie.TryDelete(key)
+	}
+	return d.stakeTrie.TryUpdate(key, amount.Bytes())
+}
+
+// CandidateDeposit returns the deposit locked by the candidate at registration.
+func (d *DposContext) CandidateDeposit(addr common.Address) (*big.Int, error) {
+	return d.getStake(candidateStakeKey(addr))
+}
+
+// DelegateDeposit returns the stake the delegator locked to vote with.
+func (d *DposContext) DelegateDeposit(addr common.Address) (*big.Int, error) {
+	return d.getStake(delegateStakeKey(addr))
+}
+
+// LockCandidateDeposit adds amount to the deposit held for the candidate.
+func (d *DposContext) LockCandidateDeposit(addr common.Address, amount *big.Int) error {
+	return d.lock(candidateStakeKey(addr), amount)
+}
+
+// LockDelegateDeposit adds amount to the stake held for the delegator.
+func (d *DposContext) LockDelegateDeposit(addr common.Address, amount *big.Int) error {
+	candidate, err := d.undelegateStake(addr)
+	if err != nil {
+		return err
+	}
+	if err := d.lock(delegateStakeKey(addr), amount); err != nil {
+		return err
+	}
+	if candidate == nil {
+		return nil
+	}
+	stake, err := d.DelegateDeposit(addr)
+	if err != nil {
+		return err
+	}
+	return d.delegateStake(addr, candidate, stake)
+}
+
+// UnbondDelegateDeposit moves the whole stake of the delegator into unbonding,
+// to be paid back at releaseTime.
+func (d *DposContext) UnbondDelegateDeposit(addr common.Address, releaseTime int64) error {
+	if _, err := d.undelegateStake(addr); err != nil {
+		return err
+	}
+	return d.unbond(delegateStakeKey(addr), addr, releaseTime)
+}
+
+func (d *DposContext) lock(key []byte, amount *big.Int) error {
+	stake, err := d.getStake(key)
+	if err != nil {
+		return err
+	}
+	return d.setStake(key, stake.Add(stake, amount))
+}
+
+// release subtracts amount from the stake held under key, down to zero.
+func (d *DposContext) release(key []byte, amount *big.Int) error {
+	stake, err := d.getStake(key)
+	if err != nil {
+		return err
+	}
+	if stake.Cmp(amount) < 0 {
+		return d.setStake(key, new(big.Int))
+	}
+	return d.setStake(key, stake.Sub(stake, amount))
+}
+
+func (d *DposContext) unbond(key []byte, addr common.Address, releaseTime int64) error {
+	stake, err := d.getStake(key)
+	if err != nil || stake.Sign() == 0 {
+		return err
+	}
+	if err := d.stakeTrie.TryDelete(key); err != nil {
+		return err
+	}
+	return d.lock(unbondingStakeKey(releaseTime, addr), stake)
+}
+
+// Unbonding is an amount of stake waiting to be paid back to its owner.
+type Unbonding struct {
+	Address     common.Address `json:"address"`
+	Amount      *big.Int       `json:"amount"`
+	ReleaseTime int64          `json:"releaseTime"`
+}
+
+// ReleaseUnbonded removes every unbonding entry whose release time is at or
+// before now from the stake trie and returns them, ordered by release time.
+// The caller is responsible for crediting the amounts back to their owners.
+func (d *DposContext) ReleaseUnbonded(now int64) ([]*Unbonding, error) {
+	var released []*Unbonding
+	iter := trie.NewIterator(d.stakeTrie.PrefixIterator([]byte{unbondingStakeKind}))
+	for iter.Next() {
+		// Iterator keys carry the trie prefix in front of the stake key
+		key := iter.Key[len(stakePrefix):]
+		if len(key) != 9+common.AddressLength {
+			continue
+		}
+		releaseTime := int64(binary.BigEndian.Uint64(key[1:9]))
+		if releaseTime > now {
+			break
+		}
+		released = append(released, &Unbonding{
+			Address:     common.BytesToAddress(key[9:]),
+			Amount:      new(big.Int).SetBytes(iter.Value),
+			ReleaseTime: releaseTime,
+		})
+	}
+	for _, entry := range released {
+		if err := d.stakeTrie.TryDelete(unbondingStakeKey(entry.ReleaseTime, entry.Address)); err != nil {
+			return nil, err
+		}
+	}
+	return released, nil
+}
+
+// SlashCandidate punishes a candidate that double signed the given slot. The
+// percent share of its deposit is confiscated and the candidate is kicked out,
+// unbonding the remaining deposit and the stake of its delegators. Every slot
+// of a candidate is punished at most once. The confiscated amount is returned.
+func (d *DposContext) SlashCandidate(addr common.Address, slot int64, percent uint64, releaseTime int64) (*big.Int, error) {
+	candidate, err := d.candidateTrie.TryGet(addr.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if candidate == nil {
+		return nil, errors.New("invalid candidate to slash")
+	}
+	slashed, err := d.stakeTrie.TryGet(slashedStakeKey(slot, addr))
+	if err != nil {
+		return nil, err
+	}
+	if slashed != nil {
+		return nil, errors.New("candidate already slashed for slot")
+	}
+	if err := d.stakeTrie.TryUpdate(slashedStakeKey(slot, addr), []byte{1}); err != nil {
+		return nil, err
+	}
+	deposit, err := d.CandidateDeposit(addr)
+	if err != nil {
+		return nil, err
+	}
+	penalty := new(big.Int).Mul(deposit, new(big.Int).SetUint64(percent))
+	penalty.Div(penalty, big.NewInt(100))
+	if err := d.setStake(candidateStakeKey(addr), deposit.Sub(deposit, penalty)); err != nil {
+		return nil, err
+	}
+	return penalty, d.KickoutCandidate(addr, releaseTime)
+}
+
+// Rewards are distributed lazily: every block only raises the rewards per unit
+// of stake of its validator, and the share of a delegator is settled whenever
+// its stake or vote changes, or when the rewards are withdrawn.
+
+// undelegateStake settles the rewards the delegator earned with its stake on
+// the candidate it votes for and removes that stake from the candidate total.
+// The candidate is returned, nil if the delegator doesn't vote.
+func (d *DposContext) undelegateStake(delegator common.Address) ([]byte, error) {
+	candidate, err := d.voteTrie.TryGet(delegator.Bytes())
+	if err != nil || candidate == nil {
+		return nil, err
+	}
+	earned, err := d.pendingReward(delegator, candidate)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.lock(addrStakeKey(accruedRewardKind, delegator.Bytes()), earned); err != nil {
+		return nil, err
+	}
+	stake, err := d.DelegateDeposit(delegator)
+	if err != nil {
+		return nil, err
+	}
+	return candidate, d.release(addrStakeKey(delegatedStakeKind, candidate), stake)
+}
+
+// delegateStake adds the stake of the delegator to the candidate total, starting
+// to earn rewards from now on.
+func (d *DposContext) delegateStake(delegator common.Address, candidate []byte, stake *big.Int) error {
+	perStake, err := d.getStake(addrStakeKey(rewardPerStakeKind, candidate))
+	if err != nil {
+		return err
+	}
+	if err := d.setStake(addrStakeKey(rewardDebtKind, delegator.Bytes()), perStake); err != nil {
+		return err
+	}
+	return d.lock(addrStakeKey(delegatedStakeKind, candidate), stake)
+}
+
+// pendingReward returns the rewards the delegator earned on the candidate since
+// it was last settled.
+func (d *DposContext) pendingReward(delegator common.Address, candidate []byte) (*big.Int, error) {
+	stake, err := d.DelegateDeposit(delegator)
+	if err != nil {
+		return nil, err
+	}
+	perStake, err := d.getStake(addrStakeKey(rewardPerStakeKind, candidate))
+	if err != nil {
+		return nil, err
+	}
+	debt, err := d.getStake(addrStakeKey(rewardDebtKind, delegator.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	if perStake.Cmp(debt) <= 0 {
+		return new(big.Int), nil
+	}
+	earned := perStake.Sub(perStake, debt)
+	earned.Mul(earned, stake)
+	return earned.Div(earned, rewardPrecision), nil
+}
+
+// Commission returns the percentage of the block rewards the validator keeps.
+// Validators that never set one keep DefaultCommission percent.
+func (d *DposContext) Commission(addr common.Address) (uint64, error) {
+	enc, err := d.stakeTrie.TryGet(addrStakeKey(commissionKind, addr.Bytes()))
+	if err != nil {
+		return 0, err
+	}
+	if len(enc) == 0 {
+		return DefaultCommission, nil
+	}
+	return uint64(enc[0]), nil
+}
+
+// SetCommission sets the percentage of the block rewards the validator keeps.
+func (d *DposContext) SetCommission(addr common.Address, commission uint64) error {
+	if commission > 100 {
+		return errors.New("commission above 100 percent")
+	}
+	return d.stakeTrie.TryUpdate(addrStakeKey(commissionKind, addr.Bytes()), []byte{byte(commission)})
+}
+
+// SigningKey returns the key the candidate seals its blocks with, its own
+// account if it never registered another one.
+func (d *DposContext) SigningKey(candidate common.Address) (common.Address, error) {
+	signer, err := d.stakeTrie.TryGet(addrStakeKey(signingKeyKind, candidate.Bytes()))
+	if err != nil {
+		return common.Address{}, err
+	}
+	if len(signer) == 0 {
+		return candidate, nil
+	}
+	return common.BytesToAddress(signer), nil
+}
+
+// SigningKeyAt returns the key the candidate sealed the block of the slot at the
+// given time with. Every change of the key keeps the previous one along with the
+// time of the block changing it, which was still sealed with the previous key.
+func (d *DposContext) SigningKeyAt(candidate common.Address, time int64) (common.Address, error) {
+	iter := trie.NewIterator(d.stakeTrie.PrefixIterator(addrStakeKey(signingKeyHistoryKind, candidate.Bytes())))
+	for iter.Next() {
+		// Iterator keys carry the trie prefix in front of the stake key
+		key := iter.Key[len(stakePrefix):]
+		if len(key) != 1+common.AddressLength+8 {
+			continue
+		}
+		if int64(binary.BigEndian.Uint64(key[1+common.AddressLength:])) >= time {
+			return common.BytesToAddress(iter.Value), nil
+		}
+	}
+	if iter.Err != nil {
+		return common.Address{}, iter.Err
+	}
+	return d.SigningKey(candidate)
+}
+
+// SigningKeyOwner returns the candidate that registered the signing key, a zero
+// address if no candidate did.
+func (d *DposContext) SigningKeyOwner(signer common.Address) (common.Address, error) {
+	owner, err := d.stakeTrie.TryGet(addrStakeKey(signerOwnerKind, signer.Bytes()))
+	if err != nil {
+		return common.Address{}, err
+	}
+	return common.BytesToAddress(owner), nil
+}
+
+// SetSigningKey registers the key the candidate seals its blocks with, replacing
+// the previous one from the block after the one at the given time. If the
+// candidate is a validator of the current epoch, the new key has to seal its
+// blocks right away.
+func (d *DposContext) SetSigningKey(candidate, signer common.Address, time int64) error {
+	old, err := d.SigningKey(candidate)
+	if err != nil {
+		return err
+	}
+	// Keep the key the block at the time was sealed with, for double sign
+	// evidence of the slots up to it
+	history := signingKeyHistoryKey(candidate, time)
+	sealed, err := d.stakeTrie.TryGet(history)
+	if err != nil {
+		return err
+	}
+	if sealed == nil {
+		if err := d.stakeTrie.TryUpdate(history, old.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := d.stakeTrie.TryDelete(addrStakeKey(signerOwnerKind, old.Bytes())); err != nil {
+		return err
+	}
+	if signer == candidate {
+		err = d.stakeTrie.TryDelete(addrStakeKey(signingKeyKind, candidate.Bytes()))
+	} else {
+		err = d.stakeTrie.TryUpdate(addrStakeKey(signingKeyKind, candidate.Bytes()), signer.Bytes())
+		if err == nil {
+			err = d.stakeTrie.TryUpdate(addrStakeKey(signerOwnerKind, signer.Bytes()), candidate.Bytes())
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if d.epochTrie.Get(validatorsKey) == nil {
+		return nil
+	}
+	validators, err := d.GetValidators()
+	if err != nil {
+		return err
+	}
+	for _, validator := range validators {
+		if validator == candidate {
+			return d.setValidatorSigners(validators)
+		}
+	}
 	return nil
 }
+
+// DistributeReward shares the reward of a block between its validator and the
+// delegators voting for it. The commission of the validator is returned for the
+// caller to pay out, the rest is accrued to the delegators in proportion to
+// their stake. Without delegated stake the validator keeps the whole reward.
+func (d *DposContext) DistributeReward(validator common.Address, reward *big.Int) (*big.Int, error) {
+	commission, err := d.Commission(validator)
+	if err != nil {
+		return nil, err
+	}
+	total, err := d.getStake(addrStakeKey(delegatedStakeKind, validator.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	share := new(big.Int).Mul(reward, new(big.Int).SetUint64(commission))
+	share.Div(share, big.NewInt(100))
+	rest := new(big.Int).Sub(reward, share)
+	if total.Sign() == 0 || rest.Sign() == 0 {
+		return new(big.Int).Set(reward), nil
+	}
+	perStake := rest.Mul(rest, rewardPrecision)
+	perStake.Div(perStake, total)
+	return share, d.lock(addrStakeKey(rewardPerStakeKind, validator.Bytes()), perStake)
+}
+
+// Rewards returns the rewards accrued by the delegator and not withdrawn yet,
+// and the total it withdrew so far.
+func (d *DposContext) Rewards(delegator common.Address) (accrued, withdrawn *big.Int, err error) {
+	if accrued, err = d.getStake(addrStakeKey(accruedRewardKind, delegator.Bytes())); err != nil {
+		return nil, nil, err
+	}
+	candidate, err := d.voteTrie.TryGet(delegator.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	if candidate != nil {
+		earned, err := d.pendingReward(delegator, candidate)
+		if err != nil {
+			return nil, nil, err
+		}
+		accrued.Add(accrued, earned)
+	}
+	if withdrawn, err = d.getStake(addrStakeKey(withdrawnRewardKind, delegator.Bytes())); err != nil {
+		return nil, nil, err
+	}
+	return accrued, withdrawn, nil
+}
+
+// WithdrawReward settles and removes the rewards accrued by the delegator and
+// returns them for the caller to pay out.
+func (d *DposContext) WithdrawReward(delegator common.Address) (*big.Int, error) {
+	candidate, err := d.undelegateStake(delegator)
+	if err != nil {
+		return nil, err
+	}
+	if candidate != nil {
+		stake, err := d.DelegateDeposit(delegator)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.delegateStake(delegator, candidate, stake); err != nil {
+			return nil, err
+		}
+	}
+	key := addrStakeKey(accruedRewardKind, delegator.Bytes())
+	amount, err := d.getStake(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.setStake(key, new(big.Int)); err != nil {
+		return nil, err
+	}
+	return amount, d.lock(addrStakeKey(withdrawnRewardKind, delegator.Bytes()), amount)
+}
+
+// Kinds of the governance trie keys. Proposals are followed by the address of
+// the proposing validator, approvals by the addresses of the proposing and of
+// the approving validator, and pending params by the epoch they take effect in.
+const (
+	proposalKind byte = iota
+	approvalKind
+	pendingParamsKind
+)
+
+// activeParamsKey is the key of the governance trie holding the params in effect.
+var activeParamsKey = []byte("active")
+
+func proposalKey(kind byte, proposer common.Address) []byte {
+	return append([]byte{kind}, proposer.Bytes()...)
+}
+
+func approvalKey(proposer, approver common.Address) []byte {
+	return append(proposalKey(approvalKind, proposer), approver.Bytes()...)
+}
+
+func pendingParamsKey(epoch int64) []byte {
+	key := make([]byte, 9)
+	key[0] = pendingParamsKind
+	binary.BigEndian.PutUint64(key[1:], uint64(epoch))
+	return key
+}
+
+func decodeDposParams(enc []byte) (*DposParams, error) {
+	params := new(DposParams)
+	if err := rlp.DecodeBytes(enc, params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+func (d *DposContext) setDposParams(key []byte, params *DposParams) error {
+	if params == nil {
+		return d.governanceTrie.TryDelete(key)
+	}
+	enc, err := rlp.EncodeToBytes(params)
+	if err != nil {
+		return err
+	}
+	return d.governanceTrie.TryUpdate(key, enc)
+}
+
+// ParamsProposal returns the params the validator proposed, nil if it has no
+// open proposal.
+func (d *DposContext) ParamsProposal(proposer common.Address) (*DposParams, error) {
+	enc, err := d.governanceTrie.TryGet(proposalKey(proposalKind, proposer))
+	if err != nil || enc == nil {
+		return nil, err
+	}
+	return decodeDposParams(enc)
+}
+
+// ProposeParams opens a proposal of the validator, replacing its previous one
+// and the approvals gathered for it. The proposer approves its own proposal.
+func (d *DposContext) ProposeParams(proposer common.Address, params *DposParams) error {
+	if err := d.DeleteParamsProposal(proposer); err != nil {
+		return err
+	}
+	if err := d.setDposParams(proposalKey(proposalKind, proposer), params); err != nil {
+		return err
+	}
+	return d.ApproveParams(proposer, proposer)
+}
+
+// ApproveParams records the approval of the proposal of proposer by approver.
+func (d *DposContext) ApproveParams(proposer, approver common.Address) error {
+	return d.governanceTrie.TryUpdate(approvalKey(proposer, approver), approver.Bytes())
+}
+
+// ParamsApprovals returns the validators that approved the proposal of proposer.
+func (d *DposContext) ParamsApprovals(proposer common.Address) ([]common.Address, error) {
+	var approvers []common.Address
+	iter := trie.NewIterator(d.governanceTrie.PrefixIterator(proposalKey(approvalKind, proposer)))
+	for iter.Next() {
+		approvers = append(approvers, common.BytesToAddress(iter.Value))
+	}
+	return approvers, iter.Err
+}
+
+// DeleteParamsProposal closes the proposal of proposer along with its approvals.
+func (d *DposContext) DeleteParamsProposal(proposer common.Address) error {
+	approvers, err := d.ParamsApprovals(proposer)
+	if err != nil {
+		return err
+	}
+	for _, approver := range approvers {
+		if err := d.governanceTrie.TryDelete(approvalKey(proposer, approver)); err != nil {
+			return err
+		}
+	}
+	return d.governanceTrie.TryDelete(proposalKey(proposalKind, proposer))
+}
+
+// PendingParams returns the approved params taking effect in epoch, nil if no
+// change is pending for it.
+func (d *DposContext) PendingParams(epoch int64) (*DposParams, error) {
+	enc, err := d.governanceTrie.TryGet(pendingParamsKey(epoch))
+	if err != nil || enc == nil {
+		return nil, err
+	}
+	return decodeDposParams(enc)
+}
+
+// PendingEpochs returns the epochs changes are pending for, in ascending order.
+func (d *DposContext) PendingEpochs() ([]int64, error) {
+	var epochs []int64
+	iter := trie.NewIterator(d.governanceTrie.PrefixIterator([]byte{pendingParamsKind}))
+	for iter.Next() {
+		// Iterator keys carry the trie prefix in front of the kind and epoch
+		key := iter.Key[len(governancePrefix):]
+		epochs = append(epochs, int64(binary.BigEndian.Uint64(key[1:])))
+	}
+	return epochs, iter.Err
+}
+
+// SetPendingParams schedules the approved params to take effect in epoch. A nil
+// params cancels the change pending for it.
+func (d *DposContext) SetPendingParams(epoch int64, params *DposParams) error {
+	return d.setDposParams(pendingParamsKey(epoch), params)
+}
+
+// ActiveParams returns the params in effect through governance, empty params if
+// none were ever changed.
+func (d *DposContext) ActiveParams() (*DposParams, error) {
+	enc, err := d.governanceTrie.TryGet(activeParamsKey)
+	if err != nil {
+		return nil, err
+	}
+	if enc == nil {
+		return new(DposParams), nil
+	}
+	return decodeDposParams(enc)
+}
+
+// SetActiveParams records the params in effect through governance.
+func (d *DposContext) SetActiveParams(params *DposParams) error {
+	return d.setDposParams(activeParamsKey, params)
+}
+
+// CandidateInfo returns the description the candidate registered, an empty one
+// if it gave none.
+func (d *DposContext) CandidateInfo(addr common.Address) (*CandidateInfo, error) {
+	enc, err := d.candidateInfoTrie.TryGet(addr.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	info := new(CandidateInfo)
+	if enc == nil {
+		return info, nil
+	}
+	if err := rlp.DecodeBytes(enc, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// UpdateCandidateInfo replaces the description of the candidate. An empty
+// description removes it.
+func (d *DposContext) UpdateCandidateInfo(addr common.Address, info *CandidateInfo) error {
+	if info.IsEmpty() {
+		return d.candidateInfoTrie.TryDelete(addr.Bytes())
+	}
+	enc, err := rlp.EncodeToBytes(info)
+	if err != nil {
+		return err
+	}
+	return d.candidateInfoTrie.TryUpdate(addr.Bytes(), enc)
+}
@@ -1,10 +1,13 @@
 package types
 
 import (
+	"encoding/binary"
+	"math/big"
 	"testing"
 
 	"github.com/haxicode/go-ethereum/common"
 	"github.com/haxicode/go-ethereum/ethdb"
+	"github.com/haxicode/go-ethereum/rlp"
 	"github.com/haxicode/go-ethereum/trie"
 	"github.com/stretchr/testify/assert"
 )
@@ -70,7 +73,7 @@ func TestDposContextKickoutCandidate(t *testing.T) {
 	}
 
 	kickIdx := 1
-	assert.Nil(t, dposContext.KickoutCandidate(candidates[kickIdx]))
+	assert.Nil(t, dposContext.KickoutCandidate(candidates[kickIdx], 0))
 	candidateMap := map[common.Address]bool{}
 	candidateIter := trie.NewIterator(dposContext.candidateTrie.NodeIterator(nil))
 	for candidateIter.Next() {
@@ -181,3 +184,592 @@ func TestDposContextValidators(t *testing.T) {
 		assert.True(t, validatorMap[validator])
 	}
 }
+
+func TestDposContextMissedCounts(t *testing.T) {
+	validator := common.HexToAddress("0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e")
+	dposContext, err := NewDposContext(trie.NewDatabase(ethdb.NewMemDatabase()))
+	assert.Nil(t, err)
+
+	minted := make([]byte, 8)
+	binary.BigEndian.PutUint64(minted, 3)
+	assert.Nil(t, dposContext.MintCntTrie().TryUpdate(mintCntKey(2, validator), minted))
+	assert.Nil(t, dposContext.AddMissedCount(2, validator, 1))
+	assert.Nil(t, dposContext.AddMissedCount(2, validator, 2))
+	assert.Nil(t, dposContext.AddMissedCount(3, validator, 5))
+
+	assert.Equal(t, uint64(3), dposContext.MintCount(2, validator))
+	assert.Equal(t, uint64(3), dposContext.MissedCount(2, validator))
+	assert.Equal(t, uint64(0), dposContext.MintCount(3, validator))
+
+	minters, err := dposContext.GetMintCounts(2)
+	assert.Nil(t, err)
+	assert.Equal(t, map[common.Address]uint64{validator: 3}, minters)
+	missed, err := dposContext.GetMissedCounts(3)
+	assert.Nil(t, err)
+	assert.Equal(t, map[common.Address]uint64{validator: 5}, missed)
+}
+
+func TestDposContextPruneMintCounts(t *testing.T) {
+	validator := common.HexToAddress("0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e")
+	dposContext, err := NewDposContext(trie.NewDatabase(ethdb.NewMemDatabase()))
+	assert.Nil(t, err)
+	for epoch := int64(1); epoch <= 4; epoch++ {
+		assert.Nil(t, dposContext.addCount(mintCntKey(epoch, validator), 1))
+		assert.Nil(t, dposContext.AddMissedCount(epoch, validator, 1))
+	}
+
+	assert.Nil(t, dposContext.PruneMintCounts(3))
+	for epoch := int64(1); epoch <= 4; epoch++ {
+		kept := epoch >= 3
+		assert.Equal(t, kept, dposContext.MintCount(epoch, validator) == 1)
+		assert.Equal(t, kept, dposContext.MissedCount(epoch, validator) == 1)
+	}
+	// Pruning is a no-op once the older epochs are gone
+	root := dposContext.MintCntTrie().Hash()
+	assert.Nil(t, dposContext.PruneMintCounts(3))
+	assert.Equal(t, root, dposContext.MintCntTrie().Hash())
+}
+
+func TestDposContextCommit(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	trieDB := trie.NewDatabase(db)
+	dposContext, err := NewDposContext(trieDB)
+	assert.Nil(t, err)
+	assert.Nil(t, dposContext.BecomeCandidate(common.HexToAddress("0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e")))
+	proto, err := dposContext.Commit()
+	assert.Nil(t, err)
+
+	// The tries are held in the trie database until it is flushed
+	_, err = NewDposContextFromProto(trieDB, proto)
+	assert.Nil(t, err)
+	_, err = NewDposContextFromProto(trie.NewDatabase(db), proto)
+	assert.NotNil(t, err)
+
+	for _, root := range proto.Roots() {
+		assert.Nil(t, trieDB.Commit(root, false))
+	}
+	_, err = NewDposContextFromProto(trie.NewDatabase(db), proto)
+	assert.Nil(t, err)
+}
+
+func TestDposContextRandao(t *testing.T) {
+	validator := common.HexToAddress("0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e")
+	db := trie.NewDatabase(ethdb.NewMemDatabase())
+	dposContext, err := NewDposContext(db)
+	assert.Nil(t, err)
+
+	mix := common.HexToHash("0x01")
+	commitment := common.HexToHash("0x02")
+	assert.Nil(t, dposContext.SetRandaoMix(mix))
+	assert.Nil(t, dposContext.SetRandaoCommitment(validator, commitment, 7))
+	assert.Nil(t, dposContext.AddUnrevealedCount(2, validator, 1))
+	assert.Nil(t, dposContext.AddMissedCount(2, validator, 4))
+
+	// The randao state survives a commit and reload
+	proto, err := dposContext.Commit()
+	assert.Nil(t, err)
+	dposContext, err = NewDposContextFromProto(db, proto)
+	assert.Nil(t, err)
+	assert.Equal(t, mix, dposContext.RandaoMix())
+	hash, number := dposContext.RandaoCommitment(validator)
+	assert.Equal(t, commitment, hash)
+	assert.Equal(t, uint64(7), number)
+
+	// Unrevealed and missed counters don't mix up
+	assert.Equal(t, uint64(1), dposContext.UnrevealedCount(2, validator))
+	unrevealed, err := dposContext.GetUnrevealedCounts(2)
+	assert.Nil(t, err)
+	assert.Equal(t, map[common.Address]uint64{validator: 1}, unrevealed)
+	missed, err := dposContext.GetMissedCounts(2)
+	assert.Nil(t, err)
+	assert.Equal(t, map[common.Address]uint64{validator: 4}, missed)
+
+	// Zero commitments clear the validator's commitment
+	assert.Nil(t, dposContext.SetRandaoCommitment(validator, common.Hash{}, 8))
+	hash, number = dposContext.RandaoCommitment(validator)
+	assert.Equal(t, common.Hash{}, hash)
+	assert.Equal(t, uint64(0), number)
+}
+
+func TestDposContextProtoRLP(t *testing.T) {
+	dposContext, err := NewDposContext(trie.NewDatabase(ethdb.NewMemDatabase()))
+	assert.Nil(t, err)
+
+	countRoots := func(proto *DposContextProto) int {
+		enc, err := rlp.EncodeToBytes(proto)
+		assert.Nil(t, err)
+		content, _, err := rlp.SplitList(enc)
+		assert.Nil(t, err)
+		count, err := rlp.CountValues(content)
+		assert.Nil(t, err)
+		decoded := new(DposContextProto)
+		assert.Nil(t, rlp.DecodeBytes(enc, decoded))
+		assert.Equal(t, proto, decoded)
+		return count
+	}
+	// Without stake, randao, governance and candidate info state their roots
+	// are left out, like before these tries existed
+	assert.Equal(t, 5, countRoots(dposContext.ToProto()))
+
+	// Without randao state only the randao root is left out, like before the beacon
+	assert.Nil(t, dposContext.LockCandidateDeposit(common.HexToAddress("0x01"), big.NewInt(1)))
+	assert.Equal(t, 8, countRoots(dposContext.ToProto()))
+
+	// With randao state every root is encoded
+	assert.Nil(t, dposContext.SetRandaoMix(common.HexToHash("0x01")))
+	proto := dposContext.ToProto()
+	assert.Equal(t, 9, countRoots(proto))
+
+	// Empty roots are only valid left out
+	roots := []common.Hash{proto.EpochHash, proto.DelegateHash, proto.CandidateHash, proto.VoteHash, proto.MintCntHash, proto.StakeHash, EmptyRootHash, proto.GovernanceHash, proto.CandidateInfoHash}
+	enc, err := rlp.EncodeToBytes(roots)
+	assert.Nil(t, err)
+	assert.NotNil(t, rlp.DecodeBytes(enc, new(DposContextProto)))
+
+	roots = []common.Hash{proto.EpochHash, proto.DelegateHash, proto.CandidateHash, proto.VoteHash, proto.MintCntHash, EmptyRootHash, EmptyRootHash, EmptyRootHash}
+	enc, err = rlp.EncodeToBytes(roots)
+	assert.Nil(t, err)
+	assert.NotNil(t, rlp.DecodeBytes(enc, new(DposContextProto)))
+}
+
+// Tests that headers of chains created before the stake, randao, governance and
+// candidate info tries were added to the DPoS state keep their hash and the
+// DPoS context root they are signed with.
+func TestDposContextProtoLegacyHeader(t *testing.T) {
+	header := &Header{
+		ParentHash:  common.HexToHash("0x01"),
+		UncleHash:   EmptyUncleHash,
+		Validator:   common.HexToAddress("0x02"),
+		Coinbase:    common.HexToAddress("0x03"),
+		Root:        common.HexToHash("0x04"),
+		TxHash:      EmptyRootHash,
+		ReceiptHash: EmptyRootHash,
+		DposContext: &DposContextProto{
+			EpochHash:         common.HexToHash("0x05"),
+			DelegateHash:      common.HexToHash("0x06"),
+			CandidateHash:     common.HexToHash("0x07"),
+			VoteHash:          common.HexToHash("0x08"),
+			MintCntHash:       common.HexToHash("0x09"),
+			StakeHash:         EmptyRootHash,
+			RandaoHash:        EmptyRootHash,
+			GovernanceHash:    EmptyRootHash,
+			CandidateInfoHash: EmptyRootHash,
+		},
+		Difficulty:       big.NewInt(1),
+		Number:           big.NewInt(100),
+		GasLimit:         8000000,
+		Time:             big.NewInt(1500000000),
+		Extra:            make([]byte, 32+65),
+		MaxValidatorSize: 21,
+		BlockInterval:    10,
+	}
+	assert.Equal(t, common.HexToHash("0x9f693f6d7e0b1adc8857d92575fec189d1f65f905d4f0f0e46869fc7fcdf0fa8"), header.Hash())
+	assert.Equal(t, common.HexToHash("0xb62b5fe341e37cf32977ed4b1cb86186bd33c4468a7fc7aaf72a3c781c6b749b"), header.DposContext.Root())
+
+	enc, err := rlp.EncodeToBytes(header)
+	assert.Nil(t, err)
+	decoded := new(Header)
+	assert.Nil(t, rlp.DecodeBytes(enc, decoded))
+	assert.Equal(t, header.DposContext, decoded.DposContext)
+	assert.Equal(t, header.Hash(), decoded.Hash())
+}
+
+func TestDposContextValidatorsProof(t *testing.T) {
+	validators := []common.Address{
+		common.HexToAddress("0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e"),
+		common.HexToAddress("0xa60a3886b552ff9992cfcd208ec1152079e046c2"),
+	}
+	dposContext, err := NewDposContext(trie.NewDatabase(ethdb.NewMemDatabase()))
+	assert.Nil(t, err)
+	assert.Nil(t, dposContext.SetValidators(validators))
+	proto, err := dposContext.Commit()
+	assert.Nil(t, err)
+
+	proof := ethdb.NewMemDatabase()
+	assert.Nil(t, dposContext.EpochTrie().Prove(ValidatorsProofKey(), 0, proof))
+	value, _, err := trie.VerifyProof(proto.EpochHash, ValidatorsProofKey(), proof)
+	assert.Nil(t, err)
+	result, err := DecodeValidators(value)
+	assert.Nil(t, err)
+	assert.Equal(t, validators, result)
+
+	// the proof does not hold for another epoch root
+	_, _, err = trie.VerifyProof(common.Hash{1}, ValidatorsProofKey(), proof)
+	assert.NotNil(t, err)
+}
+
+func TestDposContextSigningKey(t *testing.T) {
+	validators := []common.Address{
+		common.HexToAddress("0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e"),
+		common.HexToAddress("0xa60a3886b552ff9992cfcd208ec1152079e046c2"),
+	}
+	signer := common.HexToAddress("0x4e080e49f62694554871e669aeb4ebe17c4a9670")
+	dposContext, err := NewDposContext(trie.NewDatabase(ethdb.NewMemDatabase()))
+	assert.Nil(t, err)
+	assert.Nil(t, dposContext.SetValidators(validators))
+	legacyRoot := dposContext.EpochTrie().Hash()
+
+	// validators sign with their own account by default
+	signers, err := dposContext.GetValidatorSigners()
+	assert.Nil(t, err)
+	assert.Equal(t, validators, signers)
+	owner, err := dposContext.SigningKeyOwner(signer)
+	assert.Nil(t, err)
+	assert.Equal(t, common.Address{}, owner)
+
+	// a registered key replaces the account of a validator right away
+	assert.Nil(t, dposContext.SetSigningKey(validators[1], signer, 10))
+	key, err := dposContext.SigningKey(validators[1])
+	assert.Nil(t, err)
+	assert.Equal(t, signer, key)
+	owner, err = dposContext.SigningKeyOwner(signer)
+	assert.Nil(t, err)
+	assert.Equal(t, validators[1], owner)
+	signers, err = dposContext.GetValidatorSigners()
+	assert.Nil(t, err)
+	assert.Equal(t, []common.Address{validators[0], signer}, signers)
+
+	// and is kept when the validator is elected again
+	assert.Nil(t, dposContext.SetValidators([]common.Address{validators[1], validators[0]}))
+	signers, err = dposContext.GetValidatorSigners()
+	assert.Nil(t, err)
+	assert.Equal(t, []common.Address{signer, validators[0]}, signers)
+
+	// rotating back to the account releases the key and the epoch trie entry
+	assert.Nil(t, dposContext.SetValidators(validators))
+	assert.Nil(t, dposContext.SetSigningKey(validators[1], validators[1], 20))
+	owner, err = dposContext.SigningKeyOwner(signer)
+	assert.Nil(t, err)
+	assert.Equal(t, common.Address{}, owner)
+	assert.Equal(t, legacyRoot, dposContext.EpochTrie().Hash())
+
+	// the keys of earlier slots are kept, up to the slot of the block changing them
+	for slot, want := range map[int64]common.Address{5: validators[1], 10: validators[1], 15: signer, 20: signer, 25: validators[1]} {
+		key, err := dposContext.SigningKeyAt(validators[1], slot)
+		assert.Nil(t, err)
+		assert.Equal(t, want, key, "slot %d", slot)
+	}
+}
+
+func TestDposContextStake(t *testing.T) {
+	candidate := common.HexToAddress("0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e")
+	delegator := common.HexToAddress("0xa60a3886b552ff9992cfcd208ec1152079e046c2")
+	other := common.HexToAddress("0x4e080e49f62694554871e669aeb4ebe17c4a9670")
+
+	db := ethdb.NewMemDatabase()
+	dposContext, err := NewDposContext(trie.NewDatabase(db))
+	assert.Nil(t, err)
+
+	// lock deposits for a candidate and two votes
+	assert.Nil(t, dposContext.BecomeCandidate(candidate))
+	assert.Nil(t, dposContext.LockCandidateDeposit(candidate, big.NewInt(100)))
+	assert.Nil(t, dposContext.Delegate(delegator, candidate))
+	assert.Nil(t, dposContext.LockDelegateDeposit(delegator, big.NewInt(10)))
+	assert.Nil(t, dposContext.LockDelegateDeposit(delegator, big.NewInt(5)))
+	assert.Nil(t, dposContext.Delegate(other, candidate))
+	assert.Nil(t, dposContext.LockDelegateDeposit(other, big.NewInt(7)))
+
+	deposit, err := dposContext.CandidateDeposit(candidate)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(100), deposit.Int64())
+	deposit, err = dposContext.DelegateDeposit(delegator)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(15), deposit.Int64())
+
+	// undelegating only unbonds the stake of that delegator
+	assert.Nil(t, dposContext.UnDelegate(other, candidate))
+	assert.Nil(t, dposContext.UnbondDelegateDeposit(other, 50))
+	deposit, err = dposContext.DelegateDeposit(other)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), deposit.Int64())
+
+	// kicking out the candidate unbonds its deposit and the stake voting for it
+	assert.Nil(t, dposContext.KickoutCandidate(candidate, 100))
+	deposit, err = dposContext.CandidateDeposit(candidate)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), deposit.Int64())
+	deposit, err = dposContext.DelegateDeposit(delegator)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), deposit.Int64())
+
+	// nothing is paid back before the release time
+	released, err := dposContext.ReleaseUnbonded(49)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(released))
+
+	released, err = dposContext.ReleaseUnbonded(50)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(released))
+	assert.Equal(t, other, released[0].Address)
+	assert.Equal(t, int64(7), released[0].Amount.Int64())
+
+	released, err = dposContext.ReleaseUnbonded(1000)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(released))
+	amounts := map[common.Address]int64{}
+	for _, entry := range released {
+		assert.Equal(t, int64(100), entry.ReleaseTime)
+		amounts[entry.Address] = entry.Amount.Int64()
+	}
+	assert.Equal(t, map[common.Address]int64{candidate: 100, delegator: 15}, amounts)
+
+	// the escrow is empty once everything has been released
+	iter := trie.NewIterator(dposContext.StakeTrie().NodeIterator(nil))
+	assert.False(t, iter.Next())
+}
+
+func TestDposContextSlashCandidate(t *testing.T) {
+	candidate := common.HexToAddress("0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e")
+	delegator := common.HexToAddress("0xa60a3886b552ff9992cfcd208ec1152079e046c2")
+
+	db := ethdb.NewMemDatabase()
+	dposContext, err := NewDposContext(trie.NewDatabase(db))
+	assert.Nil(t, err)
+
+	// slashing is only possible for candidates
+	_, err = dposContext.SlashCandidate(candidate, 10, 10, 100)
+	assert.NotNil(t, err)
+
+	assert.Nil(t, dposContext.BecomeCandidate(candidate))
+	assert.Nil(t, dposContext.LockCandidateDeposit(candidate, big.NewInt(1000)))
+	assert.Nil(t, dposContext.Delegate(delegator, candidate))
+	assert.Nil(t, dposContext.LockDelegateDeposit(delegator, big.NewInt(50)))
+
+	penalty, err := dposContext.SlashCandidate(candidate, 10, 10, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(100), penalty.Int64())
+
+	// the candidate is kicked out and the rest of the stake unbonds
+	candidateInTrie, err := dposContext.candidateTrie.TryGet(candidate.Bytes())
+	assert.Nil(t, err)
+	assert.Nil(t, candidateInTrie)
+	released, err := dposContext.ReleaseUnbonded(100)
+	assert.Nil(t, err)
+	amounts := map[common.Address]int64{}
+	for _, entry := range released {
+		amounts[entry.Address] = entry.Amount.Int64()
+	}
+	assert.Equal(t, map[common.Address]int64{candidate: 900, delegator: 50}, amounts)
+
+	// the same slot can't be punished twice, even after registering again
+	assert.Nil(t, dposContext.BecomeCandidate(candidate))
+	_, err = dposContext.SlashCandidate(candidate, 10, 10, 200)
+	assert.NotNil(t, err)
+	_, err = dposContext.SlashCandidate(candidate, 20, 10, 200)
+	assert.Nil(t, err)
+}
+
+func TestDposContextRewards(t *testing.T) {
+	validator := common.HexToAddress("0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e")
+	other := common.HexToAddress("0x4e080e49f62694554871e669aeb4ebe17c4a9670")
+	alice := common.HexToAddress("0xa60a3886b552ff9992cfcd208ec1152079e046c2")
+	bob := common.HexToAddress("0xb040353ec0f2c113d5639444f7253681aecda1f8")
+
+	db := ethdb.NewMemDatabase()
+	dposContext, err := NewDposContext(trie.NewDatabase(db))
+	assert.Nil(t, err)
+	assert.Nil(t, dposContext.BecomeCandidate(validator))
+	assert.Nil(t, dposContext.BecomeCandidate(other))
+
+	rewards := func(addr common.Address) (int64, int64) {
+		accrued, withdrawn, err := dposContext.Rewards(addr)
+		assert.Nil(t, err)
+		return accrued.Int64(), withdrawn.Int64()
+	}
+
+	// without commission or delegators the validator keeps everything
+	share, err := dposContext.DistributeReward(validator, big.NewInt(1000))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1000), share.Int64())
+	assert.Nil(t, dposContext.SetCommission(validator, 20))
+	share, err = dposContext.DistributeReward(validator, big.NewInt(1000))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1000), share.Int64())
+
+	// delegators share the rest in proportion to their stake
+	assert.Nil(t, dposContext.Delegate(alice, validator))
+	assert.Nil(t, dposContext.LockDelegateDeposit(alice, big.NewInt(30)))
+	assert.Nil(t, dposContext.LockDelegateDeposit(bob, big.NewInt(10)))
+	assert.Nil(t, dposContext.Delegate(bob, validator))
+	share, err = dposContext.DistributeReward(validator, big.NewInt(1000))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(200), share.Int64())
+	accrued, _ := rewards(alice)
+	assert.Equal(t, int64(600), accrued)
+	accrued, _ = rewards(bob)
+	assert.Equal(t, int64(200), accrued)
+
+	// topping up the stake only counts for later blocks
+	assert.Nil(t, dposContext.LockDelegateDeposit(bob, big.NewInt(10)))
+	_, err = dposContext.DistributeReward(validator, big.NewInt(1000))
+	assert.Nil(t, err)
+	accrued, _ = rewards(alice)
+	assert.Equal(t, int64(1080), accrued)
+	accrued, _ = rewards(bob)
+	assert.Equal(t, int64(520), accrued)
+
+	// moving the vote keeps the rewards earned so far, shares are rounded down
+	assert.Nil(t, dposContext.Delegate(bob, other))
+	_, err = dposContext.DistributeReward(validator, big.NewInt(1000))
+	assert.Nil(t, err)
+	accrued, _ = rewards(alice)
+	assert.Equal(t, int64(1879), accrued)
+	accrued, _ = rewards(bob)
+	assert.Equal(t, int64(520), accrued)
+
+	reward, err := dposContext.WithdrawReward(alice)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1879), reward.Int64())
+	accrued, withdrawn := rewards(alice)
+	assert.Equal(t, int64(0), accrued)
+	assert.Equal(t, int64(1879), withdrawn)
+
+	// undelegating settles the rewards and stops earning
+	assert.Nil(t, dposContext.UnDelegate(alice, validator))
+	assert.Nil(t, dposContext.UnbondDelegateDeposit(alice, 100))
+	_, err = dposContext.DistributeReward(validator, big.NewInt(1000))
+	assert.Nil(t, err)
+	share, err = dposContext.DistributeReward(validator, big.NewInt(1000))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1000), share.Int64())
+	accrued, withdrawn = rewards(alice)
+	assert.Equal(t, int64(0), accrued)
+	assert.Equal(t, int64(1879), withdrawn)
+
+	assert.NotNil(t, dposContext.SetCommission(validator, 101))
+}
+
+func TestDposContextDefaultCommission(t *testing.T) {
+	validator := common.HexToAddress("0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e")
+	alice := common.HexToAddress("0xa60a3886b552ff9992cfcd208ec1152079e046c2")
+
+	db := ethdb.NewMemDatabase()
+	dposContext, err := NewDposContext(trie.NewDatabase(db))
+	assert.Nil(t, err)
+	assert.Nil(t, dposContext.BecomeCandidate(validator))
+	assert.Nil(t, dposContext.Delegate(alice, validator))
+	assert.Nil(t, dposContext.LockDelegateDeposit(alice, big.NewInt(30)))
+
+	// a validator registered without a commission shares the default with its delegators
+	commission, err := dposContext.Commission(validator)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(DefaultCommission), commission)
+	share, err := dposContext.DistributeReward(validator, big.NewInt(1000))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(100), share.Int64())
+	accrued, _, err := dposContext.Rewards(alice)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(900), accrued.Int64())
+}
+
+func TestDposContextGovernance(t *testing.T) {
+	proposer := common.HexToAddress("0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e")
+	approver := common.HexToAddress("0xa60a3886b552ff9992cfcd208ec1152079e046c2")
+	db := trie.NewDatabase(ethdb.NewMemDatabase())
+	dposContext, err := NewDposContext(db)
+	assert.Nil(t, err)
+
+	// The proposer approves its own proposal
+	root := dposContext.Root()
+	assert.Nil(t, dposContext.ProposeParams(proposer, &DposParams{MaxValidatorSize: 7}))
+	assert.NotEqual(t, root, dposContext.Root())
+	assert.Nil(t, dposContext.ApproveParams(proposer, approver))
+	approvers, err := dposContext.ParamsApprovals(proposer)
+	assert.Nil(t, err)
+	assert.Equal(t, []common.Address{proposer, approver}, approvers)
+
+	// A new proposal replaces the previous one and its approvals
+	assert.Nil(t, dposContext.ProposeParams(proposer, &DposParams{BlockInterval: 5}))
+	proposal, err := dposContext.ParamsProposal(proposer)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), proposal.MaxValidatorSize)
+	assert.Equal(t, uint64(5), proposal.BlockInterval)
+	approvers, err = dposContext.ParamsApprovals(proposer)
+	assert.Nil(t, err)
+	assert.Equal(t, []common.Address{proposer}, approvers)
+
+	assert.Nil(t, dposContext.SetPendingParams(9, proposal))
+	assert.Nil(t, dposContext.SetPendingParams(3, &DposParams{KickoutThreshold: 30}))
+	assert.Nil(t, dposContext.DeleteParamsProposal(proposer))
+	assert.Nil(t, dposContext.SetActiveParams(&DposParams{BlockReward: big.NewInt(100)}))
+
+	// The governance state survives a commit and reload
+	proto, err := dposContext.Commit()
+	assert.Nil(t, err)
+	dposContext, err = NewDposContextFromProto(db, proto)
+	assert.Nil(t, err)
+	proposal, err = dposContext.ParamsProposal(proposer)
+	assert.Nil(t, err)
+	assert.Nil(t, proposal)
+	approvers, err = dposContext.ParamsApprovals(proposer)
+	assert.Nil(t, err)
+	assert.Empty(t, approvers)
+
+	epochs, err := dposContext.PendingEpochs()
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{3, 9}, epochs)
+	pending, err := dposContext.PendingParams(9)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(5), pending.BlockInterval)
+	pending, err = dposContext.PendingParams(4)
+	assert.Nil(t, err)
+	assert.Nil(t, pending)
+
+	active, err := dposContext.ActiveParams()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(100), active.BlockReward.Int64())
+	merged := active.Merge(&DposParams{MaxValidatorSize: 7, BlockReward: new(big.Int)})
+	assert.Equal(t, uint64(7), merged.MaxValidatorSize)
+	assert.Equal(t, int64(100), merged.BlockReward.Int64())
+
+	// Payloads have to change something within bounds
+	_, err = DecodeDposParams(common.FromHex("0xc58080808080"))
+	assert.NotNil(t, err)
+	enc, err := rlp.EncodeToBytes(&DposParams{KickoutThreshold: 101})
+	assert.Nil(t, err)
+	_, err = DecodeDposParams(enc)
+	assert.NotNil(t, err)
+}
+
+func TestDposContextCandidateInfo(t *testing.T) {
+	candidate := common.HexToAddress("0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e")
+	db := trie.NewDatabase(ethdb.NewMemDatabase())
+	dposContext, err := NewDposContext(db)
+	assert.Nil(t, err)
+
+	info, err := dposContext.CandidateInfo(candidate)
+	assert.Nil(t, err)
+	assert.True(t, info.IsEmpty())
+
+	root := dposContext.Root()
+	assert.Nil(t, dposContext.UpdateCandidateInfo(candidate, &CandidateInfo{Name: "node", Enode: testEnode}))
+	assert.NotEqual(t, root, dposContext.Root())
+
+	// The description survives a commit and reload
+	proto, err := dposContext.Commit()
+	assert.Nil(t, err)
+	dposContext, err = NewDposContextFromProto(db, proto)
+	assert.Nil(t, err)
+	info, err = dposContext.CandidateInfo(candidate)
+	assert.Nil(t, err)
+	assert.Equal(t, &CandidateInfo{Name: "node", Enode: testEnode}, info)
+
+	// An empty description removes it
+	assert.Nil(t, dposContext.UpdateCandidateInfo(candidate, new(CandidateInfo)))
+	assert.Equal(t, root, dposContext.Root())
+
+	// Withdrawn or kicked out candidates lose their description
+	assert.Nil(t, dposContext.BecomeCandidate(candidate))
+	assert.Nil(t, dposContext.UpdateCandidateInfo(candidate, &CandidateInfo{Name: "node"}))
+	assert.Nil(t, dposContext.KickoutCandidate(candidate, 0))
+	info, err = dposContext.CandidateInfo(candidate)
+	assert.Nil(t, err)
+	assert.True(t, info.IsEmpty())
+	assert.Equal(t, EmptyRootHash, dposContext.CandidateInfoTrie().Hash())
+
+	// Legacy registrations only carry the commission
+	reg, err := DecodeCandidateRegistration(common.FromHex("0xc10a"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(10), reg.Commission)
+	assert.True(t, reg.Info().IsEmpty())
+}
@@ -0,0 +1,202 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"fmt"
+
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/trie"
+)
+
+// DposTrieNames lists the tries of a DposContext, in the order of the roots
+// returned by DposContextProto.Roots.
+var DposTrieNames = []string{"epoch", "delegate", "candidate", "vote", "mintCnt", "stake", "randao", "governance", "candidateInfo"}
+
+// dposTriePrefixes are the key prefixes of the tries, in the order of DposTrieNames.
+var dposTriePrefixes = [][]byte{epochPrefix, delegatePrefix, candidatePrefix, votePrefix, mintCntPrefix, stakePrefix, randaoPrefix, governancePrefix, candidateInfoPrefix}
+
+// tries returns the tries of the context, in the order of DposTrieNames.
+func (d *DposContext) tries() []*trie.Trie {
+	return []*trie.Trie{d.epochTrie, d.delegateTrie, d.candidateTrie, d.voteTrie, d.mintCntTrie, d.stakeTrie, d.randaoTrie, d.governanceTrie, d.candidateInfoTrie}
+}
+
+// DposTrieDump is the content of a DPoS trie, hex keys without the trie prefix
+// mapped to hex values.
+type DposTrieDump struct {
+	Root    string            `json:"root"`
+	Entries map[string]string `json:"entries"`
+}
+
+// DposDump is the content of all the tries of a DposContext, by trie name.
+type DposDump struct {
+	Root  string                  `json:"root"`
+	Tries map[string]DposTrieDump `json:"tries"`
+}
+
+// RawDump returns the content of every trie of the context.
+func (d *DposContext) RawDump() (DposDump, error) {
+	dump := DposDump{
+		Root:  d.Root().Hex(),
+		Tries: make(map[string]DposTrieDump),
+	}
+	for i, t := range d.tries() {
+		trieDump := DposTrieDump{
+			Root:    t.Hash().Hex(),
+			Entries: make(map[string]string),
+		}
+		it := trie.NewIterator(t.NodeIterator(nil))
+		for it.Next() {
+			key := it.Key[len(dposTriePrefixes[i]):]
+			trieDump.Entries[common.ToHex(key)] = common.ToHex(it.Value)
+		}
+		if it.Err != nil {
+			return DposDump{}, fmt.Errorf("%s trie: %v", DposTrieNames[i], it.Err)
+		}
+		dump.Tries[DposTrieNames[i]] = trieDump
+	}
+	return dump, nil
+}
+
+// DposTrieDiff lists the entries that differ between two versions of a trie,
+// with their value in the first and in the second one ("" if absent).
+type DposTrieDiff struct {
+	From    string               `json:"from"`
+	To      string               `json:"to"`
+	Entries map[string][2]string `json:"entries"`
+}
+
+// DiffDposDumps returns the differences between two dumps, by the name of the
+// tries whose roots differ.
+func DiffDposDumps(from, to DposDump) map[string]DposTrieDiff {
+	diffs := make(map[string]DposTrieDiff)
+	for _, name := range DposTrieNames {
+		a, b := from.Tries[name], to.Tries[name]
+		if a.Root == b.Root {
+			continue
+		}
+		diff := DposTrieDiff{
+			From:    a.Root,
+			To:      b.Root,
+			Entries: make(map[string][2]string),
+		}
+		for key, value := range a.Entries {
+			if b.Entries[key] != value {
+				diff.Entries[key] = [2]string{value, b.Entries[key]}
+			}
+		}
+		for key, value := range b.Entries {
+			if _, ok := a.Entries[key]; !ok {
+				diff.Entries[key] = [2]string{"", value}
+			}
+		}
+		diffs[name] = diff
+	}
+	return diffs
+}
+
+// DposTrieCheck is the result of checking that a DPoS trie is complete in a
+// database.
+type DposTrieCheck struct {
+	Name  string
+	Root  common.Hash
+	Nodes int   // Number of nodes found
+	Err   error // First missing or unreadable node, nil if the trie is complete
+}
+
+// Check walks every trie referenced by the proto and reports whether all of
+// their nodes can be read from db.
+func (p *DposContextProto) Check(db *trie.Database) []DposTrieCheck {
+	checks := make([]DposTrieCheck, 0, len(DposTrieNames))
+	for i, root := range p.Roots() {
+		check := DposTrieCheck{Name: DposTrieNames[i], Root: root}
+		// The key prefix would make the iterator skip the nodes above it
+		t, err := trie.New(root, db)
+		if err != nil {
+			check.Err = err
+		} else {
+			it := t.NodeIterator(nil)
+			for it.Next(true) {
+				if it.Hash() != (common.Hash{}) {
+					check.Nodes++
+				}
+			}
+			check.Err = it.Error()
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// DposEntry is a key of a DPoS trie, trie prefix included, with its value.
+type DposEntry struct {
+	Key   []byte
+	Value []byte
+}
+
+// Entries returns the content of every trie of the context, in the order of
+// DposTrieNames. Along with the state, it is enough to rebuild the context of a
+// block with RestoreDposContext.
+func (d *DposContext) Entries() ([][]DposEntry, error) {
+	entries := make([][]DposEntry, len(DposTrieNames))
+	for i, t := range d.tries() {
+		it := trie.NewIterator(t.NodeIterator(nil))
+		for it.Next() {
+			entries[i] = append(entries[i], DposEntry{Key: it.Key, Value: it.Value})
+		}
+		if it.Err != nil {
+			return nil, fmt.Errorf("%s trie: %v", DposTrieNames[i], it.Err)
+		}
+	}
+	return entries, nil
+}
+
+// RestoreDposContext rebuilds the tries listed by Entries into the trie database
+// and returns their roots. Like DposContext.Commit, the tries are only held in
+// memory until the database is flushed to disk, which is left to the caller.
+func RestoreDposContext(db *trie.Database, entries [][]DposEntry) (*DposContextProto, error) {
+	if len(entries) != len(DposTrieNames) {
+		return nil, fmt.Errorf("invalid number of DPoS tries: have %d, want %d", len(entries), len(DposTrieNames))
+	}
+	roots := make([]common.Hash, len(entries))
+	for i, trieEntries := range entries {
+		// The keys carry the trie prefix already, which a plain trie keeps as is
+		t, err := trie.New(common.Hash{}, db)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range trieEntries {
+			if err := t.TryUpdate(entry.Key, entry.Value); err != nil {
+				return nil, fmt.Errorf("%s trie: %v", DposTrieNames[i], err)
+			}
+		}
+		if roots[i], err = t.Commit(nil); err != nil {
+			return nil, fmt.Errorf("%s trie: %v", DposTrieNames[i], err)
+		}
+	}
+	return &DposContextProto{
+		EpochHash:         roots[0],
+		DelegateHash:      roots[1],
+		CandidateHash:     roots[2],
+		VoteHash:          roots[3],
+		MintCntHash:       roots[4],
+		StakeHash:         roots[5],
+		RandaoHash:        roots[6],
+		GovernanceHash:    roots[7],
+		CandidateInfoHash: roots[8],
+	}, nil
+}
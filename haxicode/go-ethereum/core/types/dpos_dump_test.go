@@ -0,0 +1,95 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/ethdb"
+	"github.com/haxicode/go-ethereum/trie"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDposContextDumpAndDiff(t *testing.T) {
+	candidate := common.HexToAddress("0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e")
+	dposContext, err := NewDposContext(trie.NewDatabase(ethdb.NewMemDatabase()))
+	assert.Nil(t, err)
+	assert.Nil(t, dposContext.BecomeCandidate(candidate))
+	from, err := dposContext.RawDump()
+	assert.Nil(t, err)
+	assert.Equal(t, len(DposTrieNames), len(from.Tries))
+	assert.Equal(t, map[string]string{common.ToHex(candidate[:]): common.ToHex(candidate[:])}, from.Tries["candidate"].Entries)
+
+	assert.Nil(t, dposContext.AddMissedCount(1, candidate, 2))
+	assert.Nil(t, dposContext.SetValidators([]common.Address{candidate}))
+	to, err := dposContext.RawDump()
+	assert.Nil(t, err)
+
+	diffs := DiffDposDumps(from, to)
+	assert.Equal(t, 2, len(diffs))
+	assert.Contains(t, diffs, "epoch")
+	missedKey := append(mintCntKey(1, candidate), missedCntSuffix...)
+	assert.Equal(t, map[string][2]string{common.ToHex(missedKey): {"", "0x0000000000000002"}}, diffs["mintCnt"].Entries)
+	assert.Equal(t, from.Tries["mintCnt"].Root, diffs["mintCnt"].From)
+	assert.Equal(t, [2]string{"0x0000000000000002", ""}, DiffDposDumps(to, from)["mintCnt"].Entries[common.ToHex(missedKey)])
+	assert.Empty(t, DiffDposDumps(to, to))
+}
+
+func TestDposContextProtoCheck(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	trieDB := trie.NewDatabase(db)
+	dposContext, err := NewDposContext(trieDB)
+	assert.Nil(t, err)
+	assert.Nil(t, dposContext.BecomeCandidate(common.HexToAddress("0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e")))
+	assert.Nil(t, dposContext.SetValidators([]common.Address{common.HexToAddress("0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e")}))
+	proto, err := dposContext.Commit()
+	assert.Nil(t, err)
+
+	// Nothing but the tries held in memory is on disk yet
+	for _, check := range proto.Check(trie.NewDatabase(db)) {
+		if check.Root == EmptyRootHash {
+			assert.Nil(t, check.Err, check.Name)
+		} else {
+			assert.NotNil(t, check.Err, check.Name)
+		}
+	}
+	for _, root := range proto.Roots() {
+		assert.Nil(t, trieDB.Commit(root, false))
+	}
+	for _, check := range proto.Check(trie.NewDatabase(db)) {
+		assert.Nil(t, check.Err, check.Name)
+		if check.Name == "candidate" || check.Name == "epoch" {
+			assert.NotZero(t, check.Nodes, check.Name)
+		}
+	}
+}
+
+func TestDposContextEntriesRestore(t *testing.T) {
+	candidate := common.HexToAddress("0x44d1ce0b7cb3588bca96151fe1bc05af38f91b6e")
+	dposContext, err := NewDposContext(trie.NewDatabase(ethdb.NewMemDatabase()))
+	assert.Nil(t, err)
+	assert.Nil(t, dposContext.BecomeCandidate(candidate))
+	assert.Nil(t, dposContext.Delegate(candidate, candidate))
+	assert.Nil(t, dposContext.SetValidators([]common.Address{candidate}))
+	proto, err := dposContext.Commit()
+	assert.Nil(t, err)
+	entries, err := dposContext.Entries()
+	assert.Nil(t, err)
+	assert.Equal(t, len(DposTrieNames), len(entries))
+
+	db := ethdb.NewMemDatabase()
+	trieDB := trie.NewDatabase(db)
+	restored, err := RestoreDposContext(trieDB, entries)
+	assert.Nil(t, err)
+	assert.Equal(t, proto.Roots(), restored.Roots())
+	for _, root := range restored.Roots() {
+		assert.Nil(t, trieDB.Commit(root, false))
+	}
+	restoredContext, err := NewDposContextFromProto(trie.NewDatabase(db), restored)
+	assert.Nil(t, err)
+	validators, err := restoredContext.GetValidators()
+	assert.Nil(t, err)
+	assert.Equal(t, []common.Address{candidate}, validators)
+
+	_, err = RestoreDposContext(trieDB, entries[1:])
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,48 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"errors"
+
+	"github.com/haxicode/go-ethereum/rlp"
+)
+
+var (
+	ErrInvalidEvidence = errors.New("invalid double sign evidence")
+)
+
+// DoubleSignEvidence is the payload of an Evidence transaction: two different
+// headers sealed by the same validator for the same slot.
+type DoubleSignEvidence struct {
+	First  *Header
+	Second *Header
+}
+
+// DecodeDoubleSignEvidence decodes the payload of an Evidence transaction. Only
+// the shape of the evidence is checked, the signatures are verified by the
+// consensus engine.
+func DecodeDoubleSignEvidence(data []byte) (*DoubleSignEvidence, error) {
+	evidence := new(DoubleSignEvidence)
+	if err := rlp.DecodeBytes(data, evidence); err != nil {
+		return nil, err
+	}
+	if evidence.First == nil || evidence.Second == nil {
+		return nil, ErrInvalidEvidence
+	}
+	return evidence, nil
+}
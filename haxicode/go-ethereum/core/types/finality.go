@@ -0,0 +1,63 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"github.com/haxicode/go-ethereum/common"
+)
+
+// preCommitDomain separates the hashes signed by pre-commits from any other
+// hash a validator signs.
+var preCommitDomain = []byte("dpos-precommit")
+
+// PreCommit is the signed vote of a DPoS validator to finalize a block.
+type PreCommit struct {
+	Number    uint64
+	Hash      common.Hash
+	Signature []byte
+}
+
+// SigHash returns the hash signed by the validator casting the vote.
+func (p *PreCommit) SigHash() common.Hash {
+	return preCommitSigHash(p.Number, p.Hash)
+}
+
+// ID returns the hash identifying the vote, signature included.
+func (p *PreCommit) ID() common.Hash {
+	return rlpHash(p)
+}
+
+func preCommitSigHash(number uint64, hash common.Hash) common.Hash {
+	return rlpHash([]interface{}{preCommitDomain, number, hash})
+}
+
+// QuorumCert is the finality certificate of a block: the pre-commits of a
+// quorum of the validators of the block.
+type QuorumCert struct {
+	Number     uint64
+	Hash       common.Hash
+	Signatures [][]byte
+}
+
+// PreCommits returns the votes gathered in the certificate.
+func (c *QuorumCert) PreCommits() []*PreCommit {
+	votes := make([]*PreCommit, len(c.Signatures))
+	for i, sig := range c.Signatures {
+		votes[i] = &PreCommit{Number: c.Number, Hash: c.Hash, Signature: sig}
+	}
+	return votes
+}
@@ -55,6 +55,7 @@ type Receipt struct {
 	TxHash          common.Hash    `json:"transactionHash" gencodec:"required"`
 	ContractAddress common.Address `json:"contractAddress"`
 	GasUsed         uint64         `json:"gasUsed" gencodec:"required"`
+	Reason          string         `json:"reason,omitempty"`
 }
 
 type receiptMarshaling struct {
@@ -80,6 +81,7 @@ type receiptStorageRLP struct {
 	ContractAddress   common.Address
 	Logs              []*LogForStorage
 	GasUsed           uint64
+	Reason            []string `rlp:"tail"` // At most one, absent in receipts stored before it was added
 }
 
 // NewReceipt creates a barebone transaction receipt, copying the init fields.
@@ -168,6 +170,9 @@ func (r *ReceiptForStorage) EncodeRLP(w io.Writer) error {
 	for i, log := range r.Logs {
 		enc.Logs[i] = (*LogForStorage)(log)
 	}
+	if r.Reason != "" {
+		enc.Reason = []string{r.Reason}
+	}
 	return rlp.Encode(w, enc)
 }
 
@@ -189,6 +194,9 @@ func (r *ReceiptForStorage) DecodeRLP(s *rlp.Stream) error {
 	}
 	// Assign the implementation fields
 	r.TxHash, r.ContractAddress, r.GasUsed = dec.TxHash, dec.ContractAddress, dec.GasUsed
+	if len(dec.Reason) > 0 {
+		r.Reason = dec.Reason[0]
+	}
 	return nil
 }
 
@@ -0,0 +1,39 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/rlp"
+)
+
+func TestReceiptStorageReason(t *testing.T) {
+	receipt := NewReceipt(nil, true, 42)
+	receipt.TxHash = common.Hash{1}
+	receipt.GasUsed = 42
+	receipt.Reason = "not a registered candidate"
+
+	enc, err := rlp.EncodeToBytes((*ReceiptForStorage)(receipt))
+	if err != nil {
+		t.Fatalf("failed to encode receipt: %v", err)
+	}
+	var dec ReceiptForStorage
+	if err := rlp.DecodeBytes(enc, &dec); err != nil {
+		t.Fatalf("failed to decode receipt: %v", err)
+	}
+	if dec.Reason != receipt.Reason || dec.Status != ReceiptStatusFailed || dec.TxHash != receipt.TxHash {
+		t.Errorf("receipt mismatch: have %+v, want %+v", dec, *receipt)
+	}
+	// Receipts stored without a reason decode as before
+	legacy, err := rlp.EncodeToBytes([]interface{}{receiptStatusSuccessfulRLP, uint64(42), Bloom{}, common.Hash{1}, common.Address{}, []*LogForStorage{}, uint64(42)})
+	if err != nil {
+		t.Fatalf("failed to encode legacy receipt: %v", err)
+	}
+	dec = ReceiptForStorage{}
+	if err := rlp.DecodeBytes(legacy, &dec); err != nil {
+		t.Fatalf("failed to decode legacy receipt: %v", err)
+	}
+	if dec.Reason != "" || dec.Status != ReceiptStatusSuccessful || dec.GasUsed != 42 {
+		t.Errorf("legacy receipt mismatch: have %+v", dec)
+	}
+}
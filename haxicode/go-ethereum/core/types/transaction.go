@@ -21,11 +21,13 @@ import (
 	"errors"
 	"io"
 	"math/big"
+	"strings"
 	"sync/atomic"
 
 	"github.com/haxicode/go-ethereum/common"
 	"github.com/haxicode/go-ethereum/common/hexutil"
 	"github.com/haxicode/go-ethereum/crypto"
+	"github.com/haxicode/go-ethereum/p2p/discover"
 	"github.com/haxicode/go-ethereum/rlp"
 )
 
@@ -36,11 +38,33 @@ type TxType uint8
 
 //新增交易类型
 const (
-	Binary         TxType = iota //之前的转账或者合约调用交易
-	RegCandidate                 //注册成为候选人
-	UnregCandidate               //注销成为候选人
-	Delegate                     //用户为候选人投票
-	UnDelegate                   //撤销投票（授权proxy）
+	Binary          TxType = iota //之前的转账或者合约调用交易
+	RegCandidate                  //注册成为候选人
+	UnregCandidate                //注销成为候选人
+	Delegate                      //用户为候选人投票
+	UnDelegate                    //撤销投票（授权proxy）
+	Evidence                      // Proof of a validator double signing a slot
+	WithdrawReward                // Payout of the block rewards accrued by a delegator
+	RotateSigner                  // Switch of the key a candidate seals its blocks with
+	ProposeParams                 // Validator proposal to change the DPoS parameters
+	ApproveParams                 // Validator approval of the proposal of another validator
+	UpdateCandidate               // Change of the commission and description of a candidate
+)
+
+// NeedsRecipient returns whether transactions of the type must have a recipient,
+// the candidate they vote for or approve, or the new signing key. The other DPoS
+// types act on their sender, binary ones without a recipient create a contract.
+func (t TxType) NeedsRecipient() bool {
+	switch t {
+	case Delegate, UnDelegate, RotateSigner, ApproveParams:
+		return true
+	}
+	return false
+}
+
+const (
+	maxCandidateNameLength    = 64  // Maximum length in bytes of a candidate name
+	maxCandidateWebsiteLength = 256 // Maximum length in bytes of a candidate website
 )
 
 var (
@@ -132,22 +156,159 @@ func (tx *Transaction) ChainId() *big.Int {
 	return deriveChainId(tx.data.V)
 }
 
-// Valid the transaction when the type isn't the binary
+// Valid the transaction when the type isn't the binary. The value of RegCandidate
+// and Delegate transactions is the stake deposit to lock, the payload of Evidence
+// transactions the RLP encoded DoubleSignEvidence and the optional payload of
+// RegCandidate transactions, and the payload of UpdateCandidate transactions, the
+// RLP encoded CandidateRegistration. The recipient of RotateSigner and,
+// optionally, RegCandidate transactions is the key the candidate seals its
+// blocks with. The payload of ProposeParams transactions is
+// the RLP encoded DposParams and the recipient of ApproveParams transactions the
+// validator whose proposal is approved.
 func (tx *Transaction) Validate() error {
 	if tx.Type() != Binary {
-		if tx.Value().Uint64() != 0 {
+		if tx.Value().Sign() != 0 && tx.Type() != RegCandidate && tx.Type() != Delegate {
 			return errors.New("transaction value should be 0")
 		}
-		if tx.To() == nil && tx.Type() != RegCandidate && tx.Type() != UnregCandidate {
+		if tx.To() == nil && tx.Type().NeedsRecipient() {
 			return errors.New("receipient was required")
 		}
-		if tx.Data() != nil {
+		switch {
+		case tx.Type() == Evidence:
+			if _, err := DecodeDoubleSignEvidence(tx.Data()); err != nil {
+				return err
+			}
+		case tx.Type() == RegCandidate && len(tx.Data()) > 0, tx.Type() == UpdateCandidate:
+			if _, err := DecodeCandidateRegistration(tx.Data()); err != nil {
+				return err
+			}
+		case tx.Type() == ProposeParams:
+			if _, err := DecodeDposParams(tx.Data()); err != nil {
+				return err
+			}
+		case len(tx.Data()) > 0:
 			return errors.New("payload should be empty")
 		}
 	}
 	return nil
 }
 
+// CandidateRegistration is the optional payload of a RegCandidate transaction
+// and the payload of an UpdateCandidate transaction.
+type CandidateRegistration struct {
+	Commission uint64 // Percentage of the block rewards kept by the validator
+	Name       string // Name the candidate is known by
+	Website    string // Website describing the candidate
+	Enode      string // URL of the node of the candidate
+}
+
+// Info returns the description of the candidate in the registration.
+func (reg *CandidateRegistration) Info() *CandidateInfo {
+	return &CandidateInfo{Name: reg.Name, Website: reg.Website, Enode: reg.Enode}
+}
+
+// DecodeCandidateRegistration decodes the payload of a RegCandidate or
+// UpdateCandidate transaction. Payloads only carrying the commission, as
+// registrations did before candidates could describe themselves, are accepted.
+func DecodeCandidateRegistration(data []byte) (*CandidateRegistration, error) {
+	reg := new(CandidateRegistration)
+	if err := rlp.DecodeBytes(data, reg); err != nil {
+		var legacy struct{ Commission uint64 }
+		if rlp.DecodeBytes(data, &legacy) != nil {
+			return nil, err
+		}
+		reg.Commission = legacy.Commission
+	}
+	if reg.Commission > 100 {
+		return nil, errors.New("commission above 100 percent")
+	}
+	if err := reg.Info().Validate(); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+// CandidateInfo describes a candidate to the voters.
+type CandidateInfo struct {
+	Name    string `json:"name"`
+	Website string `json:"website"`
+	Enode   string `json:"enode"`
+}
+
+// IsEmpty returns whether the candidate gave no description.
+func (info *CandidateInfo) IsEmpty() bool {
+	return info.Name == "" && info.Website == "" && info.Enode == ""
+}
+
+// Validate checks the length of the name and website, and that the enode is
+// the URL of a node.
+func (info *CandidateInfo) Validate() error {
+	if len(info.Name) > maxCandidateNameLength {
+		return errors.New("candidate name too long")
+	}
+	if len(info.Website) > maxCandidateWebsiteLength {
+		return errors.New("candidate website too long")
+	}
+	if info.Enode != "" {
+		if !strings.HasPrefix(info.Enode, "enode://") {
+			return errors.New("candidate enode is not an enode URL")
+		}
+		if _, err := discover.ParseNode(info.Enode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DposParams is the payload of a ProposeParams transaction, and the record of
+// the DPoS parameters changed through governance. Zero fields keep the value in
+// effect.
+type DposParams struct {
+	MaxValidatorSize uint64   // Number of elected validators
+	BlockInterval    uint64   // Seconds between blocks
+	KickoutThreshold uint64   // Percentage of its slots a validator must seal not to be kicked out
+	BlockReward      *big.Int // Wei issued per block
+}
+
+// IsEmpty returns whether the params change nothing.
+func (p *DposParams) IsEmpty() bool {
+	return p.MaxValidatorSize == 0 && p.BlockInterval == 0 && p.KickoutThreshold == 0 &&
+		(p.BlockReward == nil || p.BlockReward.Sign() == 0)
+}
+
+// Merge returns the params with the non-zero fields of next overriding p.
+func (p *DposParams) Merge(next *DposParams) *DposParams {
+	merged := *p
+	if next.MaxValidatorSize != 0 {
+		merged.MaxValidatorSize = next.MaxValidatorSize
+	}
+	if next.BlockInterval != 0 {
+		merged.BlockInterval = next.BlockInterval
+	}
+	if next.KickoutThreshold != 0 {
+		merged.KickoutThreshold = next.KickoutThreshold
+	}
+	if next.BlockReward != nil && next.BlockReward.Sign() != 0 {
+		merged.BlockReward = new(big.Int).Set(next.BlockReward)
+	}
+	return &merged
+}
+
+// DecodeDposParams decodes the payload of a ProposeParams transaction.
+func DecodeDposParams(data []byte) (*DposParams, error) {
+	params := new(DposParams)
+	if err := rlp.DecodeBytes(data, params); err != nil {
+		return nil, err
+	}
+	if params.IsEmpty() {
+		return nil, errors.New("proposal changes no parameter")
+	}
+	if params.KickoutThreshold > 100 {
+		return nil, errors.New("kickout threshold above 100 percent")
+	}
+	return params, nil
+}
+
 // Protected returns whether the transaction is protected from replay protection.
 func (tx *Transaction) Protected() bool {
 	return isProtectedV(tx.data.V)
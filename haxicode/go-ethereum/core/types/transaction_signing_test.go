@@ -22,7 +22,6 @@ import (
 
 	"github.com/haxicode/go-ethereum/common"
 	"github.com/haxicode/go-ethereum/crypto"
-	"github.com/haxicode/go-ethereum/rlp"
 )
 
 func TestEIP155Signing(t *testing.T) {
@@ -185,21 +185,64 @@ func TestTransactionJSON(t *testing.T) {
 	}
 }
 
+// testEnode is the URL of a node candidates can describe themselves with.
+const testEnode = "enode://a979fb575495b8d6db44f750317d0f4622bf4c2aa3365d6af7c284339968eef29b69ad0dce72a4d8db5ebb4968de0e3bec910127f134779fbcb0cb6d3331163c@52.16.188.185:30303"
+
 //测试交易类型普通交易，注册候选人，注销候选人，
 func TestTransactionValidate(t *testing.T) {
+	evidence, err := rlp.EncodeToBytes(&DoubleSignEvidence{
+		First:  &Header{Number: common.Big1, Time: common.Big2, DposContext: &DposContextProto{}},
+		Second: &Header{Number: common.Big1, Time: common.Big3, DposContext: &DposContextProto{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registration, _ := rlp.EncodeToBytes(&CandidateRegistration{Commission: 10})
+	overCommission, _ := rlp.EncodeToBytes(&CandidateRegistration{Commission: 101})
+	legacyRegistration, _ := rlp.EncodeToBytes([]uint64{10})
+	description, _ := rlp.EncodeToBytes(&CandidateRegistration{Commission: 10, Name: "node", Website: "https://example.org", Enode: testEnode})
+	longName, _ := rlp.EncodeToBytes(&CandidateRegistration{Name: string(make([]byte, 65))})
+	badEnode, _ := rlp.EncodeToBytes(&CandidateRegistration{Enode: "http://52.16.188.185:30303"})
 	validTransactions := []*Transaction{
 		newTransaction(Binary, 0, nil, common.Big0, 0, common.Big2, []byte("abcdef")),
 		newTransaction(RegCandidate, 0, nil, common.Big0, 1, common.Big2, nil),
 		newTransaction(UnregCandidate, 0, &common.Address{1}, common.Big0, 2, common.Big2, nil),
 		newTransaction(UnDelegate, 0, &common.Address{1}, common.Big0, 3, common.Big2, nil),
+		// the value of candidate registrations and votes is the locked deposit
+		newTransaction(RegCandidate, 0, nil, common.Big1, 4, common.Big2, nil),
+		newTransaction(Delegate, 0, &common.Address{1}, common.Big1, 5, common.Big2, nil),
+		// the payload of evidence is the pair of conflicting headers
+		newTransaction(Evidence, 0, nil, common.Big0, 6, common.Big2, evidence),
+		// candidates may set their commission when registering
+		newTransaction(RegCandidate, 0, nil, common.Big1, 7, common.Big2, registration),
+		newTransaction(WithdrawReward, 0, nil, common.Big0, 8, common.Big2, nil),
+		// registrations may only carry the commission, or describe the candidate
+		newTransaction(RegCandidate, 0, nil, common.Big1, 9, common.Big2, legacyRegistration),
+		newTransaction(RegCandidate, 0, nil, common.Big1, 10, common.Big2, description),
+		newTransaction(UpdateCandidate, 0, nil, common.Big0, 11, common.Big2, description),
+		// transactions decoded from the network carry an empty payload instead of none
+		newTransaction(Delegate, 0, &common.Address{1}, common.Big1, 12, common.Big2, []byte{}),
 	}
 	invalidTransactions := []*Transaction{
-		// value = 0 is invalid when the type isn't binary
-		newTransaction(RegCandidate, 0, nil, common.Big1, 0, common.Big2, nil),
+		// value != 0 is invalid when the type doesn't lock a deposit
+		newTransaction(UnregCandidate, 0, &common.Address{1}, common.Big1, 0, common.Big2, nil),
+		newTransaction(UnDelegate, 0, &common.Address{1}, common.Big1, 0, common.Big2, nil),
 		// to = nil is invalid when the type isn't binary
 		newTransaction(Delegate, 0, nil, common.Big0, 1, common.Big2, nil),
-		// payload != nil is invalid when the type isn't binary
+		// a payload is invalid when the type doesn't carry one
 		newTransaction(UnDelegate, 0, &common.Address{1}, common.Big0, 2, common.Big2, []byte("abcddf")),
+		// evidence must carry two headers and no value
+		newTransaction(Evidence, 0, nil, common.Big0, 3, common.Big2, nil),
+		newTransaction(Evidence, 0, nil, common.Big0, 4, common.Big2, []byte("abcddf")),
+		newTransaction(Evidence, 0, nil, common.Big1, 5, common.Big2, evidence),
+		newTransaction(RegCandidate, 0, nil, common.Big1, 6, common.Big2, []byte("abcddf")),
+		newTransaction(RegCandidate, 0, nil, common.Big1, 7, common.Big2, overCommission),
+		newTransaction(WithdrawReward, 0, nil, common.Big1, 8, common.Big2, nil),
+		// updates carry a valid description and no value
+		newTransaction(UpdateCandidate, 0, nil, common.Big0, 9, common.Big2, nil),
+		newTransaction(UpdateCandidate, 0, nil, common.Big1, 10, common.Big2, description),
+		newTransaction(UpdateCandidate, 0, nil, common.Big0, 11, common.Big2, longName),
+		newTransaction(UpdateCandidate, 0, nil, common.Big0, 12, common.Big2, badEnode),
 	}
 	for _, tx := range validTransactions {
 		if err := tx.Validate(); err != nil {
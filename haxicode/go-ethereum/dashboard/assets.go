@@ -27881,7 +27881,9 @@ var _bundleJs = []byte((((((((((`!function(modules) {
                 commit: null
             },
             home: {},
-            chain: {},
+            chain: {
+                candidates: []
+            },
             txpool: {},
             network: {},
             system: {
@@ -27908,7 +27910,9 @@ var _bundleJs = []byte((((((((((`!function(modules) {
             commit: replacer
         },
         home: null,
-        chain: null,
+        chain: {
+            candidates: replacer
+        },
         txpool: null,
         network: null,
         system: {
@@ -32419,7 +32423,7 @@ var _bundleJs = []byte((((((((((`!function(modules) {
             return protoProps && defineProperties(Constructor.prototype, protoProps), staticProps && defineProperties(Constructor, staticProps), 
             Constructor;
         };
-    }(), _react = __webpack_require__(0), _react2 = _interopRequireDefault(_react), _withStyles = __webpack_require__(10), _withStyles2 = _interopRequireDefault(_withStyles), _common = __webpack_require__(81), _Logs = __webpack_require__(261), _Logs2 = _interopRequireDefault(_Logs), _Footer = __webpack_require__(551), _Footer2 = _interopRequireDefault(_Footer), styles = {
+    }(), _react = __webpack_require__(0), _react2 = _interopRequireDefault(_react), _withStyles = __webpack_require__(10), _withStyles2 = _interopRequireDefault(_withStyles), _common = __webpack_require__(81), _Chain = __webpack_require__(949), _Chain2 = _interopRequireDefault(_Chain), _Logs = __webpack_require__(261), _Logs2 = _interopRequireDefault(_Logs), _Footer = __webpack_require__(551), _Footer2 = _interopRequireDefault(_Footer), styles = {
         wrapper: {
             display: "flex",
             flexDirection: "column",
@@ -32460,8 +32464,14 @@ var _bundleJs = []byte((((((((((`!function(modules) {
             value: function() {
                 var _this2 = this, _props = this.props, classes = _props.classes, active = _props.active, content = _props.content, shouldUpdate = _props.shouldUpdate, children = null;
                 switch (active) {
-                  case _common.MENU.get("home").id:
                   case _common.MENU.get("chain").id:
+                    children = _react2.default.createElement(_Chain2.default, {
+                        content: content.chain,
+                        shouldUpdate: shouldUpdate
+                    });
+                    break;
+
+                  case _common.MENU.get("home").id:
                   case _common.MENU.get("txpool").id:
                   case _common.MENU.get("network").id:
                   case _common.MENU.get("system").id:
@@ -40616,6 +40626,108 @@ var _bundleJs = []byte((((((((((`!function(modules) {
         } ]), CustomTooltip;
     }(_react.Component));
     exports.default = CustomTooltip;
+}, function(module, exports, __webpack_require__) {
+    "use strict";
+    function _classCallCheck(instance, Constructor) {
+        if (!(instance instanceof Constructor)) throw new TypeError("Cannot call a class as a function");
+    }
+    function _possibleConstructorReturn(self, call) {
+        if (!self) throw new ReferenceError("this hasn't been initialised - super() hasn't been called");
+        return !call || "object" != typeof call && "function" != typeof call ? self : call;
+    }
+    function _inherits(subClass, superClass) {
+        if ("function" != typeof superClass && null !== superClass) throw new TypeError("Super expression must either be null or a function, not " + typeof superClass);
+        subClass.prototype = Object.create(superClass && superClass.prototype, {
+            constructor: {
+                value: subClass,
+                enumerable: !1,
+                writable: !0,
+                configurable: !0
+            }
+        }), superClass && (Object.setPrototypeOf ? Object.setPrototypeOf(subClass, superClass) : subClass.__proto__ = superClass);
+    }
+    Object.defineProperty(exports, "__esModule", {
+        value: !0
+    });
+    var _createClass = function() {
+        function defineProperties(target, props) {
+            for (var i = 0; i < props.length; i++) {
+                var descriptor = props[i];
+                descriptor.enumerable = descriptor.enumerable || !1, descriptor.configurable = !0, 
+                "value" in descriptor && (descriptor.writable = !0), Object.defineProperty(target, descriptor.key, descriptor);
+            }
+        }
+        return function(Constructor, protoProps, staticProps) {
+            return protoProps && defineProperties(Constructor.prototype, protoProps), staticProps && defineProperties(Constructor, staticProps), 
+            Constructor;
+        };
+    }(), _react = __webpack_require__(0), _react2 = function(obj) {
+        return obj && obj.__esModule ? obj : {
+            default: obj
+        };
+    }(_react), styles = {
+        table: {
+            width: "100%",
+            borderCollapse: "collapse"
+        },
+        cell: {
+            padding: "4px 8px",
+            textAlign: "left",
+            borderBottom: "1px solid rgba(255, 255, 255, 0.12)"
+        },
+        numeric: {
+            padding: "4px 8px",
+            textAlign: "right",
+            borderBottom: "1px solid rgba(255, 255, 255, 0.12)"
+        }
+    }, Chain = function(_Component) {
+        function Chain() {
+            return _classCallCheck(this, Chain), _possibleConstructorReturn(this, (Chain.__proto__ || Object.getPrototypeOf(Chain)).apply(this, arguments));
+        }
+        return _inherits(Chain, _Component), _createClass(Chain, [ {
+            key: "shouldComponentUpdate",
+            value: function(nextProps) {
+                return void 0 !== nextProps.shouldUpdate.chain;
+            }
+        }, {
+            key: "render",
+            value: function() {
+                var candidates = this.props.content.candidates;
+                return candidates.length < 1 ? _react2.default.createElement("div", null, "No candidates registered.") : _react2.default.createElement("table", {
+                    style: styles.table
+                }, _react2.default.createElement("thead", null, _react2.default.createElement("tr", null, _react2.default.createElement("th", {
+                    style: styles.cell
+                }, "Candidate"), _react2.default.createElement("th", {
+                    style: styles.cell
+                }, "Name"), _react2.default.createElement("th", {
+                    style: styles.cell
+                }, "Website"), _react2.default.createElement("th", {
+                    style: styles.cell
+                }, "Enode"), _react2.default.createElement("th", {
+                    style: styles.numeric
+                }, "Commission"), _react2.default.createElement("th", {
+                    style: styles.cell
+                }, "Validator"))), _react2.default.createElement("tbody", null, candidates.map(function(candidate) {
+                    return _react2.default.createElement("tr", {
+                        key: candidate.address
+                    }, _react2.default.createElement("td", {
+                        style: styles.cell
+                    }, candidate.address), _react2.default.createElement("td", {
+                        style: styles.cell
+                    }, candidate.name), _react2.default.createElement("td", {
+                        style: styles.cell
+                    }, candidate.website), _react2.default.createElement("td", {
+                        style: styles.cell
+                    }, candidate.enode), _react2.default.createElement("td", {
+                        style: styles.numeric
+                    }, candidate.commission, "%"), _react2.default.createElement("td", {
+                        style: styles.cell
+                    }, candidate.validator ? "Yes" : "No"));
+                })));
+            }
+        } ]), Chain;
+    }(_react.Component);
+    exports.default = Chain;
 } ]);`)))))))))))
 
 func bundleJsBytes() ([]byte, error) {
@@ -40629,7 +40741,7 @@ func bundleJs() (*asset, error) {
 	}
 
 	info := bindataFileInfo{name: "bundle.js", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
-	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xef, 0x26, 0x2b, 0x98, 0x2d, 0xce, 0x9a, 0x8f, 0x9a, 0x5e, 0x37, 0x64, 0x5c, 0x70, 0xa2, 0xeb, 0x44, 0xb1, 0x6d, 0x8b, 0x8e, 0x95, 0x34, 0x14, 0x7a, 0x79, 0x4f, 0x8, 0xc3, 0xb3, 0x5, 0x3}}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xa6, 0x4d, 0xe1, 0xe1, 0xf9, 0x11, 0xb0, 0xfa, 0xc6, 0x8a, 0x77, 0x8, 0x7e, 0xb2, 0x0, 0x61, 0x3c, 0x91, 0x11, 0x6d, 0xf, 0x14, 0xbd, 0x4f, 0x27, 0xd5, 0x11, 0x72, 0xf6, 0x32, 0x43, 0x56}}
 	return a, nil
 }
 
@@ -0,0 +1,86 @@
+// @flow
+
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+import React, {Component} from 'react';
+
+import type {Chain as ChainType} from '../types/content';
+
+// styles contains the constant styles of the component.
+const styles = {
+	table: {
+		width:          '100%',
+		borderCollapse: 'collapse',
+	},
+	cell: {
+		padding:      '4px 8px',
+		textAlign:    'left',
+		borderBottom: '1px solid rgba(255, 255, 255, 0.12)',
+	},
+	numeric: {
+		padding:      '4px 8px',
+		textAlign:    'right',
+		borderBottom: '1px solid rgba(255, 255, 255, 0.12)',
+	},
+};
+
+export type Props = {
+	content:      ChainType,
+	shouldUpdate: Object,
+};
+
+// Chain renders the DPoS candidates registered at the chain head.
+class Chain extends Component<Props> {
+	shouldComponentUpdate(nextProps) {
+		return typeof nextProps.shouldUpdate.chain !== 'undefined';
+	}
+
+	render() {
+		const {candidates} = this.props.content;
+		if (candidates.length < 1) {
+			return <div>No candidates registered.</div>;
+		}
+		return (
+			<table style={styles.table}>
+				<thead>
+					<tr>
+						<th style={styles.cell}>Candidate</th>
+						<th style={styles.cell}>Name</th>
+						<th style={styles.cell}>Website</th>
+						<th style={styles.cell}>Enode</th>
+						<th style={styles.numeric}>Commission</th>
+						<th style={styles.cell}>Validator</th>
+					</tr>
+				</thead>
+				<tbody>
+					{candidates.map(candidate => (
+						<tr key={candidate.address}>
+							<td style={styles.cell}>{candidate.address}</td>
+							<td style={styles.cell}>{candidate.name}</td>
+							<td style={styles.cell}>{candidate.website}</td>
+							<td style={styles.cell}>{candidate.enode}</td>
+							<td style={styles.numeric}>{candidate.commission}%</td>
+							<td style={styles.cell}>{candidate.validator ? 'Yes' : 'No'}</td>
+						</tr>
+					))}
+				</tbody>
+			</table>
+		);
+	}
+}
+
+export default Chain;
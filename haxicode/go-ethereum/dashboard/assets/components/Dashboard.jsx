@@ -86,7 +86,9 @@ const defaultContent: () => Content = () => ({
 		commit:  null,
 	},
 	home:    {},
-	chain:   {},
+	chain:   {
+		candidates: [],
+	},
 	txpool:  {},
 	network: {},
 	system:  {
@@ -117,7 +119,9 @@ const updaters = {
 		commit:  replacer,
 	},
 	home:    null,
-	chain:   null,
+	chain:   {
+		candidates: replacer,
+	},
 	txpool:  null,
 	network: null,
 	system:  {
@@ -21,6 +21,7 @@ import React, {Component} from 'react';
 import withStyles from 'material-ui/styles/withStyles';
 
 import {MENU} from '../common';
+import Chain from './Chain';
 import Logs from './Logs';
 import Footer from './Footer';
 import type {Content} from '../types/content';
@@ -88,8 +89,10 @@ class Main extends Component<Props> {
 
 		let children = null;
 		switch (active) {
-		case MENU.get('home').id:
 		case MENU.get('chain').id:
+			children = <Chain content={content.chain} shouldUpdate={shouldUpdate} />;
+			break;
+		case MENU.get('home').id:
 		case MENU.get('txpool').id:
 		case MENU.get('network').id:
 		case MENU.get('system').id:
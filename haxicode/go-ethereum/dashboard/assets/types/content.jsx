@@ -43,7 +43,16 @@ export type Home = {
 };
 
 export type Chain = {
-	/* TODO (kurkomisi) */
+	candidates: Array<Candidate>,
+};
+
+export type Candidate = {
+	address:    string,
+	name:       ?string,
+	website:    ?string,
+	enode:      ?string,
+	commission: number,
+	validator:  boolean,
 };
 
 export type TxPool = {
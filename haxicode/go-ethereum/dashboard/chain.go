@@ -0,0 +1,109 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dashboard
+
+import (
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/core"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/log"
+)
+
+// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
+const chainHeadChanSize = 10
+
+// collectChain sends the DPoS candidates of every new chain head to the
+// dashboards. Light nodes don't have the DPoS state, so nothing is collected.
+func (db *Dashboard) collectChain() {
+	defer db.wg.Done()
+
+	if db.eth == nil {
+		errc := <-db.quit
+		errc <- nil
+		return
+	}
+	heads := make(chan core.ChainHeadEvent, chainHeadChanSize)
+	sub := db.eth.BlockChain().SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	db.updateChain(db.eth.BlockChain().CurrentHeader())
+	for {
+		select {
+		case errc := <-db.quit:
+			errc <- nil
+			return
+		case head := <-heads:
+			db.updateChain(head.Block.Header())
+		}
+	}
+}
+
+// updateChain collects the candidates registered at the given header, records
+// them for the dashboards connecting later and sends them to the active ones.
+func (db *Dashboard) updateChain(header *types.Header) {
+	chain, err := db.chainMessage(header)
+	if err != nil {
+		log.Warn("Failed to collect candidates", "number", header.Number, "err", err)
+		return
+	}
+	db.lock.Lock()
+	db.history.Chain = chain
+	db.lock.Unlock()
+
+	db.sendToAll(&Message{Chain: chain})
+}
+
+// chainMessage assembles the candidates registered at the given header, with
+// their description and whether they validate the current epoch.
+func (db *Dashboard) chainMessage(header *types.Header) (*ChainMessage, error) {
+	dposContext, err := types.NewDposContextFromProto(db.eth.BlockChain().StateCache().TrieDB(), header.DposContext)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := dposContext.GetCandidates()
+	if err != nil {
+		return nil, err
+	}
+	validators, err := dposContext.GetValidators()
+	if err != nil {
+		return nil, err
+	}
+	elected := make(map[common.Address]bool, len(validators))
+	for _, validator := range validators {
+		elected[validator] = true
+	}
+	chain := &ChainMessage{Candidates: make([]*Candidate, len(candidates))}
+	for i, candidate := range candidates {
+		info, err := dposContext.CandidateInfo(candidate)
+		if err != nil {
+			return nil, err
+		}
+		commission, err := dposContext.Commission(candidate)
+		if err != nil {
+			return nil, err
+		}
+		chain.Candidates[i] = &Candidate{
+			Address:    candidate,
+			Name:       info.Name,
+			Website:    info.Website,
+			Enode:      info.Enode,
+			Commission: commission,
+			Validator:  elected[candidate],
+		}
+	}
+	return chain, nil
+}
@@ -35,6 +35,7 @@ import (
 	"io"
 
 	"github.com/elastic/gosigar"
+	"github.com/haxicode/go-ethereum/eth"
 	"github.com/haxicode/go-ethereum/log"
 	"github.com/haxicode/go-ethereum/metrics"
 	"github.com/haxicode/go-ethereum/p2p"
@@ -67,6 +68,7 @@ type Dashboard struct {
 	lock     sync.RWMutex // Lock protecting the dashboard's internals
 
 	logdir string
+	eth    *eth.Ethereum // Full Ethereum service to retrieve the DPoS candidates from, nil for light nodes
 
 	quit chan chan error // Channel used for graceful exit
 	wg   sync.WaitGroup
@@ -79,8 +81,9 @@ type client struct {
 	logger log.Logger      // Logger for the particular live websocket connection
 }
 
-// New creates a new dashboard instance with the given configuration.
-func New(config *Config, commit string, logdir string) *Dashboard {
+// New creates a new dashboard instance with the given configuration. The
+// candidates of the chain are only shown if ethServ is given.
+func New(config *Config, commit string, logdir string, ethServ *eth.Ethereum) *Dashboard {
 	now := time.Now()
 	versionMeta := ""
 	if len(params.VersionMeta) > 0 {
@@ -107,6 +110,7 @@ func New(config *Config, commit string, logdir string) *Dashboard {
 			},
 		},
 		logdir: logdir,
+		eth:    ethServ,
 	}
 }
 
@@ -132,9 +136,10 @@ func (db *Dashboard) APIs() []rpc.API { return nil }
 func (db *Dashboard) Start(server *p2p.Server) error {
 	log.Info("Starting dashboard")
 
-	db.wg.Add(2)
+	db.wg.Add(3)
 	go db.collectData()
 	go db.streamLogs()
+	go db.collectChain()
 
 	http.HandleFunc("/", db.webHandler)
 	http.Handle("/api", websocket.Handler(db.apiHandler))
@@ -160,7 +165,7 @@ func (db *Dashboard) Stop() error {
 	}
 	// Close the collectors.
 	errc := make(chan error, 1)
-	for i := 0; i < 2; i++ {
+	for i := 0; i < 3; i++ {
 		db.quit <- errc
 		if err := <-errc; err != nil {
 			errs = append(errs, err)
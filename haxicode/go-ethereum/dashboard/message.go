@@ -19,6 +19,8 @@ package dashboard
 import (
 	"encoding/json"
 	"time"
+
+	"github.com/haxicode/go-ethereum/common"
 )
 
 type Message struct {
@@ -48,7 +50,17 @@ type HomeMessage struct {
 }
 
 type ChainMessage struct {
-	/* TODO (kurkomisi) */
+	Candidates []*Candidate `json:"candidates,omitempty"`
+}
+
+// Candidate describes a DPoS candidate registered at the chain head.
+type Candidate struct {
+	Address    common.Address `json:"address"`
+	Name       string         `json:"name,omitempty"`
+	Website    string         `json:"website,omitempty"`
+	Enode      string         `json:"enode,omitempty"`
+	Commission uint64         `json:"commission"` // Percentage of the block rewards kept by the candidate
+	Validator  bool           `json:"validator"`  // Whether the candidate validates the current epoch
 }
 
 type TxPoolMessage struct {
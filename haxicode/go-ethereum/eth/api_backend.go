@@ -67,6 +67,9 @@ func (b *EthAPIBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNum
 	if blockNr == rpc.LatestBlockNumber {
 		return b.eth.blockchain.CurrentBlock().Header(), nil
 	}
+	if blockNr == rpc.FinalizedBlockNumber {
+		return b.eth.blockchain.FinalizedHeader(), nil
+	}
 	return b.eth.blockchain.GetHeaderByNumber(uint64(blockNr)), nil
 }
 
@@ -84,6 +87,12 @@ func (b *EthAPIBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumb
 	if blockNr == rpc.LatestBlockNumber {
 		return b.eth.blockchain.CurrentBlock(), nil
 	}
+	if blockNr == rpc.FinalizedBlockNumber {
+		if header := b.eth.blockchain.FinalizedHeader(); header != nil {
+			return b.eth.blockchain.GetBlock(header.Hash(), header.Number.Uint64()), nil
+		}
+		return nil, nil
+	}
 	return b.eth.blockchain.GetBlockByNumber(uint64(blockNr)), nil
 }
 
@@ -29,7 +29,9 @@ import (
 	"github.com/haxicode/go-ethereum/common"
 	"github.com/haxicode/go-ethereum/common/hexutil"
 	"github.com/haxicode/go-ethereum/consensus"
+	"github.com/haxicode/go-ethereum/consensus/clique"
 	"github.com/haxicode/go-ethereum/consensus/dpos"
+	"github.com/haxicode/go-ethereum/consensus/ethash"
 	"github.com/haxicode/go-ethereum/core"
 	"github.com/haxicode/go-ethereum/core/bloombits"
 	"github.com/haxicode/go-ethereum/core/rawdb"
@@ -43,6 +45,7 @@ import (
 	"github.com/haxicode/go-ethereum/internal/ethapi"
 	"github.com/haxicode/go-ethereum/log"
 	"github.com/haxicode/go-ethereum/miner"
+	"github.com/haxicode/go-ethereum/miner/failover"
 	"github.com/haxicode/go-ethereum/node"
 	"github.com/haxicode/go-ethereum/p2p"
 	"github.com/haxicode/go-ethereum/params"
@@ -91,6 +94,8 @@ type Ethereum struct {
 	networkID     uint64
 	netRPCService *ethapi.PublicNetAPI
 
+	evidenceSub event.Subscription // Double signing reported by the DPoS engine
+
 	lock sync.RWMutex // Protects the variadic fields (e.g. gas price and coinbase)
 }
 
@@ -125,7 +130,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		chainConfig:    chainConfig,
 		eventMux:       ctx.EventMux,
 		accountManager: ctx.AccountManager,
-		engine:         dpos.New(chainConfig.Dpos, chainDb),
+		engine:         CreateConsensusEngine(ctx, chainConfig, &config.Ethash, config.MinerNotify, chainDb),
 		shutdownChan:   make(chan bool),
 		networkID:      config.NetworkId,
 		gasPrice:       config.MinerGasPrice,
@@ -145,7 +150,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	}
 	var (
 		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
-		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout}
+		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout, DposSnapshots: config.DposSnapshots}
 	)
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, eth.chainConfig, eth.engine, vmConfig)
 	if err != nil {
@@ -167,9 +172,17 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.SyncMode, config.NetworkId, eth.eventMux, eth.txPool, eth.engine, eth.blockchain, chainDb); err != nil {
 		return nil, err
 	}
+	eth.protocolManager.downloader.SetCheckpoint(config.DposCheckpoint)
 
 	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.engine, config.MinerRecommit)
 	eth.miner.SetExtra(makeExtraData(config.MinerExtraData))
+	if config.MinerFailover != "" {
+		locker, err := failover.New(config.MinerFailover)
+		if err != nil {
+			return nil, err
+		}
+		eth.miner.SetFailover(failover.NewElector(locker, failover.DefaultTTL))
+	}
 
 	eth.APIBackend = &EthAPIBackend{eth, nil}
 	gpoParams := config.GPO
@@ -210,6 +223,40 @@ func CreateDB(ctx *node.ServiceContext, config *Config, name string) (ethdb.Data
 	return db, nil
 }
 
+// CreateConsensusEngine creates the consensus engine the chain configuration
+// asks for: DPoS, Clique, or Ethash in the mode of the given ethash config.
+func CreateConsensusEngine(ctx *node.ServiceContext, chainConfig *params.ChainConfig, config *ethash.Config, notify []string, db ethdb.Database) consensus.Engine {
+	if chainConfig.IsDpos() {
+		return dpos.New(chainConfig.Dpos, db)
+	}
+	if chainConfig.Clique != nil {
+		return clique.New(chainConfig.Clique, db)
+	}
+	// Otherwise assume proof-of-work
+	switch config.PowMode {
+	case ethash.ModeFake:
+		log.Warn("Ethash used in fake mode")
+		return ethash.NewFaker()
+	case ethash.ModeTest:
+		log.Warn("Ethash used in test mode")
+		return ethash.NewTester(nil)
+	case ethash.ModeShared:
+		log.Warn("Ethash used in shared mode")
+		return ethash.NewShared()
+	default:
+		engine := ethash.New(ethash.Config{
+			CacheDir:       ctx.ResolvePath(config.CacheDir),
+			CachesInMem:    config.CachesInMem,
+			CachesOnDisk:   config.CachesOnDisk,
+			DatasetDir:     config.DatasetDir,
+			DatasetsInMem:  config.DatasetsInMem,
+			DatasetsOnDisk: config.DatasetsOnDisk,
+		}, notify)
+		engine.SetThreads(-1) // Disable CPU mining
+		return engine
+	}
+}
+
 // APIs returns the collection of RPC services the ethereum package offers.
 // NOTE, some of these services probably need to be moved to somewhere else.
 func (s *Ethereum) APIs() []rpc.API {
@@ -321,24 +368,26 @@ func (self *Ethereum) SetCoinbase(coinbase common.Address) {
 }
 
 func (s *Ethereum) StartMining(local bool) error {
-	validator, err := s.Validator()
-	if err != nil {
-		log.Error("Cannot start mining without validator", "err", err)
-		return fmt.Errorf("validator missing: %v", err)
-	}
 	cb, err := s.Coinbase()
 	if err != nil {
 		log.Error("Cannot start mining without coinbase", "err", err)
 		return fmt.Errorf("coinbase missing: %v", err)
 	}
 
-	if dpos, ok := s.engine.(*dpos.Dpos); ok {
-		wallet, err := s.accountManager.Find(accounts.Account{Address: validator})
-		if wallet == nil || err != nil {
-			log.Error("Coinbase account unavailable locally", "err", err)
-			return fmt.Errorf("signer missing: %v", err)
+	// Engines sealing with a signature need the validator key unlocked
+	switch engine := s.engine.(type) {
+	case *dpos.Dpos:
+		validator, wallet, err := s.validatorWallet()
+		if err != nil {
+			return err
+		}
+		engine.Authorize(validator, wallet.SignHash)
+	case *clique.Clique:
+		validator, wallet, err := s.validatorWallet()
+		if err != nil {
+			return err
 		}
-		dpos.Authorize(validator, wallet.SignHash)
+		engine.Authorize(validator, wallet.SignHash)
 	}
 	if local {
 		// If local (CPU) mining is started, we can disable the transaction rejection
@@ -347,13 +396,27 @@ func (s *Ethereum) StartMining(local bool) error {
 		// will ensure that private networks work in single miner mode too.
 		atomic.StoreUint32(&s.protocolManager.acceptTxs, 1)
 	}
-	bi := s.chainConfig.Dpos.BlockInterval
-	fmt.Println(bi)
-	go s.miner.Start(cb,bi)
+	go s.miner.Start(cb)
 
 	return nil
 }
 
+// validatorWallet returns the validator address and the local wallet holding
+// its key.
+func (s *Ethereum) validatorWallet() (common.Address, accounts.Wallet, error) {
+	validator, err := s.Validator()
+	if err != nil {
+		log.Error("Cannot start mining without validator", "err", err)
+		return common.Address{}, nil, fmt.Errorf("validator missing: %v", err)
+	}
+	wallet, err := s.accountManager.Find(accounts.Account{Address: validator})
+	if wallet == nil || err != nil {
+		log.Error("Validator account unavailable locally", "err", err)
+		return common.Address{}, nil, fmt.Errorf("signer missing: %v", err)
+	}
+	return validator, wallet, nil
+}
+
 func (s *Ethereum) StopMining()         { s.miner.Stop() }
 func (s *Ethereum) IsMining() bool      { return s.miner.Mining() }
 func (s *Ethereum) Miner() *miner.Miner { return s.miner }
@@ -400,12 +463,77 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 	if s.lesServer != nil {
 		s.lesServer.Start(srvr)
 	}
+	// Report validators caught double signing while we are validating
+	s.startEvidenceLoop()
 	return nil
 }
 
+// startEvidenceLoop subscribes to the double signing detected by the DPoS
+// engine, if any, and starts submitting evidence for it.
+func (s *Ethereum) startEvidenceLoop() {
+	if engine, ok := s.engine.(*dpos.Dpos); ok {
+		evidenceCh := make(chan dpos.DoubleSignEvent, 16)
+		s.evidenceSub = engine.SubscribeDoubleSignEvent(evidenceCh)
+		go s.evidenceLoop(evidenceCh, s.evidenceSub)
+	}
+}
+
+// evidenceLoop submits an evidence transaction for every double signing
+// detected by the DPoS engine while the node is mining as a validator.
+func (s *Ethereum) evidenceLoop(evidenceCh <-chan dpos.DoubleSignEvent, sub event.Subscription) {
+	for {
+		select {
+		case ev := <-evidenceCh:
+			if !s.IsMining() {
+				continue
+			}
+			if err := s.submitEvidence(ev); err != nil {
+				log.Warn("Failed to submit double sign evidence", "offender", ev.Offender, "err", err)
+			}
+		case <-sub.Err():
+			return
+		}
+	}
+}
+
+// submitEvidence signs an evidence transaction with the validator account and
+// adds it to the local transaction pool.
+func (s *Ethereum) submitEvidence(ev dpos.DoubleSignEvent) error {
+	validator, err := s.Validator()
+	if err != nil {
+		return err
+	}
+	if validator == ev.Offender {
+		return nil
+	}
+	account := accounts.Account{Address: validator}
+	wallet, err := s.accountManager.Find(account)
+	if err != nil {
+		return err
+	}
+	data, err := rlp.EncodeToBytes(ev.Evidence)
+	if err != nil {
+		return err
+	}
+	gas, err := core.IntrinsicGas(types.Evidence, data, false, true)
+	if err != nil {
+		return err
+	}
+	tx := types.NewTransaction(types.Evidence, s.txPool.State().GetNonce(validator), common.Address{}, nil, gas, s.gasPrice, data)
+	signed, err := wallet.SignTx(account, tx, s.chainConfig.ChainID)
+	if err != nil {
+		return err
+	}
+	log.Info("Submitting double sign evidence", "offender", ev.Offender, "tx", signed.Hash())
+	return s.txPool.AddLocal(signed)
+}
+
 // Stop implements node.Service, terminating all internal goroutines used by the
 // Ethereum protocol.
 func (s *Ethereum) Stop() error {
+	if s.evidenceSub != nil {
+		s.evidenceSub.Unsubscribe()
+	}
 	s.bloomIndexer.Close()
 	s.blockchain.Stop()
 	s.engine.Close()
@@ -0,0 +1,223 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"crypto/ecdsa"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/haxicode/go-ethereum/accounts"
+	"github.com/haxicode/go-ethereum/accounts/keystore"
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/consensus/dpos"
+	"github.com/haxicode/go-ethereum/core"
+	"github.com/haxicode/go-ethereum/core/rawdb"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/core/vm"
+	"github.com/haxicode/go-ethereum/crypto"
+	"github.com/haxicode/go-ethereum/ethdb"
+	"github.com/haxicode/go-ethereum/event"
+	"github.com/haxicode/go-ethereum/miner"
+	"github.com/haxicode/go-ethereum/params"
+)
+
+// fixedClock is a DPoS clock standing still at a slot.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time                         { return time.Time(c) }
+func (c fixedClock) After(d time.Duration) <-chan time.Time { return time.After(0) }
+
+// newDposValidator returns an Ethereum service validating a developer DPoS chain
+// with the key, whose stake outweighs any other candidate. Only the parts used
+// for mining and reporting double signing are set up.
+func newDposValidator(t *testing.T, key *ecdsa.PrivateKey, alloc core.GenesisAlloc, keydir string) *Ethereum {
+	validator := crypto.PubkeyToAddress(key.PublicKey)
+	genesis := core.DeveloperGenesisBlock(0, validator)
+	genesis.Config.Dpos.CandidateDeposit = big.NewInt(1000)
+	genesis.Config.Dpos.UnbondingPeriod = 3600
+	genesis.Config.Dpos.Delegations = []*params.DposDelegation{{Delegator: validator, Candidate: validator, Stake: big.NewInt(params.Ether)}}
+	for addr, account := range alloc {
+		genesis.Alloc[addr] = account
+	}
+	db := ethdb.NewMemDatabase()
+	genesis.MustCommit(db)
+
+	ks := keystore.NewKeyStore(keydir, keystore.LightScryptN, keystore.LightScryptP)
+	account, err := ks.ImportECDSA(key, "")
+	if err != nil {
+		t.Fatalf("failed to import validator key: %v", err)
+	}
+	if err := ks.Unlock(account, ""); err != nil {
+		t.Fatalf("failed to unlock validator key: %v", err)
+	}
+	engine := dpos.New(genesis.Config.Dpos, db)
+	chain, err := core.NewBlockChain(db, nil, genesis.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	poolConfig := core.DefaultTxPoolConfig
+	poolConfig.Journal = ""
+
+	eth := &Ethereum{
+		chainConfig:    genesis.Config,
+		txPool:         core.NewTxPool(poolConfig, genesis.Config, chain),
+		blockchain:     chain,
+		chainDb:        db,
+		eventMux:       new(event.TypeMux),
+		engine:         engine,
+		accountManager: accounts.NewManager(ks),
+		gasPrice:       big.NewInt(1),
+		validator:      validator,
+		coinbase:       validator,
+	}
+	eth.miner = miner.New(eth, eth.chainConfig, eth.eventMux, eth.engine, time.Second)
+	return eth
+}
+
+// waitDposState waits for a block whose DPoS state satisfies cond.
+func waitDposState(t *testing.T, chain *core.BlockChain, what string, cond func(*types.DposContext) bool) *types.Block {
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		head := chain.CurrentBlock()
+		statedb, err := chain.StateAt(head.Root())
+		if err != nil {
+			continue
+		}
+		dposContext, err := types.NewDposContextFromProto(statedb.Database().TrieDB(), head.Header().DposContext)
+		if err == nil && cond(dposContext) {
+			return head
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+	return nil
+}
+
+// Tests that a validator seeing two headers sealed by another candidate for the
+// same slot submits the evidence, which gets mined and slashes the deposit of
+// the offender.
+func TestDoubleSignEvidence(t *testing.T) {
+	keydir, err := ioutil.TempDir("", "eth-evidence")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(keydir)
+
+	key, _ := crypto.GenerateKey()
+	offenderKey, _ := crypto.GenerateKey()
+	offender := crypto.PubkeyToAddress(offenderKey.PublicKey)
+	eth := newDposValidator(t, key, core.GenesisAlloc{offender: {Balance: big.NewInt(params.Ether)}}, keydir)
+	defer eth.blockchain.Stop()
+	defer eth.txPool.Stop()
+	defer eth.engine.Close()
+
+	eth.startEvidenceLoop()
+	defer eth.evidenceSub.Unsubscribe()
+	if err := eth.StartMining(false); err != nil {
+		t.Fatalf("failed to start mining: %v", err)
+	}
+	defer eth.StopMining()
+
+	// Register the offender as a candidate, without a recipient
+	signer := types.NewEIP155Signer(eth.chainConfig.ChainID)
+	gas, _ := core.IntrinsicGas(types.RegCandidate, nil, false, true)
+	tx, err := types.SignTx(types.NewTransaction(types.RegCandidate, 0, common.Address{}, big.NewInt(1000), gas, big.NewInt(1), nil), signer, offenderKey)
+	if err != nil {
+		t.Fatalf("failed to sign registration: %v", err)
+	}
+	if err := eth.txPool.AddRemote(tx); err != nil {
+		t.Fatalf("failed to add registration: %v", err)
+	}
+	waitDposState(t, eth.blockchain, "the registration", func(dposContext *types.DposContext) bool {
+		registered, _ := dposContext.IsCandidate(offender)
+		return registered
+	})
+
+	// Seal two different headers for the same slot with the offender key, as two
+	// nodes running the same candidate would, and let the validator see them
+	slot := time.Now().Unix()
+	var headers []*types.Header
+	for i := 0; i < 2; i++ {
+		engine := dpos.New(eth.chainConfig.Dpos, ethdb.NewMemDatabase())
+		engine.SetClock(fixedClock(time.Unix(slot, 0)))
+		engine.Authorize(offender, func(account accounts.Account, hash []byte) ([]byte, error) {
+			return crypto.Sign(hash, offenderKey)
+		})
+		header := &types.Header{
+			ParentHash:    eth.blockchain.CurrentBlock().Hash(),
+			Number:        new(big.Int).Add(eth.blockchain.CurrentBlock().Number(), common.Big1),
+			Root:          common.Hash{byte(i)},
+			Difficulty:    common.Big1,
+			Time:          big.NewInt(slot),
+			Validator:     offender,
+			Extra:         make([]byte, 32+65),
+			BlockInterval: 1,
+		}
+		block, err := engine.Seal(eth.blockchain, types.NewBlockWithHeader(header), nil)
+		if err != nil {
+			t.Fatalf("failed to seal conflicting header %d: %v", i, err)
+		}
+		headers = append(headers, block.Header())
+		engine.Close()
+	}
+	for _, header := range headers {
+		eth.engine.(*dpos.Dpos).ObserveHeader(header)
+	}
+
+	// The evidence is mined and slashes the offender, unbonding the rest
+	block := waitDposState(t, eth.blockchain, "the slashing", func(dposContext *types.DposContext) bool {
+		registered, _ := dposContext.IsCandidate(offender)
+		return !registered
+	})
+	var evidence *types.Receipt
+	for number := block.NumberU64(); number > 0 && evidence == nil; number-- {
+		block := eth.blockchain.GetBlockByNumber(number)
+		receipts := rawdb.ReadReceipts(eth.chainDb, block.Hash(), number)
+		for i, tx := range block.Transactions() {
+			if tx.Type() == types.Evidence {
+				evidence = receipts[i]
+			}
+		}
+	}
+	if evidence == nil {
+		t.Fatalf("no evidence transaction mined")
+	}
+	if evidence.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("evidence transaction failed: %s", evidence.Reason)
+	}
+	statedb, _ := eth.blockchain.StateAt(block.Root())
+	dposContext, err := types.NewDposContextFromProto(statedb.Database().TrieDB(), block.Header().DposContext)
+	if err != nil {
+		t.Fatalf("failed to load DPoS state: %v", err)
+	}
+	unbondings, err := dposContext.ReleaseUnbonded(math.MaxInt64)
+	if err != nil {
+		t.Fatalf("failed to list unbonding stake: %v", err)
+	}
+	remaining := new(big.Int)
+	for _, unbonding := range unbondings {
+		if unbonding.Address == offender {
+			remaining.Add(remaining, unbonding.Amount)
+		}
+	}
+	if want := big.NewInt(1000 - 1000*params.DefaultDposSlashPercent/100); remaining.Cmp(want) != 0 {
+		t.Fatalf("unbonding deposit mismatch: have %v, want %v", remaining, want)
+	}
+}
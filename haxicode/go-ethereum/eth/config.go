@@ -28,6 +28,7 @@ import (
 	"github.com/haxicode/go-ethereum/common/hexutil"
 	"github.com/haxicode/go-ethereum/consensus/ethash"
 	"github.com/haxicode/go-ethereum/core"
+	"github.com/haxicode/go-ethereum/core/types"
 	"github.com/haxicode/go-ethereum/eth/downloader"
 	"github.com/haxicode/go-ethereum/eth/gasprice"
 	"github.com/haxicode/go-ethereum/params"
@@ -84,6 +85,12 @@ type Config struct {
 	SyncMode  downloader.SyncMode
 	NoPruning bool
 
+	// Whether to keep the state of the blocks starting a DPoS epoch for snapshots
+	DposSnapshots bool
+
+	// Trusted block of a DPoS chain that fast sync starts from instead of the genesis
+	DposCheckpoint *types.DposCheckpoint `toml:",omitempty"`
+
 	// Light client options
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
@@ -103,6 +110,7 @@ type Config struct {
 	MinerExtraData []byte         `toml:",omitempty"`
 	MinerGasPrice  *big.Int
 	MinerRecommit  time.Duration
+	MinerFailover  string `toml:",omitempty"`
 
 	// Ethash options
 	Ethash ethash.Config
@@ -0,0 +1,158 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"time"
+
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/core/types"
+	"github.com/haxicode/go-ethereum/log"
+)
+
+// syncCheckpoint downloads the trusted checkpoint block from the peer along with
+// its receipts, its state and its DPoS tries, and makes it the head of the local
+// chain. None of the ancestors of the checkpoint are retrieved.
+func (d *Downloader) syncCheckpoint(p *peerConnection, cp *types.DposCheckpoint) error {
+	if p.version < 63 {
+		return errTooOld
+	}
+	p.log.Debug("Retrieving checkpoint", "number", cp.Number, "hash", cp.Hash)
+
+	header, err := d.fetchCheckpointHeader(p, cp)
+	if err != nil {
+		return err
+	}
+	block, receipts, err := d.fetchCheckpointBody(p, header)
+	if err != nil {
+		return err
+	}
+	log.Info("Syncing checkpoint state", "number", cp.Number, "hash", cp.Hash)
+	if err := d.syncState(header.Root).Wait(); err != nil {
+		return err
+	}
+	if err := d.syncDposContextState(header.DposContext); err != nil {
+		return err
+	}
+	return d.blockchain.InsertCheckpoint(block, receipts)
+}
+
+// fetchCheckpointHeader retrieves the header of the checkpoint and checks it
+// against the configured hash, number and DPoS roots.
+func (d *Downloader) fetchCheckpointHeader(p *peerConnection, cp *types.DposCheckpoint) (*types.Header, error) {
+	go p.peer.RequestHeadersByHash(cp.Hash, 1, 0, false)
+
+	ttl := d.requestTTL()
+	timeout := time.After(ttl)
+	for {
+		select {
+		case <-d.cancelCh:
+			return nil, errCancelHeaderFetch
+
+		case packet := <-d.headerCh:
+			// Discard anything not from the origin peer
+			if packet.PeerId() != p.id {
+				log.Debug("Received headers from incorrect peer", "peer", packet.PeerId())
+				break
+			}
+			headers := packet.(*headerPack).headers
+			if len(headers) != 1 {
+				p.log.Debug("Multiple headers for single request", "headers", len(headers))
+				return nil, errBadPeer
+			}
+			header := headers[0]
+			if header.Hash() != cp.Hash || header.Number.Uint64() != cp.Number {
+				p.log.Warn("Checkpoint header mismatch", "number", header.Number, "hash", header.Hash(), "want", cp.Hash)
+				return nil, errInvalidCheckpoint
+			}
+			if cp.DposContext != nil && (header.DposContext == nil || header.DposContext.Root() != cp.DposContext.Root()) {
+				p.log.Warn("Checkpoint DPoS context mismatch", "number", header.Number, "hash", header.Hash())
+				return nil, errInvalidCheckpoint
+			}
+			return header, nil
+
+		case <-timeout:
+			p.log.Debug("Waiting for checkpoint header timed out", "elapsed", ttl)
+			return nil, errTimeout
+
+		case <-d.bodyCh:
+		case <-d.receiptCh:
+			// Out of bounds delivery, ignore
+		}
+	}
+}
+
+// fetchCheckpointBody retrieves the body and the receipts of the checkpoint and
+// assembles its block.
+func (d *Downloader) fetchCheckpointBody(p *peerConnection, header *types.Header) (*types.Block, types.Receipts, error) {
+	hash := header.Hash()
+	go p.peer.RequestBodies([]common.Hash{hash})
+	go p.peer.RequestReceipts([]common.Hash{hash})
+
+	var (
+		block    *types.Block
+		receipts types.Receipts
+	)
+	ttl := d.requestTTL()
+	timeout := time.After(ttl)
+	for block == nil || receipts == nil {
+		select {
+		case <-d.cancelCh:
+			return nil, nil, errCancelBodyFetch
+
+		case packet := <-d.bodyCh:
+			if packet.PeerId() != p.id {
+				log.Debug("Received bodies from incorrect peer", "peer", packet.PeerId())
+				break
+			}
+			bodies := packet.(*bodyPack)
+			if len(bodies.transactions) != 1 || len(bodies.uncles) != 1 {
+				return nil, nil, errBadPeer
+			}
+			txs, uncles := bodies.transactions[0], bodies.uncles[0]
+			if types.DeriveSha(types.Transactions(txs)) != header.TxHash || types.CalcUncleHash(uncles) != header.UncleHash {
+				return nil, nil, errBadPeer
+			}
+			block = types.NewBlockWithHeader(header).WithBody(txs, uncles)
+
+		case packet := <-d.receiptCh:
+			if packet.PeerId() != p.id {
+				log.Debug("Received receipts from incorrect peer", "peer", packet.PeerId())
+				break
+			}
+			lists := packet.(*receiptPack).receipts
+			if len(lists) != 1 {
+				return nil, nil, errBadPeer
+			}
+			if types.DeriveSha(types.Receipts(lists[0])) != header.ReceiptHash {
+				return nil, nil, errBadPeer
+			}
+			receipts = types.Receipts(lists[0])
+			if receipts == nil {
+				receipts = types.Receipts{}
+			}
+
+		case <-timeout:
+			p.log.Debug("Waiting for checkpoint body timed out", "elapsed", ttl)
+			return nil, nil, errTimeout
+
+		case <-d.headerCh:
+			// Out of bounds delivery, ignore
+		}
+	}
+	return block, receipts, nil
+}
@@ -81,6 +81,7 @@ var (
 	errInvalidBlock            = errors.New("retrieved block is invalid")
 	errInvalidBody             = errors.New("retrieved block body is invalid")
 	errInvalidReceipt          = errors.New("retrieved receipt is invalid")
+	errInvalidCheckpoint       = errors.New("retrieved checkpoint is invalid")
 	errCancelBlockFetch        = errors.New("block download canceled (requested)")
 	errCancelHeaderFetch       = errors.New("block header download canceled (requested)")
 	errCancelBodyFetch         = errors.New("block body download canceled (requested)")
@@ -112,6 +113,8 @@ type Downloader struct {
 	lightchain LightChain
 	blockchain BlockChain
 
+	checkpoint *types.DposCheckpoint // Trusted block fast sync starts from, nil to sync from the genesis
+
 	// Callbacks
 	dropPeer peerDropFn // Drops a peer for misbehaving
 
@@ -161,6 +164,9 @@ type LightChain interface {
 	// CurrentHeader retrieves the head header from the local chain.
 	CurrentHeader() *types.Header
 
+	// IrreversibleHeader retrieves the header the local chain never forks below.
+	IrreversibleHeader() *types.Header
+
 	// GetTd returns the total difficulty of a local block.
 	GetTd(common.Hash, uint64) *big.Int
 
@@ -195,6 +201,10 @@ type BlockChain interface {
 
 	// InsertReceiptChain inserts a batch of receipts into the local chain.
 	InsertReceiptChain(types.Blocks, []types.Receipts) (int, error)
+
+	// InsertCheckpoint makes a block whose state was downloaded the head of an
+	// otherwise empty local chain.
+	InsertCheckpoint(*types.Block, types.Receipts) error
 }
 
 // New creates a new downloader to fetch hashes and blocks from remote peers.
@@ -233,6 +243,12 @@ func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockC
 	return dl
 }
 
+// SetCheckpoint sets the trusted block fast sync starts from instead of the
+// genesis block. It must be called before the first sync.
+func (d *Downloader) SetCheckpoint(checkpoint *types.DposCheckpoint) {
+	d.checkpoint = checkpoint
+}
+
 // Progress retrieves the synchronisation boundaries, specifically the origin
 // block where synchronisation started at (may have failed/suspended); the block
 // or header sync is currently at; and the latest known block which the sync targets.
@@ -321,7 +337,7 @@ func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode
 
 	case errTimeout, errBadPeer, errStallingPeer,
 		errEmptyHeaderSet, errPeersUnavailable, errTooOld,
-		errInvalidAncestor, errInvalidChain:
+		errInvalidAncestor, errInvalidChain, errInvalidCheckpoint:
 		log.Warn("Synchronisation failed, dropping peer", "peer", id, "err", err)
 		if d.dropPeer == nil {
 			// The dropPeer method is nil when `--copydb` is used for a local copy.
@@ -427,6 +443,14 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 	}
 	height := latest.Number.Uint64()
 
+	// Start from the trusted checkpoint if the chain hasn't reached it yet, then
+	// execute the blocks after it to verify them against its DPoS state
+	if cp := d.checkpoint; d.mode == FastSync && cp != nil && height >= cp.Number && d.blockchain.CurrentFastBlock().NumberU64() < cp.Number {
+		if err := d.syncCheckpoint(p, cp); err != nil {
+			return err
+		}
+		d.mode = FullSync
+	}
 	origin, err := d.findAncestor(p, height)
 	if err != nil {
 		return err
@@ -601,6 +625,19 @@ func (d *Downloader) findAncestor(p *peerConnection, height uint64) (uint64, err
 	if ceil >= MaxForkAncestry {
 		floor = int64(ceil - MaxForkAncestry)
 	}
+	// Never fork off below the block the consensus engine made irreversible
+	if irreversible := d.lightchain.IrreversibleHeader(); irreversible != nil {
+		if limit := irreversible.Number.Int64() - 1; limit > floor {
+			floor = limit
+		}
+	}
+	// Nor below the checkpoint the local chain started from, its ancestors are missing
+	base := uint64(0)
+	if cp := d.checkpoint; cp != nil && d.lightchain.HasHeader(cp.Hash, cp.Number) {
+		if limit := int64(cp.Number) - 1; limit > floor {
+			floor, base = limit, cp.Number
+		}
+	}
 	p.log.Debug("Looking for common ancestor", "local", ceil, "remote", height)
 
 	// Request the topmost blocks to short circuit binary ancestor lookup
@@ -653,8 +690,9 @@ func (d *Downloader) findAncestor(p *peerConnection, height uint64) (uint64, err
 			// Check if a common ancestor was found
 			finished = true
 			for i := len(headers) - 1; i >= 0; i-- {
-				// Skip any headers that underflow/overflow our requested set
-				if headers[i].Number.Int64() < from || headers[i].Number.Uint64() > ceil {
+				// Skip any headers that underflow/overflow our requested set, or that
+				// precede the checkpoint the local chain started from
+				if headers[i].Number.Int64() < from || headers[i].Number.Uint64() > ceil || headers[i].Number.Uint64() < base {
 					continue
 				}
 				// Otherwise check if we already know the header or not
@@ -693,6 +731,9 @@ func (d *Downloader) findAncestor(p *peerConnection, height uint64) (uint64, err
 	if floor > 0 {
 		start = uint64(floor)
 	}
+	if base > start {
+		start = base
+	}
 	for start+1 < end {
 		// Split our chain interval in two, and request the hash to cross check
 		check := (start + end) / 2
@@ -1525,15 +1566,8 @@ func (d *Downloader) commitPivotBlock(result *fetchResult) error {
 }
 // Todo: sync dpos context in concurrent
 func (d *Downloader) syncDposContextState(context *types.DposContextProto) error {
-	roots := []common.Hash{
-		context.CandidateHash,
-		context.DelegateHash,
-		context.VoteHash,
-		context.EpochHash,
-		context.MintCntHash,
-	}
-	for _, root := range roots {
-		if err := d.syncState(root).Wait(); err != nil {
+	for _, root := range context.Roots() {
+		if err := d.syncTrie(root).Wait(); err != nil {
 			return err
 		}
 	}
@@ -256,6 +256,11 @@ func (dl *downloadTester) CurrentHeader() *types.Header {
 	return dl.genesis.Header()
 }
 
+// IrreversibleHeader retrieves the header the local chain never forks below.
+func (dl *downloadTester) IrreversibleHeader() *types.Header {
+	return nil
+}
+
 // CurrentBlock retrieves the current head block from the canonical chain.
 func (dl *downloadTester) CurrentBlock() *types.Block {
 	dl.lock.RLock()
@@ -371,6 +376,23 @@ func (dl *downloadTester) InsertReceiptChain(blocks types.Blocks, receipts []typ
 	return len(blocks), nil
 }
 
+// InsertCheckpoint injects a block whose state was downloaded as the head of the
+// simulated chain, without any of its ancestors.
+func (dl *downloadTester) InsertCheckpoint(block *types.Block, receipts types.Receipts) error {
+	if _, err := trie.NewSecure(block.Root(), trie.NewDatabase(dl.stateDb), 0); err != nil {
+		return err
+	}
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.ownHashes = append(dl.ownHashes, block.Hash())
+	dl.ownHeaders[block.Hash()] = block.Header()
+	dl.ownBlocks[block.Hash()] = block
+	dl.ownReceipts[block.Hash()] = receipts
+	dl.ownChainTd[block.Hash()] = new(big.Int).Add(dl.genesis.Difficulty(), new(big.Int).Mul(block.Difficulty(), block.Number()))
+	return nil
+}
+
 // Rollback removes some recently added elements from the chain.
 func (dl *downloadTester) Rollback(hashes []common.Hash) {
 	dl.lock.Lock()
@@ -686,6 +708,81 @@ func testCanonicalSynchronisation(t *testing.T, protocol int, mode SyncMode) {
 	assertOwnChain(t, tester, targetBlocks+1)
 }
 
+// Tests that fast sync starts from a configured checkpoint, retrieving none of
+// its ancestors, and executes the blocks after it.
+func TestCheckpointSynchronisation63(t *testing.T) { testCheckpointSynchronisation(t, 63) }
+func TestCheckpointSynchronisation64(t *testing.T) { testCheckpointSynchronisation(t, 64) }
+
+func testCheckpointSynchronisation(t *testing.T, protocol int) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Sync from a peer lagging 10 blocks behind, then from one at the head
+	targetBlocks := blockCacheItems - 15
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+	tester.newPeer("lagging", protocol, hashes[10:], headers, blocks, receipts)
+	tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+
+	// The ancestor lookup right after the checkpoint spans over the genesis
+	number := uint64(50)
+	hash := hashes[len(hashes)-1-int(number)]
+	tester.downloader.SetCheckpoint(&types.DposCheckpoint{Number: number, Hash: hash, DposContext: headers[hash].DposContext})
+
+	if err := tester.sync("lagging", nil, FastSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	if head := tester.CurrentBlock().Hash(); head != hashes[10] {
+		t.Fatalf("head mismatch: have %x, want %x", head, hashes[10])
+	}
+	if tester.GetHeaderByHash(hashes[len(hashes)-2]) != nil {
+		t.Fatalf("ancestor of the checkpoint retrieved")
+	}
+	// The genesis and the blocks from the checkpoint on
+	if hs, want := len(tester.ownHeaders), targetBlocks-10-int(number)+2; hs != want {
+		t.Fatalf("synchronised headers mismatch: have %v, want %v", hs, want)
+	}
+	if bs, want := len(tester.ownBlocks), targetBlocks-10-int(number)+2; bs != want {
+		t.Fatalf("synchronised blocks mismatch: have %v, want %v", bs, want)
+	}
+	// A later sync must not look for the ancestors of the checkpoint either
+	if err := tester.sync("peer", nil, FullSync); err != nil {
+		t.Fatalf("failed to synchronise blocks after the checkpoint: %v", err)
+	}
+	if head := tester.CurrentBlock().Hash(); head != hashes[0] {
+		t.Fatalf("head mismatch: have %x, want %x", head, hashes[0])
+	}
+}
+
+// Tests that a peer serving a block other than the configured checkpoint is
+// rejected.
+func TestCheckpointMismatch63(t *testing.T) { testCheckpointMismatch(t, 63) }
+func TestCheckpointMismatch64(t *testing.T) { testCheckpointMismatch(t, 64) }
+
+func testCheckpointMismatch(t *testing.T, protocol int) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	targetBlocks := blockCacheItems - 15
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+	tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+
+	// Configure the hash of a block at another height
+	number := uint64(targetBlocks / 2)
+	hash := hashes[len(hashes)-int(number)]
+	tester.downloader.SetCheckpoint(&types.DposCheckpoint{Number: number, Hash: hash})
+
+	if err := tester.sync("peer", nil, FastSync); err != errInvalidCheckpoint {
+		t.Fatalf("checkpoint mismatch error: have %v, want %v", err, errInvalidCheckpoint)
+	}
+	if hs := len(tester.ownHeaders); hs != 1 {
+		t.Fatalf("headers retrieved from the rejected peer: have %v, want 1", hs)
+	}
+}
+
 // Tests that if a large batch of blocks are being downloaded, it is throttled
 // until the cached blocks are retrieved.
 func TestThrottling62(t *testing.T)     { testThrottling(t, 62, FullSync) }
@@ -1321,6 +1418,7 @@ func testBlockHeaderAttackerDropping(t *testing.T, protocol int) {
 		{errPeersUnavailable, true},         // Nobody had the advertised blocks, drop the advertiser
 		{errInvalidAncestor, true},          // Agreed upon ancestor is not acceptable, drop the chain rewriter
 		{errInvalidChain, true},             // Hash chain was detected as invalid, definitely drop
+		{errInvalidCheckpoint, true},        // Checkpoint served doesn't match the configured one, drop
 		{errInvalidBlock, false},            // A bad peer was detected, but not the sync origin
 		{errInvalidBody, false},             // A bad peer was detected, but not the sync origin
 		{errInvalidReceipt, false},          // A bad peer was detected, but not the sync origin
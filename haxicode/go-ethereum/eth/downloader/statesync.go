@@ -59,7 +59,17 @@ type stateSyncStats struct {
 
 // syncState starts downloading state with the given root hash.
 func (d *Downloader) syncState(root common.Hash) *stateSync {
-	s := newStateSync(d, root)
+	return d.startStateSync(newStateSync(d, state.NewStateSync(root, d.stateDB)))
+}
+
+// syncTrie starts downloading a trie with the given root hash whose leaves,
+// unlike those of the state trie, don't reference other tries.
+func (d *Downloader) syncTrie(root common.Hash) *stateSync {
+	return d.startStateSync(newStateSync(d, trie.NewSync(root, d.stateDB, nil)))
+}
+
+// startStateSync hands the sync over to the state fetcher.
+func (d *Downloader) startStateSync(s *stateSync) *stateSync {
 	select {
 	case d.stateSyncStart <- s:
 	case <-d.quitCh:
@@ -236,10 +246,10 @@ type stateTask struct {
 
 // newStateSync creates a new state trie download scheduler. This method does not
 // yet start the sync. The user needs to call run to initiate.
-func newStateSync(d *Downloader, root common.Hash) *stateSync {
+func newStateSync(d *Downloader, sched *trie.Sync) *stateSync {
 	return &stateSync{
 		d:       d,
-		sched:   state.NewStateSync(root, d.stateDB),
+		sched:   sched,
 		keccak:  sha3.NewKeccak256(),
 		tasks:   make(map[common.Hash]*stateTask),
 		deliver: make(chan *stateReq),
@@ -10,6 +10,7 @@ import (
 	"github.com/haxicode/go-ethereum/common/hexutil"
 	"github.com/haxicode/go-ethereum/consensus/ethash"
 	"github.com/haxicode/go-ethereum/core"
+	"github.com/haxicode/go-ethereum/core/types"
 	"github.com/haxicode/go-ethereum/eth/downloader"
 	"github.com/haxicode/go-ethereum/eth/gasprice"
 )
@@ -23,10 +24,12 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		NetworkId               uint64
 		SyncMode                downloader.SyncMode
 		NoPruning               bool
-		LightServ               int  `toml:",omitempty"`
-		LightPeers              int  `toml:",omitempty"`
-		SkipBcVersionCheck      bool `toml:"-"`
-		DatabaseHandles         int  `toml:"-"`
+		DposSnapshots           bool
+		DposCheckpoint          *types.DposCheckpoint `toml:",omitempty"`
+		LightServ               int                   `toml:",omitempty"`
+		LightPeers              int                   `toml:",omitempty"`
+		SkipBcVersionCheck      bool                  `toml:"-"`
+		DatabaseHandles         int                   `toml:"-"`
 		DatabaseCache           int
 		TrieCache               int
 		TrieTimeout             time.Duration
@@ -37,6 +40,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		MinerExtraData          hexutil.Bytes  `toml:",omitempty"`
 		MinerGasPrice           *big.Int
 		MinerRecommit           time.Duration
+		MinerFailover           string `toml:",omitempty"`
 		Ethash                  ethash.Config
 		TxPool                  core.TxPoolConfig
 		GPO                     gasprice.Config
@@ -49,6 +53,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.NetworkId = c.NetworkId
 	enc.SyncMode = c.SyncMode
 	enc.NoPruning = c.NoPruning
+	enc.DposSnapshots = c.DposSnapshots
+	enc.DposCheckpoint = c.DposCheckpoint
 	enc.LightServ = c.LightServ
 	enc.LightPeers = c.LightPeers
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
@@ -63,6 +69,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.MinerExtraData = c.MinerExtraData
 	enc.MinerGasPrice = c.MinerGasPrice
 	enc.MinerRecommit = c.MinerRecommit
+	enc.MinerFailover = c.MinerFailover
 	enc.Ethash = c.Ethash
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
@@ -79,10 +86,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		NetworkId               *uint64
 		SyncMode                *downloader.SyncMode
 		NoPruning               *bool
-		LightServ               *int  `toml:",omitempty"`
-		LightPeers              *int  `toml:",omitempty"`
-		SkipBcVersionCheck      *bool `toml:"-"`
-		DatabaseHandles         *int  `toml:"-"`
+		DposSnapshots           *bool
+		DposCheckpoint          *types.DposCheckpoint `toml:",omitempty"`
+		LightServ               *int                  `toml:",omitempty"`
+		LightPeers              *int                  `toml:",omitempty"`
+		SkipBcVersionCheck      *bool                 `toml:"-"`
+		DatabaseHandles         *int                  `toml:"-"`
 		DatabaseCache           *int
 		TrieCache               *int
 		TrieTimeout             *time.Duration
@@ -93,6 +102,7 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		MinerExtraData          *hexutil.Bytes  `toml:",omitempty"`
 		MinerGasPrice           *big.Int
 		MinerRecommit           *time.Duration
+		MinerFailover           *string `toml:",omitempty"`
 		Ethash                  *ethash.Config
 		TxPool                  *core.TxPoolConfig
 		GPO                     *gasprice.Config
@@ -116,6 +126,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.NoPruning != nil {
 		c.NoPruning = *dec.NoPruning
 	}
+	if dec.DposSnapshots != nil {
+		c.DposSnapshots = *dec.DposSnapshots
+	}
+	if dec.DposCheckpoint != nil {
+		c.DposCheckpoint = dec.DposCheckpoint
+	}
 	if dec.LightServ != nil {
 		c.LightServ = *dec.LightServ
 	}
@@ -158,6 +174,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.MinerRecommit != nil {
 		c.MinerRecommit = *dec.MinerRecommit
 	}
+	if dec.MinerFailover != nil {
+		c.MinerFailover = *dec.MinerFailover
+	}
 	if dec.Ethash != nil {
 		c.Ethash = *dec.Ethash
 	}
@@ -28,6 +28,7 @@ import (
 
 	"github.com/haxicode/go-ethereum/common"
 	"github.com/haxicode/go-ethereum/consensus"
+	"github.com/haxicode/go-ethereum/consensus/dpos"
 	"github.com/haxicode/go-ethereum/consensus/misc"
 	"github.com/haxicode/go-ethereum/core"
 	"github.com/haxicode/go-ethereum/core/types"
@@ -49,6 +50,9 @@ const (
 	// txChanSize is the size of channel listening to NewTxsEvent.
 	// The number is referenced from the size of tx pool.
 	txChanSize = 4096
+
+	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
+	chainHeadChanSize = 10
 )
 
 var (
@@ -71,6 +75,7 @@ type ProtocolManager struct {
 
 	txpool      txPool
 	blockchain  *core.BlockChain
+	engine      consensus.Engine
 	chainconfig *params.ChainConfig
 	maxPeers    int
 
@@ -84,6 +89,8 @@ type ProtocolManager struct {
 	txsCh         chan core.NewTxsEvent
 	txsSub        event.Subscription
 	minedBlockSub *event.TypeMuxSubscription
+	chainHeadCh   chan core.ChainHeadEvent
+	chainHeadSub  event.Subscription
 
 	// channels for fetcher, syncer, txsyncLoop
 	newPeerCh   chan *peer
@@ -105,6 +112,7 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		eventMux:    mux,
 		txpool:      txpool,
 		blockchain:  blockchain,
+		engine:      engine,
 		chainconfig: config,
 		peers:       newPeerSet(),
 		newPeerCh:   make(chan *peer),
@@ -160,9 +168,8 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 	}
 	// Construct the different synchronisation mechanisms
 	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.removePeer)
-	bi := blockchain.GenesisBlock().Header().BlockInterval
 	validator := func(header *types.Header) error {
-		return engine.VerifyHeader(blockchain, header, true,bi)
+		return engine.VerifyHeader(blockchain, header, true)
 	}
 	heighter := func() uint64 {
 		return blockchain.CurrentBlock().NumberU64()
@@ -212,6 +219,11 @@ func (pm *ProtocolManager) Start(maxPeers int) {
 	pm.minedBlockSub = pm.eventMux.Subscribe(core.NewMinedBlockEvent{})
 	go pm.minedBroadcastLoop()
 
+	// pre-commit new heads for DPoS finality
+	pm.chainHeadCh = make(chan core.ChainHeadEvent, chainHeadChanSize)
+	pm.chainHeadSub = pm.blockchain.SubscribeChainHeadEvent(pm.chainHeadCh)
+	go pm.preCommitLoop()
+
 	// start sync handlers
 	go pm.syncer()
 	go pm.txsyncLoop()
@@ -222,6 +234,7 @@ func (pm *ProtocolManager) Stop() {
 
 	pm.txsSub.Unsubscribe()        // quits txBroadcastLoop
 	pm.minedBlockSub.Unsubscribe() // quits blockBroadcastLoop
+	pm.chainHeadSub.Unsubscribe()  // quits preCommitLoop
 
 	// Quit the sync loop.
 	// After this send has completed, no new peers will be accepted.
@@ -446,6 +459,8 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 				return nil
 			}
 		}
+		pm.observeHeaders(headers)
+
 		// Filter out any explicitly requested headers, deliver the rest to the downloader
 		filter := len(headers) == 1
 		if filter {
@@ -640,6 +655,8 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		request.Block.ReceivedAt = msg.ReceivedAt
 		request.Block.ReceivedFrom = p
 
+		pm.observeHeaders([]*types.Header{request.Block.Header()})
+
 		// Mark the peer as owning the block and schedule it for import
 		p.MarkBlock(request.Block.Hash())
 		pm.fetcher.Enqueue(p.id, request.Block)
@@ -682,12 +699,57 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		}
 		pm.txpool.AddRemotes(txs)
 
+	case p.version >= eth64 && msg.Code == PreCommitMsg:
+		// A DPoS pre-commit vote arrived, verify it and relay it if it's new
+		var vote *types.PreCommit
+		if err := msg.Decode(&vote); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.MarkPreCommit(vote.ID())
+		pm.handlePreCommit(vote)
+
 	default:
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
 	}
 	return nil
 }
 
+// observeHeaders hands headers received from the network to the DPoS engine so
+// that validators sealing conflicting blocks for the same slot are detected.
+func (pm *ProtocolManager) observeHeaders(headers []*types.Header) {
+	if engine, ok := pm.engine.(*dpos.Dpos); ok {
+		for _, header := range headers {
+			engine.ObserveHeader(header)
+		}
+	}
+}
+
+// handlePreCommit hands a DPoS pre-commit vote to the consensus engine and
+// relays it to the rest of the network if it was accepted. Votes that can't be
+// verified (stale or duplicate) are silently dropped, the ones for unknown
+// blocks are relayed once the block is imported.
+func (pm *ProtocolManager) handlePreCommit(vote *types.PreCommit) {
+	engine, ok := pm.engine.(*dpos.Dpos)
+	if !ok {
+		return
+	}
+	if err := engine.AddPreCommit(pm.blockchain, vote); err != nil {
+		log.Trace("Discarded pre-commit", "number", vote.Number, "hash", vote.Hash, "err", err)
+		return
+	}
+	pm.BroadcastPreCommit(vote)
+}
+
+// BroadcastPreCommit propagates a DPoS pre-commit vote to all eth/64 peers
+// which are not known to already have it.
+func (pm *ProtocolManager) BroadcastPreCommit(vote *types.PreCommit) {
+	peers := pm.peers.PeersWithoutPreCommit(vote.ID())
+	for _, peer := range peers {
+		peer.AsyncSendPreCommit(vote)
+	}
+	log.Trace("Broadcast pre-commit", "number", vote.Number, "hash", vote.Hash, "recipients", len(peers))
+}
+
 // BroadcastBlock will either propagate a block to a subset of it's peers, or
 // will only announce it's availability (depending what's requested).
 func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
@@ -764,6 +826,36 @@ func (pm *ProtocolManager) txBroadcastLoop() {
 	}
 }
 
+// preCommitLoop signs a pre-commit vote for every new chain head if the local
+// node is a DPoS validator, and feeds it through the same path as remote votes.
+// Remote votes which arrived before their block are relayed once it's imported.
+func (pm *ProtocolManager) preCommitLoop() {
+	engine, ok := pm.engine.(*dpos.Dpos)
+	for {
+		select {
+		case ev := <-pm.chainHeadCh:
+			if !ok {
+				continue
+			}
+			for _, vote := range engine.RetryPreCommits(pm.blockchain) {
+				pm.BroadcastPreCommit(vote)
+			}
+			vote, err := engine.PreCommit(pm.blockchain, ev.Block.Header())
+			if err != nil {
+				log.Warn("Failed to sign pre-commit", "number", ev.Block.Number(), "hash", ev.Block.Hash(), "err", err)
+				continue
+			}
+			if vote != nil {
+				pm.handlePreCommit(vote)
+			}
+
+		// Err() channel will be closed when unsubscribing.
+		case <-pm.chainHeadSub.Err():
+			return
+		}
+	}
+}
+
 // NodeInfo represents a short summary of the Ethereum sub-protocol metadata
 // known about the host peer.
 type NodeInfo struct {
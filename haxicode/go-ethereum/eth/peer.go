@@ -40,6 +40,10 @@ const (
 	maxKnownTxs    = 32768 // Maximum transactions hashes to keep in the known list (prevent DOS)
 	maxKnownBlocks = 1024  // Maximum block hashes to keep in the known list (prevent DOS)
 
+	// maxKnownPreCommits is the maximum number of DPoS pre-commit vote hashes to
+	// keep in the known list (prevent DOS).
+	maxKnownPreCommits = 4096
+
 	// maxQueuedTxs is the maximum number of transaction lists to queue up before
 	// dropping broadcasts. This is a sensitive number as a transaction list might
 	// contain a single transaction, or thousands.
@@ -55,6 +59,10 @@ const (
 	// above some healthy uncle limit, so use that.
 	maxQueuedAnns = 4
 
+	// maxQueuedPreCommits is the maximum number of DPoS pre-commit votes to queue
+	// up before dropping broadcasts. Votes are tiny, but stale ones are useless.
+	maxQueuedPreCommits = 128
+
 	handshakeTimeout = 5 * time.Second
 )
 
@@ -85,26 +93,30 @@ type peer struct {
 	td   *big.Int
 	lock sync.RWMutex
 
-	knownTxs    mapset.Set                // Set of transaction hashes known to be known by this peer
-	knownBlocks mapset.Set                // Set of block hashes known to be known by this peer
-	queuedTxs   chan []*types.Transaction // Queue of transactions to broadcast to the peer
-	queuedProps chan *propEvent           // Queue of blocks to broadcast to the peer
-	queuedAnns  chan *types.Block         // Queue of blocks to announce to the peer
-	term        chan struct{}             // Termination channel to stop the broadcaster
+	knownTxs         mapset.Set                // Set of transaction hashes known to be known by this peer
+	knownBlocks      mapset.Set                // Set of block hashes known to be known by this peer
+	knownPreCommits  mapset.Set                // Set of pre-commit vote hashes known to be known by this peer
+	queuedTxs        chan []*types.Transaction // Queue of transactions to broadcast to the peer
+	queuedProps      chan *propEvent           // Queue of blocks to broadcast to the peer
+	queuedAnns       chan *types.Block         // Queue of blocks to announce to the peer
+	queuedPreCommits chan *types.PreCommit     // Queue of pre-commit votes to broadcast to the peer
+	term             chan struct{}             // Termination channel to stop the broadcaster
 }
 
 func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
 	return &peer{
-		Peer:        p,
-		rw:          rw,
-		version:     version,
-		id:          fmt.Sprintf("%x", p.ID().Bytes()[:8]),
-		knownTxs:    mapset.NewSet(),
-		knownBlocks: mapset.NewSet(),
-		queuedTxs:   make(chan []*types.Transaction, maxQueuedTxs),
-		queuedProps: make(chan *propEvent, maxQueuedProps),
-		queuedAnns:  make(chan *types.Block, maxQueuedAnns),
-		term:        make(chan struct{}),
+		Peer:             p,
+		rw:               rw,
+		version:          version,
+		id:               fmt.Sprintf("%x", p.ID().Bytes()[:8]),
+		knownTxs:         mapset.NewSet(),
+		knownBlocks:      mapset.NewSet(),
+		knownPreCommits:  mapset.NewSet(),
+		queuedTxs:        make(chan []*types.Transaction, maxQueuedTxs),
+		queuedProps:      make(chan *propEvent, maxQueuedProps),
+		queuedAnns:       make(chan *types.Block, maxQueuedAnns),
+		queuedPreCommits: make(chan *types.PreCommit, maxQueuedPreCommits),
+		term:             make(chan struct{}),
 	}
 }
 
@@ -132,6 +144,12 @@ func (p *peer) broadcast() {
 			}
 			p.Log().Trace("Announced block", "number", block.Number(), "hash", block.Hash())
 
+		case vote := <-p.queuedPreCommits:
+			if err := p.SendPreCommit(vote); err != nil {
+				return
+			}
+			p.Log().Trace("Broadcast pre-commit", "number", vote.Number, "hash", vote.Hash)
+
 		case <-p.term:
 			return
 		}
@@ -215,6 +233,34 @@ func (p *peer) AsyncSendTransactions(txs []*types.Transaction) {
 	}
 }
 
+// MarkPreCommit marks a DPoS pre-commit vote as known for the peer, ensuring
+// that it will never be propagated to this particular peer.
+func (p *peer) MarkPreCommit(hash common.Hash) {
+	// If we reached the memory allowance, drop a previously known vote hash
+	for p.knownPreCommits.Cardinality() >= maxKnownPreCommits {
+		p.knownPreCommits.Pop()
+	}
+	p.knownPreCommits.Add(hash)
+}
+
+// SendPreCommit sends a DPoS pre-commit vote to the peer and includes its hash
+// in the known vote set for future reference.
+func (p *peer) SendPreCommit(vote *types.PreCommit) error {
+	p.knownPreCommits.Add(vote.ID())
+	return p2p.Send(p.rw, PreCommitMsg, vote)
+}
+
+// AsyncSendPreCommit queues a DPoS pre-commit vote for propagation to a remote
+// peer. If the peer's broadcast queue is full, the event is silently dropped.
+func (p *peer) AsyncSendPreCommit(vote *types.PreCommit) {
+	select {
+	case p.queuedPreCommits <- vote:
+		p.knownPreCommits.Add(vote.ID())
+	default:
+		p.Log().Debug("Dropping pre-commit propagation", "number", vote.Number, "hash", vote.Hash)
+	}
+}
+
 // SendNewBlockHashes announces the availability of a number of blocks through
 // a hash notification.
 func (p *peer) SendNewBlockHashes(hashes []common.Hash, numbers []uint64) error {
@@ -492,6 +538,21 @@ func (ps *peerSet) PeersWithoutTx(hash common.Hash) []*peer {
 	return list
 }
 
+// PeersWithoutPreCommit retrieves a list of eth/64 peers that do not have a
+// given DPoS pre-commit vote in their set of known hashes.
+func (ps *peerSet) PeersWithoutPreCommit(hash common.Hash) []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if p.version >= eth64 && !p.knownPreCommits.Contains(hash) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
 // BestPeer retrieves the known peer with the currently highest total difficulty.
 func (ps *peerSet) BestPeer() *peer {
 	ps.lock.RLock()
@@ -39,6 +39,7 @@ var testAccount, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6
 // Tests that handshake failures are detected and reported correctly.
 func TestStatusMsgErrors62(t *testing.T) { testStatusMsgErrors(t, 62) }
 func TestStatusMsgErrors63(t *testing.T) { testStatusMsgErrors(t, 63) }
+func TestStatusMsgErrors64(t *testing.T) { testStatusMsgErrors(t, 64) }
 
 func testStatusMsgErrors(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
@@ -95,6 +96,7 @@ func testStatusMsgErrors(t *testing.T, protocol int) {
 // This test checks that received transactions are added to the local pool.
 func TestRecvTransactions62(t *testing.T) { testRecvTransactions(t, 62) }
 func TestRecvTransactions63(t *testing.T) { testRecvTransactions(t, 63) }
+func TestRecvTransactions64(t *testing.T) { testRecvTransactions(t, 64) }
 
 func testRecvTransactions(t *testing.T, protocol int) {
 	txAdded := make(chan []*types.Transaction)
@@ -123,6 +125,7 @@ func testRecvTransactions(t *testing.T, protocol int) {
 // This test checks that pending transactions are sent.
 func TestSendTransactions62(t *testing.T) { testSendTransactions(t, 62) }
 func TestSendTransactions63(t *testing.T) { testSendTransactions(t, 63) }
+func TestSendTransactions64(t *testing.T) { testSendTransactions(t, 64) }
 
 func testSendTransactions(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
@@ -178,6 +181,36 @@ func testSendTransactions(t *testing.T, protocol int) {
 	wg.Wait()
 }
 
+// Tests that DPoS pre-commits are only accepted from eth/64 peers, older ones
+// not knowing the message.
+func TestRecvPreCommit63(t *testing.T) { testRecvPreCommit(t, 63) }
+func TestRecvPreCommit64(t *testing.T) { testRecvPreCommit(t, 64) }
+
+func testRecvPreCommit(t *testing.T, protocol int) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	p, errc := newTestPeer("peer", protocol, pm, true)
+	defer pm.Stop()
+	defer p.close()
+
+	vote := &types.PreCommit{Number: 1, Hash: common.Hash{0x01}}
+	vote.Signature, _ = crypto.Sign(vote.SigHash().Bytes(), testAccount)
+	if err := p2p.Send(p.app, PreCommitMsg, vote); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	select {
+	case err := <-errc:
+		if protocol >= eth64 {
+			t.Errorf("protocol returned error %v, want none", err)
+		} else if want := errResp(ErrInvalidMsgCode, "%v", PreCommitMsg); err == nil || err.Error() != want.Error() {
+			t.Errorf("wrong error: got %v, want %q", err, want)
+		}
+	case <-time.After(200 * time.Millisecond):
+		if protocol < eth64 {
+			t.Errorf("protocol did not shut down within 200 milliseconds")
+		}
+	}
+}
+
 // Tests that the custom union field encoder and decoder works correctly.
 func TestGetBlockHeadersDataEncodeDecode(t *testing.T) {
 	// Create a "random" hash for testing
@@ -0,0 +1,207 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/common/hexutil"
+	"github.com/haxicode/go-ethereum/consensus/dpos"
+)
+
+// DPoS State Access
+
+// DposValidators returns the validators elected for the epoch of the given block.
+// The latest known block is used if blockNumber is nil.
+func (ec *Client) DposValidators(ctx context.Context, blockNumber *big.Int) ([]common.Address, error) {
+	var result []common.Address
+	err := ec.c.CallContext(ctx, &result, "dpos_getValidators", toBlockNumArg(blockNumber))
+	return result, err
+}
+
+// DposValidatorsAtHash returns the validators elected for the epoch of the given block.
+func (ec *Client) DposValidatorsAtHash(ctx context.Context, hash common.Hash) ([]common.Address, error) {
+	var result []common.Address
+	err := ec.c.CallContext(ctx, &result, "dpos_getValidatorsAtHash", hash)
+	return result, err
+}
+
+// DposConfirmedBlockNumber returns the number of the latest irreversible block.
+func (ec *Client) DposConfirmedBlockNumber(ctx context.Context) (*big.Int, error) {
+	var result hexutil.Big
+	err := ec.c.CallContext(ctx, &result, "dpos_getConfirmedBlockNumber")
+	return (*big.Int)(&result), err
+}
+
+// DposFinalizedBlock returns the latest block with a quorum certificate.
+func (ec *Client) DposFinalizedBlock(ctx context.Context) (*dpos.FinalizedBlock, error) {
+	var result *dpos.FinalizedBlock
+	err := ec.c.CallContext(ctx, &result, "dpos_getFinalizedBlock")
+	return result, err
+}
+
+// DposCandidates returns the registered candidates at the given block.
+// The latest known block is used if blockNumber is nil.
+func (ec *Client) DposCandidates(ctx context.Context, blockNumber *big.Int) ([]common.Address, error) {
+	var result []common.Address
+	err := ec.c.CallContext(ctx, &result, "dpos_getCandidates", toBlockNumArg(blockNumber))
+	return result, err
+}
+
+// DposCandidatesAtHash returns the registered candidates at the given block.
+func (ec *Client) DposCandidatesAtHash(ctx context.Context, hash common.Hash) ([]common.Address, error) {
+	var result []common.Address
+	err := ec.c.CallContext(ctx, &result, "dpos_getCandidatesAtHash", hash)
+	return result, err
+}
+
+// DposDelegators returns the delegators voting for the candidate at the given block.
+// The latest known block is used if blockNumber is nil.
+func (ec *Client) DposDelegators(ctx context.Context, candidate common.Address, blockNumber *big.Int) ([]common.Address, error) {
+	var result []common.Address
+	err := ec.c.CallContext(ctx, &result, "dpos_getDelegators", candidate, toBlockNumArg(blockNumber))
+	return result, err
+}
+
+// DposDelegatorsAtHash returns the delegators voting for the candidate at the given block.
+func (ec *Client) DposDelegatorsAtHash(ctx context.Context, candidate common.Address, hash common.Hash) ([]common.Address, error) {
+	var result []common.Address
+	err := ec.c.CallContext(ctx, &result, "dpos_getDelegatorsAtHash", candidate, hash)
+	return result, err
+}
+
+// DposVote returns the candidate the delegator votes for at the given block, nil
+// if it doesn't vote. The latest known block is used if blockNumber is nil.
+func (ec *Client) DposVote(ctx context.Context, delegator common.Address, blockNumber *big.Int) (*common.Address, error) {
+	var result *common.Address
+	err := ec.c.CallContext(ctx, &result, "dpos_getVote", delegator, toBlockNumArg(blockNumber))
+	return result, err
+}
+
+// DposVoteAtHash returns the candidate the delegator votes for at the given block,
+// nil if it doesn't vote.
+func (ec *Client) DposVoteAtHash(ctx context.Context, delegator common.Address, hash common.Hash) (*common.Address, error) {
+	var result *common.Address
+	err := ec.c.CallContext(ctx, &result, "dpos_getVoteAtHash", delegator, hash)
+	return result, err
+}
+
+// DposVoteTally returns the votes of every candidate at the given block.
+// The latest known block is used if blockNumber is nil.
+func (ec *Client) DposVoteTally(ctx context.Context, blockNumber *big.Int) (map[common.Address]*big.Int, error) {
+	var result map[common.Address]*hexutil.Big
+	err := ec.c.CallContext(ctx, &result, "dpos_getVoteTally", toBlockNumArg(blockNumber))
+	return toBigMap(result), err
+}
+
+// DposVoteTallyAtHash returns the votes of every candidate at the given block.
+func (ec *Client) DposVoteTallyAtHash(ctx context.Context, hash common.Hash) (map[common.Address]*big.Int, error) {
+	var result map[common.Address]*hexutil.Big
+	err := ec.c.CallContext(ctx, &result, "dpos_getVoteTallyAtHash", hash)
+	return toBigMap(result), err
+}
+
+// DposMintCounts returns the number of blocks minted by each validator in the
+// epoch, the epoch of the given block if epoch is nil. The latest known block is
+// used if blockNumber is nil.
+func (ec *Client) DposMintCounts(ctx context.Context, epoch *uint64, blockNumber *big.Int) (map[common.Address]uint64, error) {
+	var result map[common.Address]hexutil.Uint64
+	err := ec.c.CallContext(ctx, &result, "dpos_getMintCounts", toEpochArg(epoch), toBlockNumArg(blockNumber))
+	return toUint64Map(result), err
+}
+
+// DposMintCountsAtHash returns the number of blocks minted by each validator in
+// the epoch, the epoch of the given block if epoch is nil.
+func (ec *Client) DposMintCountsAtHash(ctx context.Context, epoch *uint64, hash common.Hash) (map[common.Address]uint64, error) {
+	var result map[common.Address]hexutil.Uint64
+	err := ec.c.CallContext(ctx, &result, "dpos_getMintCountsAtHash", toEpochArg(epoch), hash)
+	return toUint64Map(result), err
+}
+
+// DposValidatorStats returns the blocks produced, the slots missed and the
+// uptime of each validator in the epoch, the epoch of the given block if epoch
+// is nil. The latest known block is used if blockNumber is nil.
+func (ec *Client) DposValidatorStats(ctx context.Context, epoch *uint64, blockNumber *big.Int) (map[common.Address]*dpos.ValidatorStats, error) {
+	var result map[common.Address]*dpos.ValidatorStats
+	err := ec.c.CallContext(ctx, &result, "dpos_getValidatorStats", toEpochArg(epoch), toBlockNumArg(blockNumber))
+	return result, err
+}
+
+// DposValidatorStatsAtHash returns the blocks produced, the slots missed and the
+// uptime of each validator in the epoch, the epoch of the given block if epoch
+// is nil.
+func (ec *Client) DposValidatorStatsAtHash(ctx context.Context, epoch *uint64, hash common.Hash) (map[common.Address]*dpos.ValidatorStats, error) {
+	var result map[common.Address]*dpos.ValidatorStats
+	err := ec.c.CallContext(ctx, &result, "dpos_getValidatorStatsAtHash", toEpochArg(epoch), hash)
+	return result, err
+}
+
+// DposEpoch returns the epoch and slot schedule following the given block.
+// The latest known block is used if blockNumber is nil.
+func (ec *Client) DposEpoch(ctx context.Context, blockNumber *big.Int) (*dpos.EpochInfo, error) {
+	var result *dpos.EpochInfo
+	err := ec.c.CallContext(ctx, &result, "dpos_getEpoch", toBlockNumArg(blockNumber))
+	return result, err
+}
+
+// DposEpochAtHash returns the epoch and slot schedule following the given block.
+func (ec *Client) DposEpochAtHash(ctx context.Context, hash common.Hash) (*dpos.EpochInfo, error) {
+	var result *dpos.EpochInfo
+	err := ec.c.CallContext(ctx, &result, "dpos_getEpochAtHash", hash)
+	return result, err
+}
+
+// DposRewards returns the block rewards accrued and withdrawn by the delegator at
+// the given block. The latest known block is used if blockNumber is nil.
+func (ec *Client) DposRewards(ctx context.Context, delegator common.Address, blockNumber *big.Int) (*dpos.Rewards, error) {
+	var result *dpos.Rewards
+	err := ec.c.CallContext(ctx, &result, "dpos_getRewards", delegator, toBlockNumArg(blockNumber))
+	return result, err
+}
+
+// DposRewardsAtHash returns the block rewards accrued and withdrawn by the
+// delegator at the given block.
+func (ec *Client) DposRewardsAtHash(ctx context.Context, delegator common.Address, hash common.Hash) (*dpos.Rewards, error) {
+	var result *dpos.Rewards
+	err := ec.c.CallContext(ctx, &result, "dpos_getRewardsAtHash", delegator, hash)
+	return result, err
+}
+
+func toEpochArg(epoch *uint64) *hexutil.Uint64 {
+	if epoch == nil {
+		return nil
+	}
+	return (*hexutil.Uint64)(epoch)
+}
+
+func toBigMap(m map[common.Address]*hexutil.Big) map[common.Address]*big.Int {
+	result := make(map[common.Address]*big.Int, len(m))
+	for addr, v := range m {
+		result[addr] = (*big.Int)(v)
+	}
+	return result
+}
+
+func toUint64Map(m map[common.Address]hexutil.Uint64) map[common.Address]uint64 {
+	result := make(map[common.Address]uint64, len(m))
+	for addr, v := range m {
+		result[addr] = uint64(v)
+	}
+	return result
+}
@@ -473,6 +473,7 @@ type blockStats struct {
 	ParentHash common.Hash    `json:"parentHash"`
 	Timestamp  *big.Int       `json:"timestamp"`
 	Miner      common.Address `json:"miner"`
+	MinerName  string         `json:"minerName,omitempty"`
 	GasUsed    uint64         `json:"gasUsed"`
 	GasLimit   uint64         `json:"gasLimit"`
 	Diff       string         `json:"difficulty"`
@@ -559,6 +560,7 @@ func (s *Service) assembleBlockStats(block *types.Block) *blockStats {
 		ParentHash: header.ParentHash,
 		Timestamp:  header.Time,
 		Miner:      author,
+		MinerName:  s.candidateName(header, author),
 		GasUsed:    header.GasUsed,
 		GasLimit:   header.GasLimit,
 		Diff:       header.Difficulty.String(),
@@ -570,6 +572,23 @@ func (s *Service) assembleBlockStats(block *types.Block) *blockStats {
 	}
 }
 
+// candidateName retrieves the name the author of the block registered as a
+// DPoS candidate, if any. Light nodes don't have the DPoS state to look it up.
+func (s *Service) candidateName(header *types.Header, author common.Address) string {
+	if s.eth == nil || header.DposContext == nil {
+		return ""
+	}
+	dposContext, err := types.NewDposContextFromProto(s.eth.BlockChain().StateCache().TrieDB(), header.DposContext)
+	if err != nil {
+		return ""
+	}
+	info, err := dposContext.CandidateInfo(author)
+	if err != nil {
+		return ""
+	}
+	return info.Name
+}
+
 // reportHistory retrieves the most recent batch of blocks and reports it to the
 // stats server.
 func (s *Service) reportHistory(conn *websocket.Conn, list []uint64) error {
@@ -1091,6 +1091,10 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
+	// Failed DPoS transactions record why they were rejected
+	if receipt.Reason != "" {
+		fields["reason"] = receipt.Reason
+	}
 	return fields, nil
 }
 
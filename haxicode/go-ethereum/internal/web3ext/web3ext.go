@@ -75,12 +75,144 @@ web3._extend({
 			params: 1,
 			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'getValidatorsAtHash',
+			call: 'dpos_getValidatorsAtHash',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'getConfirmedBlockNumber',
 			call: 'dpos_getConfirmedBlockNumber',
 			params: 0,
 			outputFormatter: web3._extend.utils.toBigNumber
 		}),
+		new web3._extend.Method({
+			name: 'getFinalizedBlock',
+			call: 'dpos_getFinalizedBlock',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'getCheckpoint',
+			call: 'dpos_getCheckpoint',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getCandidates',
+			call: 'dpos_getCandidates',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getCandidatesAtHash',
+			call: 'dpos_getCandidatesAtHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getDelegators',
+			call: 'dpos_getDelegators',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getDelegatorsAtHash',
+			call: 'dpos_getDelegatorsAtHash',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'getVote',
+			call: 'dpos_getVote',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getVoteAtHash',
+			call: 'dpos_getVoteAtHash',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'getVoteTally',
+			call: 'dpos_getVoteTally',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getVoteTallyAtHash',
+			call: 'dpos_getVoteTallyAtHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getMintCounts',
+			call: 'dpos_getMintCounts',
+			params: 2,
+			inputFormatter: [function(epoch) { return epoch == null ? null : web3._extend.utils.fromDecimal(epoch); }, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getMintCountsAtHash',
+			call: 'dpos_getMintCountsAtHash',
+			params: 2,
+			inputFormatter: [function(epoch) { return epoch == null ? null : web3._extend.utils.fromDecimal(epoch); }, null]
+		}),
+		new web3._extend.Method({
+			name: 'getValidatorStats',
+			call: 'dpos_getValidatorStats',
+			params: 2,
+			inputFormatter: [function(epoch) { return epoch == null ? null : web3._extend.utils.fromDecimal(epoch); }, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getValidatorStatsAtHash',
+			call: 'dpos_getValidatorStatsAtHash',
+			params: 2,
+			inputFormatter: [function(epoch) { return epoch == null ? null : web3._extend.utils.fromDecimal(epoch); }, null]
+		}),
+		new web3._extend.Method({
+			name: 'getEpoch',
+			call: 'dpos_getEpoch',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getEpochAtHash',
+			call: 'dpos_getEpochAtHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getRewards',
+			call: 'dpos_getRewards',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getRewardsAtHash',
+			call: 'dpos_getRewardsAtHash',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'getCandidateInfo',
+			call: 'dpos_getCandidateInfo',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getCandidateInfoAtHash',
+			call: 'dpos_getCandidateInfoAtHash',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'getParams',
+			call: 'dpos_getParams',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getParamsAtHash',
+			call: 'dpos_getParamsAtHash',
+			params: 1
+		}),
 	]
 });
 `
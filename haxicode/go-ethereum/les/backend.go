@@ -26,7 +26,6 @@ import (
 	"github.com/haxicode/go-ethereum/common"
 	"github.com/haxicode/go-ethereum/common/hexutil"
 	"github.com/haxicode/go-ethereum/consensus"
-	"github.com/haxicode/go-ethereum/consensus/dpos"
 	"github.com/haxicode/go-ethereum/core"
 	"github.com/haxicode/go-ethereum/core/bloombits"
 	"github.com/haxicode/go-ethereum/core/rawdb"
@@ -102,7 +101,7 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 		peers:          peers,
 		reqDist:        newRequestDistributor(peers, quitSync),
 		accountManager: ctx.AccountManager,
-		engine:           dpos.New(chainConfig.Dpos, chainDb),
+		engine:           eth.CreateConsensusEngine(ctx, chainConfig, &config.Ethash, nil, chainDb),
 		shutdownChan:   make(chan bool),
 		networkId:      config.NetworkId,
 		bloomRequests:  make(chan chan *bloombits.Retrieval),
@@ -18,6 +18,7 @@
 package les
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -73,6 +74,7 @@ type BlockChain interface {
 	GetHeader(hash common.Hash, number uint64) *types.Header
 	GetHeaderByHash(hash common.Hash) *types.Header
 	CurrentHeader() *types.Header
+	IrreversibleHeader() *types.Header
 	GetTd(hash common.Hash, number uint64) *big.Int
 	State() (*state.StateDB, error)
 	InsertHeaderChain(chain []*types.Header, checkFreq int) (int, error)
@@ -771,6 +773,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			lastBHash common.Hash
 			statedb   *state.StateDB
 			root      common.Hash
+			dposProto *types.DposContextProto
 		)
 		reqCnt := len(req.Reqs)
 		if reject(uint64(reqCnt), MaxProofsFetch) {
@@ -782,18 +785,34 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		for _, req := range req.Reqs {
 			// Look up the state belonging to the request
 			if statedb == nil || req.BHash != lastBHash {
-				statedb, root, lastBHash = nil, common.Hash{}, req.BHash
+				statedb, root, dposProto, lastBHash = nil, common.Hash{}, nil, req.BHash
 
 				if number := rawdb.ReadHeaderNumber(pm.chainDb, req.BHash); number != nil {
 					if header := rawdb.ReadHeader(pm.chainDb, req.BHash, *number); header != nil {
 						statedb, _ = pm.blockchain.State()
 						root = header.Root
+						dposProto = header.DposContext
 					}
 				}
 			}
 			if statedb == nil {
 				continue
 			}
+			// Requests for the DPoS epoch trie carry a marker instead of an account key
+			if bytes.Equal(req.AccKey, light.DposEpochTrieKey) {
+				if dposProto == nil {
+					continue
+				}
+				epochTrie, err := types.NewEpochTrie(dposProto.EpochHash, statedb.Database().TrieDB())
+				if err != nil {
+					continue
+				}
+				epochTrie.Prove(req.Key, req.FromLevel, nodes)
+				if nodes.DataSize() >= softResponseLimit {
+					break
+				}
+				continue
+			}
 			// Pull the account or storage trie of the request
 			var trie state.Trie
 			if len(req.AccKey) > 0 {
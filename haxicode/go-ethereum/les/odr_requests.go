@@ -64,6 +64,8 @@ func LesRequest(req light.OdrRequest) LesOdrRequest {
 		return (*TrieRequest)(r)
 	case *light.CodeRequest:
 		return (*CodeRequest)(r)
+	case *light.DposValidatorsRequest:
+		return (*DposValidatorsRequest)(r)
 	case *light.ChtRequest:
 		return (*ChtRequest)(r)
 	case *light.BloomRequest:
@@ -309,6 +311,70 @@ func (r *CodeRequest) Validate(db ethdb.Database, msg *Msg) error {
 	return nil
 }
 
+// ODR request type for the DPoS validator set of a block, see LesOdrRequest
+// interface. The set and its signing keys are proven from the epoch trie of the
+// block with proof requests that carry light.DposEpochTrieKey instead of an
+// account key.
+type DposValidatorsRequest light.DposValidatorsRequest
+
+// GetCost returns the cost of the given ODR request according to the serving
+// peer's cost table (implementation of LesOdrRequest)
+func (r *DposValidatorsRequest) GetCost(peer *peer) uint64 {
+	return peer.GetRequestCost(GetProofsV2Msg, 2)
+}
+
+// CanSend tells if a certain peer is suitable for serving the given request
+func (r *DposValidatorsRequest) CanSend(peer *peer) bool {
+	return peer.version >= lpv2 && peer.HasBlock(r.Header.Hash(), r.Header.Number.Uint64())
+}
+
+// Request sends an ODR request to the LES network (implementation of LesOdrRequest)
+func (r *DposValidatorsRequest) Request(reqID uint64, peer *peer) error {
+	peer.Log().Debug("Requesting dpos validators", "number", r.Header.Number, "hash", r.Header.Hash())
+	reqs := []ProofReq{
+		{BHash: r.Header.Hash(), AccKey: light.DposEpochTrieKey, Key: types.ValidatorsProofKey()},
+		{BHash: r.Header.Hash(), AccKey: light.DposEpochTrieKey, Key: types.SignersProofKey()},
+	}
+	return peer.RequestProofs(reqID, r.GetCost(peer), reqs)
+}
+
+// Valid processes an ODR request reply message from the LES network
+// returns true and stores results in memory if the message was a valid reply
+// to the request (implementation of LesOdrRequest)
+func (r *DposValidatorsRequest) Validate(db ethdb.Database, msg *Msg) error {
+	log.Debug("Validating dpos validators", "number", r.Header.Number, "hash", r.Header.Hash())
+
+	if msg.MsgType != MsgProofsV2 {
+		return errInvalidMessageType
+	}
+	if r.Header.DposContext == nil {
+		return light.ErrNoDposContext
+	}
+	proofs := msg.Obj.(light.NodeList)
+	// Verify the proof against the epoch root committed to by the header
+	nodeSet := proofs.NodeSet()
+	reads := &readTraceDB{db: nodeSet}
+	value, _, err := trie.VerifyProof(r.Header.DposContext.EpochHash, types.ValidatorsProofKey(), reads)
+	if err != nil {
+		return fmt.Errorf("merkle proof verification failed: %v", err)
+	}
+	// The signing keys are missing from the epoch trie unless one was registered
+	signers, _, err := trie.VerifyProof(r.Header.DposContext.EpochHash, types.SignersProofKey(), reads)
+	if err != nil {
+		return fmt.Errorf("merkle proof verification failed: %v", err)
+	}
+	if len(reads.reads) != nodeSet.KeyCount() {
+		return errUselessNodes
+	}
+	validators, signerKeys, err := types.DecodeValidatorSigners(value, signers)
+	if err != nil {
+		return err
+	}
+	r.Validators, r.Signers = validators, signerKeys
+	r.Proof = nodeSet
+	return nil
+}
+
 const (
 	// helper trie type constants
 	htCanonical = iota // Canonical hash trie
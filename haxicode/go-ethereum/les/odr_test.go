@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"context"
 	"math/big"
+	"reflect"
 	"testing"
 	"time"
 
@@ -35,6 +36,7 @@ import (
 	"github.com/haxicode/go-ethereum/light"
 	"github.com/haxicode/go-ethereum/params"
 	"github.com/haxicode/go-ethereum/rlp"
+	"github.com/haxicode/go-ethereum/trie"
 )
 
 type odrTestFn func(ctx context.Context, db ethdb.Database, config *params.ChainConfig, bc *core.BlockChain, lc *light.LightChain, bhash common.Hash) []byte
@@ -160,6 +162,78 @@ func odrContractCall(ctx context.Context, db ethdb.Database, config *params.Chai
 	return res
 }
 
+func TestOdrDposValidatorsLes2(t *testing.T) {
+	// Assemble the test environment
+	peers := newPeerSet()
+	dist := newRequestDistributor(peers, make(chan struct{}))
+	rm := newRetrieveManager(peers, dist, nil)
+	db := ethdb.NewMemDatabase()
+	ldb := ethdb.NewMemDatabase()
+	odr := NewLesOdr(ldb, rm)
+	pm := newTestProtocolManagerMust(t, false, 0, nil, nil, nil, db)
+	lpm := newTestProtocolManagerMust(t, true, 0, nil, peers, odr, ldb)
+	_, err1, lpeer, err2 := newTestPeerPair("peer", 2, pm, lpm)
+	select {
+	case <-time.After(time.Millisecond * 100):
+	case err := <-err1:
+		t.Fatalf("peer 1 handshake error: %v", err)
+	case err := <-err2:
+		t.Fatalf("peer 1 handshake error: %v", err)
+	}
+	lpeer.lock.Lock()
+	lpeer.hasBlock = func(common.Hash, uint64) bool { return true }
+	lpeer.lock.Unlock()
+
+	// Record a validator set with a separate signing key in the epoch trie of a
+	// block only the server knows
+	signer := common.HexToAddress("1234567812345678123456781234567812345678")
+	triedb := trie.NewDatabase(db)
+	dposContext, err := types.NewDposContext(triedb)
+	if err != nil {
+		t.Fatalf("failed to create dpos context: %v", err)
+	}
+	if err := dposContext.SetSigningKey(acc2Addr, signer, 0); err != nil {
+		t.Fatalf("failed to set signing key: %v", err)
+	}
+	if err := dposContext.SetValidators([]common.Address{acc1Addr, acc2Addr}); err != nil {
+		t.Fatalf("failed to set validators: %v", err)
+	}
+	dposProto, err := dposContext.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit dpos context: %v", err)
+	}
+	if err := triedb.Commit(dposProto.EpochHash, false); err != nil {
+		t.Fatalf("failed to commit epoch trie: %v", err)
+	}
+	header := types.CopyHeader(pm.blockchain.CurrentHeader())
+	header.Extra = []byte("validators")
+	header.DposContext = dposProto
+	rawdb.WriteHeader(db, header)
+
+	test := func(name string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		validators, signers, err := light.GetDposValidators(ctx, lpm.blockchain.(*light.LightChain).Odr(), header)
+		if err != nil {
+			t.Fatalf("%s: failed to retrieve validators: %v", name, err)
+		}
+		if want := []common.Address{acc1Addr, acc2Addr}; !reflect.DeepEqual(validators, want) {
+			t.Errorf("%s: validator mismatch: have %x, want %x", name, validators, want)
+		}
+		if want := []common.Address{acc1Addr, signer}; !reflect.DeepEqual(signers, want) {
+			t.Errorf("%s: signing key mismatch: have %x, want %x", name, signers, want)
+		}
+	}
+	// The validators are proven by the server
+	test("odr")
+
+	// The proof is kept locally, so no peer is needed anymore
+	peers.Unregister(lpeer.id)
+	time.Sleep(time.Millisecond * 10) // ensure that all peerSetNotify callbacks are executed
+	test("local")
+}
+
 func testOdr(t *testing.T, protocol int, expFail uint64, fn odrTestFn) {
 	// Assemble the test environment
 	peers := newPeerSet()
@@ -26,6 +26,7 @@ import (
 
 	"github.com/haxicode/go-ethereum/common"
 	"github.com/haxicode/go-ethereum/consensus"
+	"github.com/haxicode/go-ethereum/consensus/dpos"
 	"github.com/haxicode/go-ethereum/core"
 	"github.com/haxicode/go-ethereum/core/rawdb"
 	"github.com/haxicode/go-ethereum/core/state"
@@ -39,8 +40,11 @@ import (
 )
 
 var (
-	bodyCacheLimit  = 256
-	blockCacheLimit = 256
+	bodyCacheLimit       = 256
+	blockCacheLimit      = 256
+	validatorsCacheLimit = 64
+
+	validatorsRetrievalTimeout = 10 * time.Second
 )
 
 // LightChain represents a canonical chain that by default only handles block
@@ -63,6 +67,8 @@ type LightChain struct {
 	bodyRLPCache *lru.Cache // Cache for the most recent block bodies in RLP encoded format
 	blockCache   *lru.Cache // Cache for the most recent entire blocks
 
+	validatorsCache *lru.Cache // Cache for the DPoS validator sets, keyed by epoch trie root
+
 	quit    chan struct{}
 	running int32 // running must be called automically
 	// procInterrupt must be atomically called
@@ -79,15 +85,17 @@ func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.
 	bodyCache, _ := lru.New(bodyCacheLimit)
 	bodyRLPCache, _ := lru.New(bodyCacheLimit)
 	blockCache, _ := lru.New(blockCacheLimit)
+	validatorsCache, _ := lru.New(validatorsCacheLimit)
 
 	bc := &LightChain{
-		chainDb:      odr.Database(),
-		odr:          odr,
-		quit:         make(chan struct{}),
-		bodyCache:    bodyCache,
-		bodyRLPCache: bodyRLPCache,
-		blockCache:   blockCache,
-		engine:       engine,
+		chainDb:         odr.Database(),
+		odr:             odr,
+		quit:            make(chan struct{}),
+		bodyCache:       bodyCache,
+		bodyRLPCache:    bodyRLPCache,
+		blockCache:      blockCache,
+		validatorsCache: validatorsCache,
+		engine:          engine,
 	}
 	var err error
 	bc.hc, err = core.NewHeaderChain(odr.Database(), config, bc.engine, bc.getProcInterrupt)
@@ -355,6 +363,9 @@ func (self *LightChain) InsertHeaderChain(chain []*types.Header, checkFreq int)
 	if i, err := self.hc.ValidateHeaderChain(chain, checkFreq); err != nil {
 		return i, err
 	}
+	if i, err := self.verifyDposSeals(chain); err != nil {
+		return i, err
+	}
 
 	// Make sure only one thread manipulates the chain at once
 	self.chainmu.Lock()
@@ -388,12 +399,96 @@ func (self *LightChain) InsertHeaderChain(chain []*types.Header, checkFreq int)
 	return i, err
 }
 
+// verifyDposSeals checks that each header of a DPoS chain was signed by the
+// validator scheduled for its slot, and kept the DPoS params of its parent
+// unless they may change. Header validation does not cover seals, and the
+// validator sets are not available locally, so they are retrieved on demand
+// from the epoch tries of the parents.
+func (self *LightChain) verifyDposSeals(chain []*types.Header) (int, error) {
+	engine, ok := self.engine.(*dpos.Dpos)
+	if !ok {
+		return 0, nil
+	}
+	for i, header := range chain {
+		var parent *types.Header
+		if i > 0 {
+			parent = chain[i-1]
+		} else if number := header.Number.Uint64(); number > 0 {
+			parent = self.GetHeader(header.ParentHash, number-1)
+		}
+		if parent == nil {
+			return i, consensus.ErrUnknownAncestor
+		}
+		// The grandparent is missing right after a trusted checkpoint
+		var grandparent *types.Header
+		if i > 1 {
+			grandparent = chain[i-2]
+		} else if number := parent.Number.Uint64(); number > 0 {
+			grandparent = self.GetHeader(parent.ParentHash, number-1)
+		}
+		if err := engine.VerifyParamsChange(grandparent, parent, header); err != nil {
+			return i, err
+		}
+		validators, signers, err := self.dposValidators(parent)
+		if err != nil {
+			return i, err
+		}
+		if err := engine.VerifySealWithValidators(header, validators, signers); err != nil {
+			return i, err
+		}
+	}
+	return 0, nil
+}
+
+// dposValidatorSet is a validator set and their signing keys as cached by the
+// light chain.
+type dposValidatorSet struct {
+	validators []common.Address
+	signers    []common.Address
+}
+
+// dposValidators returns the validator set and their signing keys recorded in
+// the epoch trie of a header. The epoch trie root only changes when an election
+// picks a new set or a validator rotates its key, so caching by root holds
+// about one entry per epoch.
+func (self *LightChain) dposValidators(header *types.Header) ([]common.Address, []common.Address, error) {
+	if header.DposContext == nil {
+		return nil, nil, ErrNoDposContext
+	}
+	root := header.DposContext.EpochHash
+	if cached, ok := self.validatorsCache.Get(root); ok {
+		set := cached.(*dposValidatorSet)
+		return set.validators, set.signers, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), validatorsRetrievalTimeout)
+	defer cancel()
+
+	validators, signers, err := GetDposValidators(ctx, self.odr, header)
+	if err != nil {
+		return nil, nil, err
+	}
+	self.validatorsCache.Add(root, &dposValidatorSet{validators: validators, signers: signers})
+	return validators, signers, nil
+}
+
 // CurrentHeader retrieves the current head header of the canonical chain. The
 // header is retrieved from the HeaderChain's internal cache.
 func (self *LightChain) CurrentHeader() *types.Header {
 	return self.hc.CurrentHeader()
 }
 
+// FinalizedHeader retrieves the latest header with a quorum certificate, nil if
+// no block has been finalized yet.
+func (self *LightChain) FinalizedHeader() *types.Header {
+	return self.hc.FinalizedHeader()
+}
+
+// IrreversibleHeader retrieves the latest header the consensus engine won't
+// revert any more, nil if there is none yet.
+func (self *LightChain) IrreversibleHeader() *types.Header {
+	return self.hc.IrreversibleHeader()
+}
+
 // GetTd retrieves a block's total difficulty in the canonical chain from the
 // database by hash and number, caching it if found.
 func (self *LightChain) GetTd(hash common.Hash, number uint64) *big.Int {
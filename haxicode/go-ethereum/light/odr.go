@@ -37,6 +37,10 @@ var NoOdr = context.Background()
 // ErrNoPeers is returned if no peers capable of serving a queued request are available
 var ErrNoPeers = errors.New("no suitable peers available")
 
+// ErrNoDposContext is returned if DPoS state is requested for a header without
+// a DPoS context
+var ErrNoDposContext = errors.New("header has no dpos context")
+
 // OdrBackend is an interface to a backend service that handles ODR retrievals type
 type OdrBackend interface {
 	Database() ethdb.Database
@@ -174,3 +178,22 @@ func (req *BloomRequest) StoreResult(db ethdb.Database) {
 		rawdb.WriteBloomBits(db, req.BitIdx, sectionIdx, sectionHead, req.BloomBits[i])
 	}
 }
+
+// DposEpochTrieKey is sent in place of an account key to request proofs from
+// the DPoS epoch trie of a block instead of one of its storage tries.
+var DposEpochTrieKey = []byte("dpos-epoch")
+
+// DposValidatorsRequest is the ODR request type for retrieving the DPoS
+// validator set and their signing keys recorded in the epoch trie of a block
+type DposValidatorsRequest struct {
+	OdrRequest
+	Header     *types.Header
+	Validators []common.Address
+	Signers    []common.Address
+	Proof      *NodeSet
+}
+
+// StoreResult stores the retrieved data in local database
+func (req *DposValidatorsRequest) StoreResult(db ethdb.Database) {
+	req.Proof.Store(db)
+}
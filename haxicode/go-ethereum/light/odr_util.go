@@ -26,6 +26,7 @@ import (
 	"github.com/haxicode/go-ethereum/core/types"
 	"github.com/haxicode/go-ethereum/crypto"
 	"github.com/haxicode/go-ethereum/rlp"
+	"github.com/haxicode/go-ethereum/trie"
 )
 
 var sha3_nil = crypto.Keccak256Hash(nil)
@@ -94,6 +95,27 @@ func GetBodyRLP(ctx context.Context, odr OdrBackend, hash common.Hash, number ui
 	}
 }
 
+// GetDposValidators retrieves the DPoS validator set and their signing keys
+// recorded in the epoch trie of a block, fetching a Merkle proof of them if the
+// trie is not available locally.
+func GetDposValidators(ctx context.Context, odr OdrBackend, header *types.Header) ([]common.Address, []common.Address, error) {
+	if header.DposContext == nil {
+		return nil, nil, ErrNoDposContext
+	}
+	if epochTrie, err := trie.New(header.DposContext.EpochHash, trie.NewDatabase(odr.Database())); err == nil {
+		if data, err := epochTrie.TryGet(types.ValidatorsProofKey()); err == nil && data != nil {
+			if signersData, err := epochTrie.TryGet(types.SignersProofKey()); err == nil {
+				return types.DecodeValidatorSigners(data, signersData)
+			}
+		}
+	}
+	r := &DposValidatorsRequest{Header: header}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return nil, nil, err
+	}
+	return r.Validators, r.Signers, nil
+}
+
 // GetBody retrieves the block body (transactons, uncles) corresponding to the
 // hash.
 func GetBody(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64) (*types.Body, error) {
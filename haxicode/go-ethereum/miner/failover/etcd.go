@@ -0,0 +1,214 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package failover
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// etcdTimeout bounds a single request to the etcd endpoint.
+const etcdTimeout = 3 * time.Second
+
+var errEmptyKey = errors.New("empty etcd lock key")
+
+// EtcdLock is a Locker backed by a key bound to a lease in etcd, spoken to
+// through the JSON gateway of its v3 API. The key is created only if missing,
+// holding the node id, and disappears when the lease expires.
+type EtcdLock struct {
+	endpoint string
+	key      []byte
+	id       []byte // Value identifying the local node in the key
+	client   *http.Client
+
+	mu    sync.Mutex
+	lease int64 // Lease the key is bound to, zero if none
+}
+
+// NewEtcdLock creates a lock on the key at the etcd endpoint, identifying the
+// local node with a random id.
+func NewEtcdLock(endpoint string, key string) (*EtcdLock, error) {
+	if key == "" {
+		return nil, errEmptyKey
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return &EtcdLock{
+		endpoint: endpoint,
+		key:      []byte(key),
+		id:       []byte(hex.EncodeToString(id)),
+		client:   &http.Client{Timeout: etcdTimeout},
+	}, nil
+}
+
+// etcdInt is an int64 of the JSON gateway, encoded as a string but accepted
+// as a number too.
+type etcdInt int64
+
+func (i etcdInt) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(strconv.FormatInt(int64(i), 10))), nil
+}
+
+func (i *etcdInt) UnmarshalJSON(input []byte) error {
+	if s, err := strconv.Unquote(string(input)); err == nil {
+		input = []byte(s)
+	}
+	n, err := strconv.ParseInt(string(input), 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = etcdInt(n)
+	return nil
+}
+
+type etcdLease struct {
+	ID  etcdInt `json:"ID"`
+	TTL etcdInt `json:"TTL,omitempty"`
+}
+
+type etcdKeyValue struct {
+	Key   []byte  `json:"key"`
+	Value []byte  `json:"value,omitempty"`
+	Lease etcdInt `json:"lease,omitempty"`
+}
+
+type etcdCompare struct {
+	Key            []byte  `json:"key"`
+	Target         string  `json:"target"`
+	Result         string  `json:"result"`
+	CreateRevision etcdInt `json:"create_revision"`
+}
+
+type etcdRequestOp struct {
+	RequestPut   *etcdKeyValue `json:"request_put,omitempty"`
+	RequestRange *etcdKeyValue `json:"request_range,omitempty"`
+}
+
+type etcdTxn struct {
+	Compare []etcdCompare   `json:"compare"`
+	Success []etcdRequestOp `json:"success"`
+	Failure []etcdRequestOp `json:"failure"`
+}
+
+type etcdTxnResult struct {
+	Succeeded bool `json:"succeeded"`
+	Responses []struct {
+		ResponseRange *struct {
+			Kvs []etcdKeyValue `json:"kvs"`
+		} `json:"response_range"`
+	} `json:"responses"`
+}
+
+// TryLock implements Locker. It keeps the lease alive if it has one, grants a
+// new one otherwise, then creates the key bound to the lease unless another
+// node holds it.
+func (l *EtcdLock) TryLock(ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.lease != 0 {
+		var res struct {
+			Result etcdLease `json:"result"`
+		}
+		if err := l.call("/v3/lease/keepalive", etcdLease{ID: etcdInt(l.lease)}, &res); err != nil {
+			return false, err
+		}
+		if res.Result.TTL <= 0 {
+			l.lease = 0
+		}
+	}
+	if l.lease == 0 {
+		seconds := int64((ttl + time.Second - 1) / time.Second)
+		var res etcdLease
+		if err := l.call("/v3/lease/grant", etcdLease{TTL: etcdInt(seconds)}, &res); err != nil {
+			return false, err
+		}
+		l.lease = int64(res.ID)
+	}
+	txn := etcdTxn{
+		Compare: []etcdCompare{{Key: l.key, Target: "CREATE", Result: "EQUAL"}},
+		Success: []etcdRequestOp{{RequestPut: &etcdKeyValue{Key: l.key, Value: l.id, Lease: etcdInt(l.lease)}}},
+		Failure: []etcdRequestOp{{RequestRange: &etcdKeyValue{Key: l.key}}},
+	}
+	var res etcdTxnResult
+	if err := l.call("/v3/kv/txn", txn, &res); err != nil {
+		return false, err
+	}
+	if res.Succeeded {
+		return true, nil
+	}
+	for _, op := range res.Responses {
+		if op.ResponseRange == nil {
+			continue
+		}
+		for _, kv := range op.ResponseRange.Kvs {
+			if bytes.Equal(kv.Key, l.key) && bytes.Equal(kv.Value, l.id) && int64(kv.Lease) == l.lease {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// Unlock implements Locker. Revoking the lease deletes the key.
+func (l *EtcdLock) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.lease == 0 {
+		return nil
+	}
+	lease := l.lease
+	l.lease = 0
+	return l.call("/v3/lease/revoke", etcdLease{ID: etcdInt(lease)}, nil)
+}
+
+// call posts a request to the JSON gateway and decodes the response into res.
+func (l *EtcdLock) call(path string, req interface{}, res interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp, err := l.client.Post(l.endpoint+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("etcd %s: %s: %s", path, resp.Status, bytes.TrimSpace(data))
+	}
+	if res == nil {
+		return nil
+	}
+	return json.Unmarshal(data, res)
+}
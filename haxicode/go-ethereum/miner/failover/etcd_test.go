@@ -0,0 +1,158 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package failover
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeEtcd stands in for the JSON gateway of etcd, serving the lease and
+// transaction calls the lock makes. Leases expire when the test says so.
+type fakeEtcd struct {
+	mu        sync.Mutex
+	nextLease int64
+	leases    map[int64]bool
+	kvs       map[string]etcdKeyValue
+}
+
+func newFakeEtcd() *fakeEtcd {
+	return &fakeEtcd{leases: make(map[int64]bool), kvs: make(map[string]etcdKeyValue)}
+}
+
+// expire drops a lease and the keys bound to it.
+func (f *fakeEtcd) expire(lease int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.leases, lease)
+	for key, kv := range f.kvs {
+		if int64(kv.Lease) == lease {
+			delete(f.kvs, key)
+		}
+	}
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var res interface{}
+	switch r.URL.Path {
+	case "/v3/lease/grant":
+		var req etcdLease
+		json.NewDecoder(r.Body).Decode(&req)
+		f.nextLease++
+		f.leases[f.nextLease] = true
+		res = etcdLease{ID: etcdInt(f.nextLease), TTL: req.TTL}
+	case "/v3/lease/keepalive":
+		var req etcdLease
+		json.NewDecoder(r.Body).Decode(&req)
+		lease := etcdLease{ID: req.ID}
+		if f.leases[int64(req.ID)] {
+			lease.TTL = 10
+		}
+		res = map[string]interface{}{"result": lease}
+	case "/v3/lease/revoke":
+		var req etcdLease
+		json.NewDecoder(r.Body).Decode(&req)
+		if !f.leases[int64(req.ID)] {
+			http.Error(w, "lease not found", http.StatusNotFound)
+			return
+		}
+		delete(f.leases, int64(req.ID))
+		for key, kv := range f.kvs {
+			if kv.Lease == req.ID {
+				delete(f.kvs, key)
+			}
+		}
+		res = struct{}{}
+	case "/v3/kv/txn":
+		var req etcdTxn
+		json.NewDecoder(r.Body).Decode(&req)
+		key := string(req.Compare[0].Key)
+		if _, ok := f.kvs[key]; !ok {
+			f.kvs[key] = *req.Success[0].RequestPut
+			res = map[string]interface{}{"succeeded": true}
+		} else {
+			res = map[string]interface{}{"responses": []interface{}{
+				map[string]interface{}{"response_range": map[string]interface{}{"kvs": []etcdKeyValue{f.kvs[key]}}},
+			}}
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+func TestEtcdLock(t *testing.T) {
+	etcd := newFakeEtcd()
+	server := httptest.NewServer(etcd)
+	defer server.Close()
+
+	first, err := New(server.URL + "/validators/0x01")
+	assert.Nil(t, err)
+	second, err := New(server.URL + "/validators/0x01")
+	assert.Nil(t, err)
+
+	// Only one node gets the lock, renewing it keeps it
+	held, err := first.TryLock(time.Second)
+	assert.Nil(t, err)
+	assert.True(t, held)
+	held, err = second.TryLock(time.Second)
+	assert.Nil(t, err)
+	assert.False(t, held)
+	held, err = first.TryLock(time.Second)
+	assert.Nil(t, err)
+	assert.True(t, held)
+
+	// Once the lease of the leader expires, the other node takes over
+	etcd.expire(first.(*EtcdLock).lease)
+	held, err = second.TryLock(time.Second)
+	assert.Nil(t, err)
+	assert.True(t, held)
+	held, err = first.TryLock(time.Second)
+	assert.Nil(t, err)
+	assert.False(t, held)
+
+	// Releasing the lock hands it over immediately
+	assert.Nil(t, second.Unlock())
+	held, err = first.TryLock(time.Second)
+	assert.Nil(t, err)
+	assert.True(t, held)
+}
+
+func TestEtcdLockUnreachable(t *testing.T) {
+	server := httptest.NewServer(newFakeEtcd())
+	lock, err := NewEtcdLock(server.URL, "validator")
+	assert.Nil(t, err)
+	server.Close()
+
+	held, err := lock.TryLock(time.Second)
+	assert.NotNil(t, err)
+	assert.False(t, held)
+
+	_, err = NewEtcdLock(server.URL, "")
+	assert.Equal(t, errEmptyKey, err)
+}
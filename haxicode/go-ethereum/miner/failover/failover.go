@@ -0,0 +1,166 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package failover elects the leader among several nodes sharing a validator
+// identity, so that a standby node takes over sealing when the leader dies.
+package failover
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/haxicode/go-ethereum/log"
+)
+
+// DefaultTTL is how long a leader keeps the lock without renewing it, and so
+// about how long the validator misses slots when the leader dies.
+const DefaultTTL = 10 * time.Second
+
+// Locker is a lock shared by the nodes of a validator, the node holding it is
+// the leader.
+type Locker interface {
+	// TryLock acquires the lock, or renews it if the node holds it already, and
+	// reports whether the node holds it for at least ttl from the call.
+	TryLock(ttl time.Duration) (bool, error)
+
+	// Unlock releases the lock if the node holds it.
+	Unlock() error
+}
+
+// New creates the lock backend for a lock specification: an http(s) URL names
+// an etcd endpoint with the key as path, anything else a lock file.
+func New(lock string) (Locker, error) {
+	if strings.HasPrefix(lock, "http://") || strings.HasPrefix(lock, "https://") {
+		u, err := url.Parse(lock)
+		if err != nil {
+			return nil, err
+		}
+		key := strings.TrimPrefix(u.Path, "/")
+		u.Path, u.RawQuery, u.Fragment = "", "", ""
+		return NewEtcdLock(u.String(), key)
+	}
+	return NewFileLock(lock), nil
+}
+
+// Elector keeps trying to acquire a Locker and reports whether the local node
+// currently leads. Leadership is only assumed until the last successful
+// renewal plus the lock ttl, so a node cut off from the lock backend stops
+// signing before another one can take over.
+type Elector struct {
+	locker Locker
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	until   time.Time     // Time the current leadership expires at
+	running bool          // Whether the renewal loop is running
+	quit    chan struct{} // Quit channel of the renewal loop
+	done    chan struct{} // Closed once the renewal loop returned
+}
+
+// NewElector creates an elector over the lock, holding it for ttl at a time.
+func NewElector(locker Locker, ttl time.Duration) *Elector {
+	return &Elector{locker: locker, ttl: ttl}
+}
+
+// IsLeader reports whether the local node holds the lock.
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return time.Now().Before(e.until)
+}
+
+// Start starts competing for the lock, it does nothing if already started.
+func (e *Elector) Start() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.running {
+		return
+	}
+	e.running = true
+	e.quit, e.done = make(chan struct{}), make(chan struct{})
+	go e.loop(e.quit, e.done)
+}
+
+// Stop stops renewing the lock and releases it, handing leadership over to
+// another node.
+func (e *Elector) Stop() {
+	e.mu.Lock()
+	if !e.running {
+		e.mu.Unlock()
+		return
+	}
+	e.running = false
+	e.until = time.Time{}
+	close(e.quit)
+	done := e.done
+	e.mu.Unlock()
+
+	<-done
+	if err := e.locker.Unlock(); err != nil {
+		log.Warn("Failed to release the validator lock", "err", err)
+	}
+}
+
+// loop renews the lock a few times per ttl so a single failed attempt doesn't
+// lose the leadership.
+func (e *Elector) loop(quit, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		e.renew(quit)
+		select {
+		case <-ticker.C:
+		case <-quit:
+			return
+		}
+	}
+}
+
+// renew tries to acquire or renew the lock and updates the leadership.
+func (e *Elector) renew(quit chan struct{}) {
+	start := time.Now()
+	held, err := e.locker.TryLock(e.ttl)
+	if err != nil {
+		log.Warn("Failed to renew the validator lock", "err", err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	select {
+	case <-quit:
+		return
+	default:
+	}
+	leader := time.Now().Before(e.until)
+	if held {
+		e.until = start.Add(e.ttl)
+	} else if err == nil {
+		e.until = time.Time{}
+	}
+	switch {
+	case held && !leader:
+		log.Info("Became the leader of the validator nodes")
+	case !held && leader && err == nil:
+		log.Warn("Lost the leadership of the validator nodes")
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package failover
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "failover")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "validator.lock")
+	first, err := New(path)
+	assert.Nil(t, err)
+	second, err := New(path)
+	assert.Nil(t, err)
+
+	held, err := first.TryLock(time.Second)
+	assert.Nil(t, err)
+	assert.True(t, held)
+	held, err = second.TryLock(time.Second)
+	assert.Nil(t, err)
+	assert.False(t, held)
+
+	assert.Nil(t, first.Unlock())
+	held, err = second.TryLock(time.Second)
+	assert.Nil(t, err)
+	assert.True(t, held)
+	held, err = first.TryLock(time.Second)
+	assert.Nil(t, err)
+	assert.False(t, held)
+}
+
+// testLocker is a Locker whose answers are set by the test.
+type testLocker struct {
+	mu       sync.Mutex
+	held     bool
+	err      error
+	unlocked bool
+}
+
+func (l *testLocker) set(held bool, err error) {
+	l.mu.Lock()
+	l.held, l.err = held, err
+	l.mu.Unlock()
+}
+
+func (l *testLocker) TryLock(ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.held, l.err
+}
+
+func (l *testLocker) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.unlocked = true
+	return nil
+}
+
+func TestElector(t *testing.T) {
+	locker := new(testLocker)
+	elector := NewElector(locker, 150*time.Millisecond)
+	assert.False(t, elector.IsLeader())
+
+	// Leads once the lock is held
+	locker.set(true, nil)
+	elector.Start()
+	elector.Start()
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, elector.IsLeader())
+
+	// Losing the lock backend keeps the leadership until the ttl runs out only
+	locker.set(false, errors.New("unreachable"))
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, elector.IsLeader())
+	time.Sleep(150 * time.Millisecond)
+	assert.False(t, elector.IsLeader())
+
+	// Another node taking the lock ends the leadership at the next renewal
+	locker.set(true, nil)
+	time.Sleep(70 * time.Millisecond)
+	assert.True(t, elector.IsLeader())
+	locker.set(false, nil)
+	time.Sleep(70 * time.Millisecond)
+	assert.False(t, elector.IsLeader())
+
+	// Stopping releases the lock
+	locker.set(true, nil)
+	time.Sleep(70 * time.Millisecond)
+	elector.Stop()
+	assert.False(t, elector.IsLeader())
+	assert.True(t, locker.unlocked)
+}
@@ -0,0 +1,73 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package failover
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/util/flock"
+)
+
+// FileLock is a Locker backed by an flock on a file, for nodes sharing a host
+// or a file system with working locks. The operating system releases the lock
+// when the leader dies, so the ttl is not needed.
+type FileLock struct {
+	path string
+
+	mu      sync.Mutex
+	release flock.Releaser // Held lock, nil if not held
+}
+
+// NewFileLock creates a lock on the file at path, created if missing.
+func NewFileLock(path string) *FileLock {
+	return &FileLock{path: path}
+}
+
+// TryLock implements Locker.
+func (l *FileLock) TryLock(ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.release != nil {
+		return true, nil
+	}
+	release, _, err := flock.New(l.path)
+	if _, ok := err.(*os.PathError); ok {
+		return false, err
+	}
+	if err != nil {
+		// Locked by another node
+		return false, nil
+	}
+	l.release = release
+	return true, nil
+}
+
+// Unlock implements Locker.
+func (l *FileLock) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.release == nil {
+		return nil
+	}
+	err := l.release.Release()
+	l.release = nil
+	return err
+}
@@ -24,12 +24,14 @@ import (
 
 	"github.com/haxicode/go-ethereum/common"
 	"github.com/haxicode/go-ethereum/consensus"
+	"github.com/haxicode/go-ethereum/consensus/dpos"
 	"github.com/haxicode/go-ethereum/core"
 	"github.com/haxicode/go-ethereum/core/state"
 	"github.com/haxicode/go-ethereum/core/types"
 	"github.com/haxicode/go-ethereum/eth/downloader"
 	"github.com/haxicode/go-ethereum/event"
 	"github.com/haxicode/go-ethereum/log"
+	"github.com/haxicode/go-ethereum/miner/failover"
 	"github.com/haxicode/go-ethereum/params"
 )
 
@@ -47,13 +49,13 @@ type Miner struct {
 	eth      Backend
 	engine   consensus.Engine
 	exitCh   chan struct{}
+	elector  *failover.Elector // Leader election among nodes sharing the validator, nil if not shared
 
 	canStart    int32 // can start indicates whether we can start the mining operation
 	shouldStart int32 // should start indicates whether we should start after sync
 }
 
 func New(eth Backend, config *params.ChainConfig, mux *event.TypeMux, engine consensus.Engine, recommit time.Duration) *Miner {
-	blockInterval := eth.BlockChain().GenesisBlock().Header().BlockInterval
 	miner := &Miner{
 		eth:      eth,
 		mux:      mux,
@@ -62,7 +64,7 @@ func New(eth Backend, config *params.ChainConfig, mux *event.TypeMux, engine con
 		worker:   newWorker(config, engine, eth, mux, recommit),
 		canStart: 1,
 	}
-	go miner.update(blockInterval)
+	go miner.update()
 
 	return miner
 }
@@ -71,7 +73,7 @@ func New(eth Backend, config *params.ChainConfig, mux *event.TypeMux, engine con
 // It's entered once and as soon as `Done` or `Failed` has been broadcasted the events are unregistered and
 // the loop is exited. This to prevent a major security vuln where external parties can DOS you with blocks
 // and halt your mining operation for as long as the DOS continues.
-func (self *Miner) update(blcokInterval uint64) {
+func (self *Miner) update() {
 	events := self.mux.Subscribe(downloader.StartEvent{}, downloader.DoneEvent{}, downloader.FailedEvent{})
 
 	defer events.Unsubscribe()
@@ -96,7 +98,7 @@ func (self *Miner) update(blcokInterval uint64) {
 				atomic.StoreInt32(&self.canStart, 1)
 				atomic.StoreInt32(&self.shouldStart, 0)
 				if shouldStart {
-					self.Start(self.coinbase,blcokInterval)
+					self.Start(self.coinbase)
 				}
 				// stop immediately and ignore all further pending events
 				return
@@ -107,7 +109,7 @@ func (self *Miner) update(blcokInterval uint64) {
 	}
 }
 
-func (self *Miner) Start(coinbase common.Address, blcokInterval uint64) {
+func (self *Miner) Start(coinbase common.Address) {
 	atomic.StoreInt32(&self.shouldStart, 1)
 	self.worker.setCoinbase(coinbase)
 
@@ -115,24 +117,46 @@ func (self *Miner) Start(coinbase common.Address, blcokInterval uint64) {
 		log.Info("Network syncing, will start miner afterwards")
 		return
 	}
-	self.worker.start(blcokInterval)
+	if self.elector != nil {
+		self.elector.Start()
+	}
+	self.worker.start()
 }
 
 func (self *Miner) Stop() {
 	self.worker.stop()
+	if self.elector != nil {
+		self.elector.Stop()
+	}
 	atomic.StoreInt32(&self.shouldStart, 0)
 }
 
 func (self *Miner) Close() {
 	self.worker.close()
+	if self.elector != nil {
+		self.elector.Stop()
+	}
 	close(self.exitCh)
 }
 
+// SetFailover makes the miner one of several nodes sharing a validator
+// identity: while mining it competes for leadership through the elector and
+// only seals blocks as the leader.
+func (self *Miner) SetFailover(elector *failover.Elector) {
+	self.elector = elector
+	if engine, ok := self.engine.(*dpos.Dpos); ok {
+		engine.SetLeadership(elector)
+	}
+}
+
 func (self *Miner) Mining() bool {
 	return self.worker.isRunning()
 }
 
 func (self *Miner) HashRate() uint64 {
+	if pow, ok := self.engine.(consensus.PoW); ok {
+		return uint64(pow.Hashrate())
+	}
 	return 0
 }
 
@@ -32,7 +32,6 @@ import (
 	"github.com/haxicode/go-ethereum/log"
 	"github.com/haxicode/go-ethereum/params"
 	"math/big"
-	"os"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -219,26 +218,28 @@ func (w *worker) pendingBlock() *types.Block {
 	return w.snapshotBlock
 }
 
-// start sets the running status as 1 and triggers new work submitting.
-func (w *worker) start(blockInterval uint64) {
+// start sets the running status as 1 and triggers new work submitting. Engines
+// scheduling validators to slots are polled for the local slot, all others
+// seal on top of every new head.
+func (w *worker) start() {
 	atomic.StoreInt32(&w.running, 1)
-	go w.mintLoop(blockInterval)
-}
-
-func (self *worker) mintBlock(now int64,blockInterval uint64) {
-	engine, ok := self.engine.(*dpos.Dpos)
-	if !ok {
-		log.Error("Only the dpos engine was allowed")
+	if engine, ok := w.engine.(consensus.Scheduled); ok {
+		go w.mintLoop(engine)
 		return
 	}
+	w.startCh <- struct{}{}
+}
+
+func (self *worker) mintBlock(engine consensus.Scheduled, now int64) {
 	// 检查当前的 validator 是否为当前节点
-	err := engine.CheckValidator(self.chain.CurrentBlock(), now,blockInterval)
+	err := engine.CheckValidator(self.chain.CurrentBlock(), now)
 	if err != nil {
 		switch err {
 		case dpos.ErrWaitForPrevBlock,
 			dpos.ErrMintFutureBlock,
 			dpos.ErrInvalidBlockValidator,
-			dpos.ErrInvalidMintBlockTime:
+			dpos.ErrInvalidMintBlockTime,
+			dpos.ErrNotLeader:
 			log.Debug("Failed to mint the block, while ", "err", err)
 		default:
 			log.Error("Failed to mint the block", "err", err)
@@ -246,33 +247,36 @@ func (self *worker) mintBlock(now int64,blockInterval uint64) {
 		return
 	}
 	self.createNewWork()
-	/*
-	//如果是：创建一个新的打块任务
-	work, err := self.createNewWork()
-	if err != nil {
-		log.Error("Failed to create the new work", "err", err)
-		return
-	}
-	// 会对新块进行签名
-	result, err := self.engine.Seal(self.chain, work.Block, self.quitCh)
-	if err != nil {
-		log.Error("Failed to seal the block", "err", err)
-		return
-	}
-	//将新块广播到邻近的节点
-	self.recv <- &Result{work, result}
-	*/
 }
 
-func (self *worker) mintLoop(blockInterval uint64) {
-	wt := time.Duration(int64(blockInterval))
-	// default wt is  'time.Second', accouding blockInterval get the waittime
-	ticker := time.NewTicker(wt * time.Second /10).C   // a chanel
+func (self *worker) mintLoop(engine consensus.Scheduled) {
+	// Poll ten times per slot, so the slot starts at most a tenth of it late.
+	// Developer chains sealing periodically only poll once per period.
+	number := new(big.Int).Add(self.chain.CurrentBlock().Number(), common.Big1)
+	config := self.config.Dpos.At(number)
+	interval := config.BlockInterval
+	periodic := config.Dev != nil && config.Dev.Period > 0
+	wt := time.Duration(interval) * time.Second / 10
+	if periodic {
+		wt = time.Duration(config.Dev.Period) * time.Second
+	}
+	if wt <= 0 {
+		wt = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(wt)
+	defer func() { ticker.Stop() }()
 	for {
 		select {
-		case now := <-ticker:
+		case now := <-ticker.C:
 			atomic.StoreInt32(&self.newTxs, 0)
-			self.mintBlock(now.Unix(),blockInterval)
+			self.mintBlock(engine, now.Unix())
+
+			// Governance may change the block interval at the start of an epoch
+			if head := self.chain.CurrentHeader(); !periodic && head.BlockInterval != 0 && head.BlockInterval != interval {
+				interval = head.BlockInterval
+				ticker.Stop()
+				ticker = time.NewTicker(time.Duration(interval) * time.Second / 10)
+			}
 		case <-self.stopper:
 			close(self.quitCh)
 			self.quitCh = make(chan struct{}, 1)
@@ -285,7 +289,9 @@ func (self *worker) mintLoop(blockInterval uint64) {
 // stop sets the running status as 0.
 func (w *worker) stop() {
 	atomic.StoreInt32(&w.running, 0)
-	close(w.stopper)
+	if _, ok := w.engine.(consensus.Scheduled); ok {
+		close(w.stopper)
+	}
 }
 
 // isRunning returns an indicator whether worker is running or not.
@@ -314,10 +320,18 @@ func (w *worker) mainLoop() {
 
 	for {
 		select {
-		case  <-w.chainHeadCh:
+		case <-w.startCh:
+			w.createNewWork()
+
+		case <-w.chainHeadCh:
 			close(w.quitCh)
 			w.quitCh = make(chan struct{}, 1)
 
+			// Engines without a slot schedule seal on top of every new head
+			if _, ok := w.engine.(consensus.Scheduled); !ok && w.isRunning() {
+				w.createNewWork()
+			}
+
 		case ev := <-w.txsCh:
 			// Apply transactions to the pending state if we're not mining.
 			//
@@ -337,6 +351,9 @@ func (w *worker) mainLoop() {
 				txset := types.NewTransactionsByPriceAndNonce(w.current.signer, txs)
 				w.commitTransactions(txset, coinbase)
 				w.updateSnapshot()
+			} else if w.isRunning() && w.config.Clique != nil && w.config.Clique.Period == 0 {
+				// Clique in instant mode only seals blocks carrying transactions
+				w.createNewWork()
 			}
 			atomic.AddInt32(&w.newTxs, int32(len(ev.Txs)))
 
@@ -665,11 +682,8 @@ func (w *worker) createNewWork() (){
 
 	tstart := time.Now()
 	parent := w.chain.CurrentBlock()
-	fmt.Print("+++++++++++++++++++++++++++++++++++++Genesis Block MaxvalidatorSize**********\n")
-	Maxvalidatorsize  :=  w.chain.GenesisBlock().Header().MaxValidatorSize
-	blockInterVal :=w.chain.GenesisBlock().Header().BlockInterval
-	fmt.Printf("+++++++++++++++++++++++++++++++++++++MaxValidatorSize:%v +++++++++++++++++++++++++++++++++++++\n", int(Maxvalidatorsize))
-	log.Info("Currently Set Dpos Configuration","Maxvalidatorsize", int(Maxvalidatorsize),"BlockInterval", blockInterVal)
+	num := new(big.Int).Add(parent.Number(), common.Big1)
+	dposConfig := w.config.Dpos.At(num)
 
 	tstamp := tstart.Unix()
 	if parent.Time().Cmp(new(big.Int).SetInt64(tstamp)) >= 0 {
@@ -682,15 +696,12 @@ func (w *worker) createNewWork() (){
 		time.Sleep(wait)
 	}
 
-	num := parent.Number()
 	header := &types.Header{
 		ParentHash: parent.Hash(),
-		Number:     num.Add(num, common.Big1),
+		Number:     num,
 		GasLimit:   core.CalcGasLimit(parent),
 		Extra:      w.extra,
 		Time:       big.NewInt(tstamp),
-		MaxValidatorSize: Maxvalidatorsize,// generate new header for new blockchain
-		BlockInterval:blockInterVal,
 	}
 	// Only set the coinbase if our consensus engine is running (avoid spurious block rewards)
 	if w.isRunning() {
@@ -778,14 +789,14 @@ func (w *worker) createNewWork() (){
 			return
 		}
 	}
-	//
-	fmt.Print("+++++++++++++++++++++++++++Judged dpos config: maxvalidatorsize++++++++++++++++++++++++\n")
-	err1 := w.commit(uncles, w.fullTaskHook, tstart)
-	if err1 != nil{
-		log.Error(err1.Error())
-		os.Exit(0)
+	// Developer chains sealing on demand don't seal empty blocks
+	if dev := dposConfig.Dev; dev != nil && dev.Period == 0 && w.isRunning() && len(env.txs) == 0 {
+		w.updateSnapshot()
+		return
+	}
+	if err := w.commit(uncles, w.fullTaskHook, tstart); err != nil {
+		log.Error("Failed to finalize block for sealing", "err", err)
 	}
-	return
 }
 
 // commit runs any post-transaction state modifications, assembles the final block
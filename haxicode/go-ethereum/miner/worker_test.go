@@ -22,7 +22,9 @@ import (
 	"time"
 
 	"github.com/haxicode/go-ethereum/common"
+	"github.com/haxicode/go-ethereum/accounts"
 	"github.com/haxicode/go-ethereum/consensus"
+	"github.com/haxicode/go-ethereum/consensus/clique"
 	"github.com/haxicode/go-ethereum/consensus/ethash"
 	"github.com/haxicode/go-ethereum/core"
 	"github.com/haxicode/go-ethereum/core/types"
@@ -59,13 +61,19 @@ func init() {
 	testTxPoolConfig = core.DefaultTxPoolConfig
 	testTxPoolConfig.Journal = ""
 	ethashChainConfig = params.TestChainConfig
-	cliqueChainConfig = params.TestChainConfig
-	cliqueChainConfig.Dpos = &params.DposConfig{
+
+	cliqueConfig := *params.AllCliqueProtocolChanges
+	cliqueConfig.Clique = &params.CliqueConfig{Period: 10, Epoch: 30000}
+	cliqueChainConfig = &cliqueConfig
+
+	dposConfig := *params.TestChainConfig
+	dposConfig.Dpos = &params.DposConfig{
 		Validators:  nil,
 	}
-	tx1, _ := types.SignTx(types.NewTransaction(0, 0, acc1Addr, big.NewInt(1000), params.TxGas, nil, nil), types.HomesteadSigner{}, testBankKey)
+	dposChainConfig = &dposConfig
+	tx1, _ := types.SignTx(types.NewTransaction(types.Binary, 0, acc1Addr, big.NewInt(1000), params.TxGas, nil, nil), types.HomesteadSigner{}, testBankKey)
 	pendingTxs = append(pendingTxs, tx1)
-	tx2, _ := types.SignTx(types.NewTransaction(1, 0,acc1Addr, big.NewInt(1000), params.TxGas, nil, nil), types.HomesteadSigner{}, testBankKey)
+	tx2, _ := types.SignTx(types.NewTransaction(types.Binary, 1, acc1Addr, big.NewInt(1000), params.TxGas, nil, nil), types.HomesteadSigner{}, testBankKey)
 	newTxs = append(newTxs, tx2)
 }
 
@@ -87,7 +95,13 @@ func newTestWorkerBackend(t *testing.T, chainConfig *params.ChainConfig, engine
 		}
 	)
 
-	switch engine.(type) {
+	switch e := engine.(type) {
+	case *clique.Clique:
+		gspec.ExtraData = make([]byte, 32+common.AddressLength+65)
+		copy(gspec.ExtraData[32:], testBankAddress[:])
+		e.Authorize(testBankAddress, func(account accounts.Account, hash []byte) ([]byte, error) {
+			return crypto.Sign(hash, testBankKey)
+		})
 	case *dpos.Dpos:
 		gspec.ExtraData = make([]byte, 32+common.AddressLength+65)
 		copy(gspec.ExtraData[32:], testBankAddress[:])
@@ -125,14 +139,14 @@ func newTestWorker(t *testing.T, chainConfig *params.ChainConfig, engine consens
 	return w, backend
 }
 
-//func TestPendingStateAndBlockEthash(t *testing.T) {
-//	testPendingStateAndBlock(t, ethashChainConfig, ethash.NewFaker())
-//}
-//func TestPendingStateAndBlockClique(t *testing.T) {
-//	testPendingStateAndBlock(t, cliqueChainConfig, clique.New(cliqueChainConfig.Clique, ethdb.NewMemDatabase()))
-//}
+func TestPendingStateAndBlockEthash(t *testing.T) {
+	testPendingStateAndBlock(t, ethashChainConfig, ethash.NewFaker())
+}
+func TestPendingStateAndBlockClique(t *testing.T) {
+	testPendingStateAndBlock(t, cliqueChainConfig, clique.New(cliqueChainConfig.Clique, ethdb.NewMemDatabase()))
+}
 func TestPendingStateAndBlockDPOS(t *testing.T) {
-	testPendingStateAndBlock(t, cliqueChainConfig, dpos.New(cliqueChainConfig.Dpos, ethdb.NewMemDatabase()))
+	testPendingStateAndBlock(t, dposChainConfig, dpos.New(dposChainConfig.Dpos, ethdb.NewMemDatabase()))
 }
 
 func testPendingStateAndBlock(t *testing.T, chainConfig *params.ChainConfig, engine consensus.Engine) {
@@ -159,48 +173,28 @@ func testPendingStateAndBlock(t *testing.T, chainConfig *params.ChainConfig, eng
 	}
 }
 
-func TestEmptyWorkEthash(t *testing.T) {
-	testEmptyWork(t, ethashChainConfig, ethash.NewFaker())
+func TestStartWorkEthash(t *testing.T) {
+	testStartWork(t, ethashChainConfig, ethash.NewFaker())
 }
-func TestEmptyWorkClique(t *testing.T) {
-	testEmptyWork(t, cliqueChainConfig, dpos.New(dposChainConfig.Dpos, ethdb.NewMemDatabase()))
+func TestStartWorkClique(t *testing.T) {
+	testStartWork(t, cliqueChainConfig, clique.New(cliqueChainConfig.Clique, ethdb.NewMemDatabase()))
 }
 
-func testEmptyWork(t *testing.T, chainConfig *params.ChainConfig, engine consensus.Engine) {
+func testStartWork(t *testing.T, chainConfig *params.ChainConfig, engine consensus.Engine) {
 	defer engine.Close()
 
 	w, _ := newTestWorker(t, chainConfig, engine)
 	defer w.close()
 
-	var (
-		taskCh    = make(chan struct{}, 2)
-		taskIndex int
-	)
-
-	checkEqual := func(t *testing.T, task *task, index int) {
-		receiptLen, balance := 0, big.NewInt(0)
-		if index == 1 {
-			receiptLen, balance = 1, big.NewInt(1000)
-		}
-		if len(task.receipts) != receiptLen {
-			t.Errorf("receipt number mismatch has %d, want %d", len(task.receipts), receiptLen)
-		}
-		if task.state.GetBalance(acc1Addr).Cmp(balance) != 0 {
-			t.Errorf("account balance mismatch has %d, want %d", task.state.GetBalance(acc1Addr), balance)
-		}
-	}
-
+	var taskCh = make(chan *task, 1)
 	w.newTaskHook = func(task *task) {
 		if task.block.NumberU64() == 1 {
-			checkEqual(t, task, taskIndex)
-			taskIndex += 1
-			taskCh <- struct{}{}
+			taskCh <- task
 		}
 	}
-	w.fullTaskHook = func() {
-		time.Sleep(100 * time.Millisecond)
+	w.skipSealHook = func(task *task) bool {
+		return true
 	}
-
 	// Ensure worker has finished initialization
 	for {
 		b := w.pendingBlock()
@@ -210,12 +204,16 @@ func testEmptyWork(t *testing.T, chainConfig *params.ChainConfig, engine consens
 	}
 
 	w.start()
-	for i := 0; i < 2; i += 1 {
-		select {
-		case <-taskCh:
-		case <-time.NewTimer(time.Second).C:
-			t.Error("new task timeout")
+	select {
+	case task := <-taskCh:
+		if len(task.receipts) != 1 {
+			t.Errorf("receipt number mismatch has %d, want %d", len(task.receipts), 1)
+		}
+		if balance := task.state.GetBalance(acc1Addr); balance.Cmp(big.NewInt(1000)) != 0 {
+			t.Errorf("account balance mismatch has %d, want %d", balance, 1000)
 		}
+	case <-time.NewTimer(time.Second).C:
+		t.Error("new task timeout")
 	}
 }
 
@@ -276,43 +274,18 @@ func TestStreamUncleBlock(t *testing.T) {
 	}
 } */
 
-func TestRegenerateMiningBlockEthash(t *testing.T) {
-	testRegenerateMiningBlock(t, ethashChainConfig, ethash.NewFaker())
-}
-
-func TestRegenerateMiningBlockClique(t *testing.T) {
-	testRegenerateMiningBlock(t, cliqueChainConfig, dpos.New(dposChainConfig.Dpos, ethdb.NewMemDatabase()))
-}
-
-func testRegenerateMiningBlock(t *testing.T, chainConfig *params.ChainConfig, engine consensus.Engine) {
+// Tests that engines without a slot schedule keep sealing on top of every new
+// chain head.
+func TestSealOnNewHeadEthash(t *testing.T) {
+	engine := ethash.NewFaker()
 	defer engine.Close()
 
-	w, b := newTestWorker(t, chainConfig, engine)
+	w, _ := newTestWorker(t, ethashChainConfig, engine)
 	defer w.close()
 
-	var taskCh = make(chan struct{})
-
-	taskIndex := 0
+	var taskCh = make(chan uint64, 4)
 	w.newTaskHook = func(task *task) {
-		if task.block.NumberU64() == 1 {
-			if taskIndex == 2 {
-				receiptLen, balance := 2, big.NewInt(2000)
-				if len(task.receipts) != receiptLen {
-					t.Errorf("receipt number mismatch has %d, want %d", len(task.receipts), receiptLen)
-				}
-				if task.state.GetBalance(acc1Addr).Cmp(balance) != 0 {
-					t.Errorf("account balance mismatch has %d, want %d", task.state.GetBalance(acc1Addr), balance)
-				}
-			}
-			taskCh <- struct{}{}
-			taskIndex += 1
-		}
-	}
-	w.skipSealHook = func(task *task) bool {
-		return true
-	}
-	w.fullTaskHook = func() {
-		time.Sleep(100 * time.Millisecond)
+		taskCh <- task.block.NumberU64()
 	}
 	// Ensure worker has finished initialization
 	for {
@@ -323,22 +296,16 @@ func testRegenerateMiningBlock(t *testing.T, chainConfig *params.ChainConfig, en
 	}
 
 	w.start()
-	// Ignore the first two works
-	for i := 0; i < 2; i += 1 {
+	for want := uint64(1); want <= 2; want++ {
 		select {
-		case <-taskCh:
-		case <-time.NewTimer(time.Second).C:
-			t.Error("new task timeout")
+		case number := <-taskCh:
+			if number != want {
+				t.Fatalf("task number mismatch has %d, want %d", number, want)
+			}
+		case <-time.NewTimer(3 * time.Second).C:
+			t.Fatalf("task %d timeout", want)
 		}
 	}
-	b.txPool.AddLocals(newTxs)
-	time.Sleep(time.Second)
-
-	select {
-	case <-taskCh:
-	case <-time.NewTimer(time.Second).C:
-		t.Error("new task timeout")
-	}
 }
 
 /*
@@ -443,4 +410,67 @@ func testAdjustInterval(t *testing.T, chainConfig *params.ChainConfig, engine co
 		t.Error("interval reset timeout")
 	}
 }
-*/
\ No newline at end of file
+*/
+// Tests that the validator of a developer chain sealing on demand only seals
+// blocks once transactions are pending.
+func TestDevModeSealsOnDemand(t *testing.T) {
+	config := *params.TestChainConfig
+	config.Dpos = &params.DposConfig{
+		Validators:       []common.Address{testBankAddress},
+		MaxValidatorSize: 1,
+		BlockInterval:    1,
+		Dev:              &params.DposDevConfig{Period: 0},
+	}
+	db := ethdb.NewMemDatabase()
+	engine := dpos.New(config.Dpos, db)
+	engine.Authorize(testBankAddress, func(account accounts.Account, hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, testBankKey)
+	})
+	defer engine.Close()
+
+	gspec := core.Genesis{
+		Config: &config,
+		Alloc:  core.GenesisAlloc{testBankAddress: {Balance: testBankFunds}},
+	}
+	gspec.MustCommit(db)
+	chain, _ := core.NewBlockChain(db, nil, &config, engine, vm.Config{})
+	defer chain.Stop()
+	backend := &testWorkerBackend{db: db, chain: chain, txPool: core.NewTxPool(testTxPoolConfig, &config, chain)}
+
+	w := newWorker(&config, engine, backend, new(event.TypeMux), time.Second)
+	defer w.close()
+	w.setCoinbase(testBankAddress)
+
+	var taskCh = make(chan *task, 4)
+	w.newTaskHook = func(task *task) {
+		taskCh <- task
+	}
+	w.start()
+	defer w.stop()
+
+	select {
+	case task := <-taskCh:
+		t.Fatalf("sealed block %d without pending transactions", task.block.NumberU64())
+	case <-time.NewTimer(1500 * time.Millisecond).C:
+	}
+	backend.txPool.AddLocals(pendingTxs)
+
+	select {
+	case task := <-taskCh:
+		if number := task.block.NumberU64(); number != 1 {
+			t.Errorf("block number mismatch, has %d, want %d", number, 1)
+		}
+		if len(task.receipts) != 1 {
+			t.Errorf("receipt number mismatch has %d, want %d", len(task.receipts), 1)
+		}
+	case <-time.NewTimer(3 * time.Second).C:
+		t.Fatal("new task timeout")
+	}
+	// The sealed block is imported as the new head
+	for deadline := time.Now().Add(2 * time.Second); chain.CurrentBlock().NumberU64() != 1; {
+		if time.Now().After(deadline) {
+			t.Fatalf("head number mismatch, has %d, want %d", chain.CurrentBlock().NumberU64(), 1)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
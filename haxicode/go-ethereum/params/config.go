@@ -19,6 +19,7 @@ package params
 import (
 	"fmt"
 	"math/big"
+	"sort"
 
 	"github.com/haxicode/go-ethereum/common"
 )
@@ -158,11 +159,63 @@ func (c *CliqueConfig) String() string {
 	return "clique"
 }
 
+// DefaultDposEpoch is the epoch length in seconds used when a DPoS chain config
+// doesn't specify one.
+const DefaultDposEpoch = 86400
+
+// DefaultDposSlashPercent is the share of the candidate deposit confiscated for
+// double signing when a DPoS chain config doesn't specify one.
+const DefaultDposSlashPercent = 10
+
+// DefaultDposKickoutThreshold is the share of its slots a validator has to seal
+// not to be kicked out when a DPoS chain config doesn't specify one.
+const DefaultDposKickoutThreshold = 50
+
 // DposConfig is the consensus engine configs for delegated proof-of-stake based sealing.
 type DposConfig struct {
 	Validators []common.Address `json:"validators"` // Genesis validator list
 	MaxValidatorSize uint64		`json:"maxValidatorSize"` //Genesis maxvalidatorSize
 	BlockInterval 	 uint64		`json:"blockInterval"`
+	Epoch            uint64     `json:"epoch,omitempty"` // Seconds between validator elections (0 = DefaultDposEpoch)
+
+	CandidateDeposit *big.Int `json:"candidateDeposit,omitempty"` // Minimum stake locked to register as a candidate (nil = free)
+	DelegateDeposit  *big.Int `json:"delegateDeposit,omitempty"`  // Minimum stake locked to vote for a candidate (nil = free)
+	UnbondingPeriod  uint64   `json:"unbondingPeriod,omitempty"`  // Seconds unlocked stake stays in escrow before being paid back
+	SlashPercent     uint64   `json:"slashPercent,omitempty"`     // Percentage of the deposit confiscated for double signing (0 = DefaultDposSlashPercent)
+	BlockReward      *big.Int `json:"blockReward,omitempty"`      // Wei issued per block, shared by the validator and its delegators (nil = ethash rewards)
+	KickoutThreshold uint64   `json:"kickoutThreshold,omitempty"` // Percentage of its slots a validator must seal not to be kicked out (0 = DefaultDposKickoutThreshold)
+	MintCntHorizon   uint64   `json:"mintCntHorizon,omitempty"`   // Number of past epochs whose mint counts are kept in the state (0 = all)
+	RandaoBlock      *big.Int `json:"randaoBlock,omitempty"`      // Block the randao beacon starts seeding the elections at (nil = no randao)
+
+	Delegations []*DposDelegation `json:"delegations,omitempty"` // Genesis votes
+	Forks       []*DposFork       `json:"forks,omitempty"`       // Block number activated parameter overrides
+
+	Dev *DposDevConfig `json:"dev,omitempty"` // Developer mode sealing, nil on production chains
+}
+
+// DposDevConfig makes the validators of a developer chain seal blocks on demand
+// rather than in every slot. Blocks still follow the DPoS rules, so they are only
+// sealed in slots of the local validator, at most one per BlockInterval.
+type DposDevConfig struct {
+	Period uint64 `json:"period"` // Seconds between blocks (0 = seal only if transactions are pending)
+}
+
+// DposDelegation is a vote cast in the genesis block. The stake is locked on top
+// of the genesis allocations, it is paid back to the delegator when unbonded.
+type DposDelegation struct {
+	Delegator common.Address `json:"delegator"` // Account casting the vote
+	Candidate common.Address `json:"candidate"` // Genesis validator voted for
+	Stake     *big.Int       `json:"stake"`     // Wei locked as the weight of the vote
+}
+
+// DposFork schedules a change of the DPoS parameters at a given block number.
+// Zero fields keep the value that was in effect before the fork.
+type DposFork struct {
+	Block            *big.Int `json:"block"`                      // Fork switch block (nil = no fork)
+	Epoch            uint64   `json:"epoch,omitempty"`            // New election epoch length in seconds
+	MaxValidatorSize uint64   `json:"maxValidatorSize,omitempty"` // New number of elected validators
+	BlockInterval    uint64   `json:"blockInterval,omitempty"`    // New number of seconds between blocks
+	BlockReward      *big.Int `json:"blockReward,omitempty"`      // New wei issued per block
 }
 
 // String implements the stringer interface, returning the consensus engine details.
@@ -170,10 +223,91 @@ func (d *DposConfig) String() string {
 	return "dpos"
 }
 
+// At returns the DPoS parameters in effect at block num, that is the genesis
+// parameters with every fork scheduled at or before num applied in block order.
+// The returned config carries no forks of its own. A nil config resolves to the
+// defaults.
+func (d *DposConfig) At(num *big.Int) *DposConfig {
+	if d == nil {
+		return &DposConfig{Epoch: DefaultDposEpoch, SlashPercent: DefaultDposSlashPercent, KickoutThreshold: DefaultDposKickoutThreshold}
+	}
+	cfg := &DposConfig{
+		Validators:       d.Validators,
+		MaxValidatorSize: d.MaxValidatorSize,
+		BlockInterval:    d.BlockInterval,
+		Epoch:            d.Epoch,
+		CandidateDeposit: d.CandidateDeposit,
+		DelegateDeposit:  d.DelegateDeposit,
+		UnbondingPeriod:  d.UnbondingPeriod,
+		SlashPercent:     d.SlashPercent,
+		BlockReward:      d.BlockReward,
+		KickoutThreshold: d.KickoutThreshold,
+		MintCntHorizon:   d.MintCntHorizon,
+		RandaoBlock:      d.RandaoBlock,
+		Delegations:      d.Delegations,
+		Dev:              d.Dev,
+	}
+	forks := make([]*DposFork, 0, len(d.Forks))
+	for _, fork := range d.Forks {
+		if isForked(fork.Block, num) {
+			forks = append(forks, fork)
+		}
+	}
+	sort.SliceStable(forks, func(i, j int) bool { return forks[i].Block.Cmp(forks[j].Block) < 0 })
+	for _, fork := range forks {
+		if fork.Epoch != 0 {
+			cfg.Epoch = fork.Epoch
+		}
+		if fork.MaxValidatorSize != 0 {
+			cfg.MaxValidatorSize = fork.MaxValidatorSize
+		}
+		if fork.BlockInterval != 0 {
+			cfg.BlockInterval = fork.BlockInterval
+		}
+		if fork.BlockReward != nil {
+			cfg.BlockReward = fork.BlockReward
+		}
+	}
+	if cfg.Epoch == 0 {
+		cfg.Epoch = DefaultDposEpoch
+	}
+	if cfg.SlashPercent == 0 {
+		cfg.SlashPercent = DefaultDposSlashPercent
+	} else if cfg.SlashPercent > 100 {
+		cfg.SlashPercent = 100
+	}
+	if cfg.KickoutThreshold == 0 {
+		cfg.KickoutThreshold = DefaultDposKickoutThreshold
+	} else if cfg.KickoutThreshold > 100 {
+		cfg.KickoutThreshold = 100
+	}
+	return cfg
+}
+
+// IsRandao returns whether num is either equal to the randao block or greater,
+// blocks from then on carrying the randao section in their extra-data.
+func (d *DposConfig) IsRandao(num *big.Int) bool {
+	return d != nil && isForked(d.RandaoBlock, num)
+}
+
+// SafeSize returns the minimal number of validators (2/3+1 of MaxValidatorSize)
+// needed for the chain to make progress and confirm blocks.
+func (d *DposConfig) SafeSize() int {
+	return int(d.MaxValidatorSize*2/3 + 1)
+}
+
 
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
-
+	var engine interface{}
+	switch {
+	case c.IsDpos():
+		engine = c.Dpos
+	case c.Clique != nil:
+		engine = c.Clique
+	default:
+		engine = c.Ethash
+	}
 	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Engine: %v}",
 		c.ChainID,
 		c.HomesteadBlock,
@@ -184,10 +318,16 @@ func (c *ChainConfig) String() string {
 		c.EIP158Block,
 		c.ByzantiumBlock,
 		c.ConstantinopleBlock,
-		c.Dpos,
+		engine,
 	)
 }
 
+// IsDpos returns whether the chain is sealed by delegated-proof-of-stake, which
+// it is unless only Clique or Ethash is configured.
+func (c *ChainConfig) IsDpos() bool {
+	return c.Dpos != nil || (c.Clique == nil && c.Ethash == nil)
+}
+
 // IsHomestead returns whether num is either equal to the homestead block or greater.
 func (c *ChainConfig) IsHomestead(num *big.Int) bool {
 	return isForked(c.HomesteadBlock, num)
@@ -288,6 +428,37 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.ConstantinopleBlock, newcfg.ConstantinopleBlock, head) {
 		return newCompatError("Constantinople fork block", c.ConstantinopleBlock, newcfg.ConstantinopleBlock)
 	}
+	if c.Dpos != nil && newcfg.Dpos != nil {
+		if isForkIncompatible(c.Dpos.RandaoBlock, newcfg.Dpos.RandaoBlock, head) {
+			return newCompatError("DPoS randao fork block", c.Dpos.RandaoBlock, newcfg.Dpos.RandaoBlock)
+		}
+		if block := dposForkIncompatible(c.Dpos, newcfg.Dpos, head); block != nil {
+			return newCompatError("DPoS fork parameters", block, block)
+		}
+	}
+	return nil
+}
+
+// dposForkIncompatible returns the first block at or before head at which the
+// two DPoS configs resolve to different parameters, or nil if the already
+// imported part of the chain is valid under both.
+func dposForkIncompatible(c1, c2 *DposConfig, head *big.Int) *big.Int {
+	blocks := []*big.Int{big.NewInt(0)}
+	for _, forks := range [][]*DposFork{c1.Forks, c2.Forks} {
+		for _, fork := range forks {
+			if isForked(fork.Block, head) {
+				blocks = append(blocks, fork.Block)
+			}
+		}
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Cmp(blocks[j]) < 0 })
+	for _, block := range blocks {
+		p1, p2 := c1.At(block), c2.At(block)
+		if p1.Epoch != p2.Epoch || p1.MaxValidatorSize != p2.MaxValidatorSize || p1.BlockInterval != p2.BlockInterval ||
+			!configNumEqual(p1.BlockReward, p2.BlockReward) {
+			return block
+		}
+	}
 	return nil
 }
 
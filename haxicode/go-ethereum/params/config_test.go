@@ -70,6 +70,40 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     9,
 			},
 		},
+		{
+			stored:  &ChainConfig{Dpos: &DposConfig{BlockInterval: 10, Forks: []*DposFork{{Block: big.NewInt(10), Epoch: 600}}}},
+			new:     &ChainConfig{Dpos: &DposConfig{BlockInterval: 10, Forks: []*DposFork{{Block: big.NewInt(20), Epoch: 600}}}},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{Dpos: &DposConfig{BlockInterval: 10, Forks: []*DposFork{{Block: big.NewInt(10), Epoch: 600}}}},
+			new:    &ChainConfig{Dpos: &DposConfig{BlockInterval: 10, Forks: []*DposFork{{Block: big.NewInt(20), Epoch: 600}}}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "DPoS fork parameters",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored:  &ChainConfig{Dpos: &DposConfig{BlockInterval: 10}},
+			new:     &ChainConfig{Dpos: &DposConfig{BlockInterval: 10, RandaoBlock: big.NewInt(20)}},
+			head:    15,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{Dpos: &DposConfig{BlockInterval: 10, RandaoBlock: big.NewInt(10)}},
+			new:    &ChainConfig{Dpos: &DposConfig{BlockInterval: 10}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "DPoS randao fork block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    nil,
+				RewindTo:     9,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -79,3 +113,33 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+func TestDposConfigAt(t *testing.T) {
+	config := &DposConfig{
+		MaxValidatorSize: 21,
+		BlockInterval:    10,
+		Forks: []*DposFork{
+			{Block: big.NewInt(200), BlockInterval: 5},
+			{Block: big.NewInt(100), Epoch: 600, MaxValidatorSize: 7},
+			{Block: nil, Epoch: 60},
+		},
+	}
+	tests := []struct {
+		number                      int64
+		epoch, validators, interval uint64
+	}{
+		{0, DefaultDposEpoch, 21, 10},
+		{99, DefaultDposEpoch, 21, 10},
+		{100, 600, 7, 10},
+		{199, 600, 7, 10},
+		{200, 600, 7, 5},
+		{1000, 600, 7, 5},
+	}
+	for _, test := range tests {
+		have := config.At(big.NewInt(test.number))
+		if have.Epoch != test.epoch || have.MaxValidatorSize != test.validators || have.BlockInterval != test.interval {
+			t.Errorf("block %d: params mismatch: have {%d %d %d}, want {%d %d %d}", test.number,
+				have.Epoch, have.MaxValidatorSize, have.BlockInterval, test.epoch, test.validators, test.interval)
+		}
+	}
+}
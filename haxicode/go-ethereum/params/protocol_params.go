@@ -62,6 +62,19 @@ const (
 	MemoryGas        uint64 = 3     // Times the address of the (highest referenced byte in memory + 1). NOTE: referencing happens on read, write and in instructions such as RETURN and CALL.
 	TxDataNonZeroGas uint64 = 68    // Per byte of data attached to a transaction that is not equal to zero. NOTE: Not payable on data of calls between transactions.
 
+	// DPoS transactions replace the TxGas base cost with a cost per type, as
+	// they write to the DPoS tries instead of running code.
+	TxRegCandidateGas    uint64 = 50000 // Per candidate registration, which writes the candidate and its deposit.
+	TxUnregCandidateGas  uint64 = 50000 // Per candidate unregistration, which also unbonds the stake of its delegators.
+	TxDelegateGas        uint64 = 40000 // Per vote, which writes the vote, the delegation and the deposit.
+	TxUnDelegateGas      uint64 = 30000 // Per vote withdrawal.
+	TxEvidenceGas        uint64 = 21000 // Per double-sign evidence, kept at TxGas so that reporting stays cheap.
+	TxWithdrawRewardGas  uint64 = 30000 // Per reward withdrawal.
+	TxRotateSignerGas    uint64 = 30000 // Per signing key rotation.
+	TxProposeParamsGas   uint64 = 40000 // Per parameter change proposal, which also clears the previous one of the validator.
+	TxApproveParamsGas   uint64 = 30000 // Per parameter change approval.
+	TxUpdateCandidateGas uint64 = 30000 // Per update of the commission and description of a candidate.
+
 	MaxCodeSize = 24576 // Maximum bytecode to permit for a contract
 
 	// Precompiled contract gas prices